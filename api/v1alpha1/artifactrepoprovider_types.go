@@ -35,6 +35,14 @@ type ArtifactRepoProviderSpec struct {
 	URL          string `json:"url"`
 	ApiServer    string `json:"apiserver"`
 	ProviderType string `json:"providertype"`
+	// Insecure skips the verification of the api server certificate, the certificate is verified by default.
+	// Set caBundle rather than this to trust a self-signed server.
+	// +optional
+	Insecure *bool `json:"insecure,omitempty"`
+	// CABundle is the PEM encoded CA certificates used to verify the api server certificate,
+	// the system roots are used if it is empty.
+	// +optional
+	CABundle string `json:"caBundle,omitempty"`
 }
 
 // ArtifactRepoProviderStatus defines the observed state of ArtifactRepoProvider
@@ -73,6 +81,8 @@ func (r *ArtifactRepoProvider) Get(ctx context.Context, client client.Client, na
 	result := ref_resource.ReferenceResourceResult{
 		ApiServerUrl: artifactRepoProvider.Spec.ApiServer,
 		ProviderType: artifactRepoProvider.Spec.ProviderType,
+		Insecure:     artifactRepoProvider.Spec.Insecure,
+		CABundle:     artifactRepoProvider.Spec.CABundle,
 	}
 	return &result, nil
 }
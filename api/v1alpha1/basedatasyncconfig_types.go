@@ -28,6 +28,9 @@ const (
 	SyncProjectMemberConditionType ConditionType = "sync-project-member"
 )
 
+// AnnotationSyncNow triggers a sync without waiting for the schedule, it is removed once the sync succeeds.
+const AnnotationSyncNow = "nautes.io/sync-now"
+
 // +kubebuilder:object:generate=false
 type ConditionType string
 
@@ -47,6 +50,14 @@ type ApplicationSpec struct {
 	Name         string `json:"name"`
 	ApiServerUrl string `json:"apiServerUrl"`
 	ProviderType string `json:"providerType"`
+	// Insecure skips the verification of the api server certificate, the certificate is verified by default.
+	// Set caBundle rather than this to trust a self-signed server.
+	// +optional
+	Insecure *bool `json:"insecure,omitempty"`
+	// CABundle is the PEM encoded CA certificates used to verify the api server certificate,
+	// the system roots are used if it is empty.
+	// +optional
+	CABundle string `json:"caBundle,omitempty"`
 }
 
 type Application struct {
@@ -54,12 +65,207 @@ type Application struct {
 	ApplicationRef *ApplicationRef `json:"applicationRef"`
 	// +optional
 	ApplicationSpec *ApplicationSpec `json:"applicationSpec"`
+	// The limits of the requests sent to the application, only the max concurrency is used by the targets,
+	// which limits the entities written to the target at the same time.
+	// +optional
+	RateLimit *RateLimit `json:"rateLimit,omitempty"`
+	// The groups of the application synchronized, it is only used by the source.
+	// +optional
+	Scope *SourceScope `json:"scope,omitempty"`
+	// The rules transforming the entities of source before they are compared with the entities of the application,
+	// it is only used by the targets.
+	// +optional
+	Mappings *MappingRules `json:"mappings,omitempty"`
+}
+
+// MappingRules transforms the entities of source for a target, the entities are synchronized as they are if it is empty.
+type MappingRules struct {
+	// The fields of users are name, description, username, email, avatarUrl and mobile.
+	// +optional
+	Users *EntityMapping `json:"users,omitempty"`
+	// The fields of groups are name and description, the templates can use the full path of groups, e.g. "{{ .FullPath }}".
+	// +optional
+	Groups *EntityMapping `json:"groups,omitempty"`
+	// The fields of projects are name and description.
+	// +optional
+	Projects *EntityMapping `json:"projects,omitempty"`
+}
+
+// EntityMapping transforms the fields of the entities of a type, the identities of the entities are never transformed.
+// The fields are renamed first, then set by the templates, and at last dropped or kept.
+type EntityMapping struct {
+	// Sets a field to the value of another field, keyed by the field set, e.g. name: username.
+	// +optional
+	Renames map[string]string `json:"renames,omitempty"`
+	// Sets a field to the result of a go template executed with the renamed entity, keyed by the field set,
+	// e.g. name: '{{ .FullPath | replace "/" "-" }}-developers'. The functions lower, upper, replace, trimPrefix
+	// and trimSuffix can be used.
+	// +optional
+	Templates map[string]string `json:"templates,omitempty"`
+	// Clears the fields not listed if it is set.
+	// +optional
+	Keep []string `json:"keep,omitempty"`
+	// Clears the listed fields.
+	// +optional
+	Drop []string `json:"drop,omitempty"`
+}
+
+// SourceScope restricts the sync to the selected groups of source and their subgroups, everything is synchronized if it is empty.
+// The selected groups are read with the group-scoped apis, rather than listing all groups of source.
+type SourceScope struct {
+	// The ids of the groups whose subtrees are synchronized, such as the top-level groups of a business unit.
+	// The groups of ldap are selected by paths only.
+	// +optional
+	GroupIds []int `json:"groupIds,omitempty"`
+	// The globs of the full paths of the groups whose subtrees are synchronized, e.g. "bu1" and "bu1/team-*".
+	// "*" does not match "/".
+	// +optional
+	IncludeGroupPaths []string `json:"includeGroupPaths,omitempty"`
+	// The globs of the full paths of the groups whose subtrees are not synchronized, they take precedence over the included ones.
+	// +optional
+	ExcludeGroupPaths []string `json:"excludeGroupPaths,omitempty"`
+	// The minimum access level of the user of source in the synchronized groups and projects, e.g. 30 for developer in gitlab.
+	// It is not supported by ldap.
+	// +optional
+	MinAccessLevel int `json:"minAccessLevel,omitempty"`
+}
+
+// RateLimit limits the requests sent to an application, the zero values mean the defaults.
+type RateLimit struct {
+	// The requests per second, the requests are not limited if it is 0.
+	// +optional
+	RequestsPerSecond int `json:"requestsPerSecond,omitempty"`
+	// The max requests sent at once when the bucket of rate limiter is full, defaults to requestsPerSecond.
+	// +optional
+	Burst int `json:"burst,omitempty"`
+	// The max number of concurrent requests.
+	// +optional
+	MaxConcurrency int `json:"maxConcurrency,omitempty"`
+	// The max retries of a request failed with 429 or 5xx, set it to 0 to disable retries.
+	// +optional
+	MaxRetries *int `json:"maxRetries,omitempty"`
+}
+
+// UserFilter excludes the users of source from the sync, the excluded users are not added to the groups either.
+type UserFilter struct {
+	// Exclude the bot and service accounts.
+	// +optional
+	ExcludeBots bool `json:"excludeBots,omitempty"`
+	// Exclude the external users.
+	// +optional
+	ExcludeExternal bool `json:"excludeExternal,omitempty"`
+	// Only synchronize the members of the synchronized groups, it is usually enabled with the scope of source.
+	// +optional
+	MembersOnly bool `json:"membersOnly,omitempty"`
+}
+
+// EntityFilter selects the entities of source synchronized by their names and ids, all entities are selected if it is empty.
+type EntityFilter struct {
+	// The regular expression matched against the username of users and the name of groups and projects.
+	// +optional
+	NameRegex string `json:"nameRegex,omitempty"`
+	// The ids of the entities synchronized, only the listed entities are synchronized if it is set.
+	// +optional
+	IncludeIds []string `json:"includeIds,omitempty"`
+	// The ids of the entities not synchronized, they take precedence over the included ones.
+	// +optional
+	ExcludeIds []string `json:"excludeIds,omitempty"`
+}
+
+// SyncFilters selects the entities of source synchronized, the excluded entities are neither compared nor written to the targets.
+// The members of the excluded groups are not synchronized, and the existing memberships of these groups in the targets are kept.
+type SyncFilters struct {
+	// +optional
+	Users *EntityFilter `json:"users,omitempty"`
+	// +optional
+	Groups *EntityFilter `json:"groups,omitempty"`
+	// +optional
+	Projects *EntityFilter `json:"projects,omitempty"`
+}
+
+// MergePolicy merges the users of the sources, the union of the users is synchronized.
+type MergePolicy struct {
+	// The fields telling the same person in different sources, "email" and "username", such as an employee in ldap
+	// who is also a contractor in gitlab. The person is synchronized from the first source having it and is added to
+	// the groups of the other sources. The users are not matched if it is empty.
+	// +optional
+	MatchBy []string `json:"matchBy,omitempty"`
+}
+
+// SyncTimeouts limits the time of the phases of a sync, the phases are not limited if they are not set.
+type SyncTimeouts struct {
+	// The time to read the users, groups, projects and group members of source.
+	// +optional
+	ReadSource metav1.Duration `json:"readSource,omitempty"`
+	// The time to read each target.
+	// +optional
+	ReadTarget metav1.Duration `json:"readTarget,omitempty"`
+	// The time to write each target, the entities not written in time are synchronized in the next sync.
+	// +optional
+	Write metav1.Duration `json:"write,omitempty"`
+}
+
+// MaintenanceWindow is a period in which nothing is written to the targets.
+type MaintenanceWindow struct {
+	// The cron spec of the openings of the window, such as "0 22 * * 5" for every friday at 22:00.
+	Schedule string `json:"schedule"`
+	// How long the window lasts from each opening.
+	Duration metav1.Duration `json:"duration"`
 }
 
 // BaseDataSyncConfigSpec defines the desired state of BaseDataSyncConfig
 type BaseDataSyncConfigSpec struct {
-	Source  *Application   `json:"source"`
+	// +optional
+	Source *Application `json:"source,omitempty"`
+	// The sources synchronized to the targets in the order of priority, the source above is the first one if it is set.
+	// Each source only writes and prunes the objects it owns in the targets.
+	// +optional
+	Sources []*Application `json:"sources,omitempty"`
+	// How the users of the sources are merged, it is used if there are more than one source.
+	// +optional
+	Merge   *MergePolicy   `json:"merge,omitempty"`
 	Targets []*Application `json:"targets"`
+	// +optional
+	UserFilter *UserFilter `json:"userFilter,omitempty"`
+	// +optional
+	Filters *SyncFilters `json:"filters,omitempty"`
+	// How the members of the subgroups are synchronized, "nested" synchronizes the direct members of each group and nests
+	// the roles of the subgroups in the roles of their parents, "flattened" synchronizes the members inherited from the
+	// parent groups to each group without nesting the roles. The default is "nested".
+	// +kubebuilder:validation:Enum=nested;flattened
+	// +optional
+	Membership string `json:"membership,omitempty"`
+	// +optional
+	Timeouts *SyncTimeouts `json:"timeouts,omitempty"`
+	// The cron spec of the syncs, such as "*/15 * * * *" or "@every 5m". The default is "@every 10s".
+	// Add the annotation nautes.io/sync-now to sync without waiting for the schedule.
+	// +optional
+	Schedule string `json:"schedule,omitempty"`
+	// The syncs falling in a maintenance window are postponed to its end.
+	// +optional
+	MaintenanceWindows []MaintenanceWindow `json:"maintenanceWindows,omitempty"`
+	// How often all entities are read and compared with the targets. Between the full syncs, the entities unchanged in
+	// the sources since they were synchronized are skipped, so the changes made in the targets are reverted by the next
+	// full sync. The default is 6h, "0s" compares all entities in every sync.
+	// +optional
+	FullSyncPeriod *metav1.Duration `json:"fullSyncPeriod,omitempty"`
+}
+
+// GetSources returns the sources in the order of priority.
+func (s *BaseDataSyncConfigSpec) GetSources() []*Application {
+	sources := make([]*Application, 0, len(s.Sources)+1)
+	if s.Source != nil {
+		sources = append(sources, s.Source)
+	}
+	return append(sources, s.Sources...)
+}
+
+// MappingScope is a pair of source and target whose mappings are stored by the operator.
+type MappingScope struct {
+	IdpKind    string `json:"idpKind"`
+	IdpName    string `json:"idpName"`
+	TargetKind string `json:"targetKind"`
+	TargetName string `json:"targetName"`
 }
 
 // BaseDataSyncConfigStatus defines the observed state of BaseDataSyncConfig
@@ -68,6 +274,16 @@ type BaseDataSyncConfigStatus struct {
 	Conditions []metav1.Condition `json:"conditions"`
 	// +optional
 	TargetStatus map[string][]metav1.Condition `json:"targetStatus"`
+	// The time the last successful sync started.
+	// +optional
+	LastSyncTime *metav1.Time `json:"lastSyncTime,omitempty"`
+	// The effective time of the next sync, maintenance windows taken into account.
+	// +optional
+	NextSyncTime *metav1.Time `json:"nextSyncTime,omitempty"`
+	// The pairs of source and target whose mappings are stored, the mappings of a pair are deleted
+	// once it is removed from the config or the config is deleted.
+	// +optional
+	MappingScopes []MappingScope `json:"mappingScopes,omitempty"`
 }
 
 //+kubebuilder:object:root=true
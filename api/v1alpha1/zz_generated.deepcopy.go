@@ -21,7 +21,22 @@ func (in *Application) DeepCopyInto(out *Application) {
 	if in.ApplicationSpec != nil {
 		in, out := &in.ApplicationSpec, &out.ApplicationSpec
 		*out = new(ApplicationSpec)
-		**out = **in
+		(*in).DeepCopyInto(*out)
+	}
+	if in.RateLimit != nil {
+		in, out := &in.RateLimit, &out.RateLimit
+		*out = new(RateLimit)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Scope != nil {
+		in, out := &in.Scope, &out.Scope
+		*out = new(SourceScope)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Mappings != nil {
+		in, out := &in.Mappings, &out.Mappings
+		*out = new(MappingRules)
+		(*in).DeepCopyInto(*out)
 	}
 }
 
@@ -53,6 +68,11 @@ func (in *ApplicationRef) DeepCopy() *ApplicationRef {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ApplicationSpec) DeepCopyInto(out *ApplicationSpec) {
 	*out = *in
+	if in.Insecure != nil {
+		in, out := &in.Insecure, &out.Insecure
+		*out = new(bool)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ApplicationSpec.
@@ -70,7 +90,7 @@ func (in *ArtifactRepoProvider) DeepCopyInto(out *ArtifactRepoProvider) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
-	out.Spec = in.Spec
+	in.Spec.DeepCopyInto(&out.Spec)
 	out.Status = in.Status
 }
 
@@ -127,6 +147,11 @@ func (in *ArtifactRepoProviderList) DeepCopyObject() runtime.Object {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ArtifactRepoProviderSpec) DeepCopyInto(out *ArtifactRepoProviderSpec) {
 	*out = *in
+	if in.Insecure != nil {
+		in, out := &in.Insecure, &out.Insecure
+		*out = new(bool)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ArtifactRepoProviderSpec.
@@ -221,6 +246,22 @@ func (in *BaseDataSyncConfigSpec) DeepCopyInto(out *BaseDataSyncConfigSpec) {
 		*out = new(Application)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.Sources != nil {
+		in, out := &in.Sources, &out.Sources
+		*out = make([]*Application, len(*in))
+		for i := range *in {
+			if (*in)[i] != nil {
+				in, out := &(*in)[i], &(*out)[i]
+				*out = new(Application)
+				(*in).DeepCopyInto(*out)
+			}
+		}
+	}
+	if in.Merge != nil {
+		in, out := &in.Merge, &out.Merge
+		*out = new(MergePolicy)
+		(*in).DeepCopyInto(*out)
+	}
 	if in.Targets != nil {
 		in, out := &in.Targets, &out.Targets
 		*out = make([]*Application, len(*in))
@@ -232,6 +273,31 @@ func (in *BaseDataSyncConfigSpec) DeepCopyInto(out *BaseDataSyncConfigSpec) {
 			}
 		}
 	}
+	if in.UserFilter != nil {
+		in, out := &in.UserFilter, &out.UserFilter
+		*out = new(UserFilter)
+		**out = **in
+	}
+	if in.Filters != nil {
+		in, out := &in.Filters, &out.Filters
+		*out = new(SyncFilters)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Timeouts != nil {
+		in, out := &in.Timeouts, &out.Timeouts
+		*out = new(SyncTimeouts)
+		**out = **in
+	}
+	if in.MaintenanceWindows != nil {
+		in, out := &in.MaintenanceWindows, &out.MaintenanceWindows
+		*out = make([]MaintenanceWindow, len(*in))
+		copy(*out, *in)
+	}
+	if in.FullSyncPeriod != nil {
+		in, out := &in.FullSyncPeriod, &out.FullSyncPeriod
+		*out = new(v1.Duration)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BaseDataSyncConfigSpec.
@@ -271,6 +337,19 @@ func (in *BaseDataSyncConfigStatus) DeepCopyInto(out *BaseDataSyncConfigStatus)
 			(*out)[key] = outVal
 		}
 	}
+	if in.LastSyncTime != nil {
+		in, out := &in.LastSyncTime, &out.LastSyncTime
+		*out = (*in).DeepCopy()
+	}
+	if in.NextSyncTime != nil {
+		in, out := &in.NextSyncTime, &out.NextSyncTime
+		*out = (*in).DeepCopy()
+	}
+	if in.MappingScopes != nil {
+		in, out := &in.MappingScopes, &out.MappingScopes
+		*out = make([]MappingScope, len(*in))
+		copy(*out, *in)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BaseDataSyncConfigStatus.
@@ -397,3 +476,261 @@ func (in *CodeRepoProviderStatus) DeepCopy() *CodeRepoProviderStatus {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *EntityFilter) DeepCopyInto(out *EntityFilter) {
+	*out = *in
+	if in.IncludeIds != nil {
+		in, out := &in.IncludeIds, &out.IncludeIds
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.ExcludeIds != nil {
+		in, out := &in.ExcludeIds, &out.ExcludeIds
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EntityFilter.
+func (in *EntityFilter) DeepCopy() *EntityFilter {
+	if in == nil {
+		return nil
+	}
+	out := new(EntityFilter)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *EntityMapping) DeepCopyInto(out *EntityMapping) {
+	*out = *in
+	if in.Renames != nil {
+		in, out := &in.Renames, &out.Renames
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Templates != nil {
+		in, out := &in.Templates, &out.Templates
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Keep != nil {
+		in, out := &in.Keep, &out.Keep
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Drop != nil {
+		in, out := &in.Drop, &out.Drop
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EntityMapping.
+func (in *EntityMapping) DeepCopy() *EntityMapping {
+	if in == nil {
+		return nil
+	}
+	out := new(EntityMapping)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MaintenanceWindow) DeepCopyInto(out *MaintenanceWindow) {
+	*out = *in
+	out.Duration = in.Duration
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MaintenanceWindow.
+func (in *MaintenanceWindow) DeepCopy() *MaintenanceWindow {
+	if in == nil {
+		return nil
+	}
+	out := new(MaintenanceWindow)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MappingRules) DeepCopyInto(out *MappingRules) {
+	*out = *in
+	if in.Users != nil {
+		in, out := &in.Users, &out.Users
+		*out = new(EntityMapping)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Groups != nil {
+		in, out := &in.Groups, &out.Groups
+		*out = new(EntityMapping)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Projects != nil {
+		in, out := &in.Projects, &out.Projects
+		*out = new(EntityMapping)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MappingRules.
+func (in *MappingRules) DeepCopy() *MappingRules {
+	if in == nil {
+		return nil
+	}
+	out := new(MappingRules)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MappingScope) DeepCopyInto(out *MappingScope) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MappingScope.
+func (in *MappingScope) DeepCopy() *MappingScope {
+	if in == nil {
+		return nil
+	}
+	out := new(MappingScope)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MergePolicy) DeepCopyInto(out *MergePolicy) {
+	*out = *in
+	if in.MatchBy != nil {
+		in, out := &in.MatchBy, &out.MatchBy
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MergePolicy.
+func (in *MergePolicy) DeepCopy() *MergePolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(MergePolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RateLimit) DeepCopyInto(out *RateLimit) {
+	*out = *in
+	if in.MaxRetries != nil {
+		in, out := &in.MaxRetries, &out.MaxRetries
+		*out = new(int)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RateLimit.
+func (in *RateLimit) DeepCopy() *RateLimit {
+	if in == nil {
+		return nil
+	}
+	out := new(RateLimit)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SourceScope) DeepCopyInto(out *SourceScope) {
+	*out = *in
+	if in.GroupIds != nil {
+		in, out := &in.GroupIds, &out.GroupIds
+		*out = make([]int, len(*in))
+		copy(*out, *in)
+	}
+	if in.IncludeGroupPaths != nil {
+		in, out := &in.IncludeGroupPaths, &out.IncludeGroupPaths
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.ExcludeGroupPaths != nil {
+		in, out := &in.ExcludeGroupPaths, &out.ExcludeGroupPaths
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SourceScope.
+func (in *SourceScope) DeepCopy() *SourceScope {
+	if in == nil {
+		return nil
+	}
+	out := new(SourceScope)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SyncFilters) DeepCopyInto(out *SyncFilters) {
+	*out = *in
+	if in.Users != nil {
+		in, out := &in.Users, &out.Users
+		*out = new(EntityFilter)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Groups != nil {
+		in, out := &in.Groups, &out.Groups
+		*out = new(EntityFilter)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Projects != nil {
+		in, out := &in.Projects, &out.Projects
+		*out = new(EntityFilter)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SyncFilters.
+func (in *SyncFilters) DeepCopy() *SyncFilters {
+	if in == nil {
+		return nil
+	}
+	out := new(SyncFilters)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SyncTimeouts) DeepCopyInto(out *SyncTimeouts) {
+	*out = *in
+	out.ReadSource = in.ReadSource
+	out.ReadTarget = in.ReadTarget
+	out.Write = in.Write
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SyncTimeouts.
+func (in *SyncTimeouts) DeepCopy() *SyncTimeouts {
+	if in == nil {
+		return nil
+	}
+	out := new(SyncTimeouts)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *UserFilter) DeepCopyInto(out *UserFilter) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new UserFilter.
+func (in *UserFilter) DeepCopy() *UserFilter {
+	if in == nil {
+		return nil
+	}
+	out := new(UserFilter)
+	in.DeepCopyInto(out)
+	return out
+}
@@ -18,21 +18,29 @@ import (
 	"context"
 	"fmt"
 	"reflect"
+	"strconv"
 	"time"
 
 	"github.com/nautes-labs/base-operator/pkg/idp"
+	"github.com/nautes-labs/base-operator/pkg/metrics"
+	"github.com/nautes-labs/base-operator/pkg/schedule"
 	"github.com/nautes-labs/base-operator/pkg/services"
+	"github.com/nautes-labs/base-operator/pkg/store"
 	"github.com/nautes-labs/base-operator/pkg/target"
 
 	"github.com/nautes-labs/base-operator/pkg/log"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
 
 	"github.com/nautes-labs/base-operator/api/v1alpha1"
 	nautesv1alpha1 "github.com/nautes-labs/base-operator/api/v1alpha1"
 	"github.com/nautes-labs/base-operator/pkg/ref_resource"
+	baseschema "github.com/nautes-labs/base-operator/pkg/schema"
 	"github.com/nautes-labs/base-operator/pkg/secret_provider"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
@@ -42,8 +50,19 @@ type BaseDataSyncConfigReconciler struct {
 	client.Client
 	Scheme         *runtime.Scheme
 	SecretProvider *secret_provider.SecretProvider
+	// Store records the mappings between the idp entities and the target entities, it is optional.
+	Store store.Store
+	// RefClient reads the providers referenced by the configs, the client of the reconciler is used if it is nil.
+	// It is needed if the scheme of the manager registers other types of the providers.
+	RefClient client.Client
 }
 
+const (
+	baseDataSyncConfigKind = "BaseDataSyncConfig"
+	// baseDataSyncConfigFinalizerName keeps the config until the mappings of its sources and targets are deleted.
+	baseDataSyncConfigFinalizerName = "basedatasyncconfig.base-operator.nautes.resource.nautes.io/finalizers"
+)
+
 var (
 	refResourceGvkMapping = make(map[string]ref_resource.ReferenceResource)
 )
@@ -66,82 +85,296 @@ func init() {
 //+kubebuilder:rbac:groups=nautes.resource.nautes.io,resources=basedatasyncconfigs,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=nautes.resource.nautes.io,resources=basedatasyncconfigs/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=nautes.resource.nautes.io,resources=basedatasyncconfigs/finalizers,verbs=update
+//+kubebuilder:rbac:groups="",resources=configmaps,verbs=get;create;update;delete
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
 // For more details, check Reconcile and its Result here:
 // - https://pkg.go.dev/sigs.k8s.io/controller-runtime@v0.11.0/pkg/reconcile
 func (r *BaseDataSyncConfigReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
-	log.Loger.Info("trigger Reconcile")
+	ctx = log.WithCorrelationID(log.WithValues(ctx, log.KeyConfig, req.NamespacedName.String()))
+	logger := log.FromContext(ctx)
+	logger.V(1).Info("trigger Reconcile")
 	baseCfg := v1alpha1.BaseDataSyncConfig{}
 	if err := r.Get(ctx, req.NamespacedName, &baseCfg); err != nil {
-		log.Loger.Errorf("unable to fetch BaseDataSyncConfig, err:%v", err)
+		if client.IgnoreNotFound(err) != nil {
+			logger.Error(err, "unable to fetch BaseDataSyncConfig")
+		}
 		return ctrl.Result{}, client.IgnoreNotFound(err)
 	}
-	svc := services.NewSyncLogicService(ctx)
-	idp, err := r.getIdpEntityByCR(ctx, baseCfg)
-	if err != nil {
-		log.Loger.Errorf("unable match idp, err:%v", err)
+
+	if !baseCfg.DeletionTimestamp.IsZero() {
+		if !controllerutil.ContainsFinalizer(&baseCfg, baseDataSyncConfigFinalizerName) {
+			return ctrl.Result{}, nil
+		}
+		if failed := r.deleteMappings(ctx, baseCfg.Status.MappingScopes); len(failed) > 0 {
+			return ctrl.Result{}, fmt.Errorf("delete mappings of %d scopes fail", len(failed))
+		}
+		controllerutil.RemoveFinalizer(&baseCfg, baseDataSyncConfigFinalizerName)
+		if err := r.Update(ctx, &baseCfg); err != nil {
+			return ctrl.Result{}, err
+		}
+		logger.V(1).Info("delete finish")
+		return ctrl.Result{}, nil
+	}
+	if r.Store != nil && !controllerutil.ContainsFinalizer(&baseCfg, baseDataSyncConfigFinalizerName) {
+		controllerutil.AddFinalizer(&baseCfg, baseDataSyncConfigFinalizerName)
+		if err := r.Update(ctx, &baseCfg); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	membership := baseschema.MembershipMode(baseCfg.Spec.Membership)
+	if !membership.IsValid() {
+		err := fmt.Errorf("unsupported membership %q", baseCfg.Spec.Membership)
+		logger.Error(err, "invalid membership")
 		return ctrl.Result{}, err
 	}
-	svc.InjectIdp(idp)
-	targetApps, err := r.getTargetEntitiesByCR(ctx, idp, baseCfg)
+	sources := baseCfg.Spec.GetSources()
+	if len(sources) == 0 {
+		err := fmt.Errorf("no source is set")
+		logger.Error(err, "invalid sources")
+		return ctrl.Result{}, err
+	}
+	sched, err := getSchedule(baseCfg.Spec)
 	if err != nil {
-		log.Loger.Errorf("unable match targetApp, err:%v", err)
+		logger.Error(err, "invalid schedule")
 		return ctrl.Result{}, err
 	}
-	svc.InjectTargetApps(targetApps...)
+	policy := services.MergePolicy{}
+	if merge := baseCfg.Spec.Merge; merge != nil {
+		policy.MatchBy = merge.MatchBy
+	}
+	if err := policy.Validate(); err != nil {
+		logger.Error(err, "invalid merge policy")
+		return ctrl.Result{}, err
+	}
+	// The sync now annotation runs the sync as if it never ran, only maintenance windows postpone it.
+	now := time.Now()
+	lastSync := time.Time{}
+	_, syncNow := baseCfg.Annotations[v1alpha1.AnnotationSyncNow]
+	if baseCfg.Status.LastSyncTime != nil && !syncNow {
+		lastSync = baseCfg.Status.LastSyncTime.Time
+	}
+	if next := sched.Next(lastSync, now); next.After(now) {
+		if end, ok := sched.WindowEnd(now); ok {
+			logger.V(1).Info("writes are suspended in maintenance window", "until", end)
+		}
+		r.updateSyncTime(ctx, &baseCfg, nil, next)
+		return ctrl.Result{RequeueAfter: next.Sub(now)}, nil
+	}
 
-	// Emptying Finalizers
-	// Clean up cr configured target applications
-	if !baseCfg.DeletionTimestamp.IsZero() {
-		err = svc.ClearTargetAppData()
+	// The clients of the sources and targets are only created when the sync is due.
+	svcs := make([]*services.SyncLogicService, 0, len(sources))
+	for _, source := range sources {
+		svc, err := r.newSyncLogicService(ctx, baseCfg, source, membership)
 		if err != nil {
 			return ctrl.Result{}, err
 		}
-		return ctrl.Result{}, nil
+		svcs = append(svcs, svc)
+	}
+
+	// The scopes are recorded before the mappings are saved, so that they are deleted even if the sync fails.
+	scopes := make([]v1alpha1.MappingScope, 0, len(svcs))
+	for _, svc := range svcs {
+		for _, scope := range svc.MappingScopes() {
+			scopes = append(scopes, toMappingScope(scope))
+		}
 	}
+	r.recordMappingScopes(ctx, &baseCfg, scopes)
 
-	err = svc.Run()
+	err = services.NewMergedSyncService(policy, svcs...).Run()
 	if err != nil {
 		return ctrl.Result{}, err
 	}
+	metrics.SetLastSuccessfulSync(baseDataSyncConfigKind, baseCfg.Namespace, baseCfg.Name)
+
+	// The mappings of the sources and targets removed from the config are deleted, the scopes failed to delete are kept to retry.
+	if r.Store != nil {
+		baseCfg.Status.MappingScopes = append(scopes, r.deleteMappings(ctx, staleMappingScopes(baseCfg.Status.MappingScopes, scopes))...)
+	}
+	next := sched.Next(now, time.Now())
+	r.updateSyncTime(ctx, &baseCfg, &now, next)
+	if syncNow {
+		patch := client.MergeFrom(baseCfg.DeepCopy())
+		delete(baseCfg.Annotations, v1alpha1.AnnotationSyncNow)
+		if err := r.Patch(ctx, &baseCfg, patch); err != nil {
+			logger.Error(err, "remove sync now annotation failed")
+		}
+	}
+
+	// The sync may last longer than the interval of the schedule.
+	if wait := time.Until(next); wait > 0 {
+		return ctrl.Result{RequeueAfter: wait}, nil
+	}
+	return ctrl.Result{Requeue: true}, nil
+}
+
+// recordMappingScopes adds the scopes to the status if they are not recorded.
+func (r *BaseDataSyncConfigReconciler) recordMappingScopes(ctx context.Context, baseCfg *v1alpha1.BaseDataSyncConfig, scopes []v1alpha1.MappingScope) {
+	if r.Store == nil {
+		return
+	}
+	added := staleMappingScopes(scopes, baseCfg.Status.MappingScopes)
+	if len(added) == 0 {
+		return
+	}
+	baseCfg.Status.MappingScopes = append(baseCfg.Status.MappingScopes, added...)
+	if err := r.Status().Update(ctx, baseCfg); err != nil {
+		log.FromContext(ctx).Error(err, "record mapping scopes failed")
+	}
+}
+
+// deleteMappings deletes the mappings of the scopes from the store, it returns the scopes failed to delete.
+func (r *BaseDataSyncConfigReconciler) deleteMappings(ctx context.Context, scopes []v1alpha1.MappingScope) []v1alpha1.MappingScope {
+	if r.Store == nil {
+		return nil
+	}
+	var failed []v1alpha1.MappingScope
+	for _, scope := range scopes {
+		if err := r.Store.Delete(ctx, fromMappingScope(scope)); err != nil {
+			log.FromContext(ctx).Error(err, "delete mappings failed", "scope", fromMappingScope(scope).String())
+			failed = append(failed, scope)
+		}
+	}
+	return failed
+}
 
-	//TODO add/update Finalizers
+// staleMappingScopes returns the recorded scopes which are not in the current scopes.
+func staleMappingScopes(recorded, current []v1alpha1.MappingScope) []v1alpha1.MappingScope {
+	currentSet := make(map[v1alpha1.MappingScope]bool, len(current))
+	for _, scope := range current {
+		currentSet[scope] = true
+	}
+	var stale []v1alpha1.MappingScope
+	for _, scope := range recorded {
+		if !currentSet[scope] {
+			stale = append(stale, scope)
+		}
+	}
+	return stale
+}
 
-	//TODO update k8s status
+func toMappingScope(scope store.Scope) v1alpha1.MappingScope {
+	return v1alpha1.MappingScope{
+		IdpKind:    scope.IdpKind,
+		IdpName:    scope.IdpName,
+		TargetKind: scope.TargetKind,
+		TargetName: scope.TargetName,
+	}
+}
 
-	// Add next queue consumption interval
-	return ctrl.Result{RequeueAfter: time.Second * 10}, nil
+func fromMappingScope(scope v1alpha1.MappingScope) store.Scope {
+	return store.Scope{
+		IdpKind:    scope.IdpKind,
+		IdpName:    scope.IdpName,
+		TargetKind: scope.TargetKind,
+		TargetName: scope.TargetName,
+	}
+}
+
+// updateSyncTime records the time of the last sync if it is not nil and the time of the next sync in the status.
+func (r *BaseDataSyncConfigReconciler) updateSyncTime(ctx context.Context, baseCfg *v1alpha1.BaseDataSyncConfig, lastSync *time.Time, next time.Time) {
+	// The times are stored in seconds.
+	next = next.Truncate(time.Second)
+	status := &baseCfg.Status
+	if lastSync == nil && status.NextSyncTime != nil && status.NextSyncTime.Time.Equal(next) {
+		return
+	}
+	if lastSync != nil {
+		status.LastSyncTime = &metav1.Time{Time: *lastSync}
+	}
+	status.NextSyncTime = &metav1.Time{Time: next}
+	if err := r.Status().Update(ctx, baseCfg); err != nil {
+		log.FromContext(ctx).Error(err, "update sync time failed")
+	}
 }
 
 // SetupWithManager sets up the controller with the Manager.
+// The updates of the status are ignored, the next runs are requeued by the reconciler, and the sync now annotation triggers a run.
 func (r *BaseDataSyncConfigReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	return ctrl.NewControllerManagedBy(mgr).
-		For(&nautesv1alpha1.BaseDataSyncConfig{}).
+		For(&nautesv1alpha1.BaseDataSyncConfig{}, builder.WithPredicates(
+			predicate.Or(predicate.GenerationChangedPredicate{}, predicate.AnnotationChangedPredicate{}))).
 		Complete(r)
 }
 
+// newSyncLogicService returns the service synchronizing the source to the targets of the config.
+func (r *BaseDataSyncConfigReconciler) newSyncLogicService(ctx context.Context, baseCfg v1alpha1.BaseDataSyncConfig,
+	source *v1alpha1.Application, membership baseschema.MembershipMode) (*services.SyncLogicService, error) {
+	logger := log.FromContext(ctx)
+	svc := services.NewSyncLogicService(ctx).InjectStore(r.Store).SetMembership(membership)
+	idp, err := r.getIdpEntityByCR(ctx, source)
+	if err != nil {
+		logger.Error(err, "unable match idp")
+		return nil, err
+	}
+	svc.InjectIdp(idp)
+	if timeouts := baseCfg.Spec.Timeouts; timeouts != nil {
+		svc.SetPhaseTimeouts(services.PhaseTimeouts{
+			ReadIdp:    timeouts.ReadSource.Duration,
+			ReadTarget: timeouts.ReadTarget.Duration,
+			Write:      timeouts.Write.Duration,
+		})
+	}
+	if period := baseCfg.Spec.FullSyncPeriod; period != nil {
+		svc.SetFullSyncPeriod(period.Duration)
+	}
+	if filter := baseCfg.Spec.UserFilter; filter != nil {
+		svc.SetUserFilter(services.UserFilter{
+			ExcludeBots:     filter.ExcludeBots,
+			ExcludeExternal: filter.ExcludeExternal,
+			MembersOnly:     filter.MembersOnly,
+		})
+	}
+	filters, err := getSyncFilters(baseCfg.Spec.Filters)
+	if err != nil {
+		logger.Error(err, "invalid filters")
+		return nil, err
+	}
+	svc.SetFilters(filters)
+	targetApps, err := r.getTargetEntitiesByCR(ctx, idp, baseCfg)
+	if err != nil {
+		logger.Error(err, "unable match targetApp")
+		return nil, err
+	}
+	svc.InjectTargetApps(targetApps...)
+	for i, targetCfg := range baseCfg.Spec.Targets {
+		if targetCfg.RateLimit != nil {
+			svc.SetWriteConcurrency(targetApps[i].IdentityKey(), targetCfg.RateLimit.MaxConcurrency)
+		}
+		if targetCfg.Mappings != nil {
+			rules, err := getMappingRules(targetCfg.Mappings)
+			if err != nil {
+				logger.Error(err, "invalid mapping rules", "target", targetApps[i].IdentityKey())
+				return nil, err
+			}
+			svc.SetMappingRules(targetApps[i].IdentityKey(), rules)
+		}
+	}
+	return svc, nil
+}
+
 // Get idp object by BaseDataSyncConfig CR
 // If both of spec and ref,  spec priority is greater than ref
-func (r *BaseDataSyncConfigReconciler) getIdpEntityByCR(ctx context.Context, baseCfg v1alpha1.BaseDataSyncConfig) (idp.Idp, error) {
+func (r *BaseDataSyncConfigReconciler) getIdpEntityByCR(ctx context.Context, source *v1alpha1.Application) (idp.Idp, error) {
 	err := (error)(nil)
 	idpApiServerUrl := ""
 	idpKind := ""
 	idpName := ""
-	if baseCfg.Spec.Source.ApplicationSpec != nil {
-		idpApiServerUrl = baseCfg.Spec.Source.ApplicationSpec.ApiServerUrl
-		idpName = baseCfg.Spec.Source.ApplicationSpec.Name
-		idpKind = baseCfg.Spec.Source.ApplicationSpec.ProviderType
+	if source.ApplicationSpec != nil {
+		idpApiServerUrl = source.ApplicationSpec.ApiServerUrl
+		idpName = source.ApplicationSpec.Name
+		idpKind = source.ApplicationSpec.ProviderType
 	} else {
 		refResourceResult := (*ref_resource.ReferenceResourceResult)(nil)
-		refResourceResult, err = r.getRefResourceResult(ctx, baseCfg.Spec.Source.ApplicationRef)
+		refResourceResult, err = r.getRefResourceResult(ctx, source.ApplicationRef)
 		if err != nil {
 			return nil, err
 		}
 		idpKind = refResourceResult.ProviderType
 		idpApiServerUrl = refResourceResult.ApiServerUrl
-		idpName = baseCfg.Spec.Source.ApplicationRef.Name
+		idpName = source.ApplicationRef.Name
 	}
 	idpApp, err := idp.NewIdp(idpKind)
 	if err != nil {
@@ -150,9 +383,118 @@ func (r *BaseDataSyncConfigReconciler) getIdpEntityByCR(ctx context.Context, bas
 	idpApp.SetName(idpName)
 	idpApp.SetApiServerUrl(idpApiServerUrl)
 	idpApp.SetSecretProvider(r.SecretProvider)
+	idpApp.SetClientOptions(getIdpClientOptions(source.RateLimit))
+	scope := getIdpScope(source.Scope)
+	if err := scope.Validate(); err != nil {
+		return nil, err
+	}
+	idpApp.SetScope(scope)
 	return idpApp, nil
 }
 
+// getIdpScope converts the scope of source to the idp scope, nil means everything is synchronized.
+func getIdpScope(scope *v1alpha1.SourceScope) idp.Scope {
+	if scope == nil {
+		return idp.Scope{}
+	}
+	groupIds := make([]string, 0, len(scope.GroupIds))
+	for _, id := range scope.GroupIds {
+		groupIds = append(groupIds, strconv.Itoa(id))
+	}
+	return idp.Scope{
+		GroupIds:          groupIds,
+		IncludeGroupPaths: scope.IncludeGroupPaths,
+		ExcludeGroupPaths: scope.ExcludeGroupPaths,
+		MinAccessLevel:    scope.MinAccessLevel,
+	}
+}
+
+// getSchedule parses the schedule and the maintenance windows of the spec.
+func getSchedule(spec v1alpha1.BaseDataSyncConfigSpec) (*schedule.Schedule, error) {
+	windows := make([]schedule.Window, 0, len(spec.MaintenanceWindows))
+	for _, w := range spec.MaintenanceWindows {
+		window, err := schedule.NewWindow(w.Schedule, w.Duration.Duration)
+		if err != nil {
+			return nil, err
+		}
+		windows = append(windows, window)
+	}
+	return schedule.New(spec.Schedule, windows...)
+}
+
+// getSyncFilters converts the filters of the spec to the service filters, the zero value means everything is synchronized.
+func getSyncFilters(filters *v1alpha1.SyncFilters) (services.Filters, error) {
+	result := services.Filters{}
+	if filters == nil {
+		return result, nil
+	}
+	for _, f := range []struct {
+		kind   string
+		spec   *v1alpha1.EntityFilter
+		filter *services.EntityFilter
+	}{
+		{"users", filters.Users, &result.Users},
+		{"groups", filters.Groups, &result.Groups},
+		{"projects", filters.Projects, &result.Projects},
+	} {
+		if f.spec == nil {
+			continue
+		}
+		filter, err := services.NewEntityFilter(f.spec.NameRegex, f.spec.IncludeIds, f.spec.ExcludeIds)
+		if err != nil {
+			return result, fmt.Errorf("invalid filter of %s, err:%w", f.kind, err)
+		}
+		*f.filter = filter
+	}
+	return result, nil
+}
+
+// getMappingRules converts the mapping rules of a target to the service rules and validates them.
+func getMappingRules(mappings *v1alpha1.MappingRules) (services.MappingRules, error) {
+	result := services.MappingRules{}
+	for _, m := range []struct {
+		kind string
+		spec *v1alpha1.EntityMapping
+		rule *services.MappingRule
+	}{
+		{"users", mappings.Users, &result.Users},
+		{"groups", mappings.Groups, &result.Groups},
+		{"projects", mappings.Projects, &result.Projects},
+	} {
+		if m.spec == nil {
+			continue
+		}
+		rule, err := services.NewMappingRule(m.spec.Renames, m.spec.Templates, m.spec.Keep, m.spec.Drop)
+		if err != nil {
+			return result, fmt.Errorf("invalid mapping rule of %s, err:%w", m.kind, err)
+		}
+		*m.rule = rule
+	}
+	if err := result.Validate(); err != nil {
+		return result, err
+	}
+	return result, nil
+}
+
+// getIdpClientOptions converts the rate limit of source to the idp client options, nil means the defaults.
+func getIdpClientOptions(limit *v1alpha1.RateLimit) idp.ClientOptions {
+	if limit == nil {
+		return idp.ClientOptions{}
+	}
+	opts := idp.ClientOptions{
+		RateLimit:      float64(limit.RequestsPerSecond),
+		Burst:          limit.Burst,
+		MaxConcurrency: limit.MaxConcurrency,
+	}
+	if limit.MaxRetries != nil {
+		opts.MaxRetries = *limit.MaxRetries
+		if opts.MaxRetries == 0 {
+			opts.MaxRetries = -1
+		}
+	}
+	return opts
+}
+
 // Get targetApp objects by BaseDataSyncConfig CR
 // If both of spec and ref,  spec priority is greater than ref
 func (r *BaseDataSyncConfigReconciler) getTargetEntitiesByCR(ctx context.Context, idp idp.Idp, baseCfg v1alpha1.BaseDataSyncConfig) ([]target.TargetApp, error) {
@@ -162,10 +504,13 @@ func (r *BaseDataSyncConfigReconciler) getTargetEntitiesByCR(ctx context.Context
 		targetAppName := ""
 		targetKind := ""
 		apiServerUrl := ""
+		clientOptions := target.ClientOptions{}
 		if targetCfg.ApplicationSpec != nil {
 			targetAppName = targetCfg.ApplicationSpec.Name
 			targetKind = targetCfg.ApplicationSpec.ProviderType
 			apiServerUrl = targetCfg.ApplicationSpec.ApiServerUrl
+			clientOptions.Insecure = targetCfg.ApplicationSpec.Insecure
+			clientOptions.CABundle = []byte(targetCfg.ApplicationSpec.CABundle)
 		} else {
 			refResourceResult := (*ref_resource.ReferenceResourceResult)(nil)
 			refResourceResult, err = r.getRefResourceResult(ctx, targetCfg.ApplicationRef)
@@ -175,6 +520,8 @@ func (r *BaseDataSyncConfigReconciler) getTargetEntitiesByCR(ctx context.Context
 			targetAppName = targetCfg.ApplicationRef.Name
 			targetKind = refResourceResult.ProviderType
 			apiServerUrl = refResourceResult.ApiServerUrl
+			clientOptions.Insecure = refResourceResult.Insecure
+			clientOptions.CABundle = []byte(refResourceResult.CABundle)
 		}
 		targetApp, err := target.NewTargetApplication(targetKind)
 		if err != nil {
@@ -183,6 +530,8 @@ func (r *BaseDataSyncConfigReconciler) getTargetEntitiesByCR(ctx context.Context
 		targetApp.SetIdp(idp)
 		targetApp.SetName(targetAppName)
 		targetApp.SetApiServerUrl(apiServerUrl)
+		targetApp.SetClientOptions(clientOptions)
+		targetApp.SetMembership(baseschema.MembershipMode(baseCfg.Spec.Membership))
 		targetApp.SetSecretProvider(r.SecretProvider)
 		result = append(result, targetApp)
 	}
@@ -201,7 +550,11 @@ func (r *BaseDataSyncConfigReconciler) getRefResourceResult(ctx context.Context,
 		return nil, fmt.Errorf("unsuported k8s resources gvk:%v", refReourceGvk)
 	}
 	refResourceInstance := ref_resource.NewReferenceResource(emptyRefResource)
-	result, err := refResourceInstance.Get(ctx, r.Client, appRef.Name, appRef.Namespace)
+	refClient := r.RefClient
+	if refClient == nil {
+		refClient = r.Client
+	}
+	result, err := refResourceInstance.Get(ctx, refClient, appRef.Name, appRef.Namespace)
 	if err != nil {
 		return nil, err
 	}
@@ -20,13 +20,17 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/nautes-labs/base-operator/internal/events"
+	"github.com/nautes-labs/base-operator/pkg/metrics"
 	nautescrd "github.com/nautes-labs/pkg/api/v1alpha1"
 	nautescfg "github.com/nautes-labs/pkg/pkg/nautesconfigs"
+	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/fields"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -41,6 +45,7 @@ const (
 	warningFromBaseOperator                = "base-operator"
 	clusterConditionTypeProductInfoUpdated = "ProductInfoUpdated"
 	clusterConditionReason                 = "DataSourceChanged"
+	clusterKind                            = "Cluster"
 )
 
 var (
@@ -52,7 +57,8 @@ var (
 // ClusterReconciler reconciles a Cluster object
 type ClusterReconciler struct {
 	client.Client
-	Scheme *runtime.Scheme
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
 }
 
 //+kubebuilder:rbac:groups=nautes.resource.nautes.io,resources=clusters,verbs=get;list;watch;create;update;patch;delete
@@ -73,12 +79,16 @@ func (r *ClusterReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ct
 
 	cluster := &nautescrd.Cluster{}
 	err := r.Get(ctx, req.NamespacedName, cluster)
+	if apierrors.IsNotFound(err) || (err == nil && !cluster.DeletionTimestamp.IsZero()) {
+		metrics.DeleteLastSuccessfulSync(clusterKind, req.Namespace, req.Name)
+	}
 	if err != nil || !cluster.DeletionTimestamp.IsZero() {
 		return ctrl.Result{}, client.IgnoreNotFound(err)
 	}
 
 	cfg, err := nautescfg.NewNautesConfigFromFile()
 	if err != nil {
+		r.Recorder.Eventf(cluster, corev1.EventTypeWarning, events.ReasonSyncFailed, "load nautes config failed: %s", err)
 		r.setCondition(cluster, err)
 		if err := r.Status().Update(ctx, cluster); err != nil {
 			logger.Error(err, "Update status failed.")
@@ -86,8 +96,9 @@ func (r *ClusterReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ct
 		return ctrl.Result{}, err
 	}
 	updater := productUpdater{
-		Client: r.Client,
-		config: *cfg,
+		Client:   r.Client,
+		config:   *cfg,
+		recorder: r.Recorder,
 	}
 
 	changed, err := updater.setProductsInfo(ctx, cluster)
@@ -95,6 +106,11 @@ func (r *ClusterReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ct
 		logger.V(1).Info("Product info has changed.")
 	}
 	r.setCondition(cluster, err)
+	if err != nil {
+		r.Recorder.Eventf(cluster, corev1.EventTypeWarning, events.ReasonSyncFailed, "update product info failed: %s", err)
+	} else {
+		metrics.SetLastSuccessfulSync(clusterKind, cluster.Namespace, cluster.Name)
+	}
 
 	if err != nil || changed {
 		if err := r.Status().Update(ctx, cluster); err != nil {
@@ -193,7 +209,8 @@ func (r *ClusterReconciler) setCondition(cluster *nautescrd.Cluster, err error)
 
 type productUpdater struct {
 	client.Client
-	config nautescfg.Config
+	config   nautescfg.Config
+	recorder record.EventRecorder
 }
 
 func (r *productUpdater) setProductsInfo(ctx context.Context, cluster *nautescrd.Cluster) (isChanged bool, err error) {
@@ -218,6 +235,7 @@ func (r *productUpdater) setProductsInfo(ctx context.Context, cluster *nautescrd
 
 	if !newWarningProducts.IsSame(oldWarningProducts) {
 		cluster.Status.Warnings = append(otherWarnings, newWarningProducts.GetWarnings()...)
+		r.recordWarningEvents(cluster, oldWarningProducts, newWarningProducts)
 		logger.V(1).Info("Warning message has changed.")
 		isChanged = true
 	}
@@ -225,6 +243,16 @@ func (r *productUpdater) setProductsInfo(ctx context.Context, cluster *nautescrd
 	return isChanged, nil
 }
 
+// recordWarningEvents records the warnings which are new to the cluster, the warning type is used as the event reason.
+func (r *productUpdater) recordWarningEvents(cluster *nautescrd.Cluster, oldWarnings, newWarnings warningProducts) {
+	for name, warning := range newWarnings {
+		if oldWarning, ok := oldWarnings[name]; ok && oldWarning.warningType == warning.warningType {
+			continue
+		}
+		r.recorder.Event(cluster, corev1.EventTypeWarning, string(warning.warningType), warning.error.Error())
+	}
+}
+
 func (r *productUpdater) setProductIDMap(ctx context.Context, cluster *nautescrd.Cluster) (isChanged bool, warnings warningProducts, err error) {
 	logger := log.FromContext(ctx)
 	warnings = warningProducts{}
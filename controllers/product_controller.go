@@ -16,33 +16,92 @@ package controllers
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
 
+	argocrd "github.com/argoproj/argo-cd/v2/pkg/apis/application/v1alpha1"
+	"github.com/argoproj/gitops-engine/pkg/health"
+	synccommon "github.com/argoproj/gitops-engine/pkg/sync/common"
+	"github.com/nautes-labs/base-operator/internal/events"
 	syncer "github.com/nautes-labs/base-operator/pkg/interface"
+	"github.com/nautes-labs/base-operator/pkg/metrics"
 	nautescrd "github.com/nautes-labs/pkg/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 )
 
 const (
 	productFinalizerName = "product.base-operator.nautes.resource.nautes.io/finalizers"
+	productKind          = "Product"
 )
 
 var (
 	productConditionType   = "ProductReady"
 	productConditionReason = "RegularUpdate"
+
+	productConditionTypeAppSynced    = "AppSynced"
+	productConditionTypeAppHealthy   = "AppHealthy"
+	productConditionTypeAppOperation = "AppOperationSucceeded"
+	productConditionReasonAppMissing = "AppNotFound"
+	productConditionReasonUnknown    = "Unknown"
+	productAppConditionTypes         = map[string]bool{
+		productConditionTypeAppSynced:    true,
+		productConditionTypeAppHealthy:   true,
+		productConditionTypeAppOperation: true,
+	}
+	// productAppConditionTypeList keeps the conditions of the argocd app in a fixed order.
+	productAppConditionTypeList = []string{
+		productConditionTypeAppSynced,
+		productConditionTypeAppHealthy,
+		productConditionTypeAppOperation,
+	}
+
+	productConditionTypeDeleted    = "Deleted"
+	productConditionReasonDeleting = "Deleting"
+
+	productConditionTypeConflict     = "ResourceConflict"
+	productConditionReasonNoConflict = "NoConflict"
+
+	// deletionRequeueInterval is the time to wait before checking the deletion again.
+	deletionRequeueInterval = map[syncer.DeletionPhase]time.Duration{
+		syncer.DeletionPhaseDeletingApp:       time.Second * 10,
+		syncer.DeletionPhaseDeletingNamespace: time.Second * 5,
+	}
+
+	// productResyncInterval is the time to apply the baseline of a product again, so that the baseline objects edited or deleted
+	// in the product namespace are restored. The product namespaces are not watched, they are out of the cache.
+	productResyncInterval = time.Minute * 10
 )
 
 // ProductReconciler reconciles a Product object
 type ProductReconciler struct {
 	client.Client
-	Scheme *runtime.Scheme
-	Syncer syncer.ProductSyncer
+	Scheme   *runtime.Scheme
+	Syncer   syncer.ProductSyncer
+	Recorder record.EventRecorder
+	// Namespace is the namespace of products, the argocd apps are mapped to the products in it by the product label.
+	Namespace string
+	// syncedGenerations records the generations of the products synced by this process,
+	// only the baselines of the products are applied again until their generations change.
+	syncedGenerations sync.Map
 }
 
 //+kubebuilder:rbac:groups=nautes.resource.nautes.io,resources=products,verbs=get;list;watch;create;update;patch;delete
@@ -52,6 +111,12 @@ type ProductReconciler struct {
 //+kubebuilder:rbac:groups=argoproj.io,resources=appprojects,verbs=get;create;update;patch
 //+kubebuilder:rbac:groups=argoproj.io,resources=applications,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups="",resources=namespaces,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups="",resources=resourcequotas;limitranges,verbs=get;list;create;update;patch;delete
+//+kubebuilder:rbac:groups="",resources=pods;services;persistentvolumeclaims,verbs=list
+//+kubebuilder:rbac:groups=networking.k8s.io,resources=networkpolicies,verbs=get;list;create;update;patch;delete
+//+kubebuilder:rbac:groups=rbac.authorization.k8s.io,resources=rolebindings,verbs=get;list;create;update;patch;delete
+//+kubebuilder:rbac:groups=rbac.authorization.k8s.io,resources=clusterroles,verbs=bind,resourceNames=admin;edit;view
+//+kubebuilder:rbac:groups="",resources=events,verbs=create;patch
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
@@ -71,22 +136,39 @@ func (r *ProductReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ct
 			return ctrl.Result{}, nil
 		}
 
-		if err := r.Syncer.Delete(ctx, *product); err != nil {
-			r.setStatus(product, err)
+		phase, err := r.Syncer.Delete(ctx, *product)
+		r.recordDeletionEvent(product, phase, err)
+		if err != nil || phase != syncer.DeletionPhaseDone {
+			r.setDeletingStatus(product, err)
+			r.setDeletionStatus(product, phase)
 			if err := r.Status().Update(ctx, product); err != nil {
 				logger.Error(err, "update status failed")
 			}
-			return ctrl.Result{}, err
+			if err != nil {
+				return ctrl.Result{}, err
+			}
+			logger.V(1).Info("waiting for product resources to be deleted", "phase", phase)
+			return ctrl.Result{RequeueAfter: deletionRequeueInterval[phase]}, nil
 		}
 
 		controllerutil.RemoveFinalizer(product, productFinalizerName)
 		if err := r.Update(ctx, product); err != nil {
 			return ctrl.Result{}, err
 		}
+		r.syncedGenerations.Delete(req.NamespacedName)
+		metrics.DeleteLastSuccessfulSync(productKind, product.Namespace, product.Name)
 		logger.V(1).Info("delete finish")
 		return ctrl.Result{}, nil
 	}
 
+	if generation, ok := r.syncedGenerations.Load(req.NamespacedName); ok && generation.(int64) == product.Generation {
+		if err := r.Syncer.SyncBaseline(ctx, *product); err != nil {
+			return ctrl.Result{}, err
+		}
+		logger.V(1).Info("baseline resync finish")
+		return ctrl.Result{RequeueAfter: productResyncInterval}, nil
+	}
+
 	controllerutil.AddFinalizer(product, productFinalizerName)
 	if err := r.Update(ctx, product); err != nil {
 		return ctrl.Result{}, err
@@ -94,22 +176,134 @@ func (r *ProductReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ct
 
 	err := r.Syncer.Sync(ctx, *product)
 	r.setStatus(product, err)
+	r.setConflictStatus(product, err)
+	r.recordSyncEvent(product, err)
+	if err == nil {
+		r.syncedGenerations.Store(req.NamespacedName, product.Generation)
+		metrics.SetLastSuccessfulSync(productKind, product.Namespace, product.Name)
+	} else {
+		r.syncedGenerations.Delete(req.NamespacedName)
+	}
+	appStatus, appErr := r.Syncer.GetAppStatus(ctx, *product)
+	if appErr != nil {
+		logger.Error(appErr, "get argocd app status failed")
+	} else {
+		r.setAppStatus(product, appStatus)
+	}
 	if err := r.Status().Update(ctx, product); err != nil {
 		logger.Error(err, "update status failed")
 	}
 
 	logger.V(1).Info("sync finish")
-	return ctrl.Result{}, err
-
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	return ctrl.Result{RequeueAfter: productResyncInterval}, nil
 }
 
 // SetupWithManager sets up the controller with the Manager.
+// The changes of the argocd apps are handled by another controller, which only mirrors the status of the apps to the products.
 func (r *ProductReconciler) SetupWithManager(mgr ctrl.Manager) error {
-	return ctrl.NewControllerManagedBy(mgr).
-		For(&nautescrd.Product{}).
-		WithEventFilter(predicate.GenerationChangedPredicate{}).
+	err := ctrl.NewControllerManagedBy(mgr).
+		For(&nautescrd.Product{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
 		WithOptions(controller.Options{MaxConcurrentReconciles: 5}).
 		Complete(r)
+	if err != nil {
+		return err
+	}
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("product-app-status").
+		Watches(
+			&source.Kind{Type: &argocrd.Application{}},
+			handler.EnqueueRequestsFromMapFunc(r.findProductForApp),
+			builder.WithPredicates(appStatusChangedPredicate),
+		).
+		WithOptions(controller.Options{MaxConcurrentReconciles: 5}).
+		Complete(reconcile.Func(r.reconcileAppStatus))
+}
+
+// reconcileAppStatus mirrors the status of the argocd app to product without syncing product.
+func (r *ProductReconciler) reconcileAppStatus(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	product := &nautescrd.Product{}
+	if err := r.Get(ctx, req.NamespacedName, product); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	if !product.DeletionTimestamp.IsZero() {
+		return ctrl.Result{}, nil
+	}
+
+	appStatus, err := r.Syncer.GetAppStatus(ctx, *product)
+	if err != nil {
+		return ctrl.Result{}, fmt.Errorf("get argocd app status failed: %w", err)
+	}
+	r.setAppStatus(product, appStatus)
+	if err := r.Status().Update(ctx, product); err != nil {
+		return ctrl.Result{}, err
+	}
+	return ctrl.Result{}, nil
+}
+
+// appStatusChangedPredicate only lets through the app updates which change the status mirrored to product.
+var appStatusChangedPredicate = predicate.Funcs{
+	UpdateFunc: func(e event.UpdateEvent) bool {
+		oldApp, ok := e.ObjectOld.(*argocrd.Application)
+		if !ok {
+			return false
+		}
+		newApp, ok := e.ObjectNew.(*argocrd.Application)
+		if !ok {
+			return false
+		}
+		return !isSameAppStatus(oldApp.Status, newApp.Status)
+	},
+}
+
+func isSameAppStatus(old, new argocrd.ApplicationStatus) bool {
+	if old.Sync.Status != new.Sync.Status ||
+		old.Health.Status != new.Health.Status ||
+		old.Health.Message != new.Health.Message ||
+		old.Sync.Revision != new.Sync.Revision ||
+		len(old.Conditions) != len(new.Conditions) {
+		return false
+	}
+
+	// the length of history stops changing once the revision history limit of argocd is reached
+	if len(old.History) != len(new.History) {
+		return false
+	}
+	if len(old.History) != 0 {
+		oldLast, newLast := old.History[len(old.History)-1], new.History[len(new.History)-1]
+		if oldLast.ID != newLast.ID || oldLast.Revision != newLast.Revision {
+			return false
+		}
+	}
+
+	if (old.OperationState == nil) != (new.OperationState == nil) {
+		return false
+	}
+	if old.OperationState != nil &&
+		(old.OperationState.Phase != new.OperationState.Phase ||
+			old.OperationState.Message != new.OperationState.Message) {
+		return false
+	}
+
+	for i := range old.Conditions {
+		if old.Conditions[i].Type != new.Conditions[i].Type ||
+			old.Conditions[i].Message != new.Conditions[i].Message {
+			return false
+		}
+	}
+	return true
+}
+
+// findProductForApp finds the product which the argocd app belongs to by the product label,
+// the products missing are skipped by the reconciler.
+func (r *ProductReconciler) findProductForApp(obj client.Object) []reconcile.Request {
+	productName, ok := obj.GetLabels()[nautescrd.LABEL_FROM_PRODUCT]
+	if !ok || productName == "" {
+		return nil
+	}
+	return []reconcile.Request{{NamespacedName: types.NamespacedName{Namespace: r.Namespace, Name: productName}}}
 }
 
 func (r *ProductReconciler) setStatus(product *nautescrd.Product, err error) {
@@ -130,3 +324,146 @@ func (r *ProductReconciler) setStatus(product *nautescrd.Product, err error) {
 		product.Status.SetConditions([]metav1.Condition{condition}, map[string]bool{productConditionType: true})
 	}
 }
+
+// setDeletingStatus marks product not ready while it is being deleted, the message is the failure of deletion if any.
+func (r *ProductReconciler) setDeletingStatus(product *nautescrd.Product, err error) {
+	condition := metav1.Condition{
+		Type:    productConditionType,
+		Status:  metav1.ConditionFalse,
+		Reason:  productConditionReasonDeleting,
+		Message: "product is being deleted",
+	}
+	if err != nil {
+		condition.Message = err.Error()
+	}
+	product.Status.SetConditions([]metav1.Condition{condition}, map[string]bool{productConditionType: true})
+}
+
+// recordDeletionEvent records the failures of deletion, and the phase only when it is changed.
+func (r *ProductReconciler) recordDeletionEvent(product *nautescrd.Product, phase syncer.DeletionPhase, err error) {
+	if err != nil {
+		r.Recorder.Eventf(product, corev1.EventTypeWarning, events.ReasonDeleteFailed, "delete product failed: %s", err)
+		return
+	}
+
+	condition := meta.FindStatusCondition(product.Status.Conditions, productConditionTypeDeleted)
+	if condition != nil && condition.Reason == string(phase) {
+		return
+	}
+	r.Recorder.Eventf(product, corev1.EventTypeNormal, events.ReasonDeleting, "product deletion is in phase %s", phase)
+}
+
+func (r *ProductReconciler) recordSyncEvent(product *nautescrd.Product, err error) {
+	if err == nil {
+		return
+	}
+
+	conflictErr := &syncer.ResourceConflictError{}
+	if errors.As(err, &conflictErr) {
+		r.Recorder.Event(product, corev1.EventTypeWarning, events.ReasonResourceConflict, conflictErr.Error())
+		return
+	}
+	r.Recorder.Eventf(product, corev1.EventTypeWarning, events.ReasonSyncFailed, "sync product failed: %s", err)
+}
+
+// setConflictStatus reports the resource which blocks the product in condition "ResourceConflict".
+// The condition is left untouched if sync failed for other reasons, because the conflict can not be checked.
+func (r *ProductReconciler) setConflictStatus(product *nautescrd.Product, err error) {
+	condition := metav1.Condition{
+		Type:   productConditionTypeConflict,
+		Status: metav1.ConditionFalse,
+		Reason: productConditionReasonNoConflict,
+	}
+
+	conflictErr := &syncer.ResourceConflictError{}
+	if errors.As(err, &conflictErr) {
+		condition.Status = metav1.ConditionTrue
+		condition.Reason = conflictErr.Reason
+		condition.Message = conflictErr.Error()
+	} else if err != nil {
+		return
+	}
+
+	product.Status.SetConditions([]metav1.Condition{condition}, map[string]bool{productConditionTypeConflict: true})
+}
+
+// setDeletionStatus records the deletion phase of product in condition "Deleted".
+func (r *ProductReconciler) setDeletionStatus(product *nautescrd.Product, phase syncer.DeletionPhase) {
+	condition := metav1.Condition{
+		Type:    productConditionTypeDeleted,
+		Status:  metav1.ConditionFalse,
+		Reason:  string(phase),
+		Message: fmt.Sprintf("product deletion is in phase %s", phase),
+	}
+	if phase == syncer.DeletionPhaseDone {
+		condition.Status = metav1.ConditionTrue
+	}
+	if phase == "" {
+		condition.Reason = productConditionReasonUnknown
+	}
+	product.Status.SetConditions([]metav1.Condition{condition}, map[string]bool{productConditionTypeDeleted: true})
+}
+
+// setAppStatus mirrors the status of the argocd app to the conditions of product.
+func (r *ProductReconciler) setAppStatus(product *nautescrd.Product, status *syncer.AppStatus) {
+	if status == nil {
+		conditions := []metav1.Condition{}
+		for _, conditionType := range productAppConditionTypeList {
+			conditions = append(conditions, metav1.Condition{
+				Type:    conditionType,
+				Status:  metav1.ConditionUnknown,
+				Reason:  productConditionReasonAppMissing,
+				Message: "argocd app of product is not created",
+			})
+		}
+		product.Status.SetConditions(conditions, productAppConditionTypes)
+		return
+	}
+
+	syncCondition := metav1.Condition{
+		Type:    productConditionTypeAppSynced,
+		Status:  metav1.ConditionFalse,
+		Reason:  conditionReason(status.SyncStatus),
+		Message: fmt.Sprintf("last synced revision: %s", status.Revision),
+	}
+	if status.SyncStatus == string(argocrd.SyncStatusCodeSynced) {
+		syncCondition.Status = metav1.ConditionTrue
+	}
+	if len(status.Errors) != 0 {
+		syncCondition.Status = metav1.ConditionFalse
+		syncCondition.Message = fmt.Sprintf("%s, errors: %s", syncCondition.Message, strings.Join(status.Errors, "; "))
+	}
+
+	healthCondition := metav1.Condition{
+		Type:    productConditionTypeAppHealthy,
+		Status:  metav1.ConditionFalse,
+		Reason:  conditionReason(status.Health),
+		Message: status.HealthMessage,
+	}
+	if status.Health == string(health.HealthStatusHealthy) {
+		healthCondition.Status = metav1.ConditionTrue
+	}
+
+	operationCondition := metav1.Condition{
+		Type:    productConditionTypeAppOperation,
+		Status:  metav1.ConditionUnknown,
+		Reason:  conditionReason(status.OperationPhase),
+		Message: status.OperationMessage,
+	}
+	switch status.OperationPhase {
+	case string(synccommon.OperationSucceeded):
+		operationCondition.Status = metav1.ConditionTrue
+	case string(synccommon.OperationFailed), string(synccommon.OperationError):
+		operationCondition.Status = metav1.ConditionFalse
+	}
+
+	product.Status.SetConditions([]metav1.Condition{syncCondition, healthCondition, operationCondition}, productAppConditionTypes)
+}
+
+// conditionReason returns the status reported by argocd as reason, reason of condition can not be empty.
+func conditionReason(status string) string {
+	if status == "" {
+		return productConditionReasonUnknown
+	}
+	return status
+}
@@ -18,10 +18,14 @@ import (
 	"context"
 	"time"
 
+	"github.com/nautes-labs/base-operator/internal/events"
 	syncer "github.com/nautes-labs/base-operator/pkg/interface"
+	"github.com/nautes-labs/base-operator/pkg/metrics"
 	nautescrd "github.com/nautes-labs/pkg/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
@@ -31,6 +35,7 @@ import (
 
 const (
 	productProviderFinalizerName = "productprovider.base-operator.nautes.resource.nautes.io/finalizers"
+	productProviderKind          = "ProductProvider"
 )
 
 var (
@@ -41,8 +46,9 @@ var (
 // ProductProviderReconciler reconciles a ProductProvider object
 type ProductProviderReconciler struct {
 	client.Client
-	Scheme *runtime.Scheme
-	Syncer syncer.ProductProviderSyncer
+	Scheme   *runtime.Scheme
+	Syncer   syncer.ProductProviderSyncer
+	Recorder record.EventRecorder
 }
 
 //+kubebuilder:rbac:groups=nautes.resource.nautes.io,resources=productproviders,verbs=get;list;watch;create;update;patch;delete
@@ -84,6 +90,7 @@ func (r *ProductProviderReconciler) Reconcile(ctx context.Context, req ctrl.Requ
 		if err := r.Update(ctx, productProvider); err != nil {
 			return ctrl.Result{}, err
 		}
+		metrics.DeleteLastSuccessfulSync(productProviderKind, productProvider.Namespace, productProvider.Name)
 		logger.V(1).Info("delete finish")
 		return ctrl.Result{}, nil
 	}
@@ -95,6 +102,11 @@ func (r *ProductProviderReconciler) Reconcile(ctx context.Context, req ctrl.Requ
 
 	err := r.Syncer.Sync(ctx, *productProvider)
 	r.setStatus(productProvider, err)
+	if err != nil {
+		r.Recorder.Eventf(productProvider, corev1.EventTypeWarning, events.ReasonSyncFailed, "sync products failed: %s", err)
+	} else {
+		metrics.SetLastSuccessfulSync(productProviderKind, productProvider.Namespace, productProvider.Name)
+	}
 	if err := r.Status().Update(ctx, productProvider); err != nil {
 		logger.Error(err, "update status failed")
 	}
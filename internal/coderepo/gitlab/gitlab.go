@@ -24,6 +24,8 @@ import (
 	"strings"
 
 	baseinterface "github.com/nautes-labs/base-operator/pkg/interface"
+	"github.com/nautes-labs/base-operator/pkg/metrics"
+	"github.com/nautes-labs/base-operator/pkg/tracing"
 	nautescrd "github.com/nautes-labs/pkg/api/v1alpha1"
 	nautescfg "github.com/nautes-labs/pkg/pkg/nautesconfigs"
 	"github.com/xanzy/go-gitlab"
@@ -32,6 +34,8 @@ import (
 
 const (
 	CA_PATH = "ca/ca.crt"
+	// "/api/v4/groups/:id/members"
+	endpointSegments = 5
 )
 
 type GitLab struct {
@@ -55,6 +59,9 @@ func NewGitlab(token string, codeRepoProvider nautescrd.CodeRepoProvider, cfg na
 			return nil, err
 		}
 		opts = append(opts, gitlab.WithHTTPClient(httpClient))
+	} else {
+		httpClient := &http.Client{Transport: tracing.NewTransport(metrics.ClientGitlab, endpointSegments, metrics.NewTransport(metrics.ClientGitlab, endpointSegments, nil))}
+		opts = append(opts, gitlab.WithHTTPClient(httpClient))
 	}
 	client, err := gitlab.NewClient(token, opts...)
 	if err != nil {
@@ -83,7 +90,7 @@ func getHttpsClient() (*http.Client, error) {
 	}
 
 	transport := &http.Transport{TLSClientConfig: tlsConfig}
-	client := &http.Client{Transport: transport}
+	client := &http.Client{Transport: tracing.NewTransport(metrics.ClientGitlab, endpointSegments, metrics.NewTransport(metrics.ClientGitlab, endpointSegments, transport))}
 	return client, nil
 }
 
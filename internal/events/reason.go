@@ -0,0 +1,49 @@
+// Copyright 2023 Nautes Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package events defines the reasons of the kubernetes events recorded by base operator.
+// Controllers and syncers share the same reasons, so that "kubectl describe" reads the same everywhere.
+package events
+
+import (
+	"k8s.io/client-go/tools/record"
+)
+
+// Reasons of events recorded on product provider.
+const (
+	ReasonProductCreated = "ProductCreated"
+	ReasonProductUpdated = "ProductUpdated"
+	ReasonProductDeleted = "ProductDeleted"
+)
+
+// Reasons of events recorded on product.
+const (
+	ReasonNamespaceCreated = "NamespaceCreated"
+	ReasonNamespaceAdopted = "NamespaceAdopted"
+	ReasonAppCreated       = "AppCreated"
+	ReasonAppAdopted       = "AppAdopted"
+	ReasonResourceConflict = "ResourceConflict"
+	ReasonDeleting         = "Deleting"
+	ReasonDeleteFailed     = "DeleteFailed"
+)
+
+// Reasons shared by all resources.
+const (
+	ReasonSyncFailed = "SyncFailed"
+)
+
+// NewNopRecorder returns a recorder which drops all events, it is used when no recorder is provided.
+func NewNopRecorder() record.EventRecorder {
+	return &record.FakeRecorder{}
+}
@@ -25,6 +25,8 @@ import (
 	vault "github.com/hashicorp/vault/api"
 	kubernetesauth "github.com/hashicorp/vault/api/auth/kubernetes"
 	baseinterface "github.com/nautes-labs/base-operator/pkg/interface"
+	"github.com/nautes-labs/base-operator/pkg/metrics"
+	"github.com/nautes-labs/base-operator/pkg/tracing"
 	nautescfg "github.com/nautes-labs/pkg/pkg/nautesconfigs"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
 )
@@ -37,6 +39,8 @@ const (
 	TENANT_NAMESPACE   = "tenant"
 	GIT_REPO_ROOT_PATH = "git/%s/root"
 	GIT_REPO_ROOT_KEY  = "access_token"
+	// "/v1/auth/kubernetes"
+	endpointSegments = 3
 )
 
 func (v *Vault) GetGitRepoRootToken(ctx context.Context, name string) (string, error) {
@@ -79,6 +83,8 @@ func NewVault(cfg nautescfg.SecretRepo) (*Vault, error) {
 		}
 	}
 
+	instrumentTransport(config)
+
 	client, err := vault.NewClient(config)
 	if err != nil {
 		return nil, fmt.Errorf("unable to initialize Vault client: %w", err)
@@ -113,3 +119,16 @@ func NewVault(cfg nautescfg.SecretRepo) (*Vault, error) {
 		Client: client,
 	}, nil
 }
+
+// instrumentTransport wraps the transport of config with the metrics and tracing transports.
+// vault.NewClient sets the socket dialer on the *http.Transport of an unix:// address, the transport is kept
+// as it is for such an address or if it is not an *http.Transport.
+func instrumentTransport(config *vault.Config) {
+	if _, ok := config.HttpClient.Transport.(*http.Transport); !ok {
+		return
+	}
+	if strings.HasPrefix(config.Address, "unix://") || strings.HasPrefix(config.AgentAddress, "unix://") {
+		return
+	}
+	config.HttpClient.Transport = tracing.NewTransport(metrics.ClientVault, endpointSegments, metrics.NewTransport(metrics.ClientVault, endpointSegments, config.HttpClient.Transport))
+}
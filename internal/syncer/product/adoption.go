@@ -0,0 +1,189 @@
+// Copyright 2023 Nautes Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package product
+
+import (
+	"context"
+	"fmt"
+
+	argocrd "github.com/argoproj/argo-cd/v2/pkg/apis/application/v1alpha1"
+	"github.com/nautes-labs/base-operator/internal/events"
+	baseinterface "github.com/nautes-labs/base-operator/pkg/interface"
+	nautescrd "github.com/nautes-labs/pkg/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// AdoptionPolicy decides what to do when a namespace or an argocd app with the product name
+// already exists but is not labelled as a resource of the product.
+type AdoptionPolicy string
+
+const (
+	// Adopt the resource if nothing is running in it.
+	AdoptionPolicyAdoptIfEmpty AdoptionPolicy = "adopt-if-empty"
+	AdoptionPolicyAdoptAlways  AdoptionPolicy = "adopt-always"
+	// Report a conflict and leave the resource untouched, it is the default policy.
+	AdoptionPolicyRefuse AdoptionPolicy = "refuse"
+)
+
+var (
+	conflictReasonAlreadyExists = "AlreadyExists"
+	conflictReasonNotEmpty      = "NotEmpty"
+	conflictReasonOtherProduct  = "OwnedByOtherProduct"
+)
+
+// ParseAdoptionPolicy checks the policy name, empty name means AdoptionPolicyRefuse.
+func ParseAdoptionPolicy(name string) (AdoptionPolicy, error) {
+	switch policy := AdoptionPolicy(name); policy {
+	case "":
+		return AdoptionPolicyRefuse, nil
+	case AdoptionPolicyAdoptIfEmpty, AdoptionPolicyAdoptAlways, AdoptionPolicyRefuse:
+		return policy, nil
+	default:
+		return "", fmt.Errorf("unknown adoption policy %s", name)
+	}
+}
+
+// checkAdoption returns a ResourceConflictError if obj can not be adopted by the product.
+func (s *ProductSyncer) checkAdoption(obj client.Object, kind string, label map[string]string, isEmpty func() (bool, error)) error {
+	conflictErr := &baseinterface.ResourceConflictError{
+		Kind:      kind,
+		Namespace: obj.GetNamespace(),
+		Name:      obj.GetName(),
+		Owner:     getResourceOwner(obj),
+	}
+
+	productName, ok := obj.GetLabels()[nautescrd.LABEL_FROM_PRODUCT]
+	if ok && productName != label[nautescrd.LABEL_FROM_PRODUCT] {
+		conflictErr.Reason = conflictReasonOtherProduct
+		return conflictErr
+	}
+
+	switch s.AdoptionPolicy {
+	case AdoptionPolicyAdoptAlways:
+		return nil
+	case AdoptionPolicyAdoptIfEmpty:
+		empty, err := isEmpty()
+		if err != nil {
+			return fmt.Errorf("check %s %s is empty failed: %w", kind, obj.GetName(), err)
+		}
+		if empty {
+			return nil
+		}
+		conflictErr.Reason = conflictReasonNotEmpty
+		return conflictErr
+	default:
+		conflictErr.Reason = conflictReasonAlreadyExists
+		return conflictErr
+	}
+}
+
+// adoptNamespace labels the existing namespace as the product namespace if the adoption policy allows.
+func (s *ProductSyncer) adoptNamespace(ctx context.Context, product *nautescrd.Product, ns *corev1.Namespace, label map[string]string) error {
+	if !ns.DeletionTimestamp.IsZero() {
+		return fmt.Errorf("namespace %s is terminating", ns.Name)
+	}
+
+	err := s.checkAdoption(ns, "Namespace", label, func() (bool, error) {
+		return s.isNamespaceEmpty(ctx, ns.Name)
+	})
+	if err != nil {
+		return err
+	}
+
+	owner := getResourceOwner(ns)
+	if ns.Labels == nil {
+		ns.Labels = map[string]string{}
+	}
+	for k, v := range label {
+		ns.Labels[k] = v
+	}
+
+	log.FromContext(ctx).V(1).Info("adopt namespace", "NamespaceName", ns.Name)
+	if err := s.client.Update(ctx, ns); err != nil {
+		return err
+	}
+	s.Recorder.Eventf(product, corev1.EventTypeNormal, events.ReasonNamespaceAdopted, "namespace %s adopted, owner was %s", ns.Name, owner)
+	return nil
+}
+
+// adoptArgoApp takes over the existing argocd app if the adoption policy allows, its spec is replaced by spec.
+func (s *ProductSyncer) adoptArgoApp(ctx context.Context, product *nautescrd.Product, app *argocrd.Application, spec argocrd.ApplicationSpec, label map[string]string) error {
+	if !app.DeletionTimestamp.IsZero() {
+		return fmt.Errorf("argocd app %s is terminating", app.Name)
+	}
+
+	err := s.checkAdoption(app, "Application", label, func() (bool, error) {
+		return isArgoAppEmpty(app), nil
+	})
+	if err != nil {
+		return err
+	}
+
+	owner := getResourceOwner(app)
+	if app.Labels == nil {
+		app.Labels = map[string]string{}
+	}
+	for k, v := range label {
+		app.Labels[k] = v
+	}
+	app.Spec = spec
+
+	log.FromContext(ctx).V(1).Info("adopt argocd app", "appName", app.Name)
+	if err := s.client.Update(ctx, app); err != nil {
+		return err
+	}
+	s.Recorder.Eventf(product, corev1.EventTypeNormal, events.ReasonAppAdopted, "argocd app %s/%s adopted, owner was %s", app.Namespace, app.Name, owner)
+	return nil
+}
+
+// isNamespaceEmpty treats a namespace as empty if it has no pods, services and volume claims.
+func (s *ProductSyncer) isNamespaceEmpty(ctx context.Context, name string) (bool, error) {
+	lists := []client.ObjectList{
+		&corev1.PodList{},
+		&corev1.ServiceList{},
+		&corev1.PersistentVolumeClaimList{},
+	}
+	for _, list := range lists {
+		if err := s.client.List(ctx, list, client.InNamespace(name), client.Limit(1)); err != nil {
+			return false, err
+		}
+		if meta.LenList(list) != 0 {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// isArgoAppEmpty treats an argocd app as empty if it does not manage any resource.
+func isArgoAppEmpty(app *argocrd.Application) bool {
+	return len(app.Status.Resources) == 0
+}
+
+// getResourceOwner finds out who the resource belongs to, it is used to report conflicts.
+func getResourceOwner(obj client.Object) string {
+	if productName, ok := obj.GetLabels()[nautescrd.LABEL_FROM_PRODUCT]; ok {
+		return fmt.Sprintf("Product/%s", productName)
+	}
+	if refs := obj.GetOwnerReferences(); len(refs) != 0 {
+		return fmt.Sprintf("%s/%s", refs[0].Kind, refs[0].Name)
+	}
+	if fields := obj.GetManagedFields(); len(fields) != 0 {
+		return fmt.Sprintf("manager %s", fields[0].Manager)
+	}
+	return "unknown"
+}
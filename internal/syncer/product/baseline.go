@@ -0,0 +1,301 @@
+// Copyright 2023 Nautes Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package product
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"text/template"
+
+	"github.com/nautes-labs/base-operator/pkg/tracing"
+	nautescrd "github.com/nautes-labs/pkg/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/yaml"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+const (
+	LABEL_BASELINE = "base-operator.nautes.io/baseline"
+	// ANNOTATION_BASELINE_KINDS records the kinds of the baseline objects applied to the product namespace,
+	// so that the objects are pruned after their kinds are removed from the baseline.
+	ANNOTATION_BASELINE_KINDS = "base-operator.nautes.io/baseline-kinds"
+	baselineFieldOwn          = "base-operator"
+)
+
+// defaultBaselineKinds are always checked when pruning baseline objects,
+// so that an object is still cleaned up after its kind is removed from the baseline.
+var defaultBaselineKinds = []schema.GroupVersionKind{
+	{Group: "", Version: "v1", Kind: "ResourceQuota"},
+	{Group: "", Version: "v1", Kind: "LimitRange"},
+	{Group: "networking.k8s.io", Version: "v1", Kind: "NetworkPolicy"},
+	{Group: "rbac.authorization.k8s.io", Version: "v1", Kind: "RoleBinding"},
+}
+
+// Baseline is a set of namespaced objects rendered into every product namespace.
+// The manifests are go templates, see BaselineValues for the values can be used.
+type Baseline struct {
+	tmpl *template.Template
+}
+
+// BaselineValues is the data used to render the baseline manifests.
+type BaselineValues struct {
+	// Name of the product namespace
+	Namespace string
+	// Name of the product resource
+	ProductName string
+	// The product name in product provider, it is also the group name of the product owners
+	ProductGroup string
+	ProductID    string
+}
+
+// NewBaseline parses the manifests of baseline, multiple objects are separated by "---".
+func NewBaseline(manifests string) (*Baseline, error) {
+	tmpl, err := template.New("baseline").Option("missingkey=error").Parse(manifests)
+	if err != nil {
+		return nil, fmt.Errorf("parse baseline template failed: %w", err)
+	}
+	return &Baseline{tmpl: tmpl}, nil
+}
+
+// LoadBaseline reads the baseline manifests from file.
+func LoadBaseline(path string) (*Baseline, error) {
+	manifests, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read baseline file failed: %w", err)
+	}
+	return NewBaseline(string(manifests))
+}
+
+// Render returns the objects of baseline, all of them are placed in the product namespace.
+func (b *Baseline) Render(values BaselineValues) ([]*unstructured.Unstructured, error) {
+	buf := &bytes.Buffer{}
+	if err := b.tmpl.Execute(buf, values); err != nil {
+		return nil, fmt.Errorf("render baseline failed: %w", err)
+	}
+
+	objs := []*unstructured.Unstructured{}
+	decoder := yaml.NewYAMLOrJSONDecoder(buf, 4096)
+	for {
+		obj := &unstructured.Unstructured{}
+		err := decoder.Decode(&obj.Object)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("decode baseline object failed: %w", err)
+		}
+		if len(obj.Object) == 0 {
+			continue
+		}
+		if obj.GetKind() == "" || obj.GetName() == "" {
+			return nil, fmt.Errorf("baseline object must have kind and name")
+		}
+
+		obj.SetNamespace(values.Namespace)
+		objs = append(objs, obj)
+	}
+
+	return objs, nil
+}
+
+// SyncBaseline applies the baseline objects to the namespace of product and prunes the removed ones,
+// the other resources of product are not synced.
+func (s *ProductSyncer) SyncBaseline(ctx context.Context, product nautescrd.Product) (err error) {
+	ctx, span := tracing.Start(ctx, "ProductSyncer.SyncBaseline", tracing.AttrProduct.String(product.Name))
+	defer func() { tracing.End(span, err) }()
+
+	productID, err := getProductID(product.Name)
+	if err != nil {
+		return err
+	}
+	label := map[string]string{nautescrd.LABEL_FROM_PRODUCT: product.Name}
+	if err := s.syncBaseline(ctx, getBaselineValues(product, productID), label); err != nil {
+		return fmt.Errorf("sync namespace baseline failed: %w", err)
+	}
+	return nil
+}
+
+// getBaselineValues returns the values of the baseline of product, the namespace of product is named after it.
+func getBaselineValues(product nautescrd.Product, productID string) BaselineValues {
+	return BaselineValues{
+		Namespace:    product.Name,
+		ProductName:  product.Name,
+		ProductGroup: product.Spec.Name,
+		ProductID:    productID,
+	}
+}
+
+func (s *ProductSyncer) syncBaseline(ctx context.Context, values BaselineValues, label map[string]string) error {
+	objs := []*unstructured.Unstructured{}
+	if s.Baseline != nil {
+		var err error
+		objs, err = s.Baseline.Render(values)
+		if err != nil {
+			return err
+		}
+	}
+
+	baselineLabel := getBaselineLabel(label)
+	expected := map[schema.GroupVersionKind]map[string]bool{}
+	for _, obj := range objs {
+		labels := obj.GetLabels()
+		if labels == nil {
+			labels = map[string]string{}
+		}
+		for k, v := range baselineLabel {
+			labels[k] = v
+		}
+		obj.SetLabels(labels)
+
+		log.FromContext(ctx).V(1).Info("apply baseline object", "Kind", obj.GetKind(), "Name", obj.GetName())
+		err := s.client.Patch(ctx, obj, client.Apply, client.ForceOwnership, client.FieldOwner(baselineFieldOwn))
+		if err != nil {
+			return fmt.Errorf("apply %s %s failed: %w", obj.GetKind(), obj.GetName(), err)
+		}
+
+		gvk := obj.GroupVersionKind()
+		if expected[gvk] == nil {
+			expected[gvk] = map[string]bool{}
+		}
+		expected[gvk][obj.GetName()] = true
+	}
+
+	return s.pruneBaseline(ctx, values.Namespace, baselineLabel, expected)
+}
+
+func (s *ProductSyncer) deleteBaseline(ctx context.Context, namespace string, label map[string]string) error {
+	return s.pruneBaseline(ctx, namespace, getBaselineLabel(label), nil)
+}
+
+// pruneBaseline removes the baseline objects in namespace which are not in expected, the objects of the default kinds,
+// the expected kinds and the kinds recorded by the previous syncs are checked.
+// The expected kinds and the kinds failed to prune are recorded on the namespace afterwards.
+func (s *ProductSyncer) pruneBaseline(ctx context.Context, namespace string, label map[string]string, expected map[schema.GroupVersionKind]map[string]bool) error {
+	ns := &corev1.Namespace{}
+	if err := s.client.Get(ctx, types.NamespacedName{Name: namespace}, ns); err != nil {
+		// nothing is left to prune in a namespace which does not exist
+		return client.IgnoreNotFound(err)
+	}
+	recorded, err := getBaselineKinds(ns)
+	if err != nil {
+		return err
+	}
+	kinds := map[schema.GroupVersionKind]bool{}
+	for _, gvk := range defaultBaselineKinds {
+		kinds[gvk] = true
+	}
+	for _, gvk := range recorded {
+		kinds[gvk] = true
+	}
+	for gvk := range expected {
+		kinds[gvk] = true
+	}
+	remaining := map[schema.GroupVersionKind]bool{}
+	for gvk := range expected {
+		remaining[gvk] = true
+	}
+
+	listOpts := []client.ListOption{
+		client.MatchingLabels(label),
+		client.InNamespace(namespace),
+	}
+	errList := []error{}
+	for gvk := range kinds {
+		objList := &unstructured.UnstructuredList{}
+		objList.SetGroupVersionKind(gvk.GroupVersion().WithKind(gvk.Kind + "List"))
+		if err := s.client.List(ctx, objList, listOpts...); err != nil {
+			errList = append(errList, err)
+			remaining[gvk] = true
+			continue
+		}
+
+		for i := range objList.Items {
+			obj := &objList.Items[i]
+			if expected[gvk][obj.GetName()] {
+				continue
+			}
+
+			log.FromContext(ctx).V(1).Info("delete baseline object", "Kind", obj.GetKind(), "Name", obj.GetName())
+			if err := s.client.Delete(ctx, obj); client.IgnoreNotFound(err) != nil {
+				errList = append(errList, err)
+				remaining[gvk] = true
+			}
+		}
+	}
+	if err := s.recordBaselineKinds(ctx, ns, remaining); err != nil {
+		errList = append(errList, err)
+	}
+	if len(errList) != 0 {
+		return fmt.Errorf("%v", errList)
+	}
+
+	return nil
+}
+
+// getBaselineKinds returns the baseline kinds recorded on the namespace.
+func getBaselineKinds(ns *corev1.Namespace) ([]schema.GroupVersionKind, error) {
+	value, ok := ns.Annotations[ANNOTATION_BASELINE_KINDS]
+	if !ok {
+		return nil, nil
+	}
+	kinds := []schema.GroupVersionKind{}
+	if err := json.Unmarshal([]byte(value), &kinds); err != nil {
+		return nil, fmt.Errorf("parse baseline kinds of namespace %s failed: %w", ns.Name, err)
+	}
+	return kinds, nil
+}
+
+// recordBaselineKinds records the kinds on the namespace, the namespace is not updated if they are already recorded.
+func (s *ProductSyncer) recordBaselineKinds(ctx context.Context, ns *corev1.Namespace, kinds map[schema.GroupVersionKind]bool) error {
+	list := make([]schema.GroupVersionKind, 0, len(kinds))
+	for gvk := range kinds {
+		list = append(list, gvk)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].String() < list[j].String() })
+	value, err := json.Marshal(list)
+	if err != nil {
+		return err
+	}
+	if ns.Annotations[ANNOTATION_BASELINE_KINDS] == string(value) {
+		return nil
+	}
+	patch := client.MergeFrom(ns.DeepCopy())
+	if ns.Annotations == nil {
+		ns.Annotations = map[string]string{}
+	}
+	ns.Annotations[ANNOTATION_BASELINE_KINDS] = string(value)
+	if err := s.client.Patch(ctx, ns, patch); err != nil {
+		return fmt.Errorf("record baseline kinds of namespace %s failed: %w", ns.Name, err)
+	}
+	return nil
+}
+
+func getBaselineLabel(label map[string]string) map[string]string {
+	baselineLabel := map[string]string{LABEL_BASELINE: "true"}
+	for k, v := range label {
+		baselineLabel[k] = v
+	}
+	return baselineLabel
+}
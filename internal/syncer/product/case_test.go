@@ -16,17 +16,22 @@ package product
 
 import (
 	"context"
+	"errors"
 	"fmt"
 
 	argocrd "github.com/argoproj/argo-cd/v2/pkg/apis/application/v1alpha1"
+	"github.com/nautes-labs/base-operator/internal/events"
 	"github.com/nautes-labs/base-operator/internal/syncer/productprovider"
 	baseinterface "github.com/nautes-labs/base-operator/pkg/interface"
 	nautescrd "github.com/nautes-labs/pkg/api/v1alpha1"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
@@ -91,6 +96,18 @@ var _ = Describe("K8S syncer", func() {
 		Expect(coderepos.Items[0].Name).Should(Equal(repoName))
 	})
 
+	It("record events of created resources", func() {
+		recorder := record.NewFakeRecorder(10)
+		syncInstance.(*ProductSyncer).Recorder = recorder
+		defer func() { syncInstance.(*ProductSyncer).Recorder = events.NewNopRecorder() }()
+
+		err := syncInstance.Sync(ctx, *product)
+		Expect(err).Should(BeNil())
+
+		Expect(recorder.Events).Should(Receive(HavePrefix("Normal NamespaceCreated")))
+		Expect(recorder.Events).Should(Receive(HavePrefix("Normal AppCreated")))
+	})
+
 	It("update a product", func() {
 		err := syncInstance.Sync(ctx, *product)
 		Expect(err).Should(BeNil())
@@ -115,12 +132,184 @@ var _ = Describe("K8S syncer", func() {
 		Expect(coderepos.Items[0].Spec.URL).Should(Equal(product.Spec.MetaDataPath))
 	})
 
+	It("adopt an existing namespace", func() {
+		ns := &corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: product.Name,
+			},
+		}
+		err := k8sClient.Create(ctx, ns)
+		Expect(err).Should(BeNil())
+
+		err = syncInstance.Sync(ctx, *product)
+		conflictErr := &baseinterface.ResourceConflictError{}
+		Expect(errors.As(err, &conflictErr)).Should(BeTrue())
+		Expect(conflictErr.Kind).Should(Equal("Namespace"))
+		Expect(conflictErr.Reason).Should(Equal("AlreadyExists"))
+
+		syncInstance.(*ProductSyncer).AdoptionPolicy = AdoptionPolicyAdoptIfEmpty
+		defer func() { syncInstance.(*ProductSyncer).AdoptionPolicy = AdoptionPolicyRefuse }()
+
+		err = syncInstance.Sync(ctx, *product)
+		Expect(err).Should(BeNil())
+
+		err = k8sClient.Get(ctx, types.NamespacedName{Name: product.Name}, ns)
+		Expect(err).Should(BeNil())
+		Expect(ns.Labels[nautescrd.LABEL_FROM_PRODUCT]).Should(Equal(product.Name))
+	})
+
+	It("refuse the argocd app belongs to other product", func() {
+		app := &argocrd.Application{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      product.Name,
+				Namespace: "default",
+				Labels:    map[string]string{nautescrd.LABEL_FROM_PRODUCT: "product-other"},
+			},
+			Spec: argocrd.ApplicationSpec{
+				Source: argocrd.ApplicationSource{
+					RepoURL: "https://www.github.com/other/default.project",
+				},
+			},
+		}
+		err := k8sClient.Create(ctx, app)
+		Expect(err).Should(BeNil())
+
+		syncInstance.(*ProductSyncer).AdoptionPolicy = AdoptionPolicyAdoptAlways
+		defer func() { syncInstance.(*ProductSyncer).AdoptionPolicy = AdoptionPolicyRefuse }()
+
+		err = syncInstance.Sync(ctx, *product)
+		conflictErr := &baseinterface.ResourceConflictError{}
+		Expect(errors.As(err, &conflictErr)).Should(BeTrue())
+		Expect(conflictErr.Kind).Should(Equal("Application"))
+		Expect(conflictErr.Owner).Should(Equal("Product/product-other"))
+	})
+
+	It("render namespace baseline", func() {
+		baseline, err := NewBaseline(`
+apiVersion: v1
+kind: ResourceQuota
+metadata:
+  name: quota
+spec:
+  hard:
+    pods: "10"
+---
+apiVersion: rbac.authorization.k8s.io/v1
+kind: RoleBinding
+metadata:
+  name: owners
+roleRef:
+  apiGroup: rbac.authorization.k8s.io
+  kind: ClusterRole
+  name: admin
+subjects:
+- apiGroup: rbac.authorization.k8s.io
+  kind: Group
+  name: "{{ .ProductGroup }}"
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: product-info
+data:
+  id: "{{ .ProductID }}"
+`)
+		Expect(err).Should(BeNil())
+		syncInstance.(*ProductSyncer).Baseline = baseline
+		defer func() { syncInstance.(*ProductSyncer).Baseline = nil }()
+
+		err = syncInstance.Sync(ctx, *product)
+		Expect(err).Should(BeNil())
+
+		quota := &corev1.ResourceQuota{}
+		err = k8sClient.Get(ctx, types.NamespacedName{Namespace: product.Name, Name: "quota"}, quota)
+		Expect(err).Should(BeNil())
+		Expect(quota.Labels[LABEL_BASELINE]).Should(Equal("true"))
+
+		rb := &rbacv1.RoleBinding{}
+		err = k8sClient.Get(ctx, types.NamespacedName{Namespace: product.Name, Name: "owners"}, rb)
+		Expect(err).Should(BeNil())
+		Expect(rb.Subjects[0].Name).Should(Equal(product.Spec.Name))
+
+		baseline, err = NewBaseline(`
+apiVersion: v1
+kind: ResourceQuota
+metadata:
+  name: quota
+spec:
+  hard:
+    pods: "20"
+`)
+		Expect(err).Should(BeNil())
+		syncInstance.(*ProductSyncer).Baseline = baseline
+
+		err = syncInstance.Sync(ctx, *product)
+		Expect(err).Should(BeNil())
+
+		err = k8sClient.Get(ctx, types.NamespacedName{Namespace: product.Name, Name: "quota"}, quota)
+		Expect(err).Should(BeNil())
+		Expect(quota.Spec.Hard.Pods().String()).Should(Equal("20"))
+
+		err = k8sClient.Get(ctx, types.NamespacedName{Namespace: product.Name, Name: "owners"}, rb)
+		Expect(apierrors.IsNotFound(err)).Should(BeTrue())
+
+		// the objects of a kind removed from the baseline are pruned as well
+		err = k8sClient.Get(ctx, types.NamespacedName{Namespace: product.Name, Name: "product-info"}, &corev1.ConfigMap{})
+		Expect(apierrors.IsNotFound(err)).Should(BeTrue())
+		ns := &corev1.Namespace{}
+		err = k8sClient.Get(ctx, types.NamespacedName{Name: product.Name}, ns)
+		Expect(err).Should(BeNil())
+		Expect(ns.Annotations[ANNOTATION_BASELINE_KINDS]).Should(Equal(`[{"Group":"","Version":"v1","Kind":"ResourceQuota"}]`))
+	})
+
+	It("get argocd app status", func() {
+		status, err := syncInstance.GetAppStatus(ctx, *product)
+		Expect(err).Should(BeNil())
+		Expect(status).Should(BeNil())
+
+		err = syncInstance.Sync(ctx, *product)
+		Expect(err).Should(BeNil())
+
+		app := &argocrd.Application{}
+		key := types.NamespacedName{
+			Name:      product.Name,
+			Namespace: "default",
+		}
+		err = k8sClient.Get(ctx, key, app)
+		Expect(err).Should(BeNil())
+		app.Status.Sync.Status = argocrd.SyncStatusCodeOutOfSync
+		app.Status.Health.Status = "Degraded"
+		app.Status.History = argocrd.RevisionHistories{{Revision: "abc"}}
+		app.Status.Conditions = []argocrd.ApplicationCondition{
+			{
+				Type:    argocrd.ApplicationConditionComparisonError,
+				Message: "repository not accessible",
+			},
+		}
+		err = k8sClient.Update(ctx, app)
+		Expect(err).Should(BeNil())
+
+		status, err = syncInstance.GetAppStatus(ctx, *product)
+		Expect(err).Should(BeNil())
+		Expect(status.Name).Should(Equal(product.Name))
+		Expect(status.SyncStatus).Should(Equal(string(argocrd.SyncStatusCodeOutOfSync)))
+		Expect(status.Health).Should(Equal("Degraded"))
+		Expect(status.Revision).Should(Equal("abc"))
+		Expect(len(status.Errors)).Should(Equal(1))
+	})
+
 	It("delete product", func() {
 		err := syncInstance.Sync(ctx, *product)
 		Expect(err).Should(BeNil())
 
-		// namespace can not be delete in envtest, it must return error
-		_ = syncInstance.Delete(ctx, *product)
+		phase, err := syncInstance.Delete(ctx, *product)
+		Expect(err).Should(BeNil())
+		Expect(phase).Should(Equal(baseinterface.DeletionPhaseDeletingApp))
+
+		// namespace can not be deleted in envtest, it stays in terminating
+		phase, err = syncInstance.Delete(ctx, *product)
+		Expect(err).Should(BeNil())
+		Expect(phase).Should(Equal(baseinterface.DeletionPhaseDeletingNamespace))
 
 		appList := &argocrd.ApplicationList{}
 		err = k8sClient.List(ctx, appList, selector)
@@ -18,14 +18,15 @@ import (
 	"context"
 	"fmt"
 	"strings"
-	"time"
 
 	argocrd "github.com/argoproj/argo-cd/v2/pkg/apis/application/v1alpha1"
+	"github.com/nautes-labs/base-operator/internal/events"
 	"github.com/nautes-labs/base-operator/internal/syncer/productprovider"
 	nautescrd "github.com/nautes-labs/pkg/api/v1alpha1"
 	nautescfg "github.com/nautes-labs/pkg/pkg/nautesconfigs"
 
 	baseinterface "github.com/nautes-labs/base-operator/pkg/interface"
+	"github.com/nautes-labs/base-operator/pkg/tracing"
 	"github.com/nautes-labs/base-operator/pkg/util"
 	nautesctx "github.com/nautes-labs/pkg/pkg/context"
 	corev1 "k8s.io/api/core/v1"
@@ -33,6 +34,7 @@ import (
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/kubernetes/scheme"
 	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/record"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 	"sigs.k8s.io/controller-runtime/pkg/log"
@@ -48,15 +50,16 @@ var (
 	kubernetesDefaultService = "https://kubernetes.default.svc"
 )
 
-type appInfo struct {
-	Name   string
-	Health string
-}
-
 type ProductSyncer struct {
 	client       client.Client
 	NautesConfig nautescfg.NautesConfigs
 	Rest         *rest.Config
+	// Baseline is the objects created in every product namespace, nothing is created if it is nil.
+	Baseline *Baseline
+	// AdoptionPolicy decides whether the existing namespace and argocd app of product can be taken over.
+	AdoptionPolicy AdoptionPolicy
+	// Recorder records the events of product, events are dropped if it is nil.
+	Recorder record.EventRecorder
 }
 
 func (s *ProductSyncer) Setup() error {
@@ -80,10 +83,17 @@ func (s *ProductSyncer) Setup() error {
 	}
 	s.client = k8sClient
 
+	if s.Recorder == nil {
+		s.Recorder = events.NewNopRecorder()
+	}
+
 	return nil
 }
 
-func (s *ProductSyncer) Sync(ctx context.Context, product nautescrd.Product) error {
+func (s *ProductSyncer) Sync(ctx context.Context, product nautescrd.Product) (err error) {
+	ctx, span := tracing.Start(ctx, "ProductSyncer.Sync", tracing.AttrProduct.String(product.Name))
+	defer func() { tracing.End(span, err) }()
+
 	label := map[string]string{nautescrd.LABEL_FROM_PRODUCT: product.Name}
 
 	cfg, err := s.NautesConfig.GetConfigByRest(s.Rest)
@@ -102,11 +112,16 @@ func (s *ProductSyncer) Sync(ctx context.Context, product nautescrd.Product) err
 	}
 
 	namespaceName := product.Name
-	err = s.syncNamespace(ctx, namespaceName, label)
+	err = s.syncNamespace(ctx, &product, namespaceName, label)
 	if err != nil {
 		return fmt.Errorf("sync namespace failed: %w", err)
 	}
 
+	err = s.syncBaseline(ctx, getBaselineValues(product, productID), label)
+	if err != nil {
+		return fmt.Errorf("sync namespace baseline failed: %w", err)
+	}
+
 	productProvider, err := productprovider.GetProvider(ctx, CONTEXT_KEY_NAUTES_CONFIG, s.client)
 	if err != nil {
 		return fmt.Errorf("get product provider failed: %w", err)
@@ -127,7 +142,7 @@ func (s *ProductSyncer) Sync(ctx context.Context, product nautescrd.Product) err
 
 	appName := product.Name
 	url := product.Spec.MetaDataPath
-	err = s.syncArgoApp(ctx, appName, namespaceName, url, label)
+	err = s.syncArgoApp(ctx, &product, appName, namespaceName, url, label)
 	if err != nil {
 		return fmt.Errorf("sync argocd app failed: %w", err)
 	}
@@ -135,26 +150,99 @@ func (s *ProductSyncer) Sync(ctx context.Context, product nautescrd.Product) err
 	return nil
 }
 
-func (s *ProductSyncer) Delete(ctx context.Context, product nautescrd.Product) error {
+// Delete removes the argocd app first and then the namespace of product.
+// The phase is always worked out from the resources still existing, so it is safe to call it again at any time.
+func (s *ProductSyncer) Delete(ctx context.Context, product nautescrd.Product) (phase baseinterface.DeletionPhase, err error) {
+	ctx, span := tracing.Start(ctx, "ProductSyncer.Delete", tracing.AttrProduct.String(product.Name))
+	defer func() {
+		span.SetAttributes(tracing.AttrDeletionPhase.String(string(phase)))
+		tracing.End(span, err)
+	}()
+
 	label := map[string]string{nautescrd.LABEL_FROM_PRODUCT: product.Name}
 
 	cfg, err := s.NautesConfig.GetConfigByRest(s.Rest)
 	if err != nil {
-		return err
+		return "", err
 	}
 	ctx = NewConfigContext(ctx, *cfg)
 
-	err = s.deleteArgoApp(ctx, label)
+	deleting, err := s.deleteArgoApp(ctx, label)
 	if err != nil {
-		return fmt.Errorf("delete argocd app failed: %w", err)
+		return baseinterface.DeletionPhaseDeletingApp, fmt.Errorf("delete argocd app failed: %w", err)
+	}
+	if deleting {
+		return baseinterface.DeletionPhaseDeletingApp, nil
 	}
 
-	err = s.deleteNamespace(ctx, label)
+	err = s.deleteBaseline(ctx, product.Name, label)
 	if err != nil {
-		return fmt.Errorf("delete namespace failed: %w", err)
+		return baseinterface.DeletionPhaseDeletingNamespace, fmt.Errorf("delete namespace baseline failed: %w", err)
 	}
 
-	return nil
+	deleting, err = s.deleteNamespace(ctx, label)
+	if err != nil {
+		return baseinterface.DeletionPhaseDeletingNamespace, fmt.Errorf("delete namespace failed: %w", err)
+	}
+	if deleting {
+		return baseinterface.DeletionPhaseDeletingNamespace, nil
+	}
+
+	return baseinterface.DeletionPhaseDone, nil
+}
+
+func (s *ProductSyncer) GetAppStatus(ctx context.Context, product nautescrd.Product) (*baseinterface.AppStatus, error) {
+	label := map[string]string{nautescrd.LABEL_FROM_PRODUCT: product.Name}
+
+	cfg, err := s.NautesConfig.GetConfigByRest(s.Rest)
+	if err != nil {
+		return nil, err
+	}
+
+	appList := &argocrd.ApplicationList{}
+	listOpts := []client.ListOption{
+		client.MatchingLabels(label),
+		client.InNamespace(cfg.Deploy.ArgoCD.Namespace),
+	}
+	if err := s.client.List(ctx, appList, listOpts...); err != nil {
+		return nil, err
+	}
+
+	switch num := len(appList.Items); num {
+	case 0:
+		return nil, nil
+	case 1:
+		return convertAppStatus(appList.Items[0]), nil
+	default:
+		return nil, fmt.Errorf("too many argocd apps")
+	}
+}
+
+func convertAppStatus(app argocrd.Application) *baseinterface.AppStatus {
+	status := &baseinterface.AppStatus{
+		Name:          app.Name,
+		SyncStatus:    string(app.Status.Sync.Status),
+		Health:        string(app.Status.Health.Status),
+		HealthMessage: app.Status.Health.Message,
+	}
+
+	if app.Status.OperationState != nil {
+		status.OperationPhase = string(app.Status.OperationState.Phase)
+		status.OperationMessage = app.Status.OperationState.Message
+	}
+
+	// History only records the successful syncs, the last one is the revision running now.
+	if num := len(app.Status.History); num != 0 {
+		status.Revision = app.Status.History[num-1].Revision
+	}
+
+	for i := range app.Status.Conditions {
+		if app.Status.Conditions[i].IsError() {
+			status.Errors = append(status.Errors, fmt.Sprintf("%s: %s", app.Status.Conditions[i].Type, app.Status.Conditions[i].Message))
+		}
+	}
+
+	return status
 }
 
 func (s *ProductSyncer) syncArgoProject(ctx context.Context, name string) error {
@@ -209,7 +297,7 @@ func (s *ProductSyncer) syncArgoProject(ctx context.Context, name string) error
 	return nil
 }
 
-func (s *ProductSyncer) syncArgoApp(ctx context.Context, name, destNamespace, url string, label map[string]string) error {
+func (s *ProductSyncer) syncArgoApp(ctx context.Context, product *nautescrd.Product, name, destNamespace, url string, label map[string]string) error {
 	cfg, err := FromConfigContext(ctx)
 	if err != nil {
 		return err
@@ -230,34 +318,49 @@ func (s *ProductSyncer) syncArgoApp(ctx context.Context, name, destNamespace, ur
 
 	switch num := len(appList.Items); num {
 	case 0:
-		app := &argocrd.Application{
+		spec := argocrd.ApplicationSpec{
+			Source: argocrd.ApplicationSource{
+				RepoURL:        url,
+				Path:           kustomizePath,
+				TargetRevision: "HEAD",
+			},
+			Destination: argocrd.ApplicationDestination{
+				Server:    kubernetesDefaultService,
+				Namespace: destNamespace,
+			},
+			Project: nautesProject,
+			SyncPolicy: &argocrd.SyncPolicy{
+				Automated: &argocrd.SyncPolicyAutomated{
+					Prune:    true,
+					SelfHeal: true,
+				},
+			},
+		}
+
+		app := &argocrd.Application{}
+		err := s.client.Get(ctx, types.NamespacedName{Namespace: namespace, Name: name}, app)
+		if err == nil {
+			return s.adoptArgoApp(ctx, product, app, spec, label)
+		}
+		if client.IgnoreNotFound(err) != nil {
+			return err
+		}
+
+		app = &argocrd.Application{
 			ObjectMeta: metav1.ObjectMeta{
 				Name:      name,
 				Namespace: namespace,
 				Labels:    label,
 			},
-			Spec: argocrd.ApplicationSpec{
-				Source: argocrd.ApplicationSource{
-					RepoURL:        url,
-					Path:           kustomizePath,
-					TargetRevision: "HEAD",
-				},
-				Destination: argocrd.ApplicationDestination{
-					Server:    kubernetesDefaultService,
-					Namespace: destNamespace,
-				},
-				Project: nautesProject,
-				SyncPolicy: &argocrd.SyncPolicy{
-					Automated: &argocrd.SyncPolicyAutomated{
-						Prune:    true,
-						SelfHeal: true,
-					},
-				},
-			},
+			Spec: spec,
 		}
 
 		log.FromContext(ctx).V(1).Info("create argocd app", "appName", app.Name)
-		return s.client.Create(ctx, app)
+		if err := s.client.Create(ctx, app); err != nil {
+			return err
+		}
+		s.Recorder.Eventf(product, corev1.EventTypeNormal, events.ReasonAppCreated, "argocd app %s/%s created", app.Namespace, app.Name)
+		return nil
 	case 1:
 		app := appList.Items[0]
 
@@ -278,10 +381,11 @@ func (s *ProductSyncer) syncArgoApp(ctx context.Context, name, destNamespace, ur
 	return nil
 }
 
-func (s *ProductSyncer) deleteArgoApp(ctx context.Context, label map[string]string) error {
+// deleteArgoApp sends the delete requests of the product apps, it returns true if any app still exists.
+func (s *ProductSyncer) deleteArgoApp(ctx context.Context, label map[string]string) (bool, error) {
 	cfg, err := FromConfigContext(ctx)
 	if err != nil {
-		return err
+		return false, err
 	}
 
 	namespace := cfg.Deploy.ArgoCD.Namespace
@@ -293,34 +397,25 @@ func (s *ProductSyncer) deleteArgoApp(ctx context.Context, label map[string]stri
 	}
 	err = s.client.List(ctx, appList, listOpts...)
 	if err != nil {
-		return err
+		return false, err
 	}
 
 	errList := []error{}
 	for _, app := range appList.Items {
+		if !app.DeletionTimestamp.IsZero() {
+			continue
+		}
 		log.FromContext(ctx).V(1).Info("delete argocd app", "AppName", app.Name)
 		err := s.client.Delete(ctx, &app)
-		if err != nil {
+		if client.IgnoreNotFound(err) != nil {
 			errList = append(errList, err)
 		}
 	}
 	if len(errList) != 0 {
-		return fmt.Errorf("%v", errList)
-	}
-
-	for i := 0; i < 2; i++ {
-		appList := &argocrd.ApplicationList{}
-		err := s.client.List(ctx, appList, listOpts...)
-		if err != nil {
-			return err
-		}
-		if len(appList.Items) == 0 {
-			return nil
-		}
-		time.Sleep(time.Second * 10)
+		return false, fmt.Errorf("%v", errList)
 	}
 
-	return fmt.Errorf("wait timeout exceeded")
+	return len(appList.Items) != 0, nil
 }
 
 func (s *ProductSyncer) syncCoderepo(ctx context.Context, name string, product nautescrd.Product, provider baseinterface.CodeRepoProvider, label map[string]string) error {
@@ -354,7 +449,7 @@ func (s *ProductSyncer) syncCoderepo(ctx context.Context, name string, product n
 	return err
 }
 
-func (s *ProductSyncer) syncNamespace(ctx context.Context, name string, label map[string]string) error {
+func (s *ProductSyncer) syncNamespace(ctx context.Context, product *nautescrd.Product, name string, label map[string]string) error {
 	labelSelector := client.MatchingLabels(label)
 
 	nsList := &corev1.NamespaceList{}
@@ -365,7 +460,16 @@ func (s *ProductSyncer) syncNamespace(ctx context.Context, name string, label ma
 
 	switch num := len(nsList.Items); num {
 	case 0:
-		ns := &corev1.Namespace{
+		ns := &corev1.Namespace{}
+		err := s.client.Get(ctx, types.NamespacedName{Name: name}, ns)
+		if err == nil {
+			return s.adoptNamespace(ctx, product, ns, label)
+		}
+		if client.IgnoreNotFound(err) != nil {
+			return err
+		}
+
+		ns = &corev1.Namespace{
 			ObjectMeta: metav1.ObjectMeta{
 				Name:   name,
 				Labels: label,
@@ -373,7 +477,11 @@ func (s *ProductSyncer) syncNamespace(ctx context.Context, name string, label ma
 		}
 
 		log.FromContext(ctx).V(1).Info("create namespace", "NamespaceName", ns.Name)
-		return s.client.Create(ctx, ns)
+		if err := s.client.Create(ctx, ns); err != nil {
+			return err
+		}
+		s.Recorder.Eventf(product, corev1.EventTypeNormal, events.ReasonNamespaceCreated, "namespace %s created", ns.Name)
+		return nil
 	case 1:
 		ns := nsList.Items[0]
 		if !ns.DeletionTimestamp.IsZero() {
@@ -386,39 +494,31 @@ func (s *ProductSyncer) syncNamespace(ctx context.Context, name string, label ma
 	return nil
 }
 
-func (s *ProductSyncer) deleteNamespace(ctx context.Context, label map[string]string) error {
+// deleteNamespace sends the delete requests of the product namespaces, it returns true if any namespace still exists.
+func (s *ProductSyncer) deleteNamespace(ctx context.Context, label map[string]string) (bool, error) {
 	labelSelector := client.MatchingLabels(label)
 	nsList := &corev1.NamespaceList{}
 	err := s.client.List(ctx, nsList, labelSelector)
 	if err != nil {
-		return err
+		return false, err
 	}
 
 	errList := []error{}
 	for _, ns := range nsList.Items {
+		if !ns.DeletionTimestamp.IsZero() {
+			continue
+		}
 		log.FromContext(ctx).V(1).Info("delete namespace", "NamespaceName", ns.Name)
 		err := s.client.Delete(ctx, &ns)
-		if err != nil {
+		if client.IgnoreNotFound(err) != nil {
 			errList = append(errList, err)
 		}
 	}
 	if len(errList) != 0 {
-		return fmt.Errorf("%v", errList)
-	}
-
-	for i := 0; i < 2; i++ {
-		nsList := &corev1.NamespaceList{}
-		err := s.client.List(ctx, nsList, labelSelector)
-		if err != nil {
-			return err
-		}
-		if len(nsList.Items) == 0 {
-			return nil
-		}
-		time.Sleep(time.Second * 5)
+		return false, fmt.Errorf("%v", errList)
 	}
 
-	return fmt.Errorf("wait timeout exceeded")
+	return len(nsList.Items) != 0, nil
 }
 
 func getProductID(name string) (string, error) {
@@ -18,13 +18,18 @@ import (
 	"fmt"
 
 	coderepoprovider "github.com/nautes-labs/base-operator/internal/coderepo/provider"
+	"github.com/nautes-labs/base-operator/internal/events"
 	baseinterface "github.com/nautes-labs/base-operator/pkg/interface"
+	"github.com/nautes-labs/base-operator/pkg/metrics"
+	"github.com/nautes-labs/base-operator/pkg/tracing"
 	nautescrd "github.com/nautes-labs/pkg/api/v1alpha1"
 	nautesctx "github.com/nautes-labs/pkg/pkg/context"
 	nautescfg "github.com/nautes-labs/pkg/pkg/nautesconfigs"
 	"golang.org/x/net/context"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/record"
 	"k8s.io/kubectl/pkg/scheme"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
@@ -45,6 +50,8 @@ type ProductProviderSyncer struct {
 	client       client.Client
 	NautesConfig nautescfg.NautesConfigs
 	Rest         *rest.Config
+	// Recorder records the events of product provider, events are dropped if it is nil.
+	Recorder record.EventRecorder
 }
 
 func (s *ProductProviderSyncer) Setup() error {
@@ -59,10 +66,17 @@ func (s *ProductProviderSyncer) Setup() error {
 	}
 	s.client = k8sClient
 
+	if s.Recorder == nil {
+		s.Recorder = events.NewNopRecorder()
+	}
+
 	return nil
 }
 
-func (s *ProductProviderSyncer) Sync(ctx context.Context, productProvider nautescrd.ProductProvider) error {
+func (s *ProductProviderSyncer) Sync(ctx context.Context, productProvider nautescrd.ProductProvider) (err error) {
+	ctx, span := tracing.Start(ctx, "ProductProviderSyncer.Sync", tracing.AttrProductProvider.String(productProvider.Name))
+	defer func() { tracing.End(span, err) }()
+
 	cfg, err := s.NautesConfig.GetConfigByRest(s.Rest)
 	if err != nil {
 		return fmt.Errorf("get nautes configs failed: %w", err)
@@ -97,7 +111,7 @@ func (s *ProductProviderSyncer) Sync(ctx context.Context, productProvider nautes
 	errs := []error{}
 	errs = append(errs, s.createProduct(ctx, newList, &productProvider)...)
 	errs = append(errs, s.updateProduct(ctx, updateList, &productProvider)...)
-	errs = append(errs, s.deleteProduct(ctx, deleteList)...)
+	errs = append(errs, s.deleteProduct(ctx, deleteList, &productProvider)...)
 	if len(errs) != 0 {
 		return fmt.Errorf("get error in sync product: %v", errs)
 	}
@@ -153,9 +167,12 @@ func (s *ProductProviderSyncer) createProduct(ctx context.Context, products []na
 		product.Namespace = provider.Namespace
 		product.Labels = label
 		err := s.client.Create(ctx, &product)
+		metrics.ProductsSynced.WithLabelValues(provider.Name, metrics.OperationCreate, metrics.Result(err)).Inc()
 		if err != nil {
 			errs = append(errs, err)
+			continue
 		}
+		s.Recorder.Eventf(provider, corev1.EventTypeNormal, events.ReasonProductCreated, "product %s (%s) created", product.Name, product.Spec.Name)
 	}
 
 	return errs
@@ -178,21 +195,27 @@ func (s *ProductProviderSyncer) updateProduct(ctx context.Context, products []na
 
 		tmp.Spec = *product.Spec.DeepCopy()
 		err = s.client.Update(ctx, tmp)
+		metrics.ProductsSynced.WithLabelValues(productProvier.Name, metrics.OperationUpdate, metrics.Result(err)).Inc()
 		if err != nil {
 			errs = append(errs, err)
+			continue
 		}
+		s.Recorder.Eventf(productProvier, corev1.EventTypeNormal, events.ReasonProductUpdated, "product %s (%s) updated", product.Name, product.Spec.Name)
 	}
 
 	return errs
 }
 
-func (s *ProductProviderSyncer) deleteProduct(ctx context.Context, products []nautescrd.Product) []error {
+func (s *ProductProviderSyncer) deleteProduct(ctx context.Context, products []nautescrd.Product, provider *nautescrd.ProductProvider) []error {
 	errs := []error{}
 	for _, product := range products {
 		err := s.client.Delete(ctx, &product)
+		metrics.ProductsSynced.WithLabelValues(provider.Name, metrics.OperationDelete, metrics.Result(err)).Inc()
 		if err != nil {
 			errs = append(errs, err)
+			continue
 		}
+		s.Recorder.Eventf(provider, corev1.EventTypeNormal, events.ReasonProductDeleted, "product %s (%s) deleted", product.Name, product.Spec.Name)
 	}
 
 	return errs
@@ -15,8 +15,10 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"os"
+	"time"
 
 	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
 	// to ensure that exec-entrypoint and run can make use of them.
@@ -27,9 +29,12 @@ import (
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 
+	argocrd "github.com/argoproj/argo-cd/v2/pkg/apis/application/v1alpha1"
 	nautescfg "github.com/nautes-labs/pkg/pkg/nautesconfigs"
 
 	productsyncer "github.com/nautes-labs/base-operator/internal/syncer/product"
@@ -37,12 +42,17 @@ import (
 
 	nautesv1alpha1 "github.com/nautes-labs/pkg/api/v1alpha1"
 
+	baseoperatorv1alpha1 "github.com/nautes-labs/base-operator/api/v1alpha1"
 	"github.com/nautes-labs/base-operator/controllers"
+	"github.com/nautes-labs/base-operator/pkg/secret_provider"
+	"github.com/nautes-labs/base-operator/pkg/store"
+	"github.com/nautes-labs/base-operator/pkg/tracing"
 	//+kubebuilder:scaffold:imports
 )
 
 const (
-	secretPath = "/base-operator/secret/certification-info"
+	secretPath             = "/base-operator/secret/certification-info"
+	tracingShutdownTimeout = 5 * time.Second
 )
 
 var (
@@ -54,6 +64,7 @@ func init() {
 	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
 
 	utilruntime.Must(nautesv1alpha1.AddToScheme(scheme))
+	utilruntime.Must(argocrd.AddToScheme(scheme))
 	//+kubebuilder:scaffold:scheme
 }
 
@@ -63,9 +74,19 @@ func main() {
 	var probeAddr string
 	var globalConfigName string
 	var globalConfigNamespace string
+	var namespaceBaselinePath string
+	var adoptionPolicy string
+	var tracingEndpoint string
+	var tracingSampleRatio float64
+	var enableBaseDataSync bool
 	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8080", "The address the metric endpoint binds to.")
 	flag.StringVar(&globalConfigName, "global-config-name", "nautes-configs", "The resources name of global config.")
 	flag.StringVar(&globalConfigNamespace, "global-config-namespace", "nautes", "The namespace of global config in.")
+	flag.StringVar(&namespaceBaselinePath, "namespace-baseline-path", "", "The file of objects created in every product namespace, such as ResourceQuota and NetworkPolicy.")
+	flag.StringVar(&adoptionPolicy, "adoption-policy", "refuse", "What to do with the existing namespace and argocd app of a product, one of adopt-if-empty, adopt-always and refuse.")
+	flag.StringVar(&tracingEndpoint, "tracing-endpoint", "", "The OTLP/HTTP endpoint traces are exported to, such as http://otel-collector:4318. Tracing is disabled if it is empty.")
+	flag.Float64Var(&tracingSampleRatio, "tracing-sample-ratio", 1, "The ratio of traces to be sampled, from 0 to 1.")
+	flag.BoolVar(&enableBaseDataSync, "enable-base-data-sync", false, "Synchronize the users, groups and projects of BaseDataSyncConfigs, it requires the secret file of the applications.")
 	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8081", "The address the probe endpoint binds to.")
 	flag.BoolVar(&enableLeaderElection, "leader-elect", false,
 		"Enable leader election for controller manager. "+
@@ -78,12 +99,14 @@ func main() {
 
 	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&opts)))
 
-	// init secret provider
-	// secretProvider, err := secret_provider.NewSecretProvider(secretPath)
-	// if err != nil {
-	// 	setupLog.Error(err, "unable to load the secret provider")
-	// 	os.Exit(1)
-	// }
+	shutdownTracing, err := tracing.Setup(tracing.Options{
+		Endpoint:    tracingEndpoint,
+		SampleRatio: tracingSampleRatio,
+	})
+	if err != nil {
+		setupLog.Error(err, "unable to set up tracing")
+		os.Exit(1)
+	}
 
 	// k8sCfg := ctrl.GetConfigOrDie()
 	// // create manager
@@ -94,12 +117,56 @@ func main() {
 	// 	os.Exit(1)
 	// }
 
-	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
+	cfg := nautescfg.NautesConfigs{
+		Namespace: globalConfigNamespace,
+		Name:      globalConfigName,
+	}
+
+	restCfg := ctrl.GetConfigOrDie()
+
+	// Product controller watches argocd apps, they are not in the namespace of nautes.
+	// The namespace of argocd is read once, the operator has to be restarted when deploy.argocd.namespace is changed.
+	// The status of the apps is not mirrored to the products if the config can not be read at startup.
+	cacheNamespaces := []string{globalConfigNamespace}
+	nautesCfg, err := cfg.GetConfigByRest(restCfg)
+	if err != nil {
+		setupLog.Error(err, "unable to load nautes config, the argocd apps are not watched")
+	} else if argocdNamespace := nautesCfg.Deploy.ArgoCD.Namespace; argocdNamespace != "" && argocdNamespace != globalConfigNamespace {
+		cacheNamespaces = append(cacheNamespaces, argocdNamespace)
+	}
+
+	// The providers of nautes pkg and base operator share the same kinds, the manager only registers the configs of base operator,
+	// the mapping store and the providers referenced by the configs use a client of the base operator scheme.
+	var secretProvider *secret_provider.SecretProvider
+	var baseClient client.Client
+	if enableBaseDataSync {
+		secretProvider, err = secret_provider.NewSecretProvider(secretPath)
+		if err != nil {
+			setupLog.Error(err, "unable to load the secret provider")
+			os.Exit(1)
+		}
+		// AddToScheme can not be used on the manager scheme, it also registers ArtifactRepoProvider and CodeRepoProvider,
+		// whose group, version and kind are already registered by nautes pkg in init, and the scheme panics on a kind
+		// registered with another go type. Only the config kinds are added, the manager has to watch them.
+		scheme.AddKnownTypes(baseoperatorv1alpha1.GroupVersion, &baseoperatorv1alpha1.BaseDataSyncConfig{}, &baseoperatorv1alpha1.BaseDataSyncConfigList{})
+		baseScheme := runtime.NewScheme()
+		utilruntime.Must(clientgoscheme.AddToScheme(baseScheme))
+		utilruntime.Must(baseoperatorv1alpha1.AddToScheme(baseScheme))
+		// The mappings are read from the api server directly, the cached client may return stale config maps.
+		baseClient, err = client.New(restCfg, client.Options{Scheme: baseScheme})
+		if err != nil {
+			setupLog.Error(err, "unable to create the client of base data sync")
+			os.Exit(1)
+		}
+	}
+
+	mgr, err := ctrl.NewManager(restCfg, ctrl.Options{
 		Scheme:                 scheme,
 		MetricsBindAddress:     metricsAddr,
 		Port:                   9443,
 		HealthProbeBindAddress: probeAddr,
 		Namespace:              globalConfigNamespace,
+		NewCache:               cache.MultiNamespacedCacheBuilder(cacheNamespaces),
 		LeaderElection:         enableLeaderElection,
 		LeaderElectionID:       "0afe6787.resource.nautes.io",
 	})
@@ -108,23 +175,34 @@ func main() {
 		os.Exit(1)
 	}
 
-	cfg := nautescfg.NautesConfigs{
-		Namespace: globalConfigNamespace,
-		Name:      globalConfigName,
-	}
-
 	providerSyncer := &productprovidersyncer.ProductProviderSyncer{
 		NautesConfig: cfg,
 		Rest:         mgr.GetConfig(),
+		Recorder:     mgr.GetEventRecorderFor("productprovider-controller"),
 	}
 	if err := providerSyncer.Setup(); err != nil {
 		setupLog.Error(err, "init product provider syncer failed")
 		os.Exit(1)
 	}
 
+	policy, err := productsyncer.ParseAdoptionPolicy(adoptionPolicy)
+	if err != nil {
+		setupLog.Error(err, "invalid adoption policy")
+		os.Exit(1)
+	}
+
 	syncer := &productsyncer.ProductSyncer{
-		NautesConfig: cfg,
-		Rest:         mgr.GetConfig(),
+		NautesConfig:   cfg,
+		Rest:           mgr.GetConfig(),
+		AdoptionPolicy: policy,
+		Recorder:       mgr.GetEventRecorderFor("product-controller"),
+	}
+	if namespaceBaselinePath != "" {
+		syncer.Baseline, err = productsyncer.LoadBaseline(namespaceBaselinePath)
+		if err != nil {
+			setupLog.Error(err, "load namespace baseline failed")
+			os.Exit(1)
+		}
 	}
 
 	if err := syncer.Setup(); err != nil {
@@ -133,34 +211,41 @@ func main() {
 	}
 
 	if err = (&controllers.ProductProviderReconciler{
-		Client: mgr.GetClient(),
-		Scheme: mgr.GetScheme(),
-		Syncer: providerSyncer,
+		Client:   mgr.GetClient(),
+		Scheme:   mgr.GetScheme(),
+		Syncer:   providerSyncer,
+		Recorder: mgr.GetEventRecorderFor("productprovider-controller"),
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "ProductoProvider")
 		os.Exit(1)
 	}
 
 	if err = (&controllers.ProductReconciler{
-		Client: mgr.GetClient(),
-		Scheme: mgr.GetScheme(),
-		Syncer: syncer,
+		Client:    mgr.GetClient(),
+		Scheme:    mgr.GetScheme(),
+		Syncer:    syncer,
+		Recorder:  mgr.GetEventRecorderFor("product-controller"),
+		Namespace: globalConfigNamespace,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "Product")
 		os.Exit(1)
 	}
 
+	if enableBaseDataSync {
+		if err = (&controllers.BaseDataSyncConfigReconciler{
+			Client:         mgr.GetClient(),
+			Scheme:         mgr.GetScheme(),
+			SecretProvider: secretProvider,
+			Store:          store.NewConfigMapStore(baseClient, globalConfigNamespace),
+			RefClient:      baseClient,
+		}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "BaseDataSyncConfig")
+			os.Exit(1)
+		}
+	}
+
 	// The following controller is not implemented.
 
-	// // manager with controller
-	// if err = (&controllers.BaseDataSyncConfigReconciler{
-	// 	Client:         mgr.GetClient(),
-	// 	Scheme:         mgr.GetScheme(),
-	// 	SecretProvider: secretProvider,
-	// }).SetupWithManager(mgr); err != nil {
-	// 	setupLog.Error(err, "unable to create controller", "controller", "BaseDataSyncConfig")
-	// 	os.Exit(1)
-	// }
 	// if err = (&controllers.ArtifactRepoProviderReconciler{
 	// 	Client: mgr.GetClient(),
 	// 	Scheme: mgr.GetScheme(),
@@ -170,8 +255,9 @@ func main() {
 	// }
 
 	if err = (&controllers.ClusterReconciler{
-		Client: mgr.GetClient(),
-		Scheme: mgr.GetScheme(),
+		Client:   mgr.GetClient(),
+		Scheme:   mgr.GetScheme(),
+		Recorder: mgr.GetEventRecorderFor("cluster-controller"),
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "Cluster")
 		os.Exit(1)
@@ -188,7 +274,15 @@ func main() {
 	}
 
 	setupLog.Info("starting manager")
-	if err := mgr.Start(ctrl.SetupSignalHandler()); err != nil {
+	err = mgr.Start(ctrl.SetupSignalHandler())
+
+	ctx, cancel := context.WithTimeout(context.Background(), tracingShutdownTimeout)
+	defer cancel()
+	if tracingErr := shutdownTracing(ctx); tracingErr != nil {
+		setupLog.Error(tracingErr, "unable to flush traces")
+	}
+
+	if err != nil {
 		setupLog.Error(err, "problem running manager")
 		os.Exit(1)
 	}
@@ -17,11 +17,17 @@ package convert2idp
 // read gitlab data convert to idp struct
 
 import (
+	"strings"
+
 	"github.com/nautes-labs/base-operator/pkg/schema"
 	"github.com/spf13/cast"
 	"github.com/xanzy/go-gitlab"
 )
 
+const (
+	gitlabUserStateBanned = "banned"
+)
+
 type Gitlab2IdpConverter struct {
 }
 
@@ -41,10 +47,28 @@ func (*Gitlab2IdpConverter) ToIdpUser(gitlabUser *gitlab.User) *schema.User {
 		AvatarURL:   gitlabUser.AvatarURL,
 		Mobile:      "",
 		NamespaceId: cast.ToString(gitlabUser.NamespaceID),
+		State:       gitlabUserState(gitlabUser),
+		External:    gitlabUser.External,
 	}
 	return user
 }
 
+// gitlabUserState converts the state of the gitlab user, such as blocked, ldap_blocked and blocked_pending_approval.
+// The banned users are reported as blocked as they cannot sign in either.
+// The active bots are reported as bot, the blocked ones are reported as blocked like the others.
+func gitlabUserState(gitlabUser *gitlab.User) string {
+	switch {
+	case gitlabUser.State == schema.UserStateDeactivated:
+		return schema.UserStateDeactivated
+	case strings.Contains(gitlabUser.State, schema.UserStateBlocked), gitlabUser.State == gitlabUserStateBanned:
+		return schema.UserStateBlocked
+	case gitlabUser.Bot:
+		return schema.UserStateBot
+	default:
+		return schema.UserStateActive
+	}
+}
+
 func (*Gitlab2IdpConverter) ToIdpGroup(gitlabGroup *gitlab.Group, kind string) *schema.Group {
 	group := &schema.Group{
 		BaseEntity: schema.BaseEntity{
@@ -53,6 +77,7 @@ func (*Gitlab2IdpConverter) ToIdpGroup(gitlabGroup *gitlab.Group, kind string) *
 			Description: gitlabGroup.Description,
 		},
 		Kind:     kind,
+		FullPath: gitlabGroup.FullPath,
 		ChildIds: make([]string, 0),
 	}
 	if gitlabGroup.ParentID > 0 {
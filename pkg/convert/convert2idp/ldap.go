@@ -0,0 +1,97 @@
+// Copyright 2023 Nautes Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package convert2idp
+
+// read ldap entries convert to idp struct
+
+import (
+	"strings"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/nautes-labs/base-operator/pkg/schema"
+)
+
+const (
+	// LdapIdentityAttribute is the attribute used as the identity of users and groups, it does not change when the entry is renamed.
+	LdapIdentityAttribute = "entryUUID"
+)
+
+var (
+	// LdapUserAttributes are the attributes of the users read from ldap.
+	LdapUserAttributes = []string{LdapIdentityAttribute, "uid", "cn", "displayName", "mail", "mobile", "description", "pwdAccountLockedTime", "nsAccountLock"}
+	// LdapGroupAttributes are the attributes of the groups read from ldap, the members are either users or groups.
+	LdapGroupAttributes = []string{LdapIdentityAttribute, "cn", "description", "member", "uniqueMember"}
+)
+
+type Ldap2IdpConverter struct {
+}
+
+func NewLdap2IdpConverter() *Ldap2IdpConverter {
+	return &Ldap2IdpConverter{}
+}
+
+func (*Ldap2IdpConverter) ToIdpUser(entry *ldap.Entry) *schema.User {
+	name := entry.GetAttributeValue("displayName")
+	if name == "" {
+		name = entry.GetAttributeValue("cn")
+	}
+	identity := entry.GetAttributeValue(LdapIdentityAttribute)
+	user := &schema.User{
+		BaseEntity: schema.BaseEntity{
+			Identity:    identity,
+			Name:        name,
+			Description: entry.GetAttributeValue("description"),
+		},
+		Username:    entry.GetAttributeValue("uid"),
+		Email:       entry.GetAttributeValue("mail"),
+		Mobile:      entry.GetAttributeValue("mobile"),
+		NamespaceId: identity,
+		State:       ldapUserState(entry),
+	}
+	return user
+}
+
+// ldapUserState reports the users locked by the password policy of openldap or by the account lock of 389-ds as blocked.
+func ldapUserState(entry *ldap.Entry) string {
+	if entry.GetAttributeValue("pwdAccountLockedTime") != "" || strings.EqualFold(entry.GetAttributeValue("nsAccountLock"), "true") {
+		return schema.UserStateBlocked
+	}
+	return schema.UserStateActive
+}
+
+// ToIdpGroup converts the group entry, the parent and the full path are set by the idp as they depend on the other groups.
+func (*Ldap2IdpConverter) ToIdpGroup(entry *ldap.Entry, kind string) *schema.Group {
+	group := &schema.Group{
+		BaseEntity: schema.BaseEntity{
+			Identity:    entry.GetAttributeValue(LdapIdentityAttribute),
+			Name:        entry.GetAttributeValue("cn"),
+			Description: entry.GetAttributeValue("description"),
+		},
+		Kind:     kind,
+		ChildIds: make([]string, 0),
+	}
+	return group
+}
+
+// LdapGroupMemberDNs returns the dns of the members of the group entry.
+func LdapGroupMemberDNs(entry *ldap.Entry) []string {
+	dns := append([]string{}, entry.GetAttributeValues("member")...)
+	for _, value := range entry.GetAttributeValues("uniqueMember") {
+		// the optional uid of uniqueMember is appended as "#'0101'B"
+		dn, _, _ := strings.Cut(value, "#'")
+		dns = append(dns, dn)
+	}
+	return dns
+}
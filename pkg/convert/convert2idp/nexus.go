@@ -29,74 +29,78 @@ func NewNexus2IdpConverter() *Nexus2IdpConverter {
 	return &Nexus2IdpConverter{}
 }
 
+// ToIdpUser converts the nexus user to a user, it reverses Idp2NexusConverter.IdpUser2NexusUser.
+// The disabled users are reported as blocked, as nexus does not tell the blocked and deactivated users apart.
+// Nexus reports the status in lower case, but the status is compared case-insensitively in case of the older versions.
 func (*Nexus2IdpConverter) ToIdpUser(nexusUser *security.User) *schema.User {
 	user := &schema.User{
 		BaseEntity: schema.BaseEntity{
 			Identity:    nexusUser.UserID,
-			Name:        nexusUser.LastName,
+			Name:        schema.JoinName(nexusUser.FirstName, nexusUser.LastName),
 			Description: "",
 		},
-		Username:  nexusUser.FirstName,
 		Email:     nexusUser.EmailAddress,
 		AvatarURL: "",
 		Mobile:    "",
 		RoleIds:   nexusUser.Roles,
+		State:     schema.UserStateActive,
+	}
+	if strings.EqualFold(nexusUser.Status, security.UserStatusDisabled) {
+		user.State = schema.UserStateBlocked
 	}
 	return user
 }
 
-func (*Nexus2IdpConverter) RoleToGroup(idpKind string, idpName string, role *security.Role) *schema.Group {
-	knri := schema.StringToKNRI(role.ID)
-	if knri.IsEmpty() {
-		return nil
+// RoleOwnership returns the description without the ownership marker and the ownership of the role,
+// the ownership is inferred from the role id if the role was created before the markers were recorded.
+func (*Nexus2IdpConverter) RoleOwnership(role *security.Role) (string, schema.Ownership) {
+	description, ownership := schema.ParseOwnership(role.Description)
+	if ownership.IsEmpty() {
+		ownership = schema.OwnershipOfIdentity(role.ID)
 	}
-	if knri.Kind != idpKind {
-		return nil
-	}
-	if knri.Name != idpName {
+	return description, ownership
+}
+
+func (c *Nexus2IdpConverter) RoleToGroup(idpKind string, idpName string, role *security.Role) *schema.Group {
+	description, ownership := c.RoleOwnership(role)
+	if !ownership.IsOwnedBy(idpKind, idpName) {
 		return nil
 	}
-	if knri.RoleKind != schema.NamespaceUser && knri.RoleKind != schema.NamespaceGroup {
+	if ownership.Kind != schema.NamespaceUser && ownership.Kind != schema.NamespaceGroup {
 		return nil
 	}
 	group := &schema.Group{
 		BaseEntity: schema.BaseEntity{
 			Identity:    role.ID,
 			Name:        role.Name,
-			Description: role.Description,
+			Description: description,
 		},
-		Kind:     knri.RoleKind,
+		Kind:     ownership.Kind,
 		ChildIds: role.Roles,
 	}
 	return group
 }
 
-func (*Nexus2IdpConverter) RolesToGroups(idpKind string, idpName string, roles []*security.Role) []*schema.Group {
+func (c *Nexus2IdpConverter) RolesToGroups(idpKind string, idpName string, roles []*security.Role) []*schema.Group {
 	//existParentRoleIdMapping := make(map[string]string, len(roles))
 	result := make([]*schema.Group, 0, len(roles))
 	for _, role := range roles {
 		// filter only group role and user namespace
-		knri := schema.StringToKNRI(role.ID)
-		if knri.IsEmpty() {
-			continue
-		}
-		if knri.Kind != idpKind {
-			continue
-		}
-		if knri.Name != idpName {
+		description, ownership := c.RoleOwnership(role)
+		if !ownership.IsOwnedBy(idpKind, idpName) {
 			continue
 		}
-		if knri.RoleKind != schema.NamespaceUser && knri.RoleKind != schema.NamespaceGroup {
+		if ownership.Kind != schema.NamespaceUser && ownership.Kind != schema.NamespaceGroup {
 			continue
 		}
 		baseEnt := schema.BaseEntity{
 			Identity:    role.ID,
 			Name:        role.Name,
-			Description: role.Description,
+			Description: description,
 		}
 		g := &schema.Group{
 			BaseEntity: baseEnt,
-			Kind:       knri.RoleKind,
+			Kind:       ownership.Kind,
 		}
 		if role.Roles == nil {
 			role.Roles = make([]string, 0)
@@ -117,27 +121,21 @@ func (*Nexus2IdpConverter) RolesToGroups(idpKind string, idpName string, roles [
 	return result
 }
 
-func (*Nexus2IdpConverter) ToNormalRoles(idpKind string, idpName string, roles []*security.Role) []*schema.Group {
+func (c *Nexus2IdpConverter) ToNormalRoles(idpKind string, idpName string, roles []*security.Role) []*schema.Group {
 	result := make([]*schema.Group, 0, len(roles))
 	for _, role := range roles {
-		knri := schema.StringToKNRI(role.ID)
-		if knri.IsEmpty() {
-			continue
-		}
-		if knri.Kind != idpKind {
-			continue
-		}
-		if knri.Name != idpName {
+		description, ownership := c.RoleOwnership(role)
+		if !ownership.IsOwnedBy(idpKind, idpName) {
 			continue
 		}
 		baseEnt := schema.BaseEntity{
 			Identity:    role.ID,
 			Name:        role.Name,
-			Description: role.Description,
+			Description: description,
 		}
 		g := &schema.Group{
 			BaseEntity: baseEnt,
-			Kind:       knri.RoleKind,
+			Kind:       ownership.Kind,
 		}
 		if role.Roles == nil {
 			role.Roles = make([]string, 0)
@@ -151,41 +149,33 @@ func (*Nexus2IdpConverter) ToNormalRoles(idpKind string, idpName string, roles [
 func (r *Nexus2IdpConverter) RolesToProjects(idpKind string, idpName string, roles []*security.Role) []*schema.Project {
 	groups := r.RolesToGroups(idpKind, idpName, roles)
 	mapping := make(map[string]string, len(groups))
+	groupKinds := make(map[string]string, len(groups))
 	for _, group := range groups {
+		groupKinds[group.Identity] = group.Kind
 		for _, childId := range group.ChildIds {
 			mapping[childId] = group.Identity
 		}
 	}
 	result := make([]*schema.Project, 0, len(roles))
 	for _, role := range roles {
-		projectKnri := schema.StringToKNRI(role.ID)
-		if projectKnri.IsEmpty() {
-			continue
-		}
-		if projectKnri.Kind != idpKind {
-			continue
-		}
-		if projectKnri.Name != idpName {
+		description, ownership := r.RoleOwnership(role)
+		if !ownership.IsOwnedBy(idpKind, idpName) {
 			continue
 		}
-		if projectKnri.RoleKind != schema.NamespaceProject {
+		if ownership.Kind != schema.NamespaceProject {
 			continue
 		}
 		item := &schema.Project{
 			BaseEntity: schema.BaseEntity{
 				Identity:    role.ID,
 				Name:        role.Name,
-				Description: role.Description,
+				Description: description,
 			},
 		}
 		if gId, ok := mapping[role.ID]; ok {
-			groupKnri := schema.StringToKNRI(gId)
-			if groupKnri.IsEmpty() {
-				continue
-			}
 			item.Namespace = &schema.ProjectNamespace{
 				Identity: gId,
-				Kind:     groupKnri.RoleKind,
+				Kind:     groupKinds[gId],
 			}
 			if pGid, ok := mapping[gId]; ok {
 				item.Namespace.ParentId = pGid
@@ -230,7 +220,7 @@ func (*Nexus2IdpConverter) ToIdpProject(roles []*security.Role) []*schema.Projec
 func (*Nexus2IdpConverter) ToIdpGroupMember(nexusUser *security.User) []*schema.GroupMember {
 	groupMembers := make([]*schema.GroupMember, 0)
 	for _, roleId := range nexusUser.Roles {
-		if !strings.Contains(roleId, "group") {
+		if schema.OwnershipOfIdentity(roleId).Kind != schema.NamespaceGroup {
 			continue
 		}
 		groupMember := &schema.GroupMember{
@@ -246,7 +236,7 @@ func (*Nexus2IdpConverter) ToIdpGroupMember(nexusUser *security.User) []*schema.
 func (*Nexus2IdpConverter) ToIdpProjectMember(nexusUser *security.User) []*schema.ProjectMember {
 	projectMembers := make([]*schema.ProjectMember, 0)
 	for _, roleId := range nexusUser.Roles {
-		if !strings.Contains(roleId, "project") {
+		if schema.OwnershipOfIdentity(roleId).Kind != schema.NamespaceProject {
 			continue
 		}
 		projectMember := &schema.ProjectMember{
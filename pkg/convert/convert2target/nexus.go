@@ -26,12 +26,19 @@ func NewIdp2NexusConverter() *Idp2NexusConverter {
 	return &Idp2NexusConverter{}
 }
 
+// IdpUser2NexusUser converts the user to a nexus user, the name is split into the first name and the last name.
+// Nexus2IdpConverter.ToIdpUser reverses it except the fields nexus does not keep, such as the username and avatar.
 func (*Idp2NexusConverter) IdpUser2NexusUser(identity string, user *schema.User, roleIds []string) *security.User {
+	firstName, lastName := schema.SplitName(user.Name)
 	u := &security.User{
 		UserID:       identity,
-		FirstName:    user.Username,
-		LastName:     user.Name,
+		FirstName:    firstName,
+		LastName:     lastName,
 		EmailAddress: user.Email,
+		Status:       security.UserStatusActive,
+	}
+	if user.IsDisabled() {
+		u.Status = security.UserStatusDisabled
 	}
 	if len(roleIds) > 0 {
 		u.Roles = roleIds
@@ -39,20 +46,22 @@ func (*Idp2NexusConverter) IdpUser2NexusUser(identity string, user *schema.User,
 	return u
 }
 
-func (*Idp2NexusConverter) IdpGroup2NexusRole(identity string, group *schema.Group) *security.Role {
+// IdpGroup2NexusRole converts the group to a role, the ownership is recorded as a marker in the description.
+func (*Idp2NexusConverter) IdpGroup2NexusRole(ownership schema.Ownership, identity string, group *schema.Group) *security.Role {
 	role := &security.Role{
 		ID:          identity,
 		Name:        group.Name,
-		Description: group.Description,
+		Description: ownership.Mark(group.Description),
 	}
 	return role
 }
 
-func (*Idp2NexusConverter) IdpProject2NexusRole(identity string, project *schema.Project) *security.Role {
+// IdpProject2NexusRole converts the project to a role, the ownership is recorded as a marker in the description.
+func (*Idp2NexusConverter) IdpProject2NexusRole(ownership schema.Ownership, identity string, project *schema.Project) *security.Role {
 	role := &security.Role{
 		ID:          identity,
 		Name:        project.Name,
-		Description: project.Description,
+		Description: ownership.Mark(project.Description),
 	}
 	return role
 }
@@ -18,6 +18,7 @@ type IdpKind string
 
 const (
 	GitlabIdpKind IdpKind = "gitlab"
+	LdapIdpKind   IdpKind = "ldap"
 )
 
 func (i IdpKind) Tostring() string {
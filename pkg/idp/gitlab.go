@@ -16,16 +16,22 @@ package idp
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"math"
+	"net/http"
 	"sync"
+	"time"
 
 	"github.com/hashicorp/go-multierror"
 	"github.com/nautes-labs/base-operator/pkg/convert/convert2idp"
+	"github.com/nautes-labs/base-operator/pkg/metrics"
 	"github.com/nautes-labs/base-operator/pkg/schema"
 	"github.com/nautes-labs/base-operator/pkg/secret_provider"
+	"github.com/nautes-labs/base-operator/pkg/tracing"
 	"github.com/spf13/cast"
 	"github.com/xanzy/go-gitlab"
+	"golang.org/x/sync/errgroup"
 )
 
 const (
@@ -33,6 +39,8 @@ const (
 	gitlabGroupPageSize       = 20
 	gitlabProjectPageSize     = 20
 	gitlabGroupMemberPageSize = 20
+	// "/api/v4/groups/:id/members"
+	gitlabEndpointSegments = 5
 )
 
 var _ Idp = (*gitlabIdp)(nil)
@@ -46,6 +54,10 @@ type gitlabIdp struct {
 	users          []*schema.User
 	groups         []*schema.Group
 	projects       []*schema.Project
+	options        ClientOptions
+	pool           *requestPool
+	scope          Scope
+	groupsLock     sync.Mutex
 }
 
 func (g *gitlabIdp) Kind() IdpKind {
@@ -71,55 +83,34 @@ func (g *gitlabIdp) SetSecretProvider(provider *secret_provider.SecretProvider)
 	return
 }
 
+func (g *gitlabIdp) SetClientOptions(opts ClientOptions) {
+	g.options = opts
+	g.client = nil
+	g.pool = nil
+	return
+}
+
+func (g *gitlabIdp) SetScope(scope Scope) {
+	g.scope = scope
+	g.groups = nil
+	g.projects = nil
+	return
+}
+
 func (g *gitlabIdp) GetUsers(ctx context.Context) ([]*schema.User, error) {
 	err := g.newClient()
 	if err != nil {
 		return nil, fmt.Errorf("init gitlab client fail, err:【%w】", err)
 	}
-	page := 1
-	opts := &gitlab.ListUsersOptions{
-		ListOptions: gitlab.ListOptions{Page: page, PerPage: gitlabUserPageSize},
-	}
-	list, rsp, err := g.client.Users.ListUsers(opts)
+	gitlabUsers, err := listAllPages(ctx, g.pool, gitlabUserPageSize, func(ctx context.Context, page int) ([]*gitlab.User, *gitlab.Response, error) {
+		opts := &gitlab.ListUsersOptions{
+			ListOptions: gitlab.ListOptions{Page: page, PerPage: gitlabUserPageSize},
+		}
+		return g.client.Users.ListUsers(opts, gitlab.WithContext(ctx))
+	})
 	if err != nil {
 		return nil, err
 	}
-	totalCount := rsp.TotalItems
-	gitlabUsers := make([]*gitlab.User, 0)
-	for _, user := range list {
-		gitlabUsers = append(gitlabUsers, user)
-	}
-	totalPage := cast.ToInt(math.Ceil(cast.ToFloat64(totalCount) / cast.ToFloat64(gitlabUserPageSize)))
-	doChan := make(chan interface{}, 1)
-	wg := sync.WaitGroup{}
-	for page = 2; page <= totalPage; page++ {
-		wg.Add(1)
-		go func(page int) {
-			defer wg.Done()
-			opts := &gitlab.ListUsersOptions{
-				ListOptions: gitlab.ListOptions{Page: page, PerPage: gitlabUserPageSize},
-			}
-			list, _, err := g.client.Users.ListUsers(opts)
-			if err != nil {
-				doChan <- err
-				return
-			}
-			doChan <- list
-			return
-		}(page)
-	}
-	go func() {
-		defer close(doChan)
-		wg.Wait()
-	}()
-	for item := range doChan {
-		switch assertValue := item.(type) {
-		case error:
-			return nil, assertValue
-		case []*gitlab.User:
-			gitlabUsers = append(gitlabUsers, assertValue...)
-		}
-	}
 	result := make([]*schema.User, 0, len(gitlabUsers))
 	for _, gitlabUser := range gitlabUsers {
 		item := g.converter.ToIdpUser(gitlabUser)
@@ -138,7 +129,10 @@ func (g *gitlabIdp) GetStaticUserById(id string) (*schema.User, error) {
 	return nil, fmt.Errorf("user not found, id:%s", id)
 }
 
+// GetGroups lists the groups in the scope, the groups are listed once as the projects in the scope are read by them.
 func (g *gitlabIdp) GetGroups(ctx context.Context) ([]*schema.Group, error) {
+	g.groupsLock.Lock()
+	defer g.groupsLock.Unlock()
 	if len(g.groups) > 0 {
 		return g.groups, nil
 	}
@@ -146,50 +140,24 @@ func (g *gitlabIdp) GetGroups(ctx context.Context) ([]*schema.Group, error) {
 	if err != nil {
 		return nil, fmt.Errorf("init gitlab client fail, err:【%w】", err)
 	}
-	page := 1
-	opts := &gitlab.ListGroupsOptions{
-		ListOptions: gitlab.ListOptions{Page: page, PerPage: gitlabGroupPageSize},
+	if !g.scope.IsEmpty() {
+		groups, err := g.listScopedGroups(ctx)
+		if err != nil {
+			return nil, err
+		}
+		schema.RenderChildGroupIds(groups)
+		g.groups = groups
+		return groups, nil
 	}
-	list, rsp, err := g.client.Groups.ListGroups(opts)
+	gitlabGroups, err := listAllPages(ctx, g.pool, gitlabGroupPageSize, func(ctx context.Context, page int) ([]*gitlab.Group, *gitlab.Response, error) {
+		opts := &gitlab.ListGroupsOptions{
+			ListOptions: gitlab.ListOptions{Page: page, PerPage: gitlabGroupPageSize},
+		}
+		return g.client.Groups.ListGroups(opts, gitlab.WithContext(ctx))
+	})
 	if err != nil {
 		return nil, err
 	}
-	totalCount := rsp.TotalItems
-	gitlabGroups := make([]*gitlab.Group, 0)
-	for _, user := range list {
-		gitlabGroups = append(gitlabGroups, user)
-	}
-	totalPage := cast.ToInt(math.Ceil(cast.ToFloat64(totalCount) / cast.ToFloat64(gitlabGroupPageSize)))
-	doChan := make(chan interface{}, 1)
-	wg := sync.WaitGroup{}
-	for page = 2; page <= totalPage; page++ {
-		wg.Add(1)
-		go func(page int) {
-			defer wg.Done()
-			opts := &gitlab.ListGroupsOptions{
-				ListOptions: gitlab.ListOptions{Page: page, PerPage: gitlabGroupPageSize},
-			}
-			list, _, err := g.client.Groups.ListGroups(opts)
-			if err != nil {
-				doChan <- err
-				return
-			}
-			doChan <- list
-			return
-		}(page)
-	}
-	go func() {
-		defer close(doChan)
-		wg.Wait()
-	}()
-	for item := range doChan {
-		switch assertValue := item.(type) {
-		case error:
-			return nil, assertValue
-		case []*gitlab.Group:
-			gitlabGroups = append(gitlabGroups, assertValue...)
-		}
-	}
 	groups := make([]*schema.Group, 0, len(gitlabGroups))
 	for _, gitlabGroup := range gitlabGroups {
 		item := g.converter.ToIdpGroup(gitlabGroup, schema.NamespaceGroup)
@@ -205,49 +173,19 @@ func (g *gitlabIdp) GetProjects(ctx context.Context) ([]*schema.Project, error)
 	if err != nil {
 		return nil, fmt.Errorf("init gitlab client fail, err:【%w】", err)
 	}
-	page := 1
-	opts := &gitlab.ListProjectsOptions{
-		ListOptions: gitlab.ListOptions{Page: page, PerPage: gitlabProjectPageSize},
-	}
-	list, rsp, err := g.client.Projects.ListProjects(opts)
-	if err != nil {
-		return nil, err
-	}
-	totalCount := rsp.TotalItems
-	gitlabProjects := make([]*gitlab.Project, 0)
-	for _, user := range list {
-		gitlabProjects = append(gitlabProjects, user)
-	}
-	totalPage := cast.ToInt(math.Ceil(cast.ToFloat64(totalCount) / cast.ToFloat64(gitlabProjectPageSize)))
-	doChan := make(chan interface{}, 1)
-	wg := sync.WaitGroup{}
-	for page = 2; page <= totalPage; page++ {
-		wg.Add(1)
-		go func(page int) {
-			defer wg.Done()
+	var gitlabProjects []*gitlab.Project
+	if g.scope.IsEmpty() {
+		gitlabProjects, err = listAllPages(ctx, g.pool, gitlabProjectPageSize, func(ctx context.Context, page int) ([]*gitlab.Project, *gitlab.Response, error) {
 			opts := &gitlab.ListProjectsOptions{
 				ListOptions: gitlab.ListOptions{Page: page, PerPage: gitlabProjectPageSize},
 			}
-			list, _, err := g.client.Projects.ListProjects(opts)
-			if err != nil {
-				doChan <- err
-				return
-			}
-			doChan <- list
-			return
-		}(page)
+			return g.client.Projects.ListProjects(opts, gitlab.WithContext(ctx))
+		})
+	} else {
+		gitlabProjects, err = g.listScopedProjects(ctx)
 	}
-	go func() {
-		defer close(doChan)
-		wg.Wait()
-	}()
-	for item := range doChan {
-		switch assertValue := item.(type) {
-		case error:
-			return nil, assertValue
-		case []*gitlab.Project:
-			gitlabProjects = append(gitlabProjects, assertValue...)
-		}
+	if err != nil {
+		return nil, err
 	}
 	projects := make([]*schema.Project, 0, len(gitlabProjects))
 	for _, gitlabProject := range gitlabProjects {
@@ -263,34 +201,32 @@ func (g *gitlabIdp) GetStaticProjects() []*schema.Project {
 }
 
 func (g *gitlabIdp) GetAllGroupMembers(ctx context.Context, groups []*schema.Group, users []*schema.User) ([]*schema.GroupMember, error) {
-	result := make([]*schema.GroupMember, 0)
-	wg := sync.WaitGroup{}
-	doChan := make(chan interface{})
-	for _, group := range groups {
-		wg.Add(1)
-		go func(ctx context.Context, group *schema.Group, user *schema.User) {
-			defer wg.Done()
-			groupMembers, err := g.GetGroupMembers(ctx, group, nil)
-			if err != nil {
-				doChan <- err
-				return
-			}
-			doChan <- groupMembers
-		}(ctx, group, nil)
+	err := g.newClient()
+	if err != nil {
+		return nil, fmt.Errorf("init gitlab client fail, err:【%w】", err)
 	}
-	go func() {
-		defer close(doChan)
-		wg.Wait()
-	}()
+	groupMembers := make([][]*schema.GroupMember, len(groups))
+	// The requests are bounded by the pool, the limit here only avoids spawning a goroutine per group.
+	errGroup := errgroup.Group{}
+	errGroup.SetLimit(g.pool.Size())
+	errList := make([]error, len(groups))
+	for i, group := range groups {
+		i, group := i, group
+		errGroup.Go(func() error {
+			groupMembers[i], errList[i] = g.GetGroupMembers(ctx, group, nil)
+			return nil
+		})
+	}
+	_ = errGroup.Wait()
 
 	AggregateErr := (error)(nil)
-	for item := range doChan {
-		switch assertValue := item.(type) {
-		case error:
-			AggregateErr = multierror.Append(AggregateErr, assertValue)
-		case []*schema.GroupMember:
-			result = append(result, assertValue...)
+	result := make([]*schema.GroupMember, 0)
+	for i := range groups {
+		if errList[i] != nil {
+			AggregateErr = multierror.Append(AggregateErr, errList[i])
+			continue
 		}
+		result = append(result, groupMembers[i]...)
 	}
 	if AggregateErr != nil {
 		return nil, AggregateErr
@@ -299,55 +235,22 @@ func (g *gitlabIdp) GetAllGroupMembers(ctx context.Context, groups []*schema.Gro
 	return result, nil
 }
 
+// GetGroupMembers reads the direct members of the group, the members inherited from the parent groups are not included,
+// they are represented by the nested roles or expanded by schema.GroupTree depending on the membership mode.
 func (g *gitlabIdp) GetGroupMembers(ctx context.Context, group *schema.Group, user *schema.User) ([]*schema.GroupMember, error) {
 	err := g.newClient()
 	if err != nil {
 		return nil, fmt.Errorf("init gitlab client fail, err:【%w】", err)
 	}
-	page := 1
-	opts := &gitlab.ListGroupMembersOptions{
-		ListOptions: gitlab.ListOptions{Page: page, PerPage: gitlabGroupMemberPageSize},
-	}
-	list, rsp, err := g.client.Groups.ListAllGroupMembers(group.Identity, opts)
+	groupMembers, err := listAllPages(ctx, g.pool, gitlabGroupMemberPageSize, func(ctx context.Context, page int) ([]*gitlab.GroupMember, *gitlab.Response, error) {
+		opts := &gitlab.ListGroupMembersOptions{
+			ListOptions: gitlab.ListOptions{Page: page, PerPage: gitlabGroupMemberPageSize},
+		}
+		return g.client.Groups.ListGroupMembers(group.Identity, opts, gitlab.WithContext(ctx))
+	})
 	if err != nil {
 		return nil, err
 	}
-	totalCount := rsp.TotalItems
-	groupMembers := make([]*gitlab.GroupMember, 0)
-	for _, groupMember := range list {
-		groupMembers = append(groupMembers, groupMember)
-	}
-	totalPage := cast.ToInt(math.Ceil(cast.ToFloat64(totalCount) / cast.ToFloat64(gitlabGroupMemberPageSize)))
-	doChan := make(chan interface{}, 1)
-	wg := sync.WaitGroup{}
-	for page = 2; page <= totalPage; page++ {
-		wg.Add(1)
-		go func(page int) {
-			defer wg.Done()
-			opts := &gitlab.ListGroupMembersOptions{
-				ListOptions: gitlab.ListOptions{Page: page, PerPage: gitlabGroupMemberPageSize},
-			}
-			list, _, err := g.client.Groups.ListAllGroupMembers(group.Identity, opts)
-			if err != nil {
-				doChan <- err
-				return
-			}
-			doChan <- list
-			return
-		}(page)
-	}
-	go func() {
-		defer close(doChan)
-		wg.Wait()
-	}()
-	for item := range doChan {
-		switch assertValue := item.(type) {
-		case error:
-			return nil, assertValue
-		case []*gitlab.GroupMember:
-			groupMembers = append(groupMembers, assertValue...)
-		}
-	}
 	result := make([]*schema.GroupMember, 0, len(groupMembers))
 	for _, groupMember := range groupMembers {
 		item := g.converter.ToIdpGroupMember(group.Identity, groupMember)
@@ -368,11 +271,74 @@ func (g *gitlabIdp) newClient() error {
 			return fmt.Errorf("get token fail, err:%w", err)
 		}
 		// init gitlab client
-		client, err := gitlab.NewClient(accessToken, gitlab.WithBaseURL(g.apiServerUrl))
+		httpClient := &http.Client{Transport: tracing.NewTransport(metrics.ClientGitlab, gitlabEndpointSegments, metrics.NewTransport(metrics.ClientGitlab, gitlabEndpointSegments, nil))}
+		client, err := gitlab.NewClient(accessToken,
+			gitlab.WithBaseURL(g.apiServerUrl),
+			gitlab.WithHTTPClient(httpClient),
+			// the requests are retried by the pool, so that the retries are limited as well
+			gitlab.WithoutRetries(),
+		)
 		if err != nil {
 			return err
 		}
 		g.client = client
 	}
+	if g.pool == nil {
+		g.pool = newRequestPool(g.options, retryGitlabRequest)
+	}
 	return nil
 }
+
+// retryGitlabRequest retries the requests failed with 429 or 5xx.
+func retryGitlabRequest(err error, attempt int) (time.Duration, bool) {
+	var errResponse *gitlab.ErrorResponse
+	if !errors.As(err, &errResponse) || errResponse.Response == nil {
+		return 0, false
+	}
+	resp := errResponse.Response
+	if resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode < http.StatusInternalServerError {
+		return 0, false
+	}
+	return retryBackoff(defaultRetryWaitMin, defaultRetryWaitMax, attempt-1, resp), true
+}
+
+// listAllPages lists the first page to find out the total pages, then lists the other pages concurrently.
+// Every request is sent by pool, the items are returned in the order of pages.
+func listAllPages[T any](ctx context.Context, pool *requestPool, pageSize int, list func(ctx context.Context, page int) ([]T, *gitlab.Response, error)) ([]T, error) {
+	var first []T
+	var rsp *gitlab.Response
+	err := pool.Do(ctx, func() (err error) {
+		first, rsp, err = list(ctx, 1)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	totalPage := cast.ToInt(math.Ceil(cast.ToFloat64(rsp.TotalItems) / cast.ToFloat64(pageSize)))
+	if totalPage <= 1 {
+		return first, nil
+	}
+
+	pages := make([][]T, totalPage+1)
+	errGroup, ctx := errgroup.WithContext(ctx)
+	errGroup.SetLimit(pool.Size())
+	for page := 2; page <= totalPage; page++ {
+		page := page
+		errGroup.Go(func() error {
+			return pool.Do(ctx, func() (err error) {
+				pages[page], _, err = list(ctx, page)
+				return err
+			})
+		})
+	}
+	if err := errGroup.Wait(); err != nil {
+		return nil, err
+	}
+
+	result := first
+	for _, items := range pages[2:] {
+		result = append(result, items...)
+	}
+	return result, nil
+}
@@ -0,0 +1,185 @@
+// Copyright 2023 Nautes Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package idp
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/nautes-labs/base-operator/pkg/schema"
+	"github.com/spf13/cast"
+	"github.com/xanzy/go-gitlab"
+)
+
+// minAccessLevel returns the minimum access level of the scope in the list options, nil means no limit.
+func (g *gitlabIdp) minAccessLevel() *gitlab.AccessLevelValue {
+	if g.scope.MinAccessLevel == 0 {
+		return nil
+	}
+	return gitlab.AccessLevel(gitlab.AccessLevelValue(g.scope.MinAccessLevel))
+}
+
+// listGroups lists the groups of the instance, the options of pages are set by listAllPages.
+func (g *gitlabIdp) listGroups(ctx context.Context, opts gitlab.ListGroupsOptions) ([]*gitlab.Group, error) {
+	opts.MinAccessLevel = g.minAccessLevel()
+	return listAllPages(ctx, g.pool, gitlabGroupPageSize, func(ctx context.Context, page int) ([]*gitlab.Group, *gitlab.Response, error) {
+		opts := opts
+		opts.ListOptions = gitlab.ListOptions{Page: page, PerPage: gitlabGroupPageSize}
+		return g.client.Groups.ListGroups(&opts, gitlab.WithContext(ctx))
+	})
+}
+
+// listScopedGroups lists the groups in the scope and returns them with their full paths.
+// The subtrees are read with the apis of the selected groups, all groups of the instance are listed only if the scope selects no group.
+func (g *gitlabIdp) listScopedGroups(ctx context.Context) ([]*schema.Group, error) {
+	var gitlabGroups []*gitlab.Group
+	if g.scope.hasRoots() {
+		roots, err := g.listScopeRoots(ctx)
+		if err != nil {
+			return nil, err
+		}
+		seen := make(map[int]bool)
+		for _, root := range roots {
+			descendants, err := listAllPages(ctx, g.pool, gitlabGroupPageSize, func(ctx context.Context, page int) ([]*gitlab.Group, *gitlab.Response, error) {
+				opts := &gitlab.ListDescendantGroupsOptions{
+					ListOptions:    gitlab.ListOptions{Page: page, PerPage: gitlabGroupPageSize},
+					MinAccessLevel: g.minAccessLevel(),
+				}
+				return g.client.Groups.ListDescendantGroups(root.ID, opts, gitlab.WithContext(ctx))
+			})
+			if err != nil {
+				return nil, fmt.Errorf("list descendant groups of %s fail, err:%w", root.FullPath, err)
+			}
+			for _, group := range append([]*gitlab.Group{root}, descendants...) {
+				if !seen[group.ID] {
+					seen[group.ID] = true
+					gitlabGroups = append(gitlabGroups, group)
+				}
+			}
+		}
+	} else {
+		var err error
+		gitlabGroups, err = g.listGroups(ctx, gitlab.ListGroupsOptions{})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	groups := make([]*schema.Group, 0, len(gitlabGroups))
+	fullPaths := make(map[string]string, len(gitlabGroups))
+	for _, gitlabGroup := range gitlabGroups {
+		group := g.converter.ToIdpGroup(gitlabGroup, schema.NamespaceGroup)
+		fullPaths[group.Identity] = gitlabGroup.FullPath
+		groups = append(groups, group)
+	}
+	return g.scope.Select(groups, fullPaths), nil
+}
+
+// listScopeRoots reads the groups selected by ids and the top-level groups of the included paths,
+// the top-level groups are listed only if a glob has wildcards in the top-level path.
+func (g *gitlabIdp) listScopeRoots(ctx context.Context) ([]*gitlab.Group, error) {
+	paths, listTopLevel := g.scope.rootPaths()
+	roots := make([]*gitlab.Group, 0, len(g.scope.GroupIds)+len(paths))
+	for _, gid := range append(append([]string{}, g.scope.GroupIds...), paths...) {
+		var group *gitlab.Group
+		err := g.pool.Do(ctx, func() (err error) {
+			group, _, err = g.client.Groups.GetGroup(gid, &gitlab.GetGroupOptions{WithProjects: gitlab.Bool(false)}, gitlab.WithContext(ctx))
+			return err
+		})
+		if err != nil {
+			return nil, fmt.Errorf("get group %s fail, err:%w", gid, err)
+		}
+		roots = append(roots, group)
+	}
+	if !listTopLevel {
+		return roots, nil
+	}
+	topLevelGroups, err := g.listGroups(ctx, gitlab.ListGroupsOptions{TopLevelOnly: gitlab.Bool(true)})
+	if err != nil {
+		return nil, err
+	}
+	for _, group := range topLevelGroups {
+		if g.scope.matchesTopLevel(group.FullPath) {
+			roots = append(roots, group)
+		}
+	}
+	return roots, nil
+}
+
+// listScopedProjects lists the projects in the selected groups, the projects of the subtrees are read with the apis of
+// the top-level selected groups, the projects in the excluded subgroups and the projects shared with the groups are dropped.
+// All projects of the instance are listed only if the scope selects no group.
+func (g *gitlabIdp) listScopedProjects(ctx context.Context) ([]*gitlab.Project, error) {
+	groups, err := g.GetGroups(ctx)
+	if err != nil {
+		return nil, err
+	}
+	selected := make(map[string]bool, len(groups))
+	for _, group := range groups {
+		selected[group.Identity] = true
+	}
+	inScope := func(project *gitlab.Project) bool {
+		if project.Namespace == nil {
+			return false
+		}
+		if project.Namespace.Kind == schema.NamespaceUser {
+			return !g.scope.hasRoots()
+		}
+		return selected[cast.ToString(project.Namespace.ID)]
+	}
+
+	var gitlabProjects []*gitlab.Project
+	if g.scope.hasRoots() {
+		for _, group := range groups {
+			if len(group.ParentId) > 0 {
+				continue
+			}
+			projects, err := listAllPages(ctx, g.pool, gitlabProjectPageSize, func(ctx context.Context, page int) ([]*gitlab.Project, *gitlab.Response, error) {
+				opts := &gitlab.ListGroupProjectsOptions{
+					ListOptions:      gitlab.ListOptions{Page: page, PerPage: gitlabProjectPageSize},
+					IncludeSubGroups: gitlab.Bool(true),
+					WithShared:       gitlab.Bool(false),
+					MinAccessLevel:   g.minAccessLevel(),
+				}
+				return g.client.Groups.ListGroupProjects(group.Identity, opts, gitlab.WithContext(ctx))
+			})
+			if err != nil {
+				return nil, fmt.Errorf("list projects of group %s fail, err:%w", group.Identity, err)
+			}
+			gitlabProjects = append(gitlabProjects, projects...)
+		}
+	} else {
+		gitlabProjects, err = listAllPages(ctx, g.pool, gitlabProjectPageSize, func(ctx context.Context, page int) ([]*gitlab.Project, *gitlab.Response, error) {
+			opts := &gitlab.ListProjectsOptions{
+				ListOptions:    gitlab.ListOptions{Page: page, PerPage: gitlabProjectPageSize},
+				MinAccessLevel: g.minAccessLevel(),
+			}
+			return g.client.Projects.ListProjects(opts, gitlab.WithContext(ctx))
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	result := make([]*gitlab.Project, 0, len(gitlabProjects))
+	seen := make(map[int]bool, len(gitlabProjects))
+	for _, project := range gitlabProjects {
+		if inScope(project) && !seen[project.ID] {
+			seen[project.ID] = true
+			result = append(result, project)
+		}
+	}
+	return result, nil
+}
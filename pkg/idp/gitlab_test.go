@@ -0,0 +1,250 @@
+// Copyright 2023 Nautes Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package idp
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/nautes-labs/base-operator/pkg/schema"
+	"github.com/nautes-labs/base-operator/pkg/secret_provider"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+const testSecret = `[{"identity":{"type":"gitlab","name":"gitlab1"},"authentication_type":"token","authentication_data":{"token":"token"}}]`
+
+var _ = Describe("Gitlab idp", func() {
+	var (
+		handler  http.HandlerFunc
+		server   *httptest.Server
+		idp      Idp
+		requests int32
+	)
+
+	BeforeEach(func() {
+		requests = 0
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&requests, 1)
+			handler(w, r)
+		}))
+		DeferCleanup(server.Close)
+
+		secretPath := filepath.Join(GinkgoT().TempDir(), "secret.json")
+		Expect(os.WriteFile(secretPath, []byte(testSecret), 0600)).Should(Succeed())
+		provider, err := secret_provider.NewSecretProvider(secretPath)
+		Expect(err).Should(BeNil())
+
+		idp, err = NewIdp(GitlabIdpKind.Tostring())
+		Expect(err).Should(BeNil())
+		idp.SetName("gitlab1")
+		idp.SetApiServerUrl(server.URL)
+		idp.SetSecretProvider(provider)
+	})
+
+	It("lists all pages with bounded concurrency", func() {
+		lock := sync.Mutex{}
+		inflight, maxInflight := 0, 0
+		handler = func(w http.ResponseWriter, r *http.Request) {
+			lock.Lock()
+			inflight++
+			if inflight > maxInflight {
+				maxInflight = inflight
+			}
+			lock.Unlock()
+			defer func() {
+				lock.Lock()
+				inflight--
+				lock.Unlock()
+			}()
+
+			time.Sleep(20 * time.Millisecond)
+			w.Header().Set("X-Total", "100")
+			fmt.Fprintf(w, `[{"id": %s}]`, r.URL.Query().Get("page"))
+		}
+		idp.SetClientOptions(ClientOptions{MaxConcurrency: 2})
+
+		users, err := idp.GetUsers(context.Background())
+		Expect(err).Should(BeNil())
+		Expect(users).Should(HaveLen(5))
+		for i, user := range users {
+			Expect(user.Identity).Should(Equal(strconv.Itoa(i + 1)))
+		}
+		Expect(maxInflight).Should(Equal(2))
+	})
+
+	It("retries rate limited request after the time in Retry-After", func() {
+		handler = func(w http.ResponseWriter, r *http.Request) {
+			if atomic.LoadInt32(&requests) == 1 {
+				w.Header().Set("Retry-After", "1")
+				w.WriteHeader(http.StatusTooManyRequests)
+				return
+			}
+			w.Header().Set("X-Total", "1")
+			fmt.Fprint(w, `[{"id": 1}]`)
+		}
+
+		start := time.Now()
+		users, err := idp.GetUsers(context.Background())
+		Expect(err).Should(BeNil())
+		Expect(users).Should(HaveLen(1))
+		Expect(requests).Should(Equal(int32(2)))
+		Expect(time.Since(start)).Should(BeNumerically(">=", time.Second))
+	})
+
+	It("does not retry if retries are disabled", func() {
+		handler = func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		idp.SetClientOptions(ClientOptions{MaxRetries: -1})
+
+		_, err := idp.GetUsers(context.Background())
+		Expect(err).ShouldNot(BeNil())
+		Expect(requests).Should(Equal(int32(1)))
+	})
+
+	It("reports the state of users", func() {
+		handler = func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("X-Total", "6")
+			fmt.Fprint(w, `[
+				{"id": 1, "state": "active"},
+				{"id": 2, "state": "blocked"},
+				{"id": 3, "state": "ldap_blocked"},
+				{"id": 4, "state": "deactivated"},
+				{"id": 5, "state": "active", "bot": true, "external": true},
+				{"id": 6, "state": "banned"}
+			]`)
+		}
+
+		users, err := idp.GetUsers(context.Background())
+		Expect(err).Should(BeNil())
+		states := make([]string, 0, len(users))
+		for _, user := range users {
+			states = append(states, user.State)
+		}
+		Expect(states).Should(Equal([]string{schema.UserStateActive, schema.UserStateBlocked, schema.UserStateBlocked, schema.UserStateDeactivated, schema.UserStateBot, schema.UserStateBlocked}))
+		Expect(users[1].IsDisabled()).Should(BeTrue())
+		Expect(users[5].IsDisabled()).Should(BeTrue())
+		Expect(users[4].IsDisabled()).Should(BeFalse())
+		Expect(users[4].External).Should(BeTrue())
+	})
+
+	It("reads the groups and projects in the scope with the group apis", func() {
+		paths := make([]string, 0)
+		lock := sync.Mutex{}
+		handler = func(w http.ResponseWriter, r *http.Request) {
+			lock.Lock()
+			paths = append(paths, r.URL.Path)
+			lock.Unlock()
+			w.Header().Set("X-Total", "1")
+			switch r.URL.Path {
+			case "/api/v4/groups/bu1":
+				fmt.Fprint(w, `{"id": 1, "full_path": "bu1"}`)
+			case "/api/v4/groups/1/descendant_groups":
+				Expect(r.URL.Query().Get("min_access_level")).Should(Equal("30"))
+				w.Header().Set("X-Total", "3")
+				fmt.Fprint(w, `[
+					{"id": 2, "full_path": "bu1/team-a", "parent_id": 1},
+					{"id": 3, "full_path": "bu1/team-a/tools", "parent_id": 2},
+					{"id": 4, "full_path": "bu1/legacy", "parent_id": 1}
+				]`)
+			case "/api/v4/groups/1/projects":
+				Expect(r.URL.Query().Get("include_subgroups")).Should(Equal("true"))
+				Expect(r.URL.Query().Get("with_shared")).Should(Equal("false"))
+				w.Header().Set("X-Total", "3")
+				fmt.Fprint(w, `[
+					{"id": 10, "namespace": {"id": 1, "kind": "group"}},
+					{"id": 11, "namespace": {"id": 3, "kind": "group"}},
+					{"id": 12, "namespace": {"id": 4, "kind": "group"}}
+				]`)
+			default:
+				w.WriteHeader(http.StatusNotFound)
+			}
+		}
+		idp.SetScope(Scope{IncludeGroupPaths: []string{"bu1"}, ExcludeGroupPaths: []string{"bu1/legacy"}, MinAccessLevel: 30})
+
+		groups, err := idp.GetGroups(context.Background())
+		Expect(err).Should(BeNil())
+		groupIds := make([]string, 0, len(groups))
+		for _, group := range groups {
+			groupIds = append(groupIds, group.Identity)
+		}
+		Expect(groupIds).Should(Equal([]string{"1", "2", "3"}))
+		Expect(groups[0].ChildIds).Should(Equal([]string{"2"}))
+
+		projects, err := idp.GetProjects(context.Background())
+		Expect(err).Should(BeNil())
+		projectIds := make([]string, 0, len(projects))
+		for _, project := range projects {
+			projectIds = append(projectIds, project.Identity)
+		}
+		Expect(projectIds).Should(Equal([]string{"10", "11"}))
+		Expect(paths).Should(ConsistOf("/api/v4/groups/bu1", "/api/v4/groups/1/descendant_groups", "/api/v4/groups/1/projects"))
+	})
+
+	It("reads the direct members of groups", func() {
+		paths := make([]string, 0)
+		lock := sync.Mutex{}
+		handler = func(w http.ResponseWriter, r *http.Request) {
+			lock.Lock()
+			paths = append(paths, r.URL.Path)
+			lock.Unlock()
+			w.Header().Set("X-Total", "1")
+			switch r.URL.Path {
+			case "/api/v4/groups/1/members":
+				fmt.Fprint(w, `[{"id": 7}]`)
+			case "/api/v4/groups/2/members":
+				fmt.Fprint(w, `[{"id": 8}]`)
+			default:
+				w.WriteHeader(http.StatusNotFound)
+			}
+		}
+		groups := []*schema.Group{
+			{BaseEntity: schema.BaseEntity{Identity: "1"}, ChildIds: []string{"2"}},
+			{BaseEntity: schema.BaseEntity{Identity: "2"}, ParentId: "1"},
+		}
+
+		members, err := idp.GetAllGroupMembers(context.Background(), groups, nil)
+		Expect(err).Should(BeNil())
+		Expect(members).Should(Equal([]*schema.GroupMember{{GroupId: "1", UserId: "7"}, {GroupId: "2", UserId: "8"}}))
+		Expect(paths).Should(ConsistOf("/api/v4/groups/1/members", "/api/v4/groups/2/members"))
+	})
+
+	It("parses Retry-After in seconds and http date", func() {
+		now := time.Date(2023, 6, 1, 0, 0, 0, 0, time.UTC)
+		wait, ok := parseRetryAfter("3", now)
+		Expect(ok).Should(BeTrue())
+		Expect(wait).Should(Equal(3 * time.Second))
+
+		wait, ok = parseRetryAfter(now.Add(10*time.Second).Format(http.TimeFormat), now)
+		Expect(ok).Should(BeTrue())
+		Expect(wait).Should(Equal(10 * time.Second))
+
+		wait, ok = parseRetryAfter("3600", now)
+		Expect(ok).Should(BeTrue())
+		Expect(wait).Should(Equal(maxRetryAfter))
+
+		_, ok = parseRetryAfter("soon", now)
+		Expect(ok).Should(BeFalse())
+	})
+})
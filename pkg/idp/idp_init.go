@@ -25,6 +25,7 @@ var (
 
 func init() {
 	IdpKindMapping[GitlabIdpKind.Tostring()] = (*gitlabIdp)(nil)
+	IdpKindMapping[LdapIdpKind.Tostring()] = (*ldapIdp)(nil)
 }
 
 func NewIdp(idpKind string) (Idp, error) {
@@ -28,6 +28,8 @@ type Idp interface {
 	GetName() string
 	SetApiServerUrl(url string)
 	SetSecretProvider(provider *secret_provider.SecretProvider)
+	SetClientOptions(opts ClientOptions)
+	SetScope(scope Scope)
 	GetStaticUserById(id string) (*schema.User, error)
 	GetUsers(ctx context.Context) ([]*schema.User, error)
 	GetGroups(ctx context.Context) ([]*schema.Group, error)
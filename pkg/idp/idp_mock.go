@@ -181,6 +181,18 @@ func (mr *MockIdpMockRecorder) SetApiServerUrl(url interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetApiServerUrl", reflect.TypeOf((*MockIdp)(nil).SetApiServerUrl), url)
 }
 
+// SetClientOptions mocks base method.
+func (m *MockIdp) SetClientOptions(opts ClientOptions) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "SetClientOptions", opts)
+}
+
+// SetClientOptions indicates an expected call of SetClientOptions.
+func (mr *MockIdpMockRecorder) SetClientOptions(opts interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetClientOptions", reflect.TypeOf((*MockIdp)(nil).SetClientOptions), opts)
+}
+
 // SetName mocks base method.
 func (m *MockIdp) SetName(name string) {
 	m.ctrl.T.Helper()
@@ -193,6 +205,18 @@ func (mr *MockIdpMockRecorder) SetName(name interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetName", reflect.TypeOf((*MockIdp)(nil).SetName), name)
 }
 
+// SetScope mocks base method.
+func (m *MockIdp) SetScope(scope Scope) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "SetScope", scope)
+}
+
+// SetScope indicates an expected call of SetScope.
+func (mr *MockIdpMockRecorder) SetScope(scope interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetScope", reflect.TypeOf((*MockIdp)(nil).SetScope), scope)
+}
+
 // SetSecretProvider mocks base method.
 func (m *MockIdp) SetSecretProvider(provider *secret_provider.SecretProvider) {
 	m.ctrl.T.Helper()
@@ -0,0 +1,379 @@
+// Copyright 2023 Nautes Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package idp
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/nautes-labs/base-operator/pkg/convert/convert2idp"
+	"github.com/nautes-labs/base-operator/pkg/schema"
+	"github.com/nautes-labs/base-operator/pkg/secret_provider"
+)
+
+const (
+	ldapPageSize           = 500
+	ldapDefaultUserFilter  = "(objectClass=inetOrgPerson)"
+	ldapDefaultGroupFilter = "(|(objectClass=groupOfNames)(objectClass=groupOfUniqueNames))"
+)
+
+var _ Idp = (*ldapIdp)(nil)
+
+// ldapConn is the part of the ldap connection used by the idp.
+type ldapConn interface {
+	Bind(username, password string) error
+	SearchWithPaging(searchRequest *ldap.SearchRequest, pagingSize uint32) (*ldap.SearchResult, error)
+	Close() error
+}
+
+// dialLdap connects to the ldap server, it is replaced by the tests.
+var dialLdap = func(addr string) (ldapConn, error) {
+	return ldap.DialURL(addr)
+}
+
+// ldapConfig is parsed from the api server url, e.g. "ldaps://ldap.example.com/dc=example,dc=com?userFilter=(objectClass=person)",
+// the users and groups are searched in the subtree of the base dn, the filters default to inetOrgPerson and groupOfNames.
+type ldapConfig struct {
+	addr        string
+	baseDN      string
+	userFilter  string
+	groupFilter string
+}
+
+func parseLdapURL(rawURL string) (*ldapConfig, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("parse ldap url fail, err:%w", err)
+	}
+	if u.Scheme != "ldap" && u.Scheme != "ldaps" {
+		return nil, fmt.Errorf("unsupported ldap url scheme %q", u.Scheme)
+	}
+	config := &ldapConfig{
+		addr:        (&url.URL{Scheme: u.Scheme, Host: u.Host}).String(),
+		baseDN:      strings.TrimPrefix(u.Path, "/"),
+		userFilter:  u.Query().Get("userFilter"),
+		groupFilter: u.Query().Get("groupFilter"),
+	}
+	if config.baseDN == "" {
+		return nil, fmt.Errorf("base dn is missing in ldap url %s", rawURL)
+	}
+	if config.userFilter == "" {
+		config.userFilter = ldapDefaultUserFilter
+	}
+	if config.groupFilter == "" {
+		config.groupFilter = ldapDefaultGroupFilter
+	}
+	for _, filter := range []string{config.userFilter, config.groupFilter} {
+		if _, err := ldap.CompileFilter(filter); err != nil {
+			return nil, fmt.Errorf("invalid ldap filter %q: %w", filter, err)
+		}
+	}
+	return config, nil
+}
+
+// ldapIdp reads the users and groups from ldap with the bind dn and password of the basic auth secret.
+// The groups are nested by the groups in their members, a group in several groups is kept under the first one by dn.
+// Ldap has no projects, and the scope selects the groups by paths only, the paths are the names of the parents and the group, e.g. "dev/team-a".
+type ldapIdp struct {
+	name           string
+	apiServerUrl   string
+	secretProvider *secret_provider.SecretProvider
+	config         *ldapConfig
+	converter      *convert2idp.Ldap2IdpConverter
+	users          []*schema.User
+	userIds        map[string]string
+	groups         []*schema.Group
+	memberDNs      map[string][]string
+	options        ClientOptions
+	pool           *requestPool
+	scope          Scope
+	usersLock      sync.Mutex
+	groupsLock     sync.Mutex
+}
+
+func (l *ldapIdp) Kind() IdpKind {
+	return LdapIdpKind
+}
+
+func (l *ldapIdp) SetName(name string) {
+	l.name = name
+}
+
+func (l *ldapIdp) GetName() string {
+	return l.name
+}
+
+func (l *ldapIdp) SetApiServerUrl(url string) {
+	l.apiServerUrl = url
+	l.config = nil
+}
+
+func (l *ldapIdp) SetSecretProvider(provider *secret_provider.SecretProvider) {
+	l.secretProvider = provider
+}
+
+func (l *ldapIdp) SetClientOptions(opts ClientOptions) {
+	l.options = opts
+	l.pool = nil
+}
+
+func (l *ldapIdp) SetScope(scope Scope) {
+	l.scope = scope
+	l.groups = nil
+	l.memberDNs = nil
+}
+
+func (l *ldapIdp) GetUsers(ctx context.Context) ([]*schema.User, error) {
+	err := l.newClient()
+	if err != nil {
+		return nil, fmt.Errorf("init ldap client fail, err:【%w】", err)
+	}
+	entries, err := l.search(ctx, l.config.userFilter, convert2idp.LdapUserAttributes)
+	if err != nil {
+		return nil, err
+	}
+	users := make([]*schema.User, 0, len(entries))
+	userIds := make(map[string]string, len(entries))
+	for _, entry := range entries {
+		user := l.converter.ToIdpUser(entry)
+		if user.Identity == "" {
+			return nil, fmt.Errorf("user %s has no %s", entry.DN, convert2idp.LdapIdentityAttribute)
+		}
+		users = append(users, user)
+		userIds[normalizeDN(entry.DN)] = user.Identity
+	}
+	l.usersLock.Lock()
+	defer l.usersLock.Unlock()
+	l.users = users
+	l.userIds = userIds
+	return users, nil
+}
+
+func (l *ldapIdp) GetStaticUserById(id string) (*schema.User, error) {
+	for _, user := range l.users {
+		if user.Identity == id {
+			return user, nil
+		}
+	}
+	return nil, fmt.Errorf("user not found, id:%s", id)
+}
+
+// GetGroups lists the groups in the scope, the groups are listed once as the members are read from them.
+func (l *ldapIdp) GetGroups(ctx context.Context) ([]*schema.Group, error) {
+	l.groupsLock.Lock()
+	defer l.groupsLock.Unlock()
+	if len(l.groups) > 0 {
+		return l.groups, nil
+	}
+	if len(l.scope.GroupIds) > 0 || l.scope.MinAccessLevel > 0 {
+		return nil, fmt.Errorf("the groups of ldap are selected by paths only")
+	}
+	err := l.newClient()
+	if err != nil {
+		return nil, fmt.Errorf("init ldap client fail, err:【%w】", err)
+	}
+	entries, err := l.search(ctx, l.config.groupFilter, convert2idp.LdapGroupAttributes)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return normalizeDN(entries[i].DN) < normalizeDN(entries[j].DN)
+	})
+
+	groups := make([]*schema.Group, 0, len(entries))
+	groupsById := make(map[string]*schema.Group, len(entries))
+	groupsByDN := make(map[string]*schema.Group, len(entries))
+	for _, entry := range entries {
+		group := l.converter.ToIdpGroup(entry, schema.NamespaceGroup)
+		if group.Identity == "" {
+			return nil, fmt.Errorf("group %s has no %s", entry.DN, convert2idp.LdapIdentityAttribute)
+		}
+		groups = append(groups, group)
+		groupsById[group.Identity] = group
+		groupsByDN[normalizeDN(entry.DN)] = group
+	}
+	memberDNs := make(map[string][]string, len(entries))
+	for i, entry := range entries {
+		parent := groups[i]
+		for _, dn := range convert2idp.LdapGroupMemberDNs(entry) {
+			dn = normalizeDN(dn)
+			child, ok := groupsByDN[dn]
+			if !ok {
+				memberDNs[parent.Identity] = append(memberDNs[parent.Identity], dn)
+				continue
+			}
+			if child.ParentId == "" && !isLdapAncestor(groupsById, child.Identity, parent) {
+				child.ParentId = parent.Identity
+			}
+		}
+	}
+
+	fullPaths := renderLdapFullPaths(groups)
+	if !l.scope.IsEmpty() {
+		groups = l.scope.Select(groups, fullPaths)
+	}
+	schema.RenderChildGroupIds(groups)
+	l.groups = groups
+	l.memberDNs = memberDNs
+	return groups, nil
+}
+
+func (l *ldapIdp) GetProjects(ctx context.Context) ([]*schema.Project, error) {
+	return []*schema.Project{}, nil
+}
+
+func (l *ldapIdp) GetAllGroupMembers(ctx context.Context, groups []*schema.Group, users []*schema.User) ([]*schema.GroupMember, error) {
+	result := make([]*schema.GroupMember, 0)
+	for _, group := range groups {
+		members, err := l.GetGroupMembers(ctx, group, nil)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, members...)
+	}
+	return result, nil
+}
+
+// GetGroupMembers returns the direct members of the group, the members out of the user filter are skipped.
+func (l *ldapIdp) GetGroupMembers(ctx context.Context, group *schema.Group, user *schema.User) ([]*schema.GroupMember, error) {
+	if _, err := l.GetGroups(ctx); err != nil {
+		return nil, err
+	}
+	l.usersLock.Lock()
+	userIds := l.userIds
+	l.usersLock.Unlock()
+	if userIds == nil {
+		if _, err := l.GetUsers(ctx); err != nil {
+			return nil, err
+		}
+		l.usersLock.Lock()
+		userIds = l.userIds
+		l.usersLock.Unlock()
+	}
+
+	l.groupsLock.Lock()
+	dns := l.memberDNs[group.Identity]
+	l.groupsLock.Unlock()
+	result := make([]*schema.GroupMember, 0, len(dns))
+	for _, dn := range dns {
+		userId, ok := userIds[dn]
+		if !ok {
+			continue
+		}
+		result = append(result, &schema.GroupMember{
+			GroupId: group.Identity,
+			UserId:  userId,
+		})
+	}
+	return result, nil
+}
+
+func (l *ldapIdp) GetProjectMembers(ctx context.Context, project *schema.Project, user *schema.User) ([]*schema.ProjectMember, error) {
+	return nil, nil
+}
+
+func (l *ldapIdp) newClient() error {
+	if l.config == nil {
+		config, err := parseLdapURL(l.apiServerUrl)
+		if err != nil {
+			return err
+		}
+		l.config = config
+	}
+	if l.pool == nil {
+		l.pool = newRequestPool(l.options, nil)
+	}
+	return nil
+}
+
+// search binds and searches the subtree of the base dn in a new connection, the connection is held by the pool while searching.
+func (l *ldapIdp) search(ctx context.Context, filter string, attributes []string) ([]*ldap.Entry, error) {
+	username, passwd, err := l.secretProvider.GetApplicationBasicAuth(secret_provider.Identity{Type: l.Kind().Tostring(), Name: l.GetName()})
+	if err != nil {
+		return nil, fmt.Errorf("get basic auth info fail, err:%w", err)
+	}
+	var result *ldap.SearchResult
+	err = l.pool.Do(ctx, func() error {
+		conn, err := dialLdap(l.config.addr)
+		if err != nil {
+			return fmt.Errorf("connect to %s fail, err:%w", l.config.addr, err)
+		}
+		defer conn.Close()
+		if err := conn.Bind(username, passwd); err != nil {
+			return fmt.Errorf("bind %s fail, err:%w", username, err)
+		}
+		request := ldap.NewSearchRequest(l.config.baseDN, ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 0, 0, false, filter, attributes, nil)
+		result, err = conn.SearchWithPaging(request, ldapPageSize)
+		if err != nil {
+			return fmt.Errorf("search %s in %s fail, err:%w", filter, l.config.baseDN, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result.Entries, nil
+}
+
+// isLdapAncestor checks whether the group of id is the group or one of its parents, the parents are not set if they make a cycle.
+func isLdapAncestor(groupsById map[string]*schema.Group, id string, group *schema.Group) bool {
+	for ; group != nil; group = groupsById[group.ParentId] {
+		if group.Identity == id {
+			return true
+		}
+	}
+	return false
+}
+
+// renderLdapFullPaths sets the full paths of the groups by the names of their parents and returns them by the group ids.
+func renderLdapFullPaths(groups []*schema.Group) map[string]string {
+	byId := make(map[string]*schema.Group, len(groups))
+	for _, group := range groups {
+		byId[group.Identity] = group
+	}
+	fullPaths := make(map[string]string, len(groups))
+	for _, group := range groups {
+		names := []string{}
+		for parent := group; parent != nil; parent = byId[parent.ParentId] {
+			names = append([]string{parent.Name}, names...)
+		}
+		group.FullPath = strings.Join(names, "/")
+		fullPaths[group.Identity] = group.FullPath
+	}
+	return fullPaths
+}
+
+// normalizeDN makes the dns comparable, the attribute types and values are case-insensitive in most schemas.
+func normalizeDN(dn string) string {
+	parsed, err := ldap.ParseDN(dn)
+	if err != nil {
+		return strings.ToLower(strings.TrimSpace(dn))
+	}
+	rdns := make([]string, 0, len(parsed.RDNs))
+	for _, rdn := range parsed.RDNs {
+		attributes := make([]string, 0, len(rdn.Attributes))
+		for _, attribute := range rdn.Attributes {
+			attributes = append(attributes, strings.ToLower(attribute.Type)+"="+strings.ToLower(attribute.Value))
+		}
+		sort.Strings(attributes)
+		rdns = append(rdns, strings.Join(attributes, "+"))
+	}
+	return strings.Join(rdns, ",")
+}
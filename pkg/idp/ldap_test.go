@@ -0,0 +1,152 @@
+// Copyright 2023 Nautes Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package idp
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/nautes-labs/base-operator/pkg/schema"
+	"github.com/nautes-labs/base-operator/pkg/secret_provider"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+const testLdapSecret = `[{"identity":{"type":"ldap","name":"ldap1"},"authentication_type":"basic-auth","authentication_data":{"username":"cn=admin,dc=example,dc=com","passwd":"admin"}}]`
+
+// fakeLdapConn returns the entries of the filter of the search request.
+type fakeLdapConn struct {
+	entries map[string][]*ldap.Entry
+	baseDNs *[]string
+}
+
+func (f *fakeLdapConn) Bind(username, password string) error {
+	if username != "cn=admin,dc=example,dc=com" || password != "admin" {
+		return ldap.NewError(ldap.LDAPResultInvalidCredentials, nil)
+	}
+	return nil
+}
+
+func (f *fakeLdapConn) SearchWithPaging(searchRequest *ldap.SearchRequest, pagingSize uint32) (*ldap.SearchResult, error) {
+	*f.baseDNs = append(*f.baseDNs, searchRequest.BaseDN)
+	return &ldap.SearchResult{Entries: f.entries[searchRequest.Filter]}, nil
+}
+
+func (f *fakeLdapConn) Close() error {
+	return nil
+}
+
+var _ = Describe("Ldap idp", func() {
+	var (
+		idp     Idp
+		baseDNs []string
+	)
+
+	BeforeEach(func() {
+		baseDNs = nil
+		entries := map[string][]*ldap.Entry{
+			ldapDefaultUserFilter: {
+				ldap.NewEntry("uid=alice,ou=people,dc=example,dc=com", map[string][]string{
+					"entryUUID": {"u-1"}, "uid": {"alice"}, "cn": {"Alice"}, "mail": {"alice@example.com"},
+				}),
+				ldap.NewEntry("uid=bob,ou=people,dc=example,dc=com", map[string][]string{
+					"entryUUID": {"u-2"}, "uid": {"bob"}, "cn": {"Bob"}, "pwdAccountLockedTime": {"000001010000Z"},
+				}),
+			},
+			ldapDefaultGroupFilter: {
+				ldap.NewEntry("cn=team-a,ou=groups,dc=example,dc=com", map[string][]string{
+					"entryUUID": {"g-2"}, "cn": {"team-a"}, "member": {"UID=Alice, OU=People, DC=example, DC=com", "cn=dev,ou=groups,dc=example,dc=com"},
+				}),
+				ldap.NewEntry("cn=dev,ou=groups,dc=example,dc=com", map[string][]string{
+					"entryUUID": {"g-1"}, "cn": {"dev"}, "member": {"cn=team-a,ou=groups,dc=example,dc=com", "uid=carol,ou=people,dc=example,dc=com"},
+				}),
+				ldap.NewEntry("cn=ops,ou=groups,dc=example,dc=com", map[string][]string{
+					"entryUUID": {"g-3"}, "cn": {"ops"}, "uniqueMember": {"uid=bob,ou=people,dc=example,dc=com#'0101'B"},
+				}),
+			},
+		}
+		dial := dialLdap
+		dialLdap = func(addr string) (ldapConn, error) {
+			Expect(addr).Should(Equal("ldaps://ldap.example.com:636"))
+			return &fakeLdapConn{entries: entries, baseDNs: &baseDNs}, nil
+		}
+		DeferCleanup(func() { dialLdap = dial })
+
+		secretPath := filepath.Join(GinkgoT().TempDir(), "secret.json")
+		Expect(os.WriteFile(secretPath, []byte(testLdapSecret), 0600)).Should(Succeed())
+		provider, err := secret_provider.NewSecretProvider(secretPath)
+		Expect(err).Should(BeNil())
+
+		idp, err = NewIdp(LdapIdpKind.Tostring())
+		Expect(err).Should(BeNil())
+		idp.SetName("ldap1")
+		idp.SetApiServerUrl("ldaps://ldap.example.com:636/dc=example,dc=com")
+		idp.SetSecretProvider(provider)
+	})
+
+	It("reads the users with their states", func() {
+		users, err := idp.GetUsers(context.Background())
+		Expect(err).Should(BeNil())
+		Expect(users).Should(Equal([]*schema.User{
+			{BaseEntity: schema.BaseEntity{Identity: "u-1", Name: "Alice"}, Username: "alice", Email: "alice@example.com",
+				NamespaceId: "u-1", State: schema.UserStateActive},
+			{BaseEntity: schema.BaseEntity{Identity: "u-2", Name: "Bob"}, Username: "bob",
+				NamespaceId: "u-2", State: schema.UserStateBlocked},
+		}))
+		Expect(baseDNs).Should(Equal([]string{"dc=example,dc=com"}))
+	})
+
+	It("nests the groups in the groups of their members and skips the members which are not users", func() {
+		groups, err := idp.GetGroups(context.Background())
+		Expect(err).Should(BeNil())
+		paths := map[string]string{}
+		parents := map[string]string{}
+		for _, group := range groups {
+			paths[group.Identity] = group.FullPath
+			parents[group.Identity] = group.ParentId
+		}
+		// dev is the first by dn, so team-a is kept in dev and the membership of dev in team-a is dropped as a cycle
+		Expect(paths).Should(Equal(map[string]string{"g-1": "dev", "g-2": "dev/team-a", "g-3": "ops"}))
+		Expect(parents).Should(Equal(map[string]string{"g-1": "", "g-2": "g-1", "g-3": ""}))
+
+		members, err := idp.GetAllGroupMembers(context.Background(), groups, nil)
+		Expect(err).Should(BeNil())
+		Expect(members).Should(ConsistOf(
+			&schema.GroupMember{GroupId: "g-2", UserId: "u-1"},
+			&schema.GroupMember{GroupId: "g-3", UserId: "u-2"},
+		))
+	})
+
+	It("selects the groups by paths", func() {
+		idp.SetScope(Scope{IncludeGroupPaths: []string{"dev/*"}})
+		groups, err := idp.GetGroups(context.Background())
+		Expect(err).Should(BeNil())
+		Expect(groups).Should(HaveLen(1))
+		Expect(groups[0].Identity).Should(Equal("g-2"))
+		Expect(groups[0].ParentId).Should(BeEmpty())
+
+		idp.SetScope(Scope{GroupIds: []string{"1"}})
+		_, err = idp.GetGroups(context.Background())
+		Expect(err).Should(MatchError(ContainSubstring("selected by paths only")))
+	})
+
+	It("requires the base dn in the url", func() {
+		idp.SetApiServerUrl("ldaps://ldap.example.com:636")
+		_, err := idp.GetUsers(context.Background())
+		Expect(err).Should(MatchError(ContainSubstring("base dn is missing")))
+	})
+})
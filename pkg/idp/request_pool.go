@@ -0,0 +1,172 @@
+// Copyright 2023 Nautes Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package idp
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/hashicorp/go-retryablehttp"
+	"golang.org/x/time/rate"
+)
+
+const (
+	defaultMaxConcurrency = 10
+	defaultMaxRetries     = 5
+	defaultRetryWaitMin   = time.Second
+	defaultRetryWaitMax   = 30 * time.Second
+	// The longest wait allowed by Retry-After, the server is treated as broken if it asks for more.
+	maxRetryAfter = 5 * time.Minute
+)
+
+// ClientOptions limits the requests sent to an idp instance, the zero values mean the defaults.
+type ClientOptions struct {
+	// The requests per second, the requests are not limited if it is 0.
+	RateLimit float64
+	// The max requests sent at once when the bucket is full, defaults to RateLimit.
+	Burst int
+	// The max number of concurrent requests, defaults to 10.
+	MaxConcurrency int
+	// The max retries of a request failed with 429 or 5xx, defaults to 5.
+	// Set it to a negative number to disable retries.
+	MaxRetries int
+}
+
+func (o ClientOptions) maxRetries() int {
+	switch {
+	case o.MaxRetries < 0:
+		return 0
+	case o.MaxRetries == 0:
+		return defaultMaxRetries
+	default:
+		return o.MaxRetries
+	}
+}
+
+// retryPolicy tells whether a failed request is retried and how long to wait before the attempt, attempt starts from 1.
+type retryPolicy func(err error, attempt int) (time.Duration, bool)
+
+// requestPool bounds the concurrency and the rate of the requests sent to an idp instance.
+// It is shared by all the lists of the instance, so nested lists do not multiply the requests.
+type requestPool struct {
+	slots      chan struct{}
+	limiter    *rate.Limiter
+	maxRetries int
+	retry      retryPolicy
+}
+
+// newRequestPool creates the pool of an idp instance, the failed requests are not retried if retry is nil.
+func newRequestPool(opts ClientOptions, retry retryPolicy) *requestPool {
+	concurrency := opts.MaxConcurrency
+	if concurrency <= 0 {
+		concurrency = defaultMaxConcurrency
+	}
+
+	pool := &requestPool{
+		slots:      make(chan struct{}, concurrency),
+		maxRetries: opts.maxRetries(),
+		retry:      retry,
+	}
+	if opts.RateLimit > 0 {
+		burst := opts.Burst
+		if burst <= 0 {
+			burst = int(math.Ceil(opts.RateLimit))
+		}
+		pool.limiter = rate.NewLimiter(rate.Limit(opts.RateLimit), burst)
+	}
+	return pool
+}
+
+// Size returns the max number of concurrent requests.
+func (p *requestPool) Size() int {
+	return cap(p.slots)
+}
+
+// Do waits for a free slot and a token of the rate limiter, then runs request.
+// The slot is only held while the request is running, so request must not wait for other requests in the pool.
+// The retries wait for a slot and a token as well, the slot is released while waiting between the attempts.
+func (p *requestPool) Do(ctx context.Context, request func() error) error {
+	for attempt := 1; ; attempt++ {
+		err := p.do(ctx, request)
+		if err == nil || ctx.Err() != nil || p.retry == nil || attempt > p.maxRetries {
+			return err
+		}
+		wait, ok := p.retry(err, attempt)
+		if !ok {
+			return err
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+	}
+}
+
+func (p *requestPool) do(ctx context.Context, request func() error) error {
+	select {
+	case p.slots <- struct{}{}:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	defer func() { <-p.slots }()
+
+	if p.limiter != nil {
+		if err := p.limiter.Wait(ctx); err != nil {
+			return err
+		}
+	}
+	return request()
+}
+
+// retryBackoff waits as long as Retry-After asks when the server is overloaded,
+// otherwise it backs off exponentially between min and max.
+func retryBackoff(min, max time.Duration, attemptNum int, resp *http.Response) time.Duration {
+	if resp != nil && (resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable) {
+		if wait, ok := parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()); ok {
+			return wait
+		}
+	}
+	return retryablehttp.DefaultBackoff(min, max, attemptNum, nil)
+}
+
+// parseRetryAfter parses the Retry-After header, it is either seconds or a http date.
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+
+	var wait time.Duration
+	if seconds, err := strconv.Atoi(value); err == nil {
+		wait = time.Duration(seconds) * time.Second
+	} else if date, err := http.ParseTime(value); err == nil {
+		wait = date.Sub(now)
+	} else {
+		return 0, false
+	}
+
+	if wait < 0 {
+		wait = 0
+	}
+	if wait > maxRetryAfter {
+		wait = maxRetryAfter
+	}
+	return wait, true
+}
@@ -0,0 +1,79 @@
+// Copyright 2023 Nautes Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package idp
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Request pool", func() {
+	errFailed := errors.New("failed")
+	retryAfter := func(wait time.Duration) retryPolicy {
+		return func(err error, attempt int) (time.Duration, bool) {
+			return wait, true
+		}
+	}
+
+	It("releases the slot while waiting for a retry", func() {
+		pool := newRequestPool(ClientOptions{MaxConcurrency: 1, MaxRetries: 1}, retryAfter(500*time.Millisecond))
+		attempts := 0
+		done := make(chan error)
+		go func() {
+			done <- pool.Do(context.Background(), func() error {
+				attempts++
+				if attempts == 1 {
+					return errFailed
+				}
+				return nil
+			})
+		}()
+
+		time.Sleep(50 * time.Millisecond)
+		start := time.Now()
+		Expect(pool.Do(context.Background(), func() error { return nil })).Should(Succeed())
+		Expect(time.Since(start)).Should(BeNumerically("<", 200*time.Millisecond))
+		Eventually(done).Should(Receive(BeNil()))
+		Expect(attempts).Should(Equal(2))
+	})
+
+	It("takes a token of the rate limiter for every retry", func() {
+		pool := newRequestPool(ClientOptions{RateLimit: 5, Burst: 1, MaxRetries: 2}, retryAfter(0))
+		attempts := 0
+		start := time.Now()
+		err := pool.Do(context.Background(), func() error {
+			attempts++
+			return errFailed
+		})
+		Expect(err).Should(MatchError(errFailed))
+		Expect(attempts).Should(Equal(3))
+		Expect(time.Since(start)).Should(BeNumerically(">=", 350*time.Millisecond))
+	})
+
+	It("does not retry without a retry policy", func() {
+		pool := newRequestPool(ClientOptions{}, nil)
+		attempts := 0
+		err := pool.Do(context.Background(), func() error {
+			attempts++
+			return errFailed
+		})
+		Expect(err).Should(MatchError(errFailed))
+		Expect(attempts).Should(Equal(1))
+	})
+})
@@ -0,0 +1,134 @@
+// Copyright 2023 Nautes Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package idp
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/nautes-labs/base-operator/pkg/schema"
+)
+
+// Scope restricts the groups and projects read from an idp to the selected subtrees of groups, the zero value selects everything.
+type Scope struct {
+	// The ids of the groups whose subtrees are selected.
+	GroupIds []string
+	// The globs of the full paths of the groups whose subtrees are selected, e.g. "bu1" and "bu1/team-*",
+	// the globs are matched by path.Match, so "*" does not match "/".
+	IncludeGroupPaths []string
+	// The globs of the full paths of the groups whose subtrees are not selected, they take precedence over the included ones.
+	ExcludeGroupPaths []string
+	// The minimum access level of the user of token in the selected groups and projects, e.g. 30 for developer in gitlab.
+	MinAccessLevel int
+}
+
+// Validate checks the globs of paths.
+func (s Scope) Validate() error {
+	patterns := append(append([]string{}, s.IncludeGroupPaths...), s.ExcludeGroupPaths...)
+	for _, pattern := range patterns {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid group path %q: %w", pattern, err)
+		}
+	}
+	return nil
+}
+
+// IsEmpty checks whether everything is selected.
+func (s Scope) IsEmpty() bool {
+	return !s.hasRoots() && len(s.ExcludeGroupPaths) == 0 && s.MinAccessLevel == 0
+}
+
+// hasRoots checks whether the groups are selected by ids or paths, otherwise all groups are selected except the excluded ones.
+func (s Scope) hasRoots() bool {
+	return len(s.GroupIds) > 0 || len(s.IncludeGroupPaths) > 0
+}
+
+// rootPaths returns the paths of the top-level groups in the included globs which can be read directly,
+// and whether there is a glob which has to be matched with all top-level groups, e.g. "bu-*/team".
+func (s Scope) rootPaths() ([]string, bool) {
+	paths := make([]string, 0, len(s.IncludeGroupPaths))
+	seen := make(map[string]bool)
+	listTopLevel := false
+	for _, pattern := range s.IncludeGroupPaths {
+		root, _, _ := strings.Cut(pattern, "/")
+		if strings.ContainsAny(root, "*?[\\") {
+			listTopLevel = true
+			continue
+		}
+		if !seen[root] {
+			seen[root] = true
+			paths = append(paths, root)
+		}
+	}
+	return paths, listTopLevel
+}
+
+// matchesTopLevel checks whether the top-level group may contain the groups matched by the included globs.
+func (s Scope) matchesTopLevel(fullPath string) bool {
+	for _, pattern := range s.IncludeGroupPaths {
+		root, _, _ := strings.Cut(pattern, "/")
+		if ok, _ := path.Match(root, fullPath); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Select returns the groups in the selected subtrees, fullPaths maps the group ids to the full paths.
+// A group is selected if it or one of its parents is selected by id or by the included globs, and none of them is excluded.
+// The parent id is cleared if the parent is not selected, so that the selected subtrees become the top-level groups.
+func (s Scope) Select(groups []*schema.Group, fullPaths map[string]string) []*schema.Group {
+	values := make([]schema.Group, 0, len(groups))
+	for _, group := range groups {
+		values = append(values, *group)
+	}
+	tree := schema.NewGroupTree(values)
+	ids := make(map[string]bool, len(s.GroupIds))
+	for _, id := range s.GroupIds {
+		ids[id] = true
+	}
+	matches := func(patterns []string, id string) bool {
+		for _, pattern := range patterns {
+			if ok, _ := path.Match(pattern, fullPaths[id]); ok {
+				return true
+			}
+		}
+		return false
+	}
+
+	selected := make(map[string]bool, len(groups))
+	for _, group := range groups {
+		included := !s.hasRoots()
+		excluded := false
+		for _, id := range tree.GetParentsIds(group.Identity, true) {
+			included = included || ids[id] || matches(s.IncludeGroupPaths, id)
+			excluded = excluded || matches(s.ExcludeGroupPaths, id)
+		}
+		selected[group.Identity] = included && !excluded
+	}
+
+	result := make([]*schema.Group, 0, len(groups))
+	for _, group := range groups {
+		if !selected[group.Identity] {
+			continue
+		}
+		if !selected[group.ParentId] {
+			group.ParentId = ""
+		}
+		result = append(result, group)
+	}
+	return result
+}
@@ -0,0 +1,88 @@
+// Copyright 2023 Nautes Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package idp
+
+import (
+	"github.com/nautes-labs/base-operator/pkg/schema"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Scope", func() {
+	var (
+		groups    []*schema.Group
+		fullPaths map[string]string
+	)
+
+	BeforeEach(func() {
+		fullPaths = map[string]string{
+			"1": "bu1",
+			"2": "bu1/team-a",
+			"3": "bu1/team-a/tools",
+			"4": "bu1/legacy",
+			"5": "bu2",
+			"6": "bu2/team-b",
+		}
+		parents := map[string]string{"2": "1", "3": "2", "4": "1", "6": "5"}
+		groups = nil
+		for _, id := range []string{"1", "2", "3", "4", "5", "6"} {
+			groups = append(groups, &schema.Group{BaseEntity: schema.BaseEntity{Identity: id}, ParentId: parents[id]})
+		}
+	})
+
+	selectIds := func(scope Scope) []string {
+		ids := make([]string, 0)
+		for _, group := range scope.Select(groups, fullPaths) {
+			ids = append(ids, group.Identity)
+		}
+		return ids
+	}
+
+	It("selects everything if it is empty", func() {
+		Expect(Scope{}.IsEmpty()).Should(BeTrue())
+		Expect(selectIds(Scope{})).Should(Equal([]string{"1", "2", "3", "4", "5", "6"}))
+	})
+
+	It("selects the subtrees of the groups", func() {
+		Expect(selectIds(Scope{GroupIds: []string{"5"}})).Should(Equal([]string{"5", "6"}))
+		Expect(selectIds(Scope{IncludeGroupPaths: []string{"bu1"}})).Should(Equal([]string{"1", "2", "3", "4"}))
+	})
+
+	It("excludes the subtrees of the excluded groups", func() {
+		Expect(selectIds(Scope{IncludeGroupPaths: []string{"bu1"}, ExcludeGroupPaths: []string{"bu1/team-*"}})).Should(Equal([]string{"1", "4"}))
+		Expect(selectIds(Scope{ExcludeGroupPaths: []string{"bu1"}})).Should(Equal([]string{"5", "6"}))
+	})
+
+	It("makes the selected subtrees the top-level groups", func() {
+		selected := Scope{IncludeGroupPaths: []string{"bu1/team-*"}}.Select(groups, fullPaths)
+		Expect(selected).Should(HaveLen(2))
+		Expect(selected[0].Identity).Should(Equal("2"))
+		Expect(selected[0].ParentId).Should(BeEmpty())
+		Expect(selected[1].ParentId).Should(Equal("2"))
+	})
+
+	It("finds the top-level groups to read", func() {
+		paths, listTopLevel := Scope{IncludeGroupPaths: []string{"bu1/team-*", "bu1", "bu2/*"}}.rootPaths()
+		Expect(paths).Should(Equal([]string{"bu1", "bu2"}))
+		Expect(listTopLevel).Should(BeFalse())
+		_, listTopLevel = Scope{IncludeGroupPaths: []string{"bu-*/team"}}.rootPaths()
+		Expect(listTopLevel).Should(BeTrue())
+	})
+
+	It("refuses the invalid globs", func() {
+		Expect(Scope{IncludeGroupPaths: []string{"bu["}}.Validate()).ShouldNot(Succeed())
+		Expect(Scope{ExcludeGroupPaths: []string{"bu1/*"}}.Validate()).Should(Succeed())
+	})
+})
@@ -16,11 +16,67 @@ package syncer
 
 import (
 	"context"
+	"fmt"
 
 	nautescrd "github.com/nautes-labs/pkg/api/v1alpha1"
 )
 
 type ProductSyncer interface {
 	Sync(context.Context, nautescrd.Product) error
-	Delete(context.Context, nautescrd.Product) error
+	// SyncBaseline only applies the baseline objects to the namespace of product, which is synced by Sync before.
+	SyncBaseline(context.Context, nautescrd.Product) error
+	// Delete removes the resources of product without waiting for them to be gone.
+	// It returns the phase of the deletion, caller should call it again later until the phase is DeletionPhaseDone.
+	Delete(context.Context, nautescrd.Product) (DeletionPhase, error)
+	// GetAppStatus returns the status of the argocd app which deploys the product metadata.
+	// It returns nil if the app has not been created yet.
+	GetAppStatus(context.Context, nautescrd.Product) (*AppStatus, error)
+}
+
+// DeletionPhase is the step of product deletion, resources are removed in the order of the phases.
+type DeletionPhase string
+
+const (
+	// Waiting for the argocd app to be removed, the app has finalizers to clean up the deployed resources.
+	DeletionPhaseDeletingApp DeletionPhase = "DeletingApp"
+	// Waiting for the product namespace to be terminated.
+	DeletionPhaseDeletingNamespace DeletionPhase = "DeletingNamespace"
+	DeletionPhaseDone              DeletionPhase = "Done"
+)
+
+// AppStatus records the state of the argocd app of a product
+type AppStatus struct {
+	// Argocd app name
+	Name string
+	// Sync status of the app, such as Synced, OutOfSync
+	SyncStatus string
+	// The revision of the last successful sync
+	Revision string
+	// Health status of the app, such as Healthy, Degraded
+	Health        string
+	HealthMessage string
+	// Phase and message of the last sync operation
+	OperationPhase   string
+	OperationMessage string
+	// Error messages reported by argocd, such as the metadata repo can not be accessed
+	Errors []string
+}
+
+// ResourceConflictError means a resource needed by the product already exists and can not be adopted.
+type ResourceConflictError struct {
+	Kind      string
+	Namespace string
+	Name      string
+	// Who owns the existing resource, such as an owner reference, another product or a field manager
+	Owner string
+	// Why the resource can not be adopted, such as AlreadyExists, NotEmpty
+	Reason string
+}
+
+func (e *ResourceConflictError) Error() string {
+	name := e.Name
+	if e.Namespace != "" {
+		name = fmt.Sprintf("%s/%s", e.Namespace, e.Name)
+	}
+	return fmt.Sprintf("%s %s already exists and can not be adopted (%s), owner: %s", e.Kind, name, e.Reason, e.Owner)
 }
@@ -12,17 +12,53 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
+// Package log carries logr loggers in context for the sync engine,
+// so that every log line of a sync can be correlated by the same keys.
 package log
 
-import "github.com/sirupsen/logrus"
+import (
+	"context"
 
-var Loger *logrus.Entry
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/util/uuid"
+	crlog "sigs.k8s.io/controller-runtime/pkg/log"
+)
 
-func init() {
-	Loger = logrus.WithFields(logrus.Fields{
-		"app_name": "base-operator",
-	})
-	Loger.Logger.SetLevel(logrus.InfoLevel)
-	Loger.Logger.SetFormatter(&logrus.JSONFormatter{})
-	Loger.Logger.SetReportCaller(true)
+// Keys of the sync engine logs.
+const (
+	KeyConfig        = "config"
+	KeyIdpKind       = "idpKind"
+	KeyIdpName       = "idpName"
+	KeyTargetKind    = "targetKind"
+	KeyTargetName    = "targetName"
+	KeyEntity        = "entity"
+	KeyIdentity      = "identity"
+	KeyCorrelationID = "correlationID"
+)
+
+type correlationIDKey struct{}
+
+// FromContext returns the logger in ctx with the additional keysAndValues,
+// it falls back to the controller-runtime global logger.
+func FromContext(ctx context.Context, keysAndValues ...interface{}) logr.Logger {
+	return crlog.FromContext(ctx, keysAndValues...)
+}
+
+// WithValues returns a copy of ctx whose logger has the additional keysAndValues.
+func WithValues(ctx context.Context, keysAndValues ...interface{}) context.Context {
+	return crlog.IntoContext(ctx, FromContext(ctx, keysAndValues...))
+}
+
+// WithCorrelationID generates a correlation ID and adds it to ctx and its logger.
+// It should be called once at the beginning of a reconcile.
+func WithCorrelationID(ctx context.Context) context.Context {
+	id := string(uuid.NewUUID())
+	ctx = context.WithValue(ctx, correlationIDKey{}, id)
+	return WithValues(ctx, KeyCorrelationID, id)
+}
+
+// CorrelationIDFromContext returns the correlation ID in ctx, empty if there is none.
+func CorrelationIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(correlationIDKey{}).(string)
+	return id
 }
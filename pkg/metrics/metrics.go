@@ -0,0 +1,119 @@
+// Copyright 2023 Nautes Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package metrics defines the prometheus metrics of base operator.
+// All metrics are registered in the registry of controller-runtime, they are exposed by the metrics endpoint of manager.
+package metrics
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	crmetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+const namespace = "base_operator"
+
+// Operations on products in ProductsSynced.
+const (
+	OperationCreate = "create"
+	OperationUpdate = "update"
+	OperationDelete = "delete"
+)
+
+// Results of operations.
+const (
+	ResultSuccess = "success"
+	ResultFailure = "failure"
+)
+
+var (
+	// ProductsSynced counts the products changed by the product provider sync.
+	ProductsSynced = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "provider_products_synced_total",
+		Help:      "Number of products created, updated or deleted by product provider sync.",
+	}, []string{"provider", "operation", "result"})
+
+	// SyncPhaseDuration records how long each phase of the base data sync takes.
+	SyncPhaseDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "sync_phase_duration_seconds",
+		Help:      "Duration of the phases of base data sync.",
+		Buckets:   prometheus.ExponentialBuckets(0.1, 2, 12),
+	}, []string{"phase", "target"})
+
+	// SyncItems counts the entities written to target apps by the base data sync.
+	SyncItems = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "sync_items_total",
+		Help:      "Number of users, groups and projects written to target apps.",
+	}, []string{"target", "entity", "operation", "result"})
+
+	// HTTPRequests counts the requests sent to external services, such as gitlab, nexus and vault.
+	HTTPRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "Number of http requests sent to external services.",
+	}, []string{"client", "method", "endpoint", "code"})
+
+	// HTTPRequestDuration records the latencies of the requests sent to external services.
+	HTTPRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "Latency of http requests sent to external services.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"client", "method", "endpoint", "code"})
+
+	// LastSuccessfulSync is the unix time of the last successful sync of a resource, it is used for alerting.
+	LastSuccessfulSync = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "last_successful_sync_timestamp_seconds",
+		Help:      "Unix time of the last successful sync of a resource.",
+	}, []string{"kind", "namespace", "name"})
+)
+
+func init() {
+	crmetrics.Registry.MustRegister(
+		ProductsSynced,
+		SyncPhaseDuration,
+		SyncItems,
+		HTTPRequests,
+		HTTPRequestDuration,
+		LastSuccessfulSync,
+	)
+}
+
+// ObservePhase records the duration of a sync phase started at start.
+func ObservePhase(phase, target string, start time.Time) {
+	SyncPhaseDuration.WithLabelValues(phase, target).Observe(time.Since(start).Seconds())
+}
+
+// SetLastSuccessfulSync sets the last successful sync time of a resource to now.
+func SetLastSuccessfulSync(kind, namespace, name string) {
+	LastSuccessfulSync.WithLabelValues(kind, namespace, name).SetToCurrentTime()
+}
+
+// DeleteLastSuccessfulSync removes the series of a deleted resource.
+func DeleteLastSuccessfulSync(kind, namespace, name string) {
+	LastSuccessfulSync.DeleteLabelValues(kind, namespace, name)
+}
+
+// Result returns the result label of err.
+func Result(err error) string {
+	if err != nil {
+		return ResultFailure
+	}
+	return ResultSuccess
+}
@@ -0,0 +1,91 @@
+// Copyright 2023 Nautes Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The segments after these ones are ids or paths of gitlab groups and projects.
+var idPrefixSegments = map[string]bool{
+	"groups":   true,
+	"projects": true,
+}
+
+// Names of the clients in the http metrics.
+const (
+	ClientGitlab = "gitlab"
+	ClientNexus  = "nexus"
+	ClientVault  = "vault"
+)
+
+// transport records the count and latency of requests sent by the wrapped round tripper.
+type transport struct {
+	client       string
+	maxSegments  int
+	roundTripper http.RoundTripper
+}
+
+// NewTransport wraps rt with http metrics, rt is http.DefaultTransport if it is nil.
+// The endpoint label is the escaped request path with the numeric segments and the segments after "groups" and
+// "projects" replaced by ":id", and cut to the first maxSegments segments, so that ids and names in path do not blow
+// up the series.
+func NewTransport(client string, maxSegments int, rt http.RoundTripper) http.RoundTripper {
+	if rt == nil {
+		rt = http.DefaultTransport
+	}
+	return &transport{
+		client:       client,
+		maxSegments:  maxSegments,
+		roundTripper: rt,
+	}
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	resp, err := t.roundTripper.RoundTrip(req)
+
+	code := "error"
+	if err == nil {
+		code = strconv.Itoa(resp.StatusCode)
+	}
+	endpoint := Endpoint(req.URL.EscapedPath(), t.maxSegments)
+	HTTPRequests.WithLabelValues(t.client, req.Method, endpoint, code).Inc()
+	HTTPRequestDuration.WithLabelValues(t.client, req.Method, endpoint, code).Observe(time.Since(start).Seconds())
+
+	return resp, err
+}
+
+// Endpoint converts the request path to a low cardinality endpoint name.
+// The path should be escaped, so that an url encoded group or project path stays in one segment.
+func Endpoint(path string, maxSegments int) string {
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	if maxSegments > 0 && len(segments) > maxSegments {
+		segments = segments[:maxSegments]
+	}
+	for i, segment := range segments {
+		if i > 0 && idPrefixSegments[segments[i-1]] {
+			segments[i] = ":id"
+			continue
+		}
+		if _, err := strconv.Atoi(segment); err == nil {
+			segments[i] = ":id"
+		}
+	}
+	return "/" + strings.Join(segments, "/")
+}
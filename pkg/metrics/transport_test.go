@@ -0,0 +1,52 @@
+// Copyright 2023 Nautes Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+var _ = Describe("Http metrics", func() {
+	It("replaces ids and cuts long paths in endpoint", func() {
+		Expect(Endpoint("/api/v4/groups/123/members", 5)).Should(Equal("/api/v4/groups/:id/members"))
+		Expect(Endpoint("/service/rest/v1/security/users/admin", 5)).Should(Equal("/service/rest/v1/security/users"))
+		Expect(Endpoint("/v1/auth/kubernetes/login", 3)).Should(Equal("/v1/auth/kubernetes"))
+	})
+
+	It("replaces the group and project paths in endpoint", func() {
+		Expect(Endpoint("/api/v4/groups/product-1/members", 5)).Should(Equal("/api/v4/groups/:id/members"))
+		Expect(Endpoint("/api/v4/projects/product-1%2Frepo-1/members/all", 5)).Should(Equal("/api/v4/projects/:id/members"))
+	})
+
+	It("counts requests by endpoint and status", func() {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNotFound)
+		}))
+		defer server.Close()
+
+		client := &http.Client{Transport: NewTransport("test", 3, nil)}
+		resp, err := client.Get(server.URL + "/v1/users/42")
+		Expect(err).Should(BeNil())
+		resp.Body.Close()
+
+		count := testutil.ToFloat64(HTTPRequests.WithLabelValues("test", http.MethodGet, "/v1/users/:id", "404"))
+		Expect(count).Should(Equal(float64(1)))
+	})
+})
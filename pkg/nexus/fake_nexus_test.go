@@ -0,0 +1,230 @@
+// Copyright 2023 Nautes Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nexus
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const (
+	fakeNexusUsername = "admin"
+	fakeNexusPassword = "admin123"
+)
+
+// fakeNexus is an in-memory stand-in of the nexus rest api used by the services.
+type fakeNexus struct {
+	lock         sync.Mutex
+	items        map[string]map[string]map[string]interface{}
+	activeRealms []string
+	saml         map[string]interface{}
+}
+
+func newFakeNexus() *fakeNexus {
+	return &fakeNexus{
+		items:        map[string]map[string]map[string]interface{}{},
+		activeRealms: []string{"NexusAuthenticatingRealm", "NexusAuthorizingRealm"},
+	}
+}
+
+func (f *fakeNexus) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if username, password, ok := r.BasicAuth(); !ok || username != fakeNexusUsername || password != fakeNexusPassword {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/service/rest/v1/"), "/")
+	if parts[0] == "security" && len(parts) > 1 {
+		f.serveSecurity(w, r, parts[1], parts[2:])
+		return
+	}
+	if parts[0] == "repositories" {
+		f.serveRepositories(w, r, parts[1:])
+		return
+	}
+	w.WriteHeader(http.StatusNotFound)
+}
+
+func (f *fakeNexus) serveSecurity(w http.ResponseWriter, r *http.Request, resource string, args []string) {
+	switch {
+	case resource == "privileges" && r.Method == http.MethodPost && len(args) == 1:
+		f.create(w, r, resource, http.StatusCreated, map[string]interface{}{"type": args[0]})
+	case resource == "privileges" && r.Method == http.MethodPut && len(args) == 2:
+		f.update(w, r, resource, args[1], map[string]interface{}{"type": args[0]})
+	case resource == "realms" && len(args) == 1 && args[0] == "available" && r.Method == http.MethodGet:
+		writeJSON(w, []map[string]string{
+			{"id": "NexusAuthenticatingRealm", "name": "Local Authenticating Realm"},
+			{"id": "NexusAuthorizingRealm", "name": "Local Authorizing Realm"},
+			{"id": "LdapRealm", "name": "LDAP Realm"},
+		})
+	case resource == "realms" && len(args) == 1 && args[0] == "active" && r.Method == http.MethodGet:
+		writeJSON(w, f.activeRealms)
+	case resource == "realms" && len(args) == 1 && args[0] == "active" && r.Method == http.MethodPut:
+		f.activeRealms = nil
+		if decode(w, r, &f.activeRealms) {
+			w.WriteHeader(http.StatusNoContent)
+		}
+	case resource == "saml" && r.Method == http.MethodGet:
+		if f.saml == nil {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		writeJSON(w, f.saml)
+	case resource == "saml" && r.Method == http.MethodPut:
+		if decode(w, r, &f.saml) {
+			w.WriteHeader(http.StatusNoContent)
+		}
+	case resource == "saml" && r.Method == http.MethodDelete:
+		f.saml = nil
+		w.WriteHeader(http.StatusNoContent)
+	case resource == "privileges" || resource == "content-selectors" || resource == "ldap":
+		createStatus := http.StatusNoContent
+		if resource == "ldap" {
+			createStatus = http.StatusCreated
+		}
+		f.serveCollection(w, r, resource, args, createStatus)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+// fakeRepositoryFormats maps the path segments of the formats served by the fake to the formats it lists.
+var fakeRepositoryFormats = map[string]string{
+	"maven":  "maven2",
+	"npm":    "npm",
+	"docker": "docker",
+	"raw":    "raw",
+}
+
+func (f *fakeNexus) serveRepositories(w http.ResponseWriter, r *http.Request, args []string) {
+	if (r.Method == http.MethodPost || r.Method == http.MethodPut) && len(args) > 0 {
+		if _, ok := fakeRepositoryFormats[args[0]]; !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+	}
+	switch {
+	case r.Method == http.MethodPost && len(args) == 2:
+		f.create(w, r, "repositories", http.StatusCreated, map[string]interface{}{"format": fakeRepositoryFormats[args[0]], "type": args[1]})
+	case r.Method == http.MethodPut && len(args) == 3:
+		f.update(w, r, "repositories", args[2], map[string]interface{}{"format": fakeRepositoryFormats[args[0]], "type": args[1]})
+	default:
+		f.serveCollection(w, r, "repositories", args, http.StatusCreated)
+	}
+}
+
+func (f *fakeNexus) serveCollection(w http.ResponseWriter, r *http.Request, collection string, args []string, createStatus int) {
+	switch {
+	case r.Method == http.MethodGet && len(args) == 0:
+		f.list(w, collection)
+	case r.Method == http.MethodGet && len(args) == 1:
+		item, ok := f.items[collection][args[0]]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		writeJSON(w, item)
+	case r.Method == http.MethodPost && len(args) == 0:
+		f.create(w, r, collection, createStatus, nil)
+	case r.Method == http.MethodPut && len(args) == 1:
+		f.update(w, r, collection, args[0], nil)
+	case r.Method == http.MethodDelete && len(args) == 1:
+		if _, ok := f.items[collection][args[0]]; !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		delete(f.items[collection], args[0])
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (f *fakeNexus) list(w http.ResponseWriter, collection string) {
+	names := make([]string, 0, len(f.items[collection]))
+	for name := range f.items[collection] {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	result := make([]map[string]interface{}, 0, len(names))
+	for _, name := range names {
+		result = append(result, f.items[collection][name])
+	}
+	writeJSON(w, result)
+}
+
+func (f *fakeNexus) create(w http.ResponseWriter, r *http.Request, collection string, status int, fields map[string]interface{}) {
+	item := map[string]interface{}{}
+	if !decode(w, r, &item) {
+		return
+	}
+	name, _ := item["name"].(string)
+	if _, ok := f.items[collection][name]; ok {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(w, `[{"id":"*","message":"%s '%s' already exists"}]`, collection, name)
+		return
+	}
+	for k, v := range fields {
+		item[k] = v
+	}
+	if f.items[collection] == nil {
+		f.items[collection] = map[string]map[string]interface{}{}
+	}
+	f.items[collection][name] = item
+	w.WriteHeader(status)
+}
+
+func (f *fakeNexus) update(w http.ResponseWriter, r *http.Request, collection, name string, fields map[string]interface{}) {
+	old, ok := f.items[collection][name]
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	item := map[string]interface{}{}
+	if !decode(w, r, &item) {
+		return
+	}
+	for k, v := range fields {
+		if old[k] != v {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+	}
+	for k, v := range fields {
+		item[k] = v
+	}
+	f.items[collection][name] = item
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func decode(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return false
+	}
+	return true
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -16,18 +16,24 @@ package nexus
 
 import (
 	"github.com/nautes-labs/base-operator/pkg/nexus/pkg/client"
+	"github.com/nautes-labs/base-operator/pkg/nexus/pkg/repository"
 	"github.com/nautes-labs/base-operator/pkg/nexus/pkg/security"
 )
 
 type NexusClient struct {
-	client   *client.Client
-	Security *security.SecurityService
+	client     *client.Client
+	Security   *security.SecurityService
+	Repository *repository.RepositoryService
 }
 
-func NewClient(config client.Config) *NexusClient {
-	newClient := client.NewClient(config)
-	return &NexusClient{
-		client:   newClient,
-		Security: security.NewSecurityService(newClient),
+func NewClient(config client.Config) (*NexusClient, error) {
+	newClient, err := client.NewClient(config)
+	if err != nil {
+		return nil, err
 	}
+	return &NexusClient{
+		client:     newClient,
+		Security:   security.NewSecurityService(newClient),
+		Repository: repository.NewRepositoryService(newClient),
+	}, nil
 }
@@ -0,0 +1,241 @@
+// Copyright 2023 Nautes Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nexus
+
+import (
+	"context"
+	"net/http/httptest"
+
+	"github.com/nautes-labs/base-operator/pkg/nexus/pkg/client"
+	"github.com/nautes-labs/base-operator/pkg/nexus/schema/repository"
+	"github.com/nautes-labs/base-operator/pkg/nexus/schema/security"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Nexus services", func() {
+	var (
+		ctx    context.Context
+		server *httptest.Server
+		nexus  *NexusClient
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		server = httptest.NewServer(newFakeNexus())
+		DeferCleanup(server.Close)
+
+		var err error
+		nexus, err = NewClient(client.Config{
+			URL:        server.URL,
+			Username:   fakeNexusUsername,
+			Password:   fakeNexusPassword,
+			MaxRetries: -1,
+		})
+		Expect(err).Should(BeNil())
+	})
+
+	It("returns unauthorized error with wrong credentials", func() {
+		c, err := NewClient(client.Config{URL: server.URL, Username: fakeNexusUsername, Password: "wrong"})
+		Expect(err).Should(BeNil())
+		_, err = c.Security.Privilege.List(ctx)
+		Expect(client.IsUnauthorized(err)).Should(BeTrue())
+	})
+
+	It("manages privileges of each type", func() {
+		view := security.Privilege{
+			Type:        security.PrivilegeTypeRepositoryView,
+			Name:        "maven-releases-read",
+			Description: "read maven releases",
+			Actions:     []string{security.PrivilegeActionBrowse, security.PrivilegeActionRead},
+			Format:      "maven2",
+			Repository:  "maven-releases",
+		}
+		content := security.Privilege{
+			Type:            security.PrivilegeTypeRepositoryContentSelector,
+			Name:            "product1-write",
+			Description:     "write product1 artifacts",
+			Actions:         []string{security.PrivilegeActionAll},
+			Format:          "maven2",
+			Repository:      "*",
+			ContentSelector: "product1",
+		}
+		Expect(nexus.Security.Privilege.Create(ctx, view)).Should(Succeed())
+		Expect(nexus.Security.Privilege.Create(ctx, content)).Should(Succeed())
+
+		err := nexus.Security.Privilege.Create(ctx, view)
+		Expect(client.IsConflict(err)).Should(BeTrue())
+
+		privileges, err := nexus.Security.Privilege.List(ctx)
+		Expect(err).Should(BeNil())
+		Expect(privileges).Should(HaveLen(2))
+
+		privilege, err := nexus.Security.Privilege.Get(ctx, content.Name)
+		Expect(err).Should(BeNil())
+		Expect(*privilege).Should(Equal(content))
+
+		view.Actions = []string{security.PrivilegeActionBrowse}
+		Expect(nexus.Security.Privilege.Update(ctx, view.Name, view)).Should(Succeed())
+		privilege, err = nexus.Security.Privilege.Get(ctx, view.Name)
+		Expect(err).Should(BeNil())
+		Expect(privilege.Actions).Should(Equal(view.Actions))
+
+		Expect(nexus.Security.Privilege.Delete(ctx, view.Name)).Should(Succeed())
+		_, err = nexus.Security.Privilege.Get(ctx, view.Name)
+		Expect(client.IsNotFound(err)).Should(BeTrue())
+		err = nexus.Security.Privilege.Delete(ctx, view.Name)
+		Expect(client.IsNotFound(err)).Should(BeTrue())
+	})
+
+	It("manages content selectors", func() {
+		selector := security.ContentSelector{
+			Name:        "product1",
+			Type:        security.ContentSelectorTypeCSEL,
+			Description: "artifacts of product1",
+			Expression:  `format == "maven2" and path =^ "/com/product1/"`,
+		}
+		Expect(nexus.Security.ContentSelector.Create(ctx, selector)).Should(Succeed())
+
+		selectors, err := nexus.Security.ContentSelector.List(ctx)
+		Expect(err).Should(BeNil())
+		Expect(selectors).Should(HaveLen(1))
+		Expect(*selectors[0]).Should(Equal(selector))
+
+		selector.Expression = `path =^ "/com/product1/"`
+		Expect(nexus.Security.ContentSelector.Update(ctx, selector.Name, selector)).Should(Succeed())
+		got, err := nexus.Security.ContentSelector.Get(ctx, selector.Name)
+		Expect(err).Should(BeNil())
+		Expect(got.Expression).Should(Equal(selector.Expression))
+
+		Expect(nexus.Security.ContentSelector.Delete(ctx, selector.Name)).Should(Succeed())
+		_, err = nexus.Security.ContentSelector.Get(ctx, selector.Name)
+		Expect(client.IsNotFound(err)).Should(BeTrue())
+	})
+
+	It("manages hosted and group repositories", func() {
+		hosted := repository.HostedRepository{
+			Name:   "product1-releases",
+			Online: true,
+			Storage: repository.Storage{
+				BlobStoreName:               repository.DefaultBlobStoreName,
+				StrictContentTypeValidation: true,
+				WritePolicy:                 repository.WritePolicyAllowOnce,
+			},
+			Maven: &repository.Maven{VersionPolicy: "RELEASE", LayoutPolicy: "STRICT"},
+		}
+		group := repository.GroupRepository{
+			Name:    "product1-public",
+			Online:  true,
+			Storage: repository.Storage{BlobStoreName: repository.DefaultBlobStoreName},
+			Group:   repository.Group{MemberNames: []string{hosted.Name}},
+		}
+		Expect(nexus.Repository.CreateHosted(ctx, repository.FormatMaven2, hosted)).Should(Succeed())
+		Expect(nexus.Repository.CreateGroup(ctx, repository.FormatMaven2, group)).Should(Succeed())
+		err := nexus.Repository.CreateHosted(ctx, repository.FormatMaven2, hosted)
+		Expect(client.IsConflict(err)).Should(BeTrue())
+
+		repositories, err := nexus.Repository.List(ctx)
+		Expect(err).Should(BeNil())
+		Expect(repositories).Should(HaveLen(2))
+		Expect(repositories[0].Name).Should(Equal(group.Name))
+		Expect(repositories[0].Type).Should(Equal(repository.TypeGroup))
+		Expect(repositories[1].Name).Should(Equal(hosted.Name))
+		Expect(repositories[1].Type).Should(Equal(repository.TypeHosted))
+		Expect(repositories[1].Format).Should(Equal(repository.FormatMaven2))
+
+		hosted.Online = false
+		Expect(nexus.Repository.UpdateHosted(ctx, repository.FormatMaven2, hosted.Name, hosted)).Should(Succeed())
+		repo, err := nexus.Repository.Get(ctx, hosted.Name)
+		Expect(err).Should(BeNil())
+		Expect(repo.Online).Should(BeFalse())
+
+		err = nexus.Repository.UpdateGroup(ctx, "npm", group.Name, group)
+		Expect(err).ShouldNot(BeNil())
+		err = nexus.Repository.CreateHosted(ctx, "maven", hosted)
+		Expect(err).ShouldNot(BeNil())
+
+		Expect(nexus.Repository.Delete(ctx, group.Name)).Should(Succeed())
+		_, err = nexus.Repository.Get(ctx, group.Name)
+		Expect(client.IsNotFound(err)).Should(BeTrue())
+	})
+
+	It("activates realms", func() {
+		realms, err := nexus.Security.Realm.ListAvailable(ctx)
+		Expect(err).Should(BeNil())
+		Expect(realms).Should(ContainElement(&security.Realm{ID: security.RealmLDAP, Name: "LDAP Realm"}))
+
+		active := []string{security.RealmLocalAuthenticating, security.RealmLocalAuthorizing, security.RealmLDAP}
+		Expect(nexus.Security.Realm.SetActive(ctx, active)).Should(Succeed())
+		ids, err := nexus.Security.Realm.ListActive(ctx)
+		Expect(err).Should(BeNil())
+		Expect(ids).Should(Equal(active))
+	})
+
+	It("manages ldap servers", func() {
+		ldap := security.LDAP{
+			Name:                        "ldap1",
+			Protocol:                    "ldaps",
+			Host:                        "ldap.example.com",
+			Port:                        636,
+			SearchBase:                  "dc=example,dc=com",
+			AuthScheme:                  "SIMPLE",
+			AuthUsername:                "cn=admin,dc=example,dc=com",
+			AuthPassword:                "secret",
+			ConnectionTimeoutSeconds:    30,
+			ConnectionRetryDelaySeconds: 300,
+			MaxIncidentsCount:           3,
+			UserBaseDn:                  "ou=users",
+			UserObjectClass:             "inetOrgPerson",
+			UserIdAttribute:             "uid",
+			LdapGroupsAsRoles:           true,
+			GroupType:                   "static",
+		}
+		Expect(nexus.Security.LDAP.Create(ctx, ldap)).Should(Succeed())
+
+		servers, err := nexus.Security.LDAP.List(ctx)
+		Expect(err).Should(BeNil())
+		Expect(servers).Should(HaveLen(1))
+
+		ldap.Port = 10636
+		Expect(nexus.Security.LDAP.Update(ctx, ldap.Name, ldap)).Should(Succeed())
+		got, err := nexus.Security.LDAP.Get(ctx, ldap.Name)
+		Expect(err).Should(BeNil())
+		Expect(*got).Should(Equal(ldap))
+
+		Expect(nexus.Security.LDAP.Delete(ctx, ldap.Name)).Should(Succeed())
+		err = nexus.Security.LDAP.Delete(ctx, ldap.Name)
+		Expect(client.IsNotFound(err)).Should(BeTrue())
+	})
+
+	It("manages saml configuration", func() {
+		_, err := nexus.Security.SAML.Get(ctx)
+		Expect(client.IsNotFound(err)).Should(BeTrue())
+
+		saml := security.SAML{
+			IdpMetadata:       "<EntityDescriptor/>",
+			EntityId:          "https://nexus.example.com/service/rest/v1/security/saml/metadata",
+			UsernameAttribute: "username",
+			GroupsAttribute:   "groups",
+		}
+		Expect(nexus.Security.SAML.Apply(ctx, saml)).Should(Succeed())
+		got, err := nexus.Security.SAML.Get(ctx)
+		Expect(err).Should(BeNil())
+		Expect(*got).Should(Equal(saml))
+
+		Expect(nexus.Security.SAML.Delete(ctx)).Should(Succeed())
+		_, err = nexus.Security.SAML.Get(ctx)
+		Expect(client.IsNotFound(err)).Should(BeTrue())
+	})
+})
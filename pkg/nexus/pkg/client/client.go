@@ -15,55 +15,110 @@
 package client
 
 import (
+	"context"
 	"crypto/tls"
+	"crypto/x509"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"net/http"
 	"time"
+
+	"github.com/hashicorp/go-retryablehttp"
+	"github.com/nautes-labs/base-operator/pkg/metrics"
+	"github.com/nautes-labs/base-operator/pkg/tracing"
 )
 
 const (
 	ContentTypeApplicationJSON = "application/json"
 	BasePath                   = "service/rest/"
+	// "/service/rest/v1/security/users"
+	endpointSegments = 5
+
+	defaultTimeout      = 30 * time.Second
+	defaultMaxRetries   = 3
+	defaultRetryWaitMin = 500 * time.Millisecond
+	defaultRetryWaitMax = 10 * time.Second
 )
 
 type Config struct {
 	URL      string `json:"url"`
 	Username string `json:"username"`
 	Password string `json:"password"`
-	Insecure bool   `json:"insecure"`
+	// Insecure skips the verification of the server certificate.
+	Insecure bool `json:"insecure"`
+	// CABundle is the PEM encoded CA certificates used to verify the server certificate,
+	// the system roots are used if it is empty.
+	CABundle []byte `json:"caBundle"`
+	// MaxRetries is the max retries of a request failed with connection errors, 429 or 5xx,
+	// negative disables retries and 0 means the default.
+	MaxRetries int `json:"maxRetries"`
+}
+
+func (c Config) maxRetries() int {
+	if c.MaxRetries < 0 {
+		return 0
+	}
+	if c.MaxRetries == 0 {
+		return defaultMaxRetries
+	}
+	return c.MaxRetries
+}
+
+func (c Config) tlsConfig() (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		InsecureSkipVerify: c.Insecure,
+	}
+	if len(c.CABundle) > 0 {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(c.CABundle) {
+			return nil, fmt.Errorf("no valid certificate found in ca bundle")
+		}
+		tlsConfig.RootCAs = pool
+	}
+	return tlsConfig, nil
 }
 
 type Client struct {
 	config      Config
 	contentType string
-	httpClient  *http.Client
+	httpClient  *retryablehttp.Client
 }
 
 type Service struct {
 	Client *Client
 }
 
-func NewClient(config Config) *Client {
+func NewClient(config Config) (*Client, error) {
+	tlsConfig, err := config.tlsConfig()
+	if err != nil {
+		return nil, fmt.Errorf("load tls config of %s failed: %w", config.URL, err)
+	}
 	return &Client{
 		config:      config,
 		contentType: ContentTypeApplicationJSON,
-		httpClient: &http.Client{
-			Timeout: 30 * time.Second,
-			Transport: &http.Transport{
-				Proxy: http.ProxyFromEnvironment,
-				TLSClientConfig: &tls.Config{
-					InsecureSkipVerify: config.Insecure,
-				},
+		httpClient: &retryablehttp.Client{
+			HTTPClient: &http.Client{
+				Timeout: defaultTimeout,
+				Transport: tracing.NewTransport(metrics.ClientNexus, endpointSegments, metrics.NewTransport(metrics.ClientNexus, endpointSegments, &http.Transport{
+					Proxy:           http.ProxyFromEnvironment,
+					TLSClientConfig: tlsConfig,
+				})),
 			},
+			RetryWaitMin: defaultRetryWaitMin,
+			RetryWaitMax: defaultRetryWaitMax,
+			RetryMax:     config.maxRetries(),
+			CheckRetry:   retryablehttp.DefaultRetryPolicy,
+			Backoff:      retryablehttp.DefaultBackoff,
+			// keep the last response so that it can be converted to a typed error
+			ErrorHandler: retryablehttp.PassthroughErrorHandler,
 		},
-	}
+	}, nil
 }
 
-func (c *Client) NewRequest(method string, endpoint string, body io.Reader) (req *http.Request, err error) {
+func (c *Client) NewRequest(ctx context.Context, method string, endpoint string, body io.Reader) (req *retryablehttp.Request, err error) {
 	url := fmt.Sprintf("%s/%s", c.config.URL, endpoint)
-	req, err = http.NewRequest(method, url, body)
+	req, err = retryablehttp.NewRequestWithContext(ctx, method, url, body)
 	if err != nil {
 		return req, err
 	}
@@ -75,15 +130,15 @@ func (c *Client) NewRequest(method string, endpoint string, body io.Reader) (req
 	return req, nil
 }
 
-func (c *Client) execute(method string, endpoint string, payload io.Reader) ([]byte, *http.Response, error) {
-	req, err := c.NewRequest(method, endpoint, payload)
+func (c *Client) execute(ctx context.Context, method string, endpoint string, payload io.Reader) ([]byte, *http.Response, error) {
+	req, err := c.NewRequest(ctx, method, endpoint, payload)
 	if err != nil {
 		return nil, nil, err
 	}
 
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
-		return nil, nil, err
+		return nil, nil, fmt.Errorf("%s %s failed: %w", method, endpoint, err)
 	}
 	defer resp.Body.Close()
 
@@ -91,18 +146,18 @@ func (c *Client) execute(method string, endpoint string, payload io.Reader) ([]b
 	return body, resp, err
 }
 
-func (c *Client) Get(endpoint string, payload io.Reader) ([]byte, *http.Response, error) {
-	return c.execute(http.MethodGet, endpoint, payload)
+func (c *Client) Get(ctx context.Context, endpoint string, payload io.Reader) ([]byte, *http.Response, error) {
+	return c.execute(ctx, http.MethodGet, endpoint, payload)
 }
 
-func (c *Client) Post(endpoint string, payload io.Reader) ([]byte, *http.Response, error) {
-	return c.execute(http.MethodPost, endpoint, payload)
+func (c *Client) Post(ctx context.Context, endpoint string, payload io.Reader) ([]byte, *http.Response, error) {
+	return c.execute(ctx, http.MethodPost, endpoint, payload)
 }
 
-func (c *Client) Put(endpoint string, payload io.Reader) ([]byte, *http.Response, error) {
-	return c.execute(http.MethodPut, endpoint, payload)
+func (c *Client) Put(ctx context.Context, endpoint string, payload io.Reader) ([]byte, *http.Response, error) {
+	return c.execute(ctx, http.MethodPut, endpoint, payload)
 }
 
-func (c *Client) Delete(endpoint string) ([]byte, *http.Response, error) {
-	return c.execute(http.MethodDelete, endpoint, nil)
+func (c *Client) Delete(ctx context.Context, endpoint string) ([]byte, *http.Response, error) {
+	return c.execute(ctx, http.MethodDelete, endpoint, nil)
 }
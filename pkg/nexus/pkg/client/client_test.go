@@ -0,0 +1,156 @@
+// Copyright 2023 Nautes Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"context"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Nexus client", func() {
+	var (
+		handler  http.HandlerFunc
+		requests int32
+	)
+
+	BeforeEach(func() {
+		requests = 0
+		handler = func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		}
+	})
+
+	newServer := func() *httptest.Server {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&requests, 1)
+			handler(w, r)
+		}))
+		DeferCleanup(server.Close)
+		return server
+	}
+
+	It("returns typed errors by the status code", func() {
+		server := newServer()
+		c, err := NewClient(Config{URL: server.URL, MaxRetries: -1})
+		Expect(err).Should(BeNil())
+
+		statusErrors := map[int]func(error) bool{
+			http.StatusNotFound:     IsNotFound,
+			http.StatusConflict:     IsConflict,
+			http.StatusUnauthorized: IsUnauthorized,
+			http.StatusForbidden:    IsUnauthorized,
+		}
+		for code, isErr := range statusErrors {
+			code := code
+			handler = func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(code)
+			}
+			body, resp, err := c.Get(context.Background(), "roles", nil)
+			Expect(err).Should(BeNil())
+			err = CheckResponse(resp, body, http.StatusOK)
+			Expect(isErr(err)).Should(BeTrue(), "status code %d", code)
+		}
+
+		handler = func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`[{"id":"*","message":"Role 'r1' already exists"}]`))
+		}
+		body, resp, err := c.Post(context.Background(), "roles", nil)
+		Expect(err).Should(BeNil())
+		err = CheckResponse(resp, body, http.StatusOK)
+		Expect(IsConflict(err)).Should(BeTrue())
+		Expect(IsNotFound(err)).Should(BeFalse())
+		Expect(err.Error()).Should(ContainSubstring("already exists"))
+	})
+
+	It("retries transient failures", func() {
+		server := newServer()
+		handler = func(w http.ResponseWriter, r *http.Request) {
+			if atomic.LoadInt32(&requests) < 3 {
+				w.Header().Set("Retry-After", "0")
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			w.WriteHeader(http.StatusOK)
+		}
+		c, err := NewClient(Config{URL: server.URL})
+		Expect(err).Should(BeNil())
+
+		_, resp, err := c.Put(context.Background(), "roles/r1", nil)
+		Expect(err).Should(BeNil())
+		Expect(resp.StatusCode).Should(Equal(http.StatusOK))
+		Expect(requests).Should(Equal(int32(3)))
+	})
+
+	It("returns the last response when retries are exhausted", func() {
+		server := newServer()
+		handler = func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		c, err := NewClient(Config{URL: server.URL, MaxRetries: 2})
+		Expect(err).Should(BeNil())
+
+		_, resp, err := c.Delete(context.Background(), "roles/r1")
+		Expect(err).Should(BeNil())
+		Expect(resp.StatusCode).Should(Equal(http.StatusServiceUnavailable))
+		Expect(requests).Should(Equal(int32(3)))
+	})
+
+	It("stops when the context is canceled", func() {
+		server := newServer()
+		c, err := NewClient(Config{URL: server.URL})
+		Expect(err).Should(BeNil())
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		_, _, err = c.Get(ctx, "roles", nil)
+		Expect(err).Should(MatchError(context.Canceled))
+		Expect(requests).Should(Equal(int32(0)))
+	})
+
+	It("verifies the server certificate with the ca bundle", func() {
+		server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		}))
+		DeferCleanup(server.Close)
+		caBundle := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+
+		c, err := NewClient(Config{URL: server.URL, MaxRetries: -1})
+		Expect(err).Should(BeNil())
+		_, _, err = c.Get(context.Background(), "roles", nil)
+		Expect(err).ShouldNot(BeNil())
+
+		c, err = NewClient(Config{URL: server.URL, CABundle: caBundle})
+		Expect(err).Should(BeNil())
+		_, resp, err := c.Get(context.Background(), "roles", nil)
+		Expect(err).Should(BeNil())
+		Expect(resp.StatusCode).Should(Equal(http.StatusOK))
+
+		c, err = NewClient(Config{URL: server.URL, Insecure: true})
+		Expect(err).Should(BeNil())
+		_, _, err = c.Get(context.Background(), "roles", nil)
+		Expect(err).Should(BeNil())
+
+		_, err = NewClient(Config{URL: server.URL, CABundle: []byte("invalid")})
+		Expect(err).ShouldNot(BeNil())
+	})
+})
@@ -0,0 +1,82 @@
+// Copyright 2023 Nautes Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+var (
+	ErrNotFound     = errors.New("not found")
+	ErrConflict     = errors.New("conflict")
+	ErrUnauthorized = errors.New("unauthorized")
+)
+
+// Error is returned when nexus responds with an unexpected status code.
+type Error struct {
+	Method     string
+	URL        string
+	StatusCode int
+	Message    string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s %s: %d %s", e.Method, e.URL, e.StatusCode, e.Message)
+}
+
+// Is makes the error match ErrNotFound, ErrConflict or ErrUnauthorized by the status code.
+func (e *Error) Is(target error) bool {
+	switch target {
+	case ErrNotFound:
+		return e.StatusCode == http.StatusNotFound
+	case ErrConflict:
+		// nexus answers 400 instead of 409 when the id of a new entity is already taken
+		return e.StatusCode == http.StatusConflict ||
+			e.StatusCode == http.StatusBadRequest && strings.Contains(e.Message, "already exists")
+	case ErrUnauthorized:
+		return e.StatusCode == http.StatusUnauthorized || e.StatusCode == http.StatusForbidden
+	}
+	return false
+}
+
+// CheckResponse returns an *Error if the status code of resp is not one of the expected codes.
+func CheckResponse(resp *http.Response, body []byte, expectedCodes ...int) error {
+	for _, code := range expectedCodes {
+		if resp.StatusCode == code {
+			return nil
+		}
+	}
+	return &Error{
+		Method:     resp.Request.Method,
+		URL:        resp.Request.URL.String(),
+		StatusCode: resp.StatusCode,
+		Message:    strings.TrimSpace(string(body)),
+	}
+}
+
+func IsNotFound(err error) bool {
+	return errors.Is(err, ErrNotFound)
+}
+
+func IsConflict(err error) bool {
+	return errors.Is(err, ErrConflict)
+}
+
+func IsUnauthorized(err error) bool {
+	return errors.Is(err, ErrUnauthorized)
+}
@@ -0,0 +1,186 @@
+// Copyright 2023 Nautes Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/nautes-labs/base-operator/pkg/nexus/pkg/client"
+	"github.com/nautes-labs/base-operator/pkg/nexus/schema/repository"
+	"github.com/nautes-labs/base-operator/pkg/util"
+)
+
+const (
+	repositoriesAPIEndpoint = client.BasePath + "v1/repositories"
+)
+
+// apiFormats maps the formats listed by Nexus to the segments of the create and update paths,
+// e.g. the maven2 repositories are created at /v1/repositories/maven/hosted.
+var apiFormats = map[string]string{
+	repository.FormatMaven2: "maven",
+	repository.FormatNpm:    "npm",
+	repository.FormatDocker: "docker",
+	repository.FormatRaw:    "raw",
+	repository.FormatPypi:   "pypi",
+	repository.FormatNuget:  "nuget",
+	repository.FormatHelm:   "helm",
+	repository.FormatGo:     "go",
+	"rubygems":              "rubygems",
+	"yum":                   "yum",
+	"apt":                   "apt",
+	"r":                     "r",
+	"conan":                 "conan",
+	"conda":                 "conda",
+	"cocoapods":             "cocoapods",
+	"p2":                    "p2",
+	"gitlfs":                "gitlfs",
+	"bower":                 "bower",
+}
+
+// apiFormat returns the path segment of the format.
+func apiFormat(format string) (string, error) {
+	segment, ok := apiFormats[format]
+	if !ok {
+		return "", fmt.Errorf("unsupported repository format %q", format)
+	}
+	return segment, nil
+}
+
+type RepositoryService client.Service
+
+func NewRepositoryService(c *client.Client) *RepositoryService {
+
+	s := &RepositoryService{
+		Client: c,
+	}
+	return s
+}
+
+func (s *RepositoryService) List(ctx context.Context) ([]*repository.Repository, error) {
+	body, resp, err := s.Client.Get(ctx, repositoriesAPIEndpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := client.CheckResponse(resp, body, http.StatusOK); err != nil {
+		return nil, err
+	}
+	var repositories []*repository.Repository
+	if err := json.Unmarshal(body, &repositories); err != nil {
+		return nil, fmt.Errorf("could not unmarshal repositories: %v", err)
+	}
+	return repositories, nil
+}
+
+func (s *RepositoryService) Get(ctx context.Context, name string) (*repository.Repository, error) {
+	encodedName := url.PathEscape(name)
+
+	body, resp, err := s.Client.Get(ctx, fmt.Sprintf("%s/%s", repositoriesAPIEndpoint, encodedName), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := client.CheckResponse(resp, body, http.StatusOK); err != nil {
+		return nil, err
+	}
+
+	var repo repository.Repository
+	if err := json.Unmarshal(body, &repo); err != nil {
+		return nil, fmt.Errorf("could not unmarshal repository: %v", err)
+	}
+	return &repo, nil
+}
+
+// CreateHosted creates a hosted repository of the format as listed by Nexus, e.g. maven2, npm, docker.
+func (s *RepositoryService) CreateHosted(ctx context.Context, format string, repo repository.HostedRepository) error {
+	return s.create(ctx, format, repository.TypeHosted, repo)
+}
+
+func (s *RepositoryService) UpdateHosted(ctx context.Context, format string, name string, repo repository.HostedRepository) error {
+	return s.update(ctx, format, repository.TypeHosted, name, repo)
+}
+
+// CreateGroup creates a group repository of the format, the members must have the same format.
+func (s *RepositoryService) CreateGroup(ctx context.Context, format string, repo repository.GroupRepository) error {
+	return s.create(ctx, format, repository.TypeGroup, repo)
+}
+
+func (s *RepositoryService) UpdateGroup(ctx context.Context, format string, name string, repo repository.GroupRepository) error {
+	return s.update(ctx, format, repository.TypeGroup, name, repo)
+}
+
+func (s *RepositoryService) Delete(ctx context.Context, name string) error {
+	encodedName := url.PathEscape(name)
+
+	body, resp, err := s.Client.Delete(ctx, fmt.Sprintf("%s/%s", repositoriesAPIEndpoint, encodedName))
+	if err != nil {
+		return err
+	}
+
+	if err := client.CheckResponse(resp, body, http.StatusOK, http.StatusNoContent); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (s *RepositoryService) create(ctx context.Context, format, repoType string, repo interface{}) error {
+	segment, err := apiFormat(format)
+	if err != nil {
+		return err
+	}
+	ioReader, err := util.JsonMarshalInterfaceToIOReader(repo)
+	if err != nil {
+		return err
+	}
+
+	body, resp, err := s.Client.Post(ctx, fmt.Sprintf("%s/%s/%s", repositoriesAPIEndpoint, segment, repoType), ioReader)
+	if err != nil {
+		return err
+	}
+
+	if err := client.CheckResponse(resp, body, http.StatusCreated); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (s *RepositoryService) update(ctx context.Context, format, repoType, name string, repo interface{}) error {
+	segment, err := apiFormat(format)
+	if err != nil {
+		return err
+	}
+	ioReader, err := util.JsonMarshalInterfaceToIOReader(repo)
+	if err != nil {
+		return err
+	}
+
+	endpoint := fmt.Sprintf("%s/%s/%s/%s", repositoriesAPIEndpoint, segment, repoType, url.PathEscape(name))
+	body, resp, err := s.Client.Put(ctx, endpoint, ioReader)
+	if err != nil {
+		return err
+	}
+
+	if err := client.CheckResponse(resp, body, http.StatusOK, http.StatusNoContent); err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,130 @@
+// Copyright 2023 Nautes Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package security
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/nautes-labs/base-operator/pkg/nexus/pkg/client"
+	"github.com/nautes-labs/base-operator/pkg/nexus/schema/security"
+	"github.com/nautes-labs/base-operator/pkg/util"
+)
+
+const (
+	securityContentSelectorsAPIEndpoint = securityAPIEndpoint + "/content-selectors"
+)
+
+type SecurityContentSelectorService client.Service
+
+func NewSecurityContentSelectorService(c *client.Client) *SecurityContentSelectorService {
+
+	s := &SecurityContentSelectorService{
+		Client: c,
+	}
+	return s
+}
+
+func (s *SecurityContentSelectorService) Create(ctx context.Context, selector security.ContentSelector) error {
+	ioReader, err := util.JsonMarshalInterfaceToIOReader(selector)
+	if err != nil {
+		return err
+	}
+
+	body, resp, err := s.Client.Post(ctx, securityContentSelectorsAPIEndpoint, ioReader)
+	if err != nil {
+		return err
+	}
+
+	if err := client.CheckResponse(resp, body, http.StatusOK, http.StatusNoContent); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (s *SecurityContentSelectorService) List(ctx context.Context) ([]*security.ContentSelector, error) {
+	body, resp, err := s.Client.Get(ctx, securityContentSelectorsAPIEndpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := client.CheckResponse(resp, body, http.StatusOK); err != nil {
+		return nil, err
+	}
+	var selectors []*security.ContentSelector
+	if err := json.Unmarshal(body, &selectors); err != nil {
+		return nil, fmt.Errorf("could not unmarshal content selectors: %v", err)
+	}
+	return selectors, nil
+}
+
+func (s *SecurityContentSelectorService) Get(ctx context.Context, name string) (*security.ContentSelector, error) {
+	encodedName := url.PathEscape(name)
+
+	body, resp, err := s.Client.Get(ctx, fmt.Sprintf("%s/%s", securityContentSelectorsAPIEndpoint, encodedName), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := client.CheckResponse(resp, body, http.StatusOK); err != nil {
+		return nil, err
+	}
+
+	var selector security.ContentSelector
+	if err := json.Unmarshal(body, &selector); err != nil {
+		return nil, fmt.Errorf("could not unmarshal content selector: %v", err)
+	}
+	return &selector, nil
+}
+
+// Update updates the description and expression of the content selector.
+func (s *SecurityContentSelectorService) Update(ctx context.Context, name string, selector security.ContentSelector) error {
+	encodedName := url.PathEscape(name)
+
+	ioReader, err := util.JsonMarshalInterfaceToIOReader(selector)
+	if err != nil {
+		return err
+	}
+
+	body, resp, err := s.Client.Put(ctx, fmt.Sprintf("%s/%s", securityContentSelectorsAPIEndpoint, encodedName), ioReader)
+	if err != nil {
+		return err
+	}
+
+	if err := client.CheckResponse(resp, body, http.StatusOK, http.StatusNoContent); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (s *SecurityContentSelectorService) Delete(ctx context.Context, name string) error {
+	encodedName := url.PathEscape(name)
+
+	body, resp, err := s.Client.Delete(ctx, fmt.Sprintf("%s/%s", securityContentSelectorsAPIEndpoint, encodedName))
+	if err != nil {
+		return err
+	}
+
+	if err := client.CheckResponse(resp, body, http.StatusOK, http.StatusNoContent); err != nil {
+		return err
+	}
+
+	return nil
+}
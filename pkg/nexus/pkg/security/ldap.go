@@ -0,0 +1,130 @@
+// Copyright 2023 Nautes Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package security
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/nautes-labs/base-operator/pkg/nexus/pkg/client"
+	"github.com/nautes-labs/base-operator/pkg/nexus/schema/security"
+	"github.com/nautes-labs/base-operator/pkg/util"
+)
+
+const (
+	securityLDAPAPIEndpoint = securityAPIEndpoint + "/ldap"
+)
+
+type SecurityLDAPService client.Service
+
+func NewSecurityLDAPService(c *client.Client) *SecurityLDAPService {
+
+	s := &SecurityLDAPService{
+		Client: c,
+	}
+	return s
+}
+
+func (s *SecurityLDAPService) Create(ctx context.Context, ldap security.LDAP) error {
+	ioReader, err := util.JsonMarshalInterfaceToIOReader(ldap)
+	if err != nil {
+		return err
+	}
+
+	body, resp, err := s.Client.Post(ctx, securityLDAPAPIEndpoint, ioReader)
+	if err != nil {
+		return err
+	}
+
+	if err := client.CheckResponse(resp, body, http.StatusCreated); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (s *SecurityLDAPService) List(ctx context.Context) ([]*security.LDAP, error) {
+	body, resp, err := s.Client.Get(ctx, securityLDAPAPIEndpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := client.CheckResponse(resp, body, http.StatusOK); err != nil {
+		return nil, err
+	}
+	var servers []*security.LDAP
+	if err := json.Unmarshal(body, &servers); err != nil {
+		return nil, fmt.Errorf("could not unmarshal ldap servers: %v", err)
+	}
+	return servers, nil
+}
+
+func (s *SecurityLDAPService) Get(ctx context.Context, name string) (*security.LDAP, error) {
+	encodedName := url.PathEscape(name)
+
+	body, resp, err := s.Client.Get(ctx, fmt.Sprintf("%s/%s", securityLDAPAPIEndpoint, encodedName), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := client.CheckResponse(resp, body, http.StatusOK); err != nil {
+		return nil, err
+	}
+
+	var ldap security.LDAP
+	if err := json.Unmarshal(body, &ldap); err != nil {
+		return nil, fmt.Errorf("could not unmarshal ldap server: %v", err)
+	}
+	return &ldap, nil
+}
+
+// Update updates the ldap server, nexus requires AuthPassword again if the auth scheme is not NONE.
+func (s *SecurityLDAPService) Update(ctx context.Context, name string, ldap security.LDAP) error {
+	encodedName := url.PathEscape(name)
+
+	ioReader, err := util.JsonMarshalInterfaceToIOReader(ldap)
+	if err != nil {
+		return err
+	}
+
+	body, resp, err := s.Client.Put(ctx, fmt.Sprintf("%s/%s", securityLDAPAPIEndpoint, encodedName), ioReader)
+	if err != nil {
+		return err
+	}
+
+	if err := client.CheckResponse(resp, body, http.StatusOK, http.StatusNoContent); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (s *SecurityLDAPService) Delete(ctx context.Context, name string) error {
+	encodedName := url.PathEscape(name)
+
+	body, resp, err := s.Client.Delete(ctx, fmt.Sprintf("%s/%s", securityLDAPAPIEndpoint, encodedName))
+	if err != nil {
+		return err
+	}
+
+	if err := client.CheckResponse(resp, body, http.StatusOK, http.StatusNoContent); err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,131 @@
+// Copyright 2023 Nautes Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package security
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/nautes-labs/base-operator/pkg/nexus/pkg/client"
+	"github.com/nautes-labs/base-operator/pkg/nexus/schema/security"
+	"github.com/nautes-labs/base-operator/pkg/util"
+)
+
+const (
+	securityPrivilegesAPIEndpoint = securityAPIEndpoint + "/privileges"
+)
+
+type SecurityPrivilegeService client.Service
+
+func NewSecurityPrivilegeService(c *client.Client) *SecurityPrivilegeService {
+
+	s := &SecurityPrivilegeService{
+		Client: c,
+	}
+	return s
+}
+
+// Create creates the privilege, the endpoint is chosen by the type of privilege.
+func (s *SecurityPrivilegeService) Create(ctx context.Context, privilege security.Privilege) error {
+	ioReader, err := util.JsonMarshalInterfaceToIOReader(privilege)
+	if err != nil {
+		return err
+	}
+
+	body, resp, err := s.Client.Post(ctx, fmt.Sprintf("%s/%s", securityPrivilegesAPIEndpoint, privilege.Type), ioReader)
+	if err != nil {
+		return err
+	}
+
+	if err := client.CheckResponse(resp, body, http.StatusCreated); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (s *SecurityPrivilegeService) List(ctx context.Context) ([]*security.Privilege, error) {
+	body, resp, err := s.Client.Get(ctx, securityPrivilegesAPIEndpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := client.CheckResponse(resp, body, http.StatusOK); err != nil {
+		return nil, err
+	}
+	var privileges []*security.Privilege
+	if err := json.Unmarshal(body, &privileges); err != nil {
+		return nil, fmt.Errorf("could not unmarshal privileges: %v", err)
+	}
+	return privileges, nil
+}
+
+func (s *SecurityPrivilegeService) Get(ctx context.Context, name string) (*security.Privilege, error) {
+	encodedName := url.PathEscape(name)
+
+	body, resp, err := s.Client.Get(ctx, fmt.Sprintf("%s/%s", securityPrivilegesAPIEndpoint, encodedName), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := client.CheckResponse(resp, body, http.StatusOK); err != nil {
+		return nil, err
+	}
+
+	var privilege security.Privilege
+	if err := json.Unmarshal(body, &privilege); err != nil {
+		return nil, fmt.Errorf("could not unmarshal privilege: %v", err)
+	}
+	return &privilege, nil
+}
+
+// Update updates the privilege, the type of a privilege can not be changed.
+func (s *SecurityPrivilegeService) Update(ctx context.Context, name string, privilege security.Privilege) error {
+	encodedName := url.PathEscape(name)
+
+	ioReader, err := util.JsonMarshalInterfaceToIOReader(privilege)
+	if err != nil {
+		return err
+	}
+
+	body, resp, err := s.Client.Put(ctx, fmt.Sprintf("%s/%s/%s", securityPrivilegesAPIEndpoint, privilege.Type, encodedName), ioReader)
+	if err != nil {
+		return err
+	}
+
+	if err := client.CheckResponse(resp, body, http.StatusOK, http.StatusNoContent); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (s *SecurityPrivilegeService) Delete(ctx context.Context, name string) error {
+	encodedName := url.PathEscape(name)
+
+	body, resp, err := s.Client.Delete(ctx, fmt.Sprintf("%s/%s", securityPrivilegesAPIEndpoint, encodedName))
+	if err != nil {
+		return err
+	}
+
+	if err := client.CheckResponse(resp, body, http.StatusOK, http.StatusNoContent); err != nil {
+		return err
+	}
+
+	return nil
+}
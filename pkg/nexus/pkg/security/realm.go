@@ -0,0 +1,94 @@
+// Copyright 2023 Nautes Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package security
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/nautes-labs/base-operator/pkg/nexus/pkg/client"
+	"github.com/nautes-labs/base-operator/pkg/nexus/schema/security"
+	"github.com/nautes-labs/base-operator/pkg/util"
+)
+
+const (
+	securityRealmsAPIEndpoint          = securityAPIEndpoint + "/realms"
+	securityActiveRealmsAPIEndpoint    = securityRealmsAPIEndpoint + "/active"
+	securityAvailableRealmsAPIEndpoint = securityRealmsAPIEndpoint + "/available"
+)
+
+type SecurityRealmService client.Service
+
+func NewSecurityRealmService(c *client.Client) *SecurityRealmService {
+
+	s := &SecurityRealmService{
+		Client: c,
+	}
+	return s
+}
+
+func (s *SecurityRealmService) ListAvailable(ctx context.Context) ([]*security.Realm, error) {
+	body, resp, err := s.Client.Get(ctx, securityAvailableRealmsAPIEndpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := client.CheckResponse(resp, body, http.StatusOK); err != nil {
+		return nil, err
+	}
+	var realms []*security.Realm
+	if err := json.Unmarshal(body, &realms); err != nil {
+		return nil, fmt.Errorf("could not unmarshal realms: %v", err)
+	}
+	return realms, nil
+}
+
+// ListActive returns the ids of active realms in the order they are used.
+func (s *SecurityRealmService) ListActive(ctx context.Context) ([]string, error) {
+	body, resp, err := s.Client.Get(ctx, securityActiveRealmsAPIEndpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := client.CheckResponse(resp, body, http.StatusOK); err != nil {
+		return nil, err
+	}
+	var ids []string
+	if err := json.Unmarshal(body, &ids); err != nil {
+		return nil, fmt.Errorf("could not unmarshal active realms: %v", err)
+	}
+	return ids, nil
+}
+
+// SetActive replaces the active realms, the realms not in ids are deactivated.
+func (s *SecurityRealmService) SetActive(ctx context.Context, ids []string) error {
+	ioReader, err := util.JsonMarshalInterfaceToIOReader(ids)
+	if err != nil {
+		return err
+	}
+
+	body, resp, err := s.Client.Put(ctx, securityActiveRealmsAPIEndpoint, ioReader)
+	if err != nil {
+		return err
+	}
+
+	if err := client.CheckResponse(resp, body, http.StatusOK, http.StatusNoContent); err != nil {
+		return err
+	}
+
+	return nil
+}
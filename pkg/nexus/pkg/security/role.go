@@ -15,6 +15,7 @@
 package security
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -39,32 +40,32 @@ func NewSecurityRoleService(c *client.Client) *SecurityRoleService {
 	return s
 }
 
-func (s *SecurityRoleService) Create(role security.Role) error {
+func (s *SecurityRoleService) Create(ctx context.Context, role security.Role) error {
 	ioReader, err := util.JsonMarshalInterfaceToIOReader(role)
 	if err != nil {
 		return err
 	}
 
-	body, resp, err := s.Client.Post(securityrolesAPIEndpoint, ioReader)
+	body, resp, err := s.Client.Post(ctx, securityrolesAPIEndpoint, ioReader)
 	if err != nil {
 		return err
 	}
 
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("%s", string(body))
+	if err := client.CheckResponse(resp, body, http.StatusOK); err != nil {
+		return err
 	}
 
 	return nil
 }
 
-func (s *SecurityRoleService) List() ([]*security.Role, error) {
-	body, resp, err := s.Client.Get(securityrolesAPIEndpoint, nil)
+func (s *SecurityRoleService) List(ctx context.Context) ([]*security.Role, error) {
+	body, resp, err := s.Client.Get(ctx, securityrolesAPIEndpoint, nil)
 	if err != nil {
 		return nil, err
 	}
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("%s", string(body))
+	if err := client.CheckResponse(resp, body, http.StatusOK); err != nil {
+		return nil, err
 	}
 	var roles []*security.Role
 	if err := json.Unmarshal(body, &roles); err != nil {
@@ -73,16 +74,16 @@ func (s *SecurityRoleService) List() ([]*security.Role, error) {
 	return roles, nil
 }
 
-func (s *SecurityRoleService) GetById(id string) (*security.Role, error) {
+func (s *SecurityRoleService) GetById(ctx context.Context, id string) (*security.Role, error) {
 	encodedID := url.PathEscape(id)
 
-	body, resp, err := s.Client.Get(fmt.Sprintf("%s/%s", securityrolesAPIEndpoint, encodedID), nil)
+	body, resp, err := s.Client.Get(ctx, fmt.Sprintf("%s/%s", securityrolesAPIEndpoint, encodedID), nil)
 	if err != nil {
 		return nil, err
 	}
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("%s", string(body))
+	if err := client.CheckResponse(resp, body, http.StatusOK); err != nil {
+		return nil, err
 	}
 
 	var role security.Role
@@ -93,7 +94,7 @@ func (s *SecurityRoleService) GetById(id string) (*security.Role, error) {
 
 }
 
-func (s *SecurityRoleService) Update(id string, role security.Role) error {
+func (s *SecurityRoleService) Update(ctx context.Context, id string, role security.Role) error {
 	encodedID := url.PathEscape(id)
 
 	ioReader, err := util.JsonMarshalInterfaceToIOReader(role)
@@ -101,28 +102,28 @@ func (s *SecurityRoleService) Update(id string, role security.Role) error {
 		return err
 	}
 
-	body, resp, err := s.Client.Put(fmt.Sprintf("%s/%s", securityrolesAPIEndpoint, encodedID), ioReader)
+	body, resp, err := s.Client.Put(ctx, fmt.Sprintf("%s/%s", securityrolesAPIEndpoint, encodedID), ioReader)
 	if err != nil {
 		return err
 	}
 
-	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
-		return fmt.Errorf("%s", string(body))
+	if err := client.CheckResponse(resp, body, http.StatusOK, http.StatusNoContent); err != nil {
+		return err
 	}
 
 	return nil
 }
 
-func (s *SecurityRoleService) Delete(id string) error {
+func (s *SecurityRoleService) Delete(ctx context.Context, id string) error {
 	encodedID := url.PathEscape(id)
 
-	body, resp, err := s.Client.Delete(fmt.Sprintf("%s/%s", securityrolesAPIEndpoint, encodedID))
+	body, resp, err := s.Client.Delete(ctx, fmt.Sprintf("%s/%s", securityrolesAPIEndpoint, encodedID))
 	if err != nil {
 		return err
 	}
 
-	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
-		return fmt.Errorf("%s", string(body))
+	if err := client.CheckResponse(resp, body, http.StatusOK, http.StatusNoContent); err != nil {
+		return err
 	}
 
 	return nil
@@ -0,0 +1,90 @@
+// Copyright 2023 Nautes Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package security
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/nautes-labs/base-operator/pkg/nexus/pkg/client"
+	"github.com/nautes-labs/base-operator/pkg/nexus/schema/security"
+	"github.com/nautes-labs/base-operator/pkg/util"
+)
+
+const (
+	securitySAMLAPIEndpoint = securityAPIEndpoint + "/saml"
+)
+
+// SecuritySAMLService manages the SAML configuration, it is only available in Nexus Pro.
+type SecuritySAMLService client.Service
+
+func NewSecuritySAMLService(c *client.Client) *SecuritySAMLService {
+
+	s := &SecuritySAMLService{
+		Client: c,
+	}
+	return s
+}
+
+func (s *SecuritySAMLService) Get(ctx context.Context) (*security.SAML, error) {
+	body, resp, err := s.Client.Get(ctx, securitySAMLAPIEndpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := client.CheckResponse(resp, body, http.StatusOK); err != nil {
+		return nil, err
+	}
+
+	var saml security.SAML
+	if err := json.Unmarshal(body, &saml); err != nil {
+		return nil, fmt.Errorf("could not unmarshal saml configuration: %v", err)
+	}
+	return &saml, nil
+}
+
+// Apply creates or replaces the SAML configuration.
+func (s *SecuritySAMLService) Apply(ctx context.Context, saml security.SAML) error {
+	ioReader, err := util.JsonMarshalInterfaceToIOReader(saml)
+	if err != nil {
+		return err
+	}
+
+	body, resp, err := s.Client.Put(ctx, securitySAMLAPIEndpoint, ioReader)
+	if err != nil {
+		return err
+	}
+
+	if err := client.CheckResponse(resp, body, http.StatusOK, http.StatusNoContent); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (s *SecuritySAMLService) Delete(ctx context.Context) error {
+	body, resp, err := s.Client.Delete(ctx, securitySAMLAPIEndpoint)
+	if err != nil {
+		return err
+	}
+
+	if err := client.CheckResponse(resp, body, http.StatusOK, http.StatusNoContent); err != nil {
+		return err
+	}
+
+	return nil
+}
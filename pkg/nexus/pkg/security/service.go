@@ -21,15 +21,25 @@ const (
 )
 
 type SecurityService struct {
-	client *client.Client
-	Role   *SecurityRoleService
-	User   *SecurityUserService
+	client          *client.Client
+	Role            *SecurityRoleService
+	User            *SecurityUserService
+	Privilege       *SecurityPrivilegeService
+	ContentSelector *SecurityContentSelectorService
+	Realm           *SecurityRealmService
+	LDAP            *SecurityLDAPService
+	SAML            *SecuritySAMLService
 }
 
 func NewSecurityService(c *client.Client) *SecurityService {
 	return &SecurityService{
-		client: c,
-		Role:   NewSecurityRoleService(c),
-		User:   NewSecurityUserService(c),
+		client:          c,
+		Role:            NewSecurityRoleService(c),
+		User:            NewSecurityUserService(c),
+		Privilege:       NewSecurityPrivilegeService(c),
+		ContentSelector: NewSecurityContentSelectorService(c),
+		Realm:           NewSecurityRealmService(c),
+		LDAP:            NewSecurityLDAPService(c),
+		SAML:            NewSecuritySAMLService(c),
 	}
 }
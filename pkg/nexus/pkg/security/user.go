@@ -15,6 +15,7 @@
 package security
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -27,8 +28,8 @@ import (
 const (
 	securityUsersAPIEndpoint = securityAPIEndpoint + "/users"
 	DefaultPasswd            = "123456"
-	ActiveStatus             = "Active"
-	DisabledStatus           = "Disabled"
+	ActiveStatus             = security.UserStatusActive
+	DisabledStatus           = security.UserStatusDisabled
 )
 
 type SecurityUserService client.Service
@@ -49,34 +50,36 @@ func jsonUnmarshalUsers(data []byte) ([]*security.User, error) {
 	return users, nil
 }
 
-func (s *SecurityUserService) Create(user security.User) error {
+func (s *SecurityUserService) Create(ctx context.Context, user security.User) error {
 	user.Password = DefaultPasswd
-	user.Status = ActiveStatus
+	if user.Status == "" {
+		user.Status = ActiveStatus
+	}
 	ioReader, err := util.JsonMarshalInterfaceToIOReader(user)
 	if err != nil {
 		return err
 	}
 
-	body, resp, err := s.Client.Post(securityUsersAPIEndpoint, ioReader)
+	body, resp, err := s.Client.Post(ctx, securityUsersAPIEndpoint, ioReader)
 	if err != nil {
 		return err
 	}
 
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("%s", string(body))
+	if err := client.CheckResponse(resp, body, http.StatusOK); err != nil {
+		return err
 	}
 
 	return nil
 }
 
-func (s *SecurityUserService) List() ([]*security.User, error) {
-	body, resp, err := s.Client.Get(securityUsersAPIEndpoint, nil)
+func (s *SecurityUserService) List(ctx context.Context) ([]*security.User, error) {
+	body, resp, err := s.Client.Get(ctx, securityUsersAPIEndpoint, nil)
 	if err != nil {
 		return nil, err
 	}
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("%s", string(body))
+	if err := client.CheckResponse(resp, body, http.StatusOK); err != nil {
+		return nil, err
 	}
 
 	users, err := jsonUnmarshalUsers(body)
@@ -87,14 +90,14 @@ func (s *SecurityUserService) List() ([]*security.User, error) {
 	return users, nil
 }
 
-func (s *SecurityUserService) Get(id string) (*security.User, error) {
-	body, resp, err := s.Client.Get(fmt.Sprintf("%s?userId=%s", securityUsersAPIEndpoint, id), nil)
+func (s *SecurityUserService) Get(ctx context.Context, id string) (*security.User, error) {
+	body, resp, err := s.Client.Get(ctx, fmt.Sprintf("%s?userId=%s", securityUsersAPIEndpoint, id), nil)
 	if err != nil {
 		return nil, err
 	}
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("%s", string(body))
+	if err := client.CheckResponse(resp, body, http.StatusOK); err != nil {
+		return nil, err
 	}
 
 	users, err := jsonUnmarshalUsers(body)
@@ -111,38 +114,39 @@ func (s *SecurityUserService) Get(id string) (*security.User, error) {
 	return nil, nil
 }
 
-func (s *SecurityUserService) Update(id string, user security.User) error {
+func (s *SecurityUserService) Update(ctx context.Context, id string, user security.User) error {
 	if user.Source == "" {
 		user.Source = "default"
 	}
-	user.Password = DefaultPasswd
-	user.Status = ActiveStatus
+	if user.Status == "" {
+		user.Status = ActiveStatus
+	}
 
 	ioReader, err := util.JsonMarshalInterfaceToIOReader(user)
 	if err != nil {
 		return err
 	}
 
-	body, resp, err := s.Client.Put(fmt.Sprintf("%s/%s", securityUsersAPIEndpoint, id), ioReader)
+	body, resp, err := s.Client.Put(ctx, fmt.Sprintf("%s/%s", securityUsersAPIEndpoint, id), ioReader)
 	if err != nil {
 		return err
 	}
 
-	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
-		return fmt.Errorf("%s", string(body))
+	if err := client.CheckResponse(resp, body, http.StatusOK, http.StatusNoContent); err != nil {
+		return err
 	}
 
 	return nil
 }
 
-func (s *SecurityUserService) Delete(id string) error {
-	body, resp, err := s.Client.Delete(fmt.Sprintf("%s/%s", securityUsersAPIEndpoint, id))
+func (s *SecurityUserService) Delete(ctx context.Context, id string) error {
+	body, resp, err := s.Client.Delete(ctx, fmt.Sprintf("%s/%s", securityUsersAPIEndpoint, id))
 	if err != nil {
 		return err
 	}
 
-	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
-		return fmt.Errorf("%s", string(body))
+	if err := client.CheckResponse(resp, body, http.StatusOK, http.StatusNoContent); err != nil {
+		return err
 	}
 	return err
 }
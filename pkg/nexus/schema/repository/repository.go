@@ -0,0 +1,91 @@
+// Copyright 2023 Nautes Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package repository
+
+const (
+	TypeHosted = "hosted"
+	TypeGroup  = "group"
+	TypeProxy  = "proxy"
+
+	WritePolicyAllow     = "allow"
+	WritePolicyAllowOnce = "allow_once"
+	WritePolicyDeny      = "deny"
+
+	DefaultBlobStoreName = "default"
+
+	// The formats of the repositories, as listed by Nexus.
+	FormatMaven2 = "maven2"
+	FormatNpm    = "npm"
+	FormatDocker = "docker"
+	FormatRaw    = "raw"
+	FormatPypi   = "pypi"
+	FormatNuget  = "nuget"
+	FormatHelm   = "helm"
+	FormatGo     = "go"
+)
+
+type Repository struct {
+	Name   string `json:"name"`
+	Format string `json:"format"`
+	Type   string `json:"type"`
+	URL    string `json:"url"`
+	Online bool   `json:"online"`
+}
+
+type Storage struct {
+	BlobStoreName               string `json:"blobStoreName"`
+	StrictContentTypeValidation bool   `json:"strictContentTypeValidation"`
+	WritePolicy                 string `json:"writePolicy,omitempty"`
+}
+
+type Cleanup struct {
+	PolicyNames []string `json:"policyNames"`
+}
+
+type Group struct {
+	MemberNames []string `json:"memberNames"`
+}
+
+type Maven struct {
+	VersionPolicy string `json:"versionPolicy"`
+	LayoutPolicy  string `json:"layoutPolicy"`
+}
+
+type Docker struct {
+	V1Enabled      bool `json:"v1Enabled"`
+	ForceBasicAuth bool `json:"forceBasicAuth"`
+	HttpPort       *int `json:"httpPort,omitempty"`
+	HttpsPort      *int `json:"httpsPort,omitempty"`
+}
+
+// HostedRepository is the request body to create or update a hosted repository,
+// Maven and Docker are required by the repositories of their formats.
+type HostedRepository struct {
+	Name    string   `json:"name"`
+	Online  bool     `json:"online"`
+	Storage Storage  `json:"storage"`
+	Cleanup *Cleanup `json:"cleanup,omitempty"`
+	Maven   *Maven   `json:"maven,omitempty"`
+	Docker  *Docker  `json:"docker,omitempty"`
+}
+
+// GroupRepository is the request body to create or update a group repository.
+type GroupRepository struct {
+	Name    string  `json:"name"`
+	Online  bool    `json:"online"`
+	Storage Storage `json:"storage"`
+	Group   Group   `json:"group"`
+	Docker  *Docker `json:"docker,omitempty"`
+}
@@ -0,0 +1,50 @@
+// Copyright 2023 Nautes Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package security
+
+type LDAP struct {
+	ID                          string `json:"id,omitempty"`
+	Name                        string `json:"name"`
+	Order                       int    `json:"order,omitempty"`
+	Protocol                    string `json:"protocol"`
+	UseTrustStore               bool   `json:"useTrustStore"`
+	Host                        string `json:"host"`
+	Port                        int    `json:"port"`
+	SearchBase                  string `json:"searchBase"`
+	AuthScheme                  string `json:"authScheme"`
+	AuthRealm                   string `json:"authRealm,omitempty"`
+	AuthUsername                string `json:"authUsername,omitempty"`
+	AuthPassword                string `json:"authPassword,omitempty"`
+	ConnectionTimeoutSeconds    int    `json:"connectionTimeoutSeconds"`
+	ConnectionRetryDelaySeconds int    `json:"connectionRetryDelaySeconds"`
+	MaxIncidentsCount           int    `json:"maxIncidentsCount"`
+	UserBaseDn                  string `json:"userBaseDn,omitempty"`
+	UserSubtree                 bool   `json:"userSubtree"`
+	UserObjectClass             string `json:"userObjectClass,omitempty"`
+	UserLdapFilter              string `json:"userLdapFilter,omitempty"`
+	UserIdAttribute             string `json:"userIdAttribute,omitempty"`
+	UserRealNameAttribute       string `json:"userRealNameAttribute,omitempty"`
+	UserEmailAddressAttribute   string `json:"userEmailAddressAttribute,omitempty"`
+	UserPasswordAttribute       string `json:"userPasswordAttribute,omitempty"`
+	LdapGroupsAsRoles           bool   `json:"ldapGroupsAsRoles"`
+	GroupType                   string `json:"groupType,omitempty"`
+	GroupBaseDn                 string `json:"groupBaseDn,omitempty"`
+	GroupSubtree                bool   `json:"groupSubtree"`
+	GroupObjectClass            string `json:"groupObjectClass,omitempty"`
+	GroupIdAttribute            string `json:"groupIdAttribute,omitempty"`
+	GroupMemberAttribute        string `json:"groupMemberAttribute,omitempty"`
+	GroupMemberFormat           string `json:"groupMemberFormat,omitempty"`
+	UserMemberOfAttribute       string `json:"userMemberOfAttribute,omitempty"`
+}
@@ -0,0 +1,38 @@
+// Copyright 2023 Nautes Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package security
+
+const (
+	PrivilegeTypeRepositoryView            = "repository-view"
+	PrivilegeTypeRepositoryContentSelector = "repository-content-selector"
+
+	PrivilegeActionBrowse = "BROWSE"
+	PrivilegeActionRead   = "READ"
+	PrivilegeActionEdit   = "EDIT"
+	PrivilegeActionAdd    = "ADD"
+	PrivilegeActionDelete = "DELETE"
+	PrivilegeActionAll    = "ALL"
+)
+
+type Privilege struct {
+	Type            string   `json:"type"`
+	Name            string   `json:"name"`
+	Description     string   `json:"description"`
+	ReadOnly        bool     `json:"readOnly,omitempty"`
+	Actions         []string `json:"actions,omitempty"`
+	Format          string   `json:"format,omitempty"`
+	Repository      string   `json:"repository,omitempty"`
+	ContentSelector string   `json:"contentSelector,omitempty"`
+}
@@ -0,0 +1,27 @@
+// Copyright 2023 Nautes Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package security
+
+type SAML struct {
+	IdpMetadata                string `json:"idpMetadata"`
+	EntityId                   string `json:"entityId,omitempty"`
+	ValidateResponseSignature  *bool  `json:"validateResponseSignature,omitempty"`
+	ValidateAssertionSignature *bool  `json:"validateAssertionSignature,omitempty"`
+	UsernameAttribute          string `json:"usernameAttribute"`
+	FirstNameAttribute         string `json:"firstNameAttribute,omitempty"`
+	LastNameAttribute          string `json:"lastNameAttribute,omitempty"`
+	EmailAttribute             string `json:"emailAttribute,omitempty"`
+	GroupsAttribute            string `json:"groupsAttribute,omitempty"`
+}
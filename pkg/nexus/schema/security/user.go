@@ -14,6 +14,12 @@
 
 package security
 
+// Status of users
+const (
+	UserStatusActive   = "active"
+	UserStatusDisabled = "disabled"
+)
+
 type User struct {
 	UserID       string   `json:"userId"`
 	FirstName    string   `json:"firstName"`
@@ -24,6 +24,8 @@ import (
 type ReferenceResourceResult struct {
 	ApiServerUrl string
 	ProviderType string
+	Insecure     *bool
+	CABundle     string
 }
 
 type ReferenceResource interface {
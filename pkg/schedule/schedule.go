@@ -0,0 +1,106 @@
+// Copyright 2023 Nautes Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package schedule
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/robfig/cron"
+)
+
+const (
+	// DefaultSchedule is used if no schedule is set.
+	DefaultSchedule = "@every 10s"
+	// maxWindowHops bounds the adjoining windows skipped to find the next run.
+	maxWindowHops = 100
+)
+
+// Window is a maintenance window, it opens at each time of its schedule and lasts for its duration.
+type Window struct {
+	start    cron.Schedule
+	duration time.Duration
+}
+
+// NewWindow parses a window opening at the cron spec, such as "0 22 * * 5" for every friday at 22:00.
+func NewWindow(spec string, duration time.Duration) (Window, error) {
+	if duration <= 0 {
+		return Window{}, fmt.Errorf("duration of window %q must be positive", spec)
+	}
+	start, err := cron.ParseStandard(spec)
+	if err != nil {
+		return Window{}, fmt.Errorf("parse window %q fail, err:%w", spec, err)
+	}
+	return Window{start: start, duration: duration}, nil
+}
+
+// end returns the end of the window covering t.
+func (w Window) end(t time.Time) (time.Time, bool) {
+	// The first opening after t-duration covers t if it is not after t.
+	start := w.start.Next(t.Add(-w.duration))
+	if start.IsZero() || start.After(t) {
+		return time.Time{}, false
+	}
+	return start.Add(w.duration), true
+}
+
+// Schedule decides when a config is synchronized, no write happens in its maintenance windows.
+type Schedule struct {
+	runs    cron.Schedule
+	windows []Window
+}
+
+// New parses the cron spec of the runs, such as "*/15 * * * *" or "@every 5m", DefaultSchedule is used if it is empty.
+func New(spec string, windows ...Window) (*Schedule, error) {
+	if spec == "" {
+		spec = DefaultSchedule
+	}
+	runs, err := cron.ParseStandard(spec)
+	if err != nil {
+		return nil, fmt.Errorf("parse schedule %q fail, err:%w", spec, err)
+	}
+	return &Schedule{runs: runs, windows: windows}, nil
+}
+
+// WindowEnd returns the end of the maintenance window covering t, the latest one if windows overlap.
+func (s *Schedule) WindowEnd(t time.Time) (time.Time, bool) {
+	result, found := time.Time{}, false
+	for _, w := range s.windows {
+		if end, ok := w.end(t); ok && end.After(result) {
+			result, found = end, true
+		}
+	}
+	return result, found
+}
+
+// Next returns the effective time of the run following last, a run falling in a maintenance window is postponed to
+// the end of the window. The zero last means never synchronized, the run is due at now. A run already past is due at
+// now too, unless now is in a window.
+func (s *Schedule) Next(last, now time.Time) time.Time {
+	next := now
+	if !last.IsZero() {
+		if run := s.runs.Next(last); run.After(now) {
+			next = run
+		}
+	}
+	for i := 0; i < maxWindowHops; i++ {
+		end, ok := s.WindowEnd(next)
+		if !ok {
+			break
+		}
+		next = end
+	}
+	return next
+}
@@ -0,0 +1,95 @@
+// Copyright 2023 Nautes Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package schedule
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Schedule", func() {
+	// A monday.
+	day := time.Date(2023, 6, 5, 0, 0, 0, 0, time.UTC)
+	at := func(hour, min int) time.Time {
+		return day.Add(time.Duration(hour)*time.Hour + time.Duration(min)*time.Minute)
+	}
+
+	It("runs every 10 seconds by default", func() {
+		s, err := New("")
+		Expect(err).Should(BeNil())
+		Expect(s.Next(at(1, 0), at(1, 0))).Should(Equal(at(1, 0).Add(10 * time.Second)))
+	})
+
+	It("is due now if it never ran", func() {
+		s, err := New("0 3 * * *")
+		Expect(err).Should(BeNil())
+		Expect(s.Next(time.Time{}, at(1, 0))).Should(Equal(at(1, 0)))
+	})
+
+	It("runs at the times of the cron spec", func() {
+		s, err := New("*/15 * * * *")
+		Expect(err).Should(BeNil())
+		Expect(s.Next(at(1, 2), at(1, 5))).Should(Equal(at(1, 15)))
+	})
+
+	It("postpones the runs in a maintenance window to its end", func() {
+		window, err := NewWindow("0 1 * * *", 2*time.Hour)
+		Expect(err).Should(BeNil())
+		s, err := New("*/15 * * * *", window)
+		Expect(err).Should(BeNil())
+
+		end, ok := s.WindowEnd(at(2, 30))
+		Expect(ok).Should(BeTrue())
+		Expect(end).Should(Equal(at(3, 0)))
+		_, ok = s.WindowEnd(at(3, 0))
+		Expect(ok).Should(BeFalse())
+
+		Expect(s.Next(at(0, 50), at(0, 55))).Should(Equal(at(3, 0)))
+		Expect(s.Next(time.Time{}, at(1, 30))).Should(Equal(at(3, 0)))
+		Expect(s.Next(at(3, 0), at(3, 0))).Should(Equal(at(3, 15)))
+	})
+
+	It("postpones a run already past to the end of the window covering now", func() {
+		window, err := NewWindow("0 22 * * *", time.Hour)
+		Expect(err).Should(BeNil())
+		s, err := New("@every 5m", window)
+		Expect(err).Should(BeNil())
+		Expect(s.Next(at(21, 50), at(22, 10))).Should(Equal(at(23, 0)))
+		Expect(s.Next(at(21, 50), at(23, 10))).Should(Equal(at(23, 10)))
+	})
+
+	It("skips adjoining and overlapping windows", func() {
+		first, err := NewWindow("0 1 * * *", time.Hour)
+		Expect(err).Should(BeNil())
+		second, err := NewWindow("30 1 * * *", 2*time.Hour)
+		Expect(err).Should(BeNil())
+		third, err := NewWindow("30 3 * * *", time.Hour)
+		Expect(err).Should(BeNil())
+		s, err := New("0 * * * *", first, second, third)
+		Expect(err).Should(BeNil())
+		Expect(s.Next(at(0, 0), at(0, 30))).Should(Equal(at(4, 30)))
+	})
+
+	It("rejects invalid specs", func() {
+		_, err := New("every day")
+		Expect(err).ShouldNot(BeNil())
+		_, err = NewWindow("0 1 * * *", 0)
+		Expect(err).ShouldNot(BeNil())
+		_, err = NewWindow("61 1 * * *", time.Hour)
+		Expect(err).ShouldNot(BeNil())
+	})
+})
@@ -0,0 +1,137 @@
+// Copyright 2023 Nautes Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package schema
+
+import (
+	"strings"
+)
+
+// The identities of entities in target apps are encoded as the segments joined by identitySeparator,
+// the separator and escape characters in a segment are escaped, so a segment containing dashes or dots,
+// e.g. the idp name "gitlab-prod", can always be decoded back.
+const (
+	identitySeparator = "."
+	identityEscape    = "~"
+)
+
+var (
+	identitySegmentEscaper   = strings.NewReplacer(identityEscape, "~7e", identitySeparator, "~2e")
+	identitySegmentUnescaper = strings.NewReplacer("~7e", identityEscape, "~2e", identitySeparator)
+)
+
+// EncodeIdentity joins the escaped segments into an identity.
+func EncodeIdentity(segments ...string) string {
+	escaped := make([]string, 0, len(segments))
+	for _, segment := range segments {
+		escaped = append(escaped, identitySegmentEscaper.Replace(segment))
+	}
+	return strings.Join(escaped, identitySeparator)
+}
+
+// DecodeIdentity splits the identity encoded by EncodeIdentity into n segments,
+// it returns false if the identity does not have n segments or any of them is empty or malformed.
+func DecodeIdentity(s string, n int) ([]string, bool) {
+	escaped := strings.Split(s, identitySeparator)
+	if len(escaped) != n {
+		return nil, false
+	}
+	segments := make([]string, 0, n)
+	for _, segment := range escaped {
+		if len(segment) == 0 || !isEscapedSegment(segment) {
+			return nil, false
+		}
+		segments = append(segments, identitySegmentUnescaper.Replace(segment))
+	}
+	return segments, true
+}
+
+// isEscapedSegment checks that every escape character starts a known escape sequence.
+func isEscapedSegment(segment string) bool {
+	for i := 0; i < len(segment); i++ {
+		if segment[i] != identityEscape[0] {
+			continue
+		}
+		if !strings.HasPrefix(segment[i:], "~7e") && !strings.HasPrefix(segment[i:], "~2e") {
+			return false
+		}
+		i += 2
+	}
+	return true
+}
+
+// TargetKNI is the identity of a user created in target apps, Kind and Name are of the idp.
+type TargetKNI struct {
+	Kind     string
+	Name     string
+	Identity string
+}
+
+func (t TargetKNI) IsEmpty() bool {
+	return len(t.Kind) == 0 || len(t.Name) == 0 || len(t.Identity) == 0
+}
+
+func (t TargetKNI) String() string {
+	return EncodeIdentity(t.Kind, t.Name, t.Identity)
+}
+
+func StringToKNI(s string) TargetKNI {
+	segments, ok := DecodeIdentity(s, 3)
+	if !ok {
+		return TargetKNI{}
+	}
+	return TargetKNI{
+		Kind:     segments[0],
+		Name:     segments[1],
+		Identity: segments[2],
+	}
+}
+
+// LegacyStringToKNI parses the dash separated user identity created before the encoded format,
+// the kind and name of the idp are required as the name may contain dashes.
+func LegacyStringToKNI(kind, name, s string) TargetKNI {
+	identity, ok := strings.CutPrefix(s, kind+"-"+name+"-")
+	if !ok || len(identity) == 0 || strings.Contains(identity, "-") {
+		return TargetKNI{}
+	}
+	return TargetKNI{
+		Kind:     kind,
+		Name:     name,
+		Identity: identity,
+	}
+}
+
+// LegacyStringToKNRI parses the dash separated role identity created before the encoded format,
+// the kind and name of the idp are required as the name may contain dashes.
+func LegacyStringToKNRI(kind, name, s string) TargetKNRI {
+	rest, ok := strings.CutPrefix(s, kind+"-"+name+"-")
+	if !ok {
+		return TargetKNRI{}
+	}
+	roleKind, identity, ok := strings.Cut(rest, "-")
+	if !ok || len(identity) == 0 || strings.Contains(identity, "-") {
+		return TargetKNRI{}
+	}
+	switch roleKind {
+	case NamespaceGroup, NamespaceUser, NamespaceProject:
+	default:
+		return TargetKNRI{}
+	}
+	return TargetKNRI{
+		Kind:     kind,
+		Name:     name,
+		RoleKind: roleKind,
+		Identity: identity,
+	}
+}
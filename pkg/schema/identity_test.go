@@ -0,0 +1,60 @@
+// Copyright 2023 Nautes Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package schema
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Identity", func() {
+	It("decodes the encoded identity whose segments contain separators", func() {
+		knri := TargetKNRI{Kind: "gitlab", Name: "gitlab-prod.v2~x", RoleKind: NamespaceGroup, Identity: "48"}
+		id := knri.String()
+		Expect(id).Should(Equal("gitlab.gitlab-prod~2ev2~7ex.group.48"))
+		Expect(StringToKNRI(id)).Should(Equal(knri))
+		Expect(StringToKNI(id).IsEmpty()).Should(BeTrue())
+
+		kni := TargetKNI{Kind: "gitlab", Name: "gitlab-prod", Identity: "7"}
+		Expect(StringToKNI(kni.String())).Should(Equal(kni))
+		Expect(StringToKNRI(kni.String()).IsEmpty()).Should(BeTrue())
+	})
+
+	It("does not decode malformed identities", func() {
+		for _, id := range []string{"", "gitlab-gitlab1-group-48", "gitlab..group.48", "gitlab.gitlab~1.group.48", "gitlab.gitlab1.group.48."} {
+			Expect(StringToKNRI(id).IsEmpty()).Should(BeTrue(), id)
+		}
+	})
+
+	It("parses legacy identities of the idp", func() {
+		Expect(LegacyStringToKNRI("gitlab", "gitlab-prod", "gitlab-gitlab-prod-project-5")).Should(Equal(TargetKNRI{
+			Kind:     "gitlab",
+			Name:     "gitlab-prod",
+			RoleKind: NamespaceProject,
+			Identity: "5",
+		}))
+		Expect(LegacyStringToKNI("gitlab", "gitlab-prod", "gitlab-gitlab-prod-42")).Should(Equal(TargetKNI{
+			Kind:     "gitlab",
+			Name:     "gitlab-prod",
+			Identity: "42",
+		}))
+
+		Expect(LegacyStringToKNRI("gitlab", "gitlab", "gitlab-gitlab-prod-group-5").IsEmpty()).Should(BeTrue())
+		Expect(LegacyStringToKNRI("gitlab", "gitlab-prod", "gitlab.gitlab-prod.group.5").IsEmpty()).Should(BeTrue())
+		Expect(LegacyStringToKNRI("gitlab", "gitlab-prod", "gitlab-gitlab-prod-admin-5").IsEmpty()).Should(BeTrue())
+		Expect(LegacyStringToKNI("gitlab", "gitlab-prod", "gitlab-gitlab-prod-group-5").IsEmpty()).Should(BeTrue())
+		Expect(LegacyStringToKNI("gitlab", "gitlab-prod", "nx-admin").IsEmpty()).Should(BeTrue())
+	})
+})
@@ -0,0 +1,37 @@
+// Copyright 2023 Nautes Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package schema
+
+// MembershipMode defines how the membership of the subgroups is represented in the target apps.
+type MembershipMode string
+
+const (
+	// MembershipNested synchronizes the direct members of each group and nests the roles of the subgroups
+	// in the roles of their parents, the members of a parent group get the subgroups through the nested roles.
+	MembershipNested MembershipMode = "nested"
+	// MembershipFlattened synchronizes the effective members of each group, including the members inherited
+	// from the parent groups, and the roles of the groups are not nested.
+	MembershipFlattened MembershipMode = "flattened"
+)
+
+// IsValid checks whether the mode is known, the empty mode is the default nested mode.
+func (m MembershipMode) IsValid() bool {
+	return m == "" || m == MembershipNested || m == MembershipFlattened
+}
+
+// NestsGroups checks whether the roles of the subgroups are nested in the roles of their parents.
+func (m MembershipMode) NestsGroups() bool {
+	return m != MembershipFlattened
+}
@@ -0,0 +1,86 @@
+// Copyright 2023 Nautes Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package schema
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// ManagedBy is the owner recorded in the ownership markers.
+const ManagedBy = "base-operator"
+
+// ownershipMarkerRegexp matches the marker at the end of a description, e.g. "[managed-by=base-operator idp=gitlab/gitlab1 kind=group]".
+var ownershipMarkerRegexp = regexp.MustCompile(`(?:^|\n)\[managed-by=` + ManagedBy + ` idp=([^/\s\]]+)/([^\s\]]+) kind=([^\s\]]+)\]$`)
+
+// Ownership records which idp an object in a target app is synchronized from and which kind of entity it is,
+// it is stored as a marker at the end of the description of the object.
+type Ownership struct {
+	IdpKind string
+	IdpName string
+	Kind    string
+}
+
+func (o Ownership) IsEmpty() bool {
+	return len(o.IdpKind) == 0 || len(o.IdpName) == 0 || len(o.Kind) == 0
+}
+
+// IsOwnedBy checks whether the object is synchronized from the idp.
+func (o Ownership) IsOwnedBy(idpKind, idpName string) bool {
+	return !o.IsEmpty() && o.IdpKind == idpKind && o.IdpName == idpName
+}
+
+// Marker returns the marker appended to the description.
+func (o Ownership) Marker() string {
+	return fmt.Sprintf("[managed-by=%s idp=%s/%s kind=%s]", ManagedBy, o.IdpKind, o.IdpName, o.Kind)
+}
+
+// Mark appends the marker to the description, the existing marker is replaced.
+func (o Ownership) Mark(description string) string {
+	description, _ = ParseOwnership(description)
+	if len(description) == 0 {
+		return o.Marker()
+	}
+	return description + "\n" + o.Marker()
+}
+
+// ParseOwnership splits the description into the original description and the ownership in the marker,
+// the ownership is empty if the description has no marker.
+func ParseOwnership(description string) (string, Ownership) {
+	loc := ownershipMarkerRegexp.FindStringSubmatchIndex(description)
+	if loc == nil {
+		return description, Ownership{}
+	}
+	ownership := Ownership{
+		IdpKind: description[loc[2]:loc[3]],
+		IdpName: description[loc[4]:loc[5]],
+		Kind:    description[loc[6]:loc[7]],
+	}
+	return description[:loc[0]], ownership
+}
+
+// OwnershipOfIdentity infers the ownership from the encoded identity of a role,
+// it is used for the roles created before the markers were recorded.
+func OwnershipOfIdentity(identity string) Ownership {
+	knri := StringToKNRI(identity)
+	if knri.IsEmpty() {
+		return Ownership{}
+	}
+	return Ownership{
+		IdpKind: knri.Kind,
+		IdpName: knri.Name,
+		Kind:    knri.RoleKind,
+	}
+}
@@ -0,0 +1,53 @@
+// Copyright 2023 Nautes Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package schema
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Ownership", func() {
+	ownership := Ownership{IdpKind: "gitlab", IdpName: "project-gitlab", Kind: NamespaceGroup}
+
+	It("records the ownership in the description", func() {
+		description := ownership.Mark("the group of project-x")
+		Expect(description).Should(Equal("the group of project-x\n[managed-by=base-operator idp=gitlab/project-gitlab kind=group]"))
+
+		original, parsed := ParseOwnership(description)
+		Expect(original).Should(Equal("the group of project-x"))
+		Expect(parsed).Should(Equal(ownership))
+		Expect(parsed.IsOwnedBy("gitlab", "project-gitlab")).Should(BeTrue())
+		Expect(parsed.IsOwnedBy("gitlab", "gitlab")).Should(BeFalse())
+
+		Expect(ownership.Mark(description)).Should(Equal(description))
+		original, parsed = ParseOwnership(ownership.Mark(""))
+		Expect(original).Should(BeEmpty())
+		Expect(parsed).Should(Equal(ownership))
+	})
+
+	It("does not parse a description without marker", func() {
+		for _, description := range []string{"", "project-x", "[managed-by=base-operator idp=gitlab kind=group]", "[managed-by=base-operator idp=gitlab/gitlab1 kind=group] created by admin"} {
+			original, parsed := ParseOwnership(description)
+			Expect(original).Should(Equal(description))
+			Expect(parsed.IsEmpty()).Should(BeTrue())
+		}
+	})
+
+	It("infers the ownership from the identity", func() {
+		Expect(OwnershipOfIdentity("gitlab.project-gitlab.group.1")).Should(Equal(ownership))
+		Expect(OwnershipOfIdentity("nx-admin").IsEmpty()).Should(BeTrue())
+	})
+})
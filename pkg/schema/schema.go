@@ -15,8 +15,6 @@
 package schema
 
 import (
-	"strings"
-
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-cmp/cmp/cmpopts"
 )
@@ -41,12 +39,16 @@ type User struct {
 	Mobile      string   `json:"mobile"`
 	RoleIds     []string `json:"role_Ids"`
 	NamespaceId string   `json:"namespace_id"`
+	State       string   `json:"state"`
+	External    bool     `json:"external"`
 }
 
 type Group struct {
 	BaseEntity
-	Kind     string   `json:"kind"`
-	ParentId string   `json:"parent_id"`
+	Kind     string `json:"kind"`
+	ParentId string `json:"parent_id"`
+	// FullPath is the path of the group in idp including its parents, e.g. "bu1/team-a", it is not kept by the target apps.
+	FullPath string   `json:"full_path"`
 	ChildIds []string `json:"child_ids"`
 }
 
@@ -66,6 +68,9 @@ type GroupMember struct {
 	Id      string `json:"id"`
 	GroupId string `json:"group_id"`
 	UserId  string `json:"user_id"`
+	// TargetUserId is the identity of the user in the target app if the user is synchronized from another idp,
+	// e.g. the same person in gitlab and ldap, it is empty for the users synchronized from the idp of the group.
+	TargetUserId string `json:"target_user_id,omitempty"`
 }
 
 type ProjectMember struct {
@@ -97,10 +102,14 @@ func (t TargetKNRI) IsEmpty() bool {
 	return false
 }
 
+func (t TargetKNRI) String() string {
+	return EncodeIdentity(t.Kind, t.Name, t.RoleKind, t.Identity)
+}
+
 func StringToKNRI(s string) TargetKNRI {
 	emptyKNRI := TargetKNRI{}
-	identityArr := strings.Split(s, "-")
-	if len(identityArr) != 4 {
+	identityArr, ok := DecodeIdentity(s, 4)
+	if !ok {
 		return emptyKNRI
 	}
 	emptyKNRI.Kind = identityArr[0]
@@ -114,15 +123,11 @@ func UserIsChanged(old *User, new *User) bool {
 	return !cmp.Equal(*old, *new, cmpopts.IgnoreFields(User{}, "Identity", "RoleIds", "NamespaceId"))
 }
 
-func GroupIsChanged(old *Group, new *Group) bool {
+// GroupIsChanged compares the groups, the child ids matched by ignoreChildId are ignored, e.g. the project roles in nexus.
+func GroupIsChanged(old *Group, new *Group, ignoreChildId func(id string) bool) bool {
 	return !cmp.Equal(*old, *new,
-		cmpopts.IgnoreFields(Group{}, "Identity", "ParentId"),
-		cmpopts.IgnoreSliceElements(func(item string) bool {
-			if strings.Contains(item, "project") {
-				return true
-			}
-			return false
-		}))
+		cmpopts.IgnoreFields(Group{}, "Identity", "ParentId", "FullPath"),
+		cmpopts.IgnoreSliceElements(ignoreChildId))
 }
 
 func ProjectIsChanged(old *Project, new *Project) bool {
@@ -70,3 +70,60 @@ func (t *GroupTree) GetParentsIds(myId string, withSelf bool) []string {
 	}
 	return parentIds
 }
+
+// GetChildrenIds returns the ids of the direct subgroups.
+func (t *GroupTree) GetChildrenIds(myId string) []string {
+	childIds := make([]string, 0)
+	if len(myId) == 0 {
+		return childIds
+	}
+	for _, g := range t.groups {
+		if g.ParentId == myId {
+			childIds = append(childIds, g.Identity)
+		}
+	}
+	return childIds
+}
+
+// GetDescendantsIds returns the ids of the subgroups at any depth, the parents come before their children.
+func (t *GroupTree) GetDescendantsIds(myId string, withSelf bool) []string {
+	ids := make([]string, 0)
+	if withSelf {
+		ids = append(ids, myId)
+	}
+	for _, childId := range t.GetChildrenIds(myId) {
+		ids = append(ids, t.GetDescendantsIds(childId, true)...)
+	}
+	return ids
+}
+
+// GetEffectiveMembers expands the direct members of the groups into the effective members,
+// a member of a group is also a member of all its subgroups, the same as the inherited members of gitlab.
+// An inherited member is a copy of the direct member in the subgroup, so the identities of the user are kept.
+// Each user is a member of a group at most once, the members of the groups not in the tree are dropped.
+// Only the groups in the tree are walked, the parents out of the tree, such as the groups out of the scope or excluded
+// by the filters, pass no members to their subgroups since their members are not read.
+func (t *GroupTree) GetEffectiveMembers(directMembers []*GroupMember) []*GroupMember {
+	groupMembers := make(map[string][]*GroupMember)
+	for _, member := range directMembers {
+		groupMembers[member.GroupId] = append(groupMembers[member.GroupId], member)
+	}
+	members := make([]*GroupMember, 0, len(directMembers))
+	for _, g := range t.groups {
+		seen := make(map[string]bool)
+		parentIds := t.GetParentsIds(g.Identity, true)
+		// the nearest ancestors come last so that the members are listed from the root down
+		for i := len(parentIds) - 1; i >= 0; i-- {
+			for _, member := range groupMembers[parentIds[i]] {
+				if seen[member.UserId] {
+					continue
+				}
+				seen[member.UserId] = true
+				inherited := *member
+				inherited.GroupId = g.Identity
+				members = append(members, &inherited)
+			}
+		}
+	}
+	return members
+}
@@ -0,0 +1,106 @@
+// Copyright 2023 Nautes Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package schema
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("GroupTree", func() {
+	newGroup := func(id, parentId string) Group {
+		return Group{BaseEntity: BaseEntity{Identity: id, Name: id}, Kind: NamespaceGroup, ParentId: parentId}
+	}
+	// a -> b -> c -> d -> e, a -> f, x
+	tree := NewGroupTree([]Group{
+		newGroup("e", "d"),
+		newGroup("a", ""),
+		newGroup("d", "c"),
+		newGroup("b", "a"),
+		newGroup("f", "a"),
+		newGroup("c", "b"),
+		newGroup("x", ""),
+	})
+
+	It("reads the parents of a deep group", func() {
+		Expect(tree.GetParentsIds("e", true)).Should(Equal([]string{"e", "d", "c", "b", "a"}))
+		Expect(tree.GetParentsIds("e", false)).Should(Equal([]string{"d", "c", "b", "a"}))
+		Expect(tree.GetParentsIds("a", false)).Should(BeEmpty())
+		Expect(tree.GetParentsIds("unknown", true)).Should(BeEmpty())
+		Expect(tree.GetParent("c")).Should(Equal([]Group{newGroup("b", "a")}))
+	})
+
+	It("reads the descendants of a group", func() {
+		Expect(tree.GetChildrenIds("a")).Should(ConsistOf("b", "f"))
+		Expect(tree.GetChildrenIds("")).Should(BeEmpty())
+		Expect(tree.GetDescendantsIds("b", false)).Should(Equal([]string{"c", "d", "e"}))
+		Expect(tree.GetDescendantsIds("a", true)).Should(ConsistOf("a", "b", "c", "d", "e", "f"))
+		Expect(tree.GetDescendantsIds("e", false)).Should(BeEmpty())
+		Expect(tree.GetDescendantsIds("x", true)).Should(Equal([]string{"x"}))
+	})
+
+	It("expands the direct members into the effective members", func() {
+		members := tree.GetEffectiveMembers([]*GroupMember{
+			{GroupId: "a", UserId: "1"},
+			{GroupId: "c", UserId: "2"},
+			{GroupId: "e", UserId: "1"},
+			{GroupId: "e", UserId: "3"},
+			{GroupId: "x", UserId: "4"},
+			{GroupId: "unknown", UserId: "5"},
+		})
+		effective := make(map[string][]string)
+		for _, member := range members {
+			effective[member.GroupId] = append(effective[member.GroupId], member.UserId)
+		}
+		Expect(effective).Should(Equal(map[string][]string{
+			"a": {"1"},
+			"b": {"1"},
+			"c": {"1", "2"},
+			"d": {"1", "2"},
+			"e": {"1", "2", "3"},
+			"f": {"1"},
+			"x": {"4"},
+		}))
+	})
+
+	It("keeps the identities of the users in the inherited members", func() {
+		members := tree.GetEffectiveMembers([]*GroupMember{{Id: "7", GroupId: "d", UserId: "2", TargetUserId: "ldap.ldap1.9"}})
+		Expect(members).Should(ConsistOf(
+			&GroupMember{Id: "7", GroupId: "d", UserId: "2", TargetUserId: "ldap.ldap1.9"},
+			&GroupMember{Id: "7", GroupId: "e", UserId: "2", TargetUserId: "ldap.ldap1.9"},
+		))
+	})
+
+	It("drops the members removed from a parent group from all its subgroups", func() {
+		before := tree.GetEffectiveMembers([]*GroupMember{{GroupId: "b", UserId: "1"}, {GroupId: "d", UserId: "2"}})
+		Expect(before).Should(ContainElement(&GroupMember{GroupId: "e", UserId: "1"}))
+
+		after := tree.GetEffectiveMembers([]*GroupMember{{GroupId: "d", UserId: "2"}})
+		Expect(after).Should(ConsistOf(
+			&GroupMember{GroupId: "d", UserId: "2"},
+			&GroupMember{GroupId: "e", UserId: "2"},
+		))
+	})
+})
+
+var _ = Describe("MembershipMode", func() {
+	It("nests the groups unless the members are flattened", func() {
+		Expect(MembershipMode("").IsValid()).Should(BeTrue())
+		Expect(MembershipMode("inherited").IsValid()).Should(BeFalse())
+		Expect(MembershipMode("").NestsGroups()).Should(BeTrue())
+		Expect(MembershipNested.NestsGroups()).Should(BeTrue())
+		Expect(MembershipFlattened.NestsGroups()).Should(BeFalse())
+	})
+})
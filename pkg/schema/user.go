@@ -0,0 +1,59 @@
+// Copyright 2023 Nautes Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package schema
+
+import "strings"
+
+// State of users
+const (
+	UserStateActive      = "active"
+	UserStateBlocked     = "blocked"
+	UserStateDeactivated = "deactivated"
+	// UserStateBot is the state of the active bot and service accounts.
+	UserStateBot = "bot"
+)
+
+// IsDisabled checks whether the user is not allowed to sign in, it is disabled in the target apps.
+func (u *User) IsDisabled() bool {
+	return u.State == UserStateBlocked || u.State == UserStateDeactivated
+}
+
+// IsBot checks whether the user is a bot or service account.
+func (u *User) IsBot() bool {
+	return u.State == UserStateBot
+}
+
+// SplitName splits the full name into the first name and the last name required by the target apps, e.g. nexus.
+// The name is split at the first space, a single word name is used as both of the first name and the last name.
+func SplitName(name string) (firstName string, lastName string) {
+	name = strings.TrimSpace(name)
+	firstName, lastName, _ = strings.Cut(name, " ")
+	lastName = strings.TrimSpace(lastName)
+	if len(lastName) == 0 {
+		return name, name
+	}
+	return firstName, lastName
+}
+
+// JoinName reverses SplitName, JoinName(SplitName(name)) equals the name without the leading, trailing and repeated spaces after the first word.
+func JoinName(firstName string, lastName string) string {
+	if len(lastName) == 0 || firstName == lastName {
+		return firstName
+	}
+	if len(firstName) == 0 {
+		return lastName
+	}
+	return firstName + " " + lastName
+}
@@ -0,0 +1,38 @@
+// Copyright 2023 Nautes Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package schema
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("User name", func() {
+	DescribeTable("splits and joins the name",
+		func(name, firstName, lastName, joined string) {
+			first, last := SplitName(name)
+			Expect(first).Should(Equal(firstName))
+			Expect(last).Should(Equal(lastName))
+			Expect(JoinName(first, last)).Should(Equal(joined))
+			first, last = SplitName(joined)
+			Expect(JoinName(first, last)).Should(Equal(joined))
+		},
+		Entry("two words", "Zhang San", "Zhang", "San", "Zhang San"),
+		Entry("more words", "Mary Jane Watson", "Mary", "Jane Watson", "Mary Jane Watson"),
+		Entry("single word", "zhangsan", "zhangsan", "zhangsan", "zhangsan"),
+		Entry("spaces", "  Zhang   San ", "Zhang", "San", "Zhang San"),
+		Entry("empty", "", "", "", ""),
+	)
+})
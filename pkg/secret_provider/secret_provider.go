@@ -18,8 +18,6 @@ import (
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
-
-	"github.com/nautes-labs/base-operator/pkg/log"
 )
 
 type AuthenticationType string
@@ -66,13 +64,11 @@ func NewSecretProvider(filePath string) (*SecretProvider, error) {
 func (o *SecretProvider) parseContentByPath(filePath string) error {
 	b, err := ioutil.ReadFile(filePath)
 	if err != nil {
-		log.Loger.Errorf("read secret file fail, err:%v", err)
-		return err
+		return fmt.Errorf("read secret file failed: %w", err)
 	}
 	err = json.Unmarshal(b, &o.AuthenticationEntities)
 	if err != nil {
-		log.Loger.Errorf("unserialize secret file content fail, err:%v", err)
-		return err
+		return fmt.Errorf("unserialize secret file content failed: %w", err)
 	}
 	AuthenticationTypeMapping := make(map[AuthenticationType][]AuthenticationEntity, 0)
 	for _, item := range o.AuthenticationEntities {
@@ -0,0 +1,91 @@
+// Copyright 2023 Nautes Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package services
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/nautes-labs/base-operator/pkg/schema"
+	"github.com/nautes-labs/base-operator/pkg/util"
+)
+
+// UserFilter excludes the idp users from the sync, the zero value includes all users.
+type UserFilter struct {
+	// ExcludeBots excludes the bot and service accounts.
+	ExcludeBots bool
+	// ExcludeExternal excludes the external users.
+	ExcludeExternal bool
+	// MembersOnly excludes the users who are not members of the synchronized groups.
+	MembersOnly bool
+}
+
+// Match checks whether the user is synchronized, the memberships are checked separately.
+func (f UserFilter) Match(user *schema.User) bool {
+	if f.ExcludeBots && user.IsBot() {
+		return false
+	}
+	if f.ExcludeExternal && user.External {
+		return false
+	}
+	return true
+}
+
+// EntityFilter selects the idp entities of a type by their names and ids, the zero value selects all entities.
+type EntityFilter struct {
+	// NameRegex is matched against the username of users and the name of groups and projects.
+	NameRegex *regexp.Regexp
+	// IncludeIds selects only the listed entities if it is not empty.
+	IncludeIds []string
+	// ExcludeIds excludes the listed entities, it takes precedence over IncludeIds.
+	ExcludeIds []string
+}
+
+// NewEntityFilter compiles the name regex of the filter, an empty regex matches all names.
+func NewEntityFilter(nameRegex string, includeIds, excludeIds []string) (EntityFilter, error) {
+	filter := EntityFilter{IncludeIds: includeIds, ExcludeIds: excludeIds}
+	if len(nameRegex) == 0 {
+		return filter, nil
+	}
+	re, err := regexp.Compile(nameRegex)
+	if err != nil {
+		return filter, fmt.Errorf("compile name regex %q fail, err:%w", nameRegex, err)
+	}
+	filter.NameRegex = re
+	return filter, nil
+}
+
+// Match checks whether the entity is synchronized.
+func (f EntityFilter) Match(identity, name string) bool {
+	if util.InArray(identity, f.ExcludeIds) {
+		return false
+	}
+	if len(f.IncludeIds) > 0 && !util.InArray(identity, f.IncludeIds) {
+		return false
+	}
+	if f.NameRegex != nil && !f.NameRegex.MatchString(name) {
+		return false
+	}
+	return true
+}
+
+// Filters selects the idp entities synchronized, they are applied when the idp is read so that the excluded
+// entities are neither compared nor written. The members of the excluded groups are not synchronized, and the
+// memberships of these groups in the target apps are kept as they are.
+type Filters struct {
+	Users    EntityFilter
+	Groups   EntityFilter
+	Projects EntityFilter
+}
@@ -0,0 +1,169 @@
+// Copyright 2023 Nautes Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package services
+
+import (
+	"context"
+	"time"
+
+	"github.com/nautes-labs/base-operator/pkg/log"
+	"github.com/nautes-labs/base-operator/pkg/schema"
+	"github.com/nautes-labs/base-operator/pkg/store"
+	"github.com/nautes-labs/base-operator/pkg/target"
+)
+
+// mappingResyncPeriod is the default of how often all entities are read and compared with the target app, the entities unchanged
+// in the idp are skipped between the full syncs, so the changes made in the target app are reverted by the next full sync.
+const mappingResyncPeriod = 6 * time.Hour
+
+// entityState is the state of an idp entity synchronized to a target app.
+type entityState struct {
+	kind           string
+	idpIdentity    string
+	targetIdentity string
+	name           string
+	hash           string
+}
+
+// mappingScope returns the scope of the mappings between the idp and the target app.
+func (s *SyncLogicService) mappingScope(targetApp target.TargetApp) store.Scope {
+	return store.Scope{
+		IdpKind:    s.idp.Kind().Tostring(),
+		IdpName:    s.idp.GetName(),
+		TargetKind: string(targetApp.Kind()),
+		TargetName: targetApp.GetName(),
+	}
+}
+
+// MappingScopes returns the scopes of the mappings between the idp and the target apps.
+func (s *SyncLogicService) MappingScopes() []store.Scope {
+	scopes := make([]store.Scope, 0, len(s.targetApps))
+	for _, targetApp := range s.targetApps {
+		scopes = append(scopes, s.mappingScope(targetApp))
+	}
+	return scopes
+}
+
+// loadMappings loads the mappings recorded by the previous syncs, nothing is skipped for the target app whose mappings fail to load.
+func (s *SyncLogicService) loadMappings(ctx context.Context, state *targetAppState) {
+	if s.store == nil {
+		return
+	}
+	mappings, err := s.store.Load(ctx, s.mappingScope(state.targetApp))
+	if err != nil {
+		log.FromContext(ctx).Error(err, "load mappings fail")
+		return
+	}
+	state.mappings = mappings
+}
+
+// entityStates returns the states of the idp entities in the target app, the entities are hashed after they are transformed
+// by the mapping rules, so that the entities are updated when the rules change.
+func (s *SyncLogicService) entityStates(ctx context.Context, targetState *targetAppState) []entityState {
+	targetApp := targetState.targetApp
+	states := make([]entityState, 0, len(targetState.idpUsers)+len(targetState.idpGroups)+len(targetState.idpProjects))
+	add := func(kind, idpIdentity, targetIdentity, name string, entity interface{}) {
+		hash, err := store.Hash(entity)
+		if err != nil {
+			log.FromContext(ctx).Error(err, "hash entity fail", log.KeyEntity, kind, log.KeyIdentity, idpIdentity)
+			return
+		}
+		states = append(states, entityState{kind: kind, idpIdentity: idpIdentity, targetIdentity: targetIdentity, name: name, hash: hash})
+	}
+	for _, user := range targetState.idpUsers {
+		add(entityUser, user.Identity, targetApp.GenerateIdpUserIdentity(user.Identity), user.Name, user)
+	}
+	for _, group := range targetState.idpGroups {
+		add(entityGroup, group.Identity, targetApp.GenerateIdpGroupIdentity(schema.NamespaceGroup, group.Identity), group.Name, group)
+	}
+	for _, project := range targetState.idpProjects {
+		add(entityProject, project.Identity, targetApp.GenerateIdpProjectIdentity(project.Identity), project.Name, project)
+	}
+	return states
+}
+
+// planSync decides the entities read and compared with the target app. All of them are compared in a full sync,
+// which runs if the full sync period is not positive, nothing is recorded or the oldest mapping is synchronized before the period.
+// Otherwise the entities unchanged in the idp since they were synchronized are skipped, and the entities renamed are logged.
+func (s *SyncLogicService) planSync(ctx context.Context, targetState *targetAppState) {
+	mappings := targetState.mappings
+	if s.fullSyncPeriod <= 0 || mappings == nil || mappings.Len() == 0 {
+		return
+	}
+	now := time.Now()
+	for _, mapping := range mappings.List() {
+		if now.Sub(mapping.SyncedAt) >= s.fullSyncPeriod {
+			log.FromContext(ctx).V(1).Info("full sync after resync period", "syncedAt", mapping.SyncedAt)
+			return
+		}
+	}
+	logger := log.FromContext(ctx)
+	targetState.incremental = true
+	for _, state := range s.entityStates(ctx, targetState) {
+		mapping, ok := mappings.Get(state.kind, state.idpIdentity)
+		if !ok {
+			continue
+		}
+		if mapping.Name != state.name {
+			logger.Info("entity renamed in idp", log.KeyEntity, state.kind, log.KeyIdentity, state.idpIdentity, "oldName", mapping.Name, "newName", state.name)
+		}
+		if mapping.Hash == state.hash && mapping.TargetIdentity == state.targetIdentity {
+			targetState.skipped[mapping.Key()] = true
+		}
+	}
+	logger.V(1).Info("incremental sync", "skipped", len(targetState.skipped))
+}
+
+// saveMappings records the idp entities synchronized to the target app, the mappings of the entities removed from the idp are dropped.
+// The synchronized time of a skipped entity is kept, so that it is compared again in the next full sync,
+// and the previous mapping of a failed entity is kept, so that it is not skipped in the next sync.
+func (s *SyncLogicService) saveMappings(ctx context.Context, targetState *targetAppState) {
+	if s.store == nil {
+		return
+	}
+	previous := targetState.mappings
+	now := time.Now()
+	mappings := store.NewMappings()
+	for _, state := range s.entityStates(ctx, targetState) {
+		mapping := &store.Mapping{
+			Kind:           state.kind,
+			IdpIdentity:    state.idpIdentity,
+			TargetIdentity: state.targetIdentity,
+			Name:           state.name,
+			Hash:           state.hash,
+			SyncedAt:       now,
+		}
+		if targetState.isFailed(state) {
+			// the entity is written again in the next sync
+			if previous != nil {
+				if prev, ok := previous.Get(state.kind, state.idpIdentity); ok {
+					mappings.Set(prev)
+				}
+			}
+			continue
+		}
+		if previous != nil && targetState.skipped[mapping.Key()] {
+			if prev, ok := previous.Get(state.kind, state.idpIdentity); ok {
+				mapping.SyncedAt = prev.SyncedAt
+			}
+		}
+		mappings.Set(mapping)
+	}
+	if err := s.store.Save(ctx, s.mappingScope(targetState.targetApp), mappings); err != nil {
+		log.FromContext(ctx).Error(err, "save mappings fail")
+		return
+	}
+	log.FromContext(ctx).V(1).Info("save mappings success", "count", mappings.Len())
+}
@@ -0,0 +1,191 @@
+// Copyright 2023 Nautes Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/go-multierror"
+	"github.com/nautes-labs/base-operator/pkg/log"
+	"github.com/nautes-labs/base-operator/pkg/schema"
+	"github.com/nautes-labs/base-operator/pkg/target"
+)
+
+const (
+	MatchByEmail    = "email"
+	MatchByUsername = "username"
+)
+
+// MergePolicy merges the users of several idps synchronized to the same target apps, the union of the users is synchronized.
+// The users of different idps matched by the fields are the same person, who is synchronized from the idp of the highest
+// priority and is added to the groups of the other idps. The users are not matched if MatchBy is empty.
+type MergePolicy struct {
+	MatchBy []string
+}
+
+func (p MergePolicy) Validate() error {
+	for _, field := range p.MatchBy {
+		if field != MatchByEmail && field != MatchByUsername {
+			return fmt.Errorf("unsupported match field %q", field)
+		}
+	}
+	return nil
+}
+
+// userClaim is a user synchronized by an idp of higher priority.
+type userClaim struct {
+	svc      *SyncLogicService
+	identity string
+}
+
+// targetIdentity returns the identity of the user in the target app, it is empty if the idp of the user is not synchronized to the target app.
+func (c *userClaim) targetIdentity(key target.TargetAppKindName) string {
+	for _, targetApp := range c.svc.targetApps {
+		if targetApp.IdentityKey() == key {
+			return targetApp.GenerateIdpUserIdentity(c.identity)
+		}
+	}
+	return ""
+}
+
+// userClaims records the users synchronized by the idps by the fields matched, the idps are synchronized one by one.
+type userClaims struct {
+	policy MergePolicy
+	claims map[string]*userClaim
+}
+
+func newUserClaims(policy MergePolicy) *userClaims {
+	return &userClaims{policy: policy, claims: make(map[string]*userClaim)}
+}
+
+// keys returns the matched fields of the user, the empty fields are not matched.
+func (c *userClaims) keys(user *schema.User) []string {
+	keys := make([]string, 0, len(c.policy.MatchBy))
+	for _, field := range c.policy.MatchBy {
+		value := user.Email
+		if field == MatchByUsername {
+			value = user.Username
+		}
+		if len(value) > 0 {
+			keys = append(keys, field+":"+strings.ToLower(value))
+		}
+	}
+	return keys
+}
+
+// find returns the user of another idp matched by the user, nil if there is none.
+func (c *userClaims) find(svc *SyncLogicService, user *schema.User) *userClaim {
+	for _, key := range c.keys(user) {
+		if claim, ok := c.claims[key]; ok && claim.svc != svc {
+			return claim
+		}
+	}
+	return nil
+}
+
+// claim records the users of the idp, the users claimed before are kept.
+func (c *userClaims) claim(svc *SyncLogicService, users []*schema.User) {
+	for _, user := range users {
+		for _, key := range c.keys(user) {
+			if _, ok := c.claims[key]; !ok {
+				c.claims[key] = &userClaim{svc: svc, identity: user.Identity}
+			}
+		}
+	}
+}
+
+// resolveUserCollisions drops the users synchronized by the idps of higher priority, their memberships are kept and
+// written to the users of those idps, then claims the remaining users.
+func (s *SyncLogicService) resolveUserCollisions(ctx context.Context) {
+	if s.claims == nil {
+		return
+	}
+	s.foreignUsers = make(map[string]*userClaim)
+	users := make([]*schema.User, 0, len(s.idpUsers))
+	for _, user := range s.idpUsers {
+		if claim := s.claims.find(s, user); claim != nil {
+			log.FromContext(ctx).V(1).Info("user synchronized from another idp", log.KeyIdentity, user.Identity,
+				"idp", fmt.Sprintf("%s/%s", claim.svc.idp.Kind(), claim.svc.idp.GetName()))
+			s.foreignUsers[user.Identity] = claim
+			continue
+		}
+		users = append(users, user)
+	}
+	s.idpUsers = users
+	s.claims.claim(s, s.idpUsers)
+	s.usersClaimed = true
+}
+
+// withForeignUsers sets the target identities of the members synchronized from other idps, the members are dropped
+// if their idps are not synchronized to the target app.
+func (s *SyncLogicService) withForeignUsers(state *targetAppState, groupMembers []*schema.GroupMember) []*schema.GroupMember {
+	if len(s.foreignUsers) == 0 {
+		return groupMembers
+	}
+	result := make([]*schema.GroupMember, 0, len(groupMembers))
+	for _, member := range groupMembers {
+		claim, ok := s.foreignUsers[member.UserId]
+		if !ok {
+			result = append(result, member)
+			continue
+		}
+		if identity := claim.targetIdentity(state.identity()); len(identity) > 0 {
+			m := *member
+			m.TargetUserId = identity
+			result = append(result, &m)
+		}
+	}
+	return result
+}
+
+// MergedSyncService synchronizes several idps to the same target apps, the idps are synchronized one by one in the order of
+// their priority, and each of them only writes the objects it owns in the target apps.
+type MergedSyncService struct {
+	services []*SyncLogicService
+}
+
+// NewMergedSyncService merges the services of the idps by the policy, the services are in the order of priority.
+func NewMergedSyncService(policy MergePolicy, services ...*SyncLogicService) *MergedSyncService {
+	claims := newUserClaims(policy)
+	for _, svc := range services {
+		svc.claims = claims
+	}
+	return &MergedSyncService{services: services}
+}
+
+// Run synchronizes the idps, it stops at an idp whose users are not read, as the idps after it can't tell the same persons.
+func (m *MergedSyncService) Run() error {
+	var result error
+	for _, svc := range m.services {
+		if err := svc.Run(); err != nil {
+			result = multierror.Append(result, fmt.Errorf("sync idp %s/%s fail, err:%w", svc.idp.Kind(), svc.idp.GetName(), err))
+			if !svc.usersClaimed {
+				return result
+			}
+		}
+	}
+	return result
+}
+
+// GetResults returns the results of the idps in the order of priority.
+func (m *MergedSyncService) GetResults() []*SyncLogicResult {
+	results := make([]*SyncLogicResult, 0, len(m.services))
+	for _, svc := range m.services {
+		results = append(results, svc.GetResult())
+	}
+	return results
+}
@@ -0,0 +1,63 @@
+// Copyright 2023 Nautes Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/nautes-labs/base-operator/pkg/util"
+)
+
+// PhaseTimeouts limits the time of the phases of a sync, zero means no limit.
+type PhaseTimeouts struct {
+	// ReadIdp limits reading the users, groups, projects and group members of the idp.
+	ReadIdp time.Duration
+	// ReadTarget limits reading each target app.
+	ReadTarget time.Duration
+	// Write limits writing each target app, the entities not written in time are synchronized in the next sync.
+	Write time.Duration
+}
+
+// withPhaseTimeout returns the context of a phase, it is canceled after timeout unless timeout is zero.
+func withPhaseTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// phaseTimeoutError tells that the error is caused by the deadline of the phase rather than the application.
+func phaseTimeoutError(ctx context.Context, phase string, timeout time.Duration, err error) error {
+	if err == nil || !errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return err
+	}
+	return fmt.Errorf("phase %s timed out after %s, err:%w", phase, timeout, err)
+}
+
+// recoverPanic converts a panic into the error of the function, it has to be deferred directly.
+// The error is recorded in the detail of the target app with kind, it is left to the caller if state is nil.
+func (s *SyncLogicService) recoverPanic(ctx context.Context, state *targetAppState, kind string, err *error) {
+	recovered := recover()
+	if recovered == nil {
+		return
+	}
+	*err = util.PanicError(ctx, recovered)
+	if state != nil {
+		s.result.addDetail(state.identity(), NewPanicItem(kind, (*err).Error()))
+	}
+}
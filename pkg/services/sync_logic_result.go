@@ -14,7 +14,12 @@
 
 package services
 
-import "github.com/nautes-labs/base-operator/pkg/target"
+import (
+	"fmt"
+	"sync"
+
+	"github.com/nautes-labs/base-operator/pkg/target"
+)
 
 const (
 	SyncStatusSuccess = "True"
@@ -27,25 +32,37 @@ const (
 	SyncGroupKind       = "SyncGroup"
 	SyncProjectKind     = "SyncProject"
 	SyncGroupMemberKind = "SyncGroupMember"
+	SyncRunKind         = "SyncRun"
 )
 
+// ReasonPanic is the reason of the items recording the recovered panics.
+const ReasonPanic = "Panic"
+
 type SyncLogicResultItem struct {
 	Type    string
 	Status  string
 	Reason  string
 	Message string
+	// Identity is the entity which the item is about, it is empty if the item is about a whole step.
+	Identity string
 }
 
 type SyncLogicResult struct {
 	Brief  []*SyncLogicResultItem
 	Detail map[target.TargetAppKindName][]*SyncLogicResultItem
+	// the items are added by the target apps concurrently
+	lock sync.Mutex
 }
 
 func (r *SyncLogicResult) addBrief(items ...*SyncLogicResultItem) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
 	r.Brief = append(r.Brief, items...)
 }
 
 func (r *SyncLogicResult) addDetail(instanceIdentity target.TargetAppKindName, items ...*SyncLogicResultItem) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
 	r.Detail[instanceIdentity] = append(r.Detail[instanceIdentity], items...)
 }
 
@@ -148,3 +165,41 @@ func NewSyncGroupMemberSuccessItem() *SyncLogicResultItem {
 	}
 	return item
 }
+
+// NewSyncEntityFailItem reports an entity failed to write, kind is SyncUserKind, SyncGroupKind or SyncProjectKind.
+func NewSyncEntityFailItem(kind, identity, msg string) *SyncLogicResultItem {
+	item := &SyncLogicResultItem{
+		Type:     kind,
+		Status:   SyncStatusFail,
+		Reason:   SyncStatusFail,
+		Message:  msg,
+		Identity: identity,
+	}
+	return item
+}
+
+// NewSyncProgressItem reports how many entities of an operation are written, it fails if any entity fails.
+func NewSyncProgressItem(kind, operation string, succeeded, total int) *SyncLogicResultItem {
+	item := &SyncLogicResultItem{
+		Type:    kind,
+		Status:  SyncStatusSuccess,
+		Reason:  SyncStatusSuccess,
+		Message: fmt.Sprintf("%s: %d/%d succeeded", operation, succeeded, total),
+	}
+	if succeeded < total {
+		item.Status = SyncStatusFail
+		item.Reason = SyncStatusFail
+	}
+	return item
+}
+
+// NewPanicItem records a panic recovered in the step of kind.
+func NewPanicItem(kind, msg string) *SyncLogicResultItem {
+	item := &SyncLogicResultItem{
+		Type:    kind,
+		Status:  SyncStatusFail,
+		Reason:  ReasonPanic,
+		Message: msg,
+	}
+	return item
+}
@@ -0,0 +1,207 @@
+// Copyright 2023 Nautes Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package services
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/nautes-labs/base-operator/pkg/log"
+	"github.com/nautes-labs/base-operator/pkg/schema"
+)
+
+// the fields of the idp entities which can be transformed by the mapping rules, the identities are never transformed
+var (
+	userFields = map[string]func(*schema.User) *string{
+		"name":        func(u *schema.User) *string { return &u.Name },
+		"description": func(u *schema.User) *string { return &u.Description },
+		"username":    func(u *schema.User) *string { return &u.Username },
+		"email":       func(u *schema.User) *string { return &u.Email },
+		"avatarUrl":   func(u *schema.User) *string { return &u.AvatarURL },
+		"mobile":      func(u *schema.User) *string { return &u.Mobile },
+	}
+	groupFields = map[string]func(*schema.Group) *string{
+		"name":        func(g *schema.Group) *string { return &g.Name },
+		"description": func(g *schema.Group) *string { return &g.Description },
+	}
+	projectFields = map[string]func(*schema.Project) *string{
+		"name":        func(p *schema.Project) *string { return &p.Name },
+		"description": func(p *schema.Project) *string { return &p.Description },
+	}
+)
+
+// templateFuncs are the functions which can be used by the templates of the mapping rules.
+var templateFuncs = template.FuncMap{
+	"lower":      strings.ToLower,
+	"upper":      strings.ToUpper,
+	"replace":    func(old, new, s string) string { return strings.ReplaceAll(s, old, new) },
+	"trimPrefix": func(prefix, s string) string { return strings.TrimPrefix(s, prefix) },
+	"trimSuffix": func(suffix, s string) string { return strings.TrimSuffix(s, suffix) },
+}
+
+// MappingRule transforms the fields of the idp entities of a type for a target app, the zero value keeps the entities as they are.
+// The fields are renamed first, then set by the templates executed with the renamed entity, and at last dropped or kept.
+type MappingRule struct {
+	// Renames sets a field to the value of another one, keyed by the field set.
+	Renames map[string]string
+	// Templates sets a field to the result of a template, keyed by the field set.
+	Templates map[string]*template.Template
+	// Keep clears the fields not listed if it is not empty.
+	Keep []string
+	// Drop clears the listed fields.
+	Drop []string
+}
+
+// NewMappingRule parses the templates of the rule, the templates are go templates executed with the idp entity,
+// e.g. `{{ .FullPath | replace "/" "-" }}-developers` for groups.
+func NewMappingRule(renames, templates map[string]string, keep, drop []string) (MappingRule, error) {
+	rule := MappingRule{Renames: renames, Keep: keep, Drop: drop}
+	if len(templates) == 0 {
+		return rule, nil
+	}
+	rule.Templates = make(map[string]*template.Template, len(templates))
+	for field, text := range templates {
+		tmpl, err := template.New(field).Option("missingkey=error").Funcs(templateFuncs).Parse(text)
+		if err != nil {
+			return rule, fmt.Errorf("parse template of field %s fail, err:%w", field, err)
+		}
+		rule.Templates[field] = tmpl
+	}
+	return rule, nil
+}
+
+// MappingRules transforms the idp entities before they are compared with the entities of a target app.
+type MappingRules struct {
+	Users    MappingRule
+	Groups   MappingRule
+	Projects MappingRule
+}
+
+// Validate checks the fields set, read and cleared by the rules, the errors of the templates are reported per entity when they are executed.
+func (r MappingRules) Validate() error {
+	if err := validateMappingRule(r.Users, userFields); err != nil {
+		return fmt.Errorf("invalid mapping rule of users, err:%w", err)
+	}
+	if err := validateMappingRule(r.Groups, groupFields); err != nil {
+		return fmt.Errorf("invalid mapping rule of groups, err:%w", err)
+	}
+	if err := validateMappingRule(r.Projects, projectFields); err != nil {
+		return fmt.Errorf("invalid mapping rule of projects, err:%w", err)
+	}
+	return nil
+}
+
+func validateMappingRule[T any](rule MappingRule, fields map[string]func(*T) *string) error {
+	names := make([]string, 0, len(rule.Renames)*2+len(rule.Templates)+len(rule.Keep)+len(rule.Drop))
+	for field, from := range rule.Renames {
+		names = append(names, field, from)
+	}
+	for field := range rule.Templates {
+		names = append(names, field)
+	}
+	names = append(names, rule.Keep...)
+	names = append(names, rule.Drop...)
+	for _, name := range names {
+		if _, ok := fields[name]; !ok {
+			return fmt.Errorf("unknown field %q, the fields are %s", name, strings.Join(fieldNames(fields), ", "))
+		}
+	}
+	return nil
+}
+
+func fieldNames[T any](fields map[string]func(*T) *string) []string {
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (r MappingRule) isEmpty() bool {
+	return len(r.Renames) == 0 && len(r.Templates) == 0 && len(r.Keep) == 0 && len(r.Drop) == 0
+}
+
+// applyMappingRule returns the transformed copy of the entity, the entity itself is not changed.
+func applyMappingRule[T any](rule MappingRule, fields map[string]func(*T) *string, entity *T) (*T, error) {
+	if rule.isEmpty() {
+		return entity, nil
+	}
+	renamed := *entity
+	for field, from := range rule.Renames {
+		*fields[field](&renamed) = *fields[from](entity)
+	}
+	mapped := renamed
+	for field, tmpl := range rule.Templates {
+		var value strings.Builder
+		if err := tmpl.Execute(&value, &renamed); err != nil {
+			return nil, fmt.Errorf("execute template of field %s fail, err:%w", field, err)
+		}
+		*fields[field](&mapped) = value.String()
+	}
+	if len(rule.Keep) > 0 {
+		keep := make(map[string]bool, len(rule.Keep))
+		for _, field := range rule.Keep {
+			keep[field] = true
+		}
+		for field, value := range fields {
+			if !keep[field] {
+				*value(&mapped) = ""
+			}
+		}
+	}
+	for _, field := range rule.Drop {
+		*fields[field](&mapped) = ""
+	}
+	return &mapped, nil
+}
+
+// mapEntities transforms the entities by the rule, the entities failed to transform are reported and not synchronized.
+func mapEntities[T any](ctx context.Context, rule MappingRule, fields map[string]func(*T) *string, entities []*T,
+	identity func(*T) string, fail func(identity string, err error)) []*T {
+	if rule.isEmpty() {
+		return entities
+	}
+	result := make([]*T, 0, len(entities))
+	for _, entity := range entities {
+		mapped, err := applyMappingRule(rule, fields, entity)
+		if err != nil {
+			log.FromContext(ctx).Error(err, "map entity fail", log.KeyIdentity, identity(entity))
+			fail(identity(entity), err)
+			continue
+		}
+		result = append(result, mapped)
+	}
+	return result
+}
+
+// applyMappingRules sets the idp entities of the target app to the entities transformed by the mapping rules of the target app.
+func (s *SyncLogicService) applyMappingRules(ctx context.Context, state *targetAppState) {
+	rules := s.mappingRules[state.identity()]
+	fail := func(kind string) func(string, error) {
+		return func(identity string, err error) {
+			s.result.addDetail(state.identity(), NewSyncEntityFailItem(kind, identity, err.Error()))
+		}
+	}
+	state.idpUsers = mapEntities(ctx, rules.Users, userFields, s.idpUsers,
+		func(u *schema.User) string { return u.Identity }, fail(SyncUserKind))
+	state.idpGroups = mapEntities(ctx, rules.Groups, groupFields, s.idpGroups,
+		func(g *schema.Group) string { return g.Identity }, fail(SyncGroupKind))
+	state.idpProjects = mapEntities(ctx, rules.Projects, projectFields, s.idpProjects,
+		func(p *schema.Project) string { return p.Identity }, fail(SyncProjectKind))
+}
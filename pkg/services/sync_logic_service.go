@@ -18,24 +18,38 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"os"
-	"strings"
 	"sync"
+	"time"
 
 	"github.com/hashicorp/go-multierror"
 	"github.com/nautes-labs/base-operator/pkg/idp"
 	"github.com/nautes-labs/base-operator/pkg/log"
+	"github.com/nautes-labs/base-operator/pkg/metrics"
 	"github.com/nautes-labs/base-operator/pkg/schema"
+	"github.com/nautes-labs/base-operator/pkg/store"
 	"github.com/nautes-labs/base-operator/pkg/target"
+	"github.com/nautes-labs/base-operator/pkg/tracing"
 	"github.com/nautes-labs/base-operator/pkg/util"
-	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/trace"
 	"golang.org/x/sync/errgroup"
 )
 
+// Labels of sync metrics
+const (
+	phaseReadIdp    = "read_idp"
+	phaseReadTarget = "read_target"
+	phaseCompare    = "compare"
+	phaseWrite      = "write"
+
+	entityUser    = "user"
+	entityGroup   = "group"
+	entityProject = "project"
+)
+
 // read idp data func signature
-type readIdpDataHandleFuncSignature func() error
+type readIdpDataHandleFuncSignature func(context.Context) error
 
-type readTargetAppDataHandleFuncSignature func(target.TargetApp) error
+type readTargetAppDataHandleFuncSignature func(context.Context, *targetAppState) error
 
 type SyncLogicService struct {
 	ctx                          context.Context
@@ -44,30 +58,29 @@ type SyncLogicService struct {
 	readIdpDataHandleFuncs       []readIdpDataHandleFuncSignature
 	readTargetAppDataHandleFuncs []readTargetAppDataHandleFuncSignature
 	//
-	targetMapping     map[target.TargetAppKindName]target.TargetApp
+	userFilter        UserFilter
+	filters           Filters
+	membership        schema.MembershipMode
+	excludedUserIds   map[string]bool
+	excludedGroupIds  map[string]bool
 	idpUsers          []*schema.User
 	idpGroups         []*schema.Group
 	idpProjects       []*schema.Project
 	idpGroupMembers   []*schema.GroupMember
 	idpProjectMembers []*schema.ProjectMember
+	// the users synchronized by other idps and whether the users of the idp are claimed, see MergedSyncService
+	claims       *userClaims
+	foreignUsers map[string]*userClaim
+	usersClaimed bool
 	//
-	targetAppUsersMapping       map[target.TargetAppKindName][]*schema.User
-	targetAppGroupsMapping      map[target.TargetAppKindName][]*schema.Group
-	targetAppProjectsMapping    map[target.TargetAppKindName][]*schema.Project
-	targetAppGroupMemberMapping map[target.TargetAppKindName][]*schema.GroupMember
-	//
-	createTargetAppUsersMapping    map[target.TargetAppKindName][]*schema.User
-	updateTargetAppUsersMapping    map[target.TargetAppKindName][]*schema.User
-	createTargetAppGroupsMapping   map[target.TargetAppKindName][]*schema.Group
-	updateTargetAppGroupsMapping   map[target.TargetAppKindName][]*schema.Group
-	createTargetAppProjectsMapping map[target.TargetAppKindName][]*schema.Project
-	updateTargetAppProjectsMapping map[target.TargetAppKindName][]*schema.Project
-	//
-	createTargetAppGroupMembersMapping   map[string][]*schema.GroupMember
-	updateTargetAppGroupMembersMapping   map[string][]*schema.GroupMember
-	createTargetAppProjectMembersMapping map[string][]*schema.ProjectMember
-	updateTargetAppProjectMembersMapping map[string][]*schema.ProjectMember
-	result                               *SyncLogicResult
+	writeConcurrency map[target.TargetAppKindName]int
+	mappingRules     map[target.TargetAppKindName]MappingRules
+	timeouts         PhaseTimeouts
+	fullSyncPeriod   time.Duration
+	// the states of the target apps, they are set once the target apps are read
+	targetStates []*targetAppState
+	store        store.Store
+	result       *SyncLogicResult
 }
 
 // new service instance
@@ -77,19 +90,11 @@ func NewSyncLogicService(ctx context.Context) *SyncLogicService {
 		Detail: make(map[target.TargetAppKindName][]*SyncLogicResultItem, 0),
 	}
 	svc := &SyncLogicService{
-		ctx:                            ctx,
-		result:                         result,
-		targetMapping:                  make(map[target.TargetAppKindName]target.TargetApp, 0),
-		targetAppUsersMapping:          make(map[target.TargetAppKindName][]*schema.User, 0),
-		createTargetAppUsersMapping:    make(map[target.TargetAppKindName][]*schema.User, 0),
-		updateTargetAppUsersMapping:    make(map[target.TargetAppKindName][]*schema.User, 0),
-		targetAppGroupsMapping:         make(map[target.TargetAppKindName][]*schema.Group, 0),
-		createTargetAppGroupsMapping:   make(map[target.TargetAppKindName][]*schema.Group, 0),
-		updateTargetAppGroupsMapping:   make(map[target.TargetAppKindName][]*schema.Group, 0),
-		targetAppProjectsMapping:       make(map[target.TargetAppKindName][]*schema.Project, 0),
-		createTargetAppProjectsMapping: make(map[target.TargetAppKindName][]*schema.Project, 0),
-		updateTargetAppProjectsMapping: make(map[target.TargetAppKindName][]*schema.Project, 0),
-		targetAppGroupMemberMapping:    make(map[target.TargetAppKindName][]*schema.GroupMember, 0),
+		ctx:              ctx,
+		result:           result,
+		writeConcurrency: make(map[target.TargetAppKindName]int, 0),
+		mappingRules:     make(map[target.TargetAppKindName]MappingRules, 0),
+		fullSyncPeriod:   mappingResyncPeriod,
 	}
 	svc.registerReadIdpDataHandleFunc(
 		svc.readIdpUsers,
@@ -105,17 +110,33 @@ func NewSyncLogicService(ctx context.Context) *SyncLogicService {
 }
 
 // Service Entry Invoke Method
-func (s *SyncLogicService) Run() error {
-	defer util.PanicTrace()
+func (s *SyncLogicService) Run() (err error) {
+	s.ctx = log.WithValues(s.ctx, log.KeyIdpKind, s.idp.Kind(), log.KeyIdpName, s.idp.GetName())
+	var span trace.Span
+	s.ctx, span = tracing.Start(s.ctx, "SyncLogicService.Run", tracing.AttrIdp.String(fmt.Sprintf("%s/%s", s.idp.Kind(), s.idp.GetName())))
+	defer func() { tracing.End(span, err) }()
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			err = util.PanicError(s.ctx, recovered)
+			s.result.addBrief(NewPanicItem(SyncRunKind, err.Error()))
+		}
+	}()
 
-	err := s.readData()
+	err = s.readData()
 	if err != nil {
 		return err
 	}
 
-	s.userDataHandle()
-	s.groupDataHandle()
-	s.projectDataHandle()
+	compareStart := time.Now()
+	_, compareSpan := startPhase(s.ctx, phaseCompare, "")
+	for _, state := range s.targetStates {
+		ctx := s.targetContext(state.targetApp)
+		s.userDataHandle(ctx, state)
+		s.groupDataHandle(ctx, state)
+		s.projectDataHandle(ctx, state)
+	}
+	compareSpan.End()
+	metrics.ObservePhase(phaseCompare, "", compareStart)
 
 	err = s.writeTargetAppsData()
 	if err != nil {
@@ -149,21 +170,70 @@ func (s *SyncLogicService) InjectIdp(idp idp.Idp) *SyncLogicService {
 	return s
 }
 
+// set the filter of idp users, the excluded users are neither synchronized nor added to the groups
+func (s *SyncLogicService) SetUserFilter(filter UserFilter) *SyncLogicService {
+	s.userFilter = filter
+	return s
+}
+
+// set the filters of idp users, groups and projects, the excluded entities are neither compared nor written
+func (s *SyncLogicService) SetFilters(filters Filters) *SyncLogicService {
+	s.filters = filters
+	return s
+}
+
+// set how the membership of the subgroups is synchronized, the effective members of the groups are read if they are flattened,
+// the target apps have to be set to the same mode
+func (s *SyncLogicService) SetMembership(mode schema.MembershipMode) *SyncLogicService {
+	s.membership = mode
+	return s
+}
+
+// set the mapping rules transforming the idp entities before they are compared with the entities of the target app
+func (s *SyncLogicService) SetMappingRules(targetIdentity target.TargetAppKindName, rules MappingRules) *SyncLogicService {
+	s.mappingRules[targetIdentity] = rules
+	return s
+}
+
+// set the max number of entities written to the target app at the same time, the default is used if it is not positive
+func (s *SyncLogicService) SetWriteConcurrency(targetIdentity target.TargetAppKindName, concurrency int) *SyncLogicService {
+	s.writeConcurrency[targetIdentity] = concurrency
+	return s
+}
+
+// set the timeouts of the phases, the phases are not limited by default
+func (s *SyncLogicService) SetPhaseTimeouts(timeouts PhaseTimeouts) *SyncLogicService {
+	s.timeouts = timeouts
+	return s
+}
+
+// set how often all entities are compared with the target apps when the store is injected, the default is 6h,
+// all entities are compared in every sync if it is not positive
+func (s *SyncLogicService) SetFullSyncPeriod(period time.Duration) *SyncLogicService {
+	s.fullSyncPeriod = period
+	return s
+}
+
+// inject the store of mappings, the updates of unchanged entities are not skipped without it
+func (s *SyncLogicService) InjectStore(st store.Store) *SyncLogicService {
+	s.store = st
+	return s
+}
+
 // inject target App
 func (s *SyncLogicService) InjectTargetApps(targetApps ...target.TargetApp) *SyncLogicService {
 	s.targetApps = append(s.targetApps, targetApps...)
-	for _, targetApp := range targetApps {
-		s.targetMapping[targetApp.IdentityKey()] = targetApp
-	}
 	return s
 }
 
+// readData reads the idp before the target apps, so that the entities unchanged since the last sync are not read from the target apps.
 func (s *SyncLogicService) readData() error {
-	log.Loger.Infof("Enter synchronous data reading phase")
-	errGroup := new(errgroup.Group)
-	errGroup.Go(s.readIdpData)
-	errGroup.Go(s.readTargetAppsData)
-	if err := errGroup.Wait(); err != nil {
+	log.FromContext(s.ctx).V(1).Info("enter synchronous data reading phase")
+	err := s.readIdpData()
+	if err == nil {
+		err = s.readTargetAppsData()
+	}
+	if err != nil {
 		s.result.addBrief(NewReadResourceFailItem(err.Error()))
 		return err
 	}
@@ -171,51 +241,59 @@ func (s *SyncLogicService) readData() error {
 	return nil
 }
 
-func (s *SyncLogicService) readIdpData() error {
-	log.Loger.WithField("idp_kind", s.idp.Kind()).
-		WithField("idp_name", s.idp.Kind()).
-		Infof("Start idp data reading phase")
+func (s *SyncLogicService) readIdpData() (err error) {
+	defer metrics.ObservePhase(phaseReadIdp, "", time.Now())
+	ctx, span := startPhase(s.ctx, phaseReadIdp, "")
+	defer func() { tracing.End(span, err) }()
+	ctx, cancel := withPhaseTimeout(ctx, s.timeouts.ReadIdp)
+	defer cancel()
+	defer func() { err = phaseTimeoutError(ctx, phaseReadIdp, s.timeouts.ReadIdp, err) }()
+	logger := log.FromContext(ctx)
+	logger.V(1).Info("start idp data reading phase")
 	// concurrent start
 	errGroup := errgroup.Group{}
 	for _, f := range s.readIdpDataHandleFuncs {
-		errGroup.Go(f)
+		f := f
+		errGroup.Go(func() error { return f(ctx) })
 	}
 	if err := errGroup.Wait(); err != nil {
-		log.Loger.WithField("idp_kind", s.idp.Kind()).
-			WithField("idp_name", s.idp.Kind()).Errorf("Idp data read fail, err:%v", err)
+		logger.Error(err, "idp data read fail")
 		return err
 	}
 	// concurrent end
-	log.Loger.WithField("idp_kind", s.idp.Kind()).
-		WithField("idp_name", s.idp.Kind()).
-		Infof("Idp user 、group 、project data read success")
-	err := s.readIdpGroupMembers()
+	logger.V(1).Info("idp user, group, project data read success")
+	err = s.readIdpGroupMembers(ctx)
 	if err != nil {
-		log.Loger.WithField("idp_kind", s.idp.Kind()).
-			WithField("idp_name", s.idp.Kind()).Errorf("Idp group member data read fail, err:%v", err)
+		logger.Error(err, "idp group member data read fail")
 		return err
 	}
+	s.resolveUserCollisions(ctx)
 	return nil
 }
 
+// readTargetAppsData reads the target apps concurrently, the states of the target apps are set once all of them are read.
+// The idp entities are transformed for each target app and planned with its mappings before it is read.
 func (s *SyncLogicService) readTargetAppsData() error {
 	wg := sync.WaitGroup{}
 	doErrChan := make(chan error)
+	states := make([]*targetAppState, len(s.targetApps))
 	wg.Add(len(s.targetApps))
-	for _, targetApp := range s.targetApps {
-		go func(targetApp target.TargetApp) {
+	for i, targetApp := range s.targetApps {
+		states[i] = newTargetAppState(targetApp, s.writeConcurrency[targetApp.IdentityKey()])
+		go func(state *targetAppState) {
 			defer wg.Done()
-			if err := s.readTargetAppData(targetApp); err != nil {
-				log.Loger.WithField("targetapp_kind", targetApp.Kind()).
-					WithField("targetapp_name", targetApp.GetName()).
-					Errorf("read targetapp data fail, err:%v", err)
+			ctx := s.targetContext(state.targetApp)
+			logger := log.FromContext(ctx)
+			s.applyMappingRules(ctx, state)
+			s.loadMappings(ctx, state)
+			s.planSync(ctx, state)
+			if err := s.readTargetAppData(ctx, state); err != nil {
+				logger.Error(err, "read targetapp data fail")
 				doErrChan <- err
 				return
 			}
-			log.Loger.WithField("targetapp_kind", targetApp.Kind()).
-				WithField("targetapp_name", targetApp.GetName()).
-				Infof("read targetapp data success")
-		}(targetApp)
+			logger.V(1).Info("read targetapp data success")
+		}(states[i])
 	}
 	go func() {
 		defer close(doErrChan)
@@ -226,23 +304,32 @@ func (s *SyncLogicService) readTargetAppsData() error {
 	for errItem := range doErrChan {
 		AggregateErr = multierror.Append(AggregateErr, errItem)
 	}
-
-	return AggregateErr
+	if AggregateErr != nil {
+		return AggregateErr
+	}
+	s.targetStates = states
+	return nil
 }
 
-func (s *SyncLogicService) readTargetAppData(targetApp target.TargetApp) error {
+func (s *SyncLogicService) readTargetAppData(ctx context.Context, state *targetAppState) (err error) {
+	defer metrics.ObservePhase(phaseReadTarget, targetLabel(state.targetApp), time.Now())
+	ctx, span := startPhase(ctx, phaseReadTarget, targetLabel(state.targetApp))
+	defer func() { tracing.End(span, err) }()
+	ctx, cancel := withPhaseTimeout(ctx, s.timeouts.ReadTarget)
+	defer cancel()
+	defer func() { err = phaseTimeoutError(ctx, phaseReadTarget, s.timeouts.ReadTarget, err) }()
 	// concurrent start
 	doErrChan := make(chan error)
 	wg := sync.WaitGroup{}
 	for _, f := range s.readTargetAppDataHandleFuncs {
 		wg.Add(1)
-		go func(targetApp target.TargetApp, f readTargetAppDataHandleFuncSignature) {
+		go func(f readTargetAppDataHandleFuncSignature) {
 			defer wg.Done()
-			if err := f(targetApp); err != nil {
+			if err := f(ctx, state); err != nil {
 				doErrChan <- err
 				return
 			}
-		}(targetApp, f)
+		}(f)
 	}
 
 	go func() {
@@ -261,178 +348,243 @@ func (s *SyncLogicService) readTargetAppData(targetApp target.TargetApp) error {
 	return nil
 }
 
-func (s *SyncLogicService) readIdpUsers() error {
-	defer util.PanicTrace()
-	users, err := s.idp.GetUsers(s.ctx)
+func (s *SyncLogicService) readIdpUsers(ctx context.Context) (err error) {
+	defer s.recoverPanic(ctx, nil, ReadResourceKind, &err)
+	users, err := s.idp.GetUsers(ctx)
 	if err != nil {
 		return fmt.Errorf("read users fail, err:%w", err)
 	}
-	s.idpUsers = users
-	log.Loger.WithField("idp_kind", s.idp.Kind()).
-		WithField("idp_name", s.idp.Kind()).
-		Infof("Idp user data read success")
+	s.idpUsers = make([]*schema.User, 0, len(users))
+	s.excludedUserIds = make(map[string]bool)
+	for _, user := range users {
+		if !s.userFilter.Match(user) || !s.filters.Users.Match(user.Identity, user.Username) {
+			s.excludedUserIds[user.Identity] = true
+			continue
+		}
+		s.idpUsers = append(s.idpUsers, user)
+	}
+	log.FromContext(ctx).V(1).Info("idp user data read success", "excluded", len(s.excludedUserIds))
 	return nil
 }
 
-func (s *SyncLogicService) readIdpGroups() error {
-	defer util.PanicTrace()
-	groups, err := s.idp.GetGroups(s.ctx)
+func (s *SyncLogicService) readIdpGroups(ctx context.Context) (err error) {
+	defer s.recoverPanic(ctx, nil, ReadResourceKind, &err)
+	groups, err := s.idp.GetGroups(ctx)
 	if err != nil {
 		return fmt.Errorf("read groups fail, err:%w", err)
 	}
-	s.idpGroups = groups
-	log.Loger.WithField("idp_kind", s.idp.Kind()).
-		WithField("idp_name", s.idp.Kind()).
-		Infof("Idp group data read success")
+	s.idpGroups = make([]*schema.Group, 0, len(groups))
+	s.excludedGroupIds = make(map[string]bool)
+	for _, group := range groups {
+		if !s.filters.Groups.Match(group.Identity, group.Name) {
+			s.excludedGroupIds[group.Identity] = true
+			continue
+		}
+		s.idpGroups = append(s.idpGroups, group)
+	}
+	// the roles of the excluded subgroups are not created, so they are not nested either
+	for i, group := range s.idpGroups {
+		childIds := make([]string, 0, len(group.ChildIds))
+		for _, childId := range group.ChildIds {
+			if !s.excludedGroupIds[childId] {
+				childIds = append(childIds, childId)
+			}
+		}
+		if len(childIds) != len(group.ChildIds) {
+			filtered := *group
+			filtered.ChildIds = childIds
+			s.idpGroups[i] = &filtered
+		}
+	}
+	log.FromContext(ctx).V(1).Info("idp group data read success", "excluded", len(s.excludedGroupIds))
 	return nil
 }
 
-func (s *SyncLogicService) readIdpProjects() error {
-	defer util.PanicTrace()
-	projects, err := s.idp.GetProjects(s.ctx)
+func (s *SyncLogicService) readIdpProjects(ctx context.Context) (err error) {
+	defer s.recoverPanic(ctx, nil, ReadResourceKind, &err)
+	projects, err := s.idp.GetProjects(ctx)
 	if err != nil {
 		return fmt.Errorf("read project fail, err:%w", err)
 	}
-	s.idpProjects = projects
-	log.Loger.WithField("idp_kind", s.idp.Kind()).
-		WithField("idp_name", s.idp.Kind()).
-		Infof("Idp project data read success")
+	s.idpProjects = make([]*schema.Project, 0, len(projects))
+	for _, project := range projects {
+		if s.filters.Projects.Match(project.Identity, project.Name) {
+			s.idpProjects = append(s.idpProjects, project)
+		}
+	}
+	log.FromContext(ctx).V(1).Info("idp project data read success", "excluded", len(projects)-len(s.idpProjects))
 	return nil
 }
 
-func (s *SyncLogicService) readIdpGroupMembers() error {
-	defer util.PanicTrace()
-	groupMembers, err := s.idp.GetAllGroupMembers(s.ctx, s.idpGroups, s.idpUsers)
+func (s *SyncLogicService) readIdpGroupMembers(ctx context.Context) (err error) {
+	defer s.recoverPanic(ctx, nil, ReadResourceKind, &err)
+	groupMembers, err := s.idp.GetAllGroupMembers(ctx, s.idpGroups, s.idpUsers)
 	if err != nil {
 		return fmt.Errorf("read group members fail, err:%w", err)
 	}
-	s.idpGroupMembers = groupMembers
-	log.Loger.WithField("idp_kind", s.idp.Kind()).
-		WithField("idp_name", s.idp.Kind()).
-		Infof("Idp group member data read success")
+	if !s.membership.NestsGroups() {
+		groups := make([]schema.Group, 0, len(s.idpGroups))
+		for _, group := range s.idpGroups {
+			groups = append(groups, *group)
+		}
+		groupMembers = schema.NewGroupTree(groups).GetEffectiveMembers(groupMembers)
+	}
+	s.idpGroupMembers = make([]*schema.GroupMember, 0, len(groupMembers))
+	memberIds := make(map[string]bool)
+	for _, groupMember := range groupMembers {
+		if !s.excludedUserIds[groupMember.UserId] && !s.excludedGroupIds[groupMember.GroupId] {
+			s.idpGroupMembers = append(s.idpGroupMembers, groupMember)
+			memberIds[groupMember.UserId] = true
+		}
+	}
+	if s.userFilter.MembersOnly {
+		users := make([]*schema.User, 0, len(memberIds))
+		for _, user := range s.idpUsers {
+			if memberIds[user.Identity] {
+				users = append(users, user)
+			}
+		}
+		s.idpUsers = users
+	}
+	log.FromContext(ctx).V(1).Info("idp group member data read success")
 	return nil
 }
 
-func (s *SyncLogicService) readTargetAppUsers(targetApp target.TargetApp) error {
-	defer util.PanicTrace()
-	users, err := targetApp.GetUsers(s.ctx)
+func (s *SyncLogicService) readTargetAppUsers(ctx context.Context, state *targetAppState) (err error) {
+	defer s.recoverPanic(ctx, state, SyncUserKind, &err)
+	if state.skipsRead(len(state.pendingUsers())) {
+		return nil
+	}
+	users, err := state.targetApp.GetUsers(ctx)
 	if err != nil {
 		errMsg := fmt.Sprintf("read target users fail, err:%v", err)
-		s.result.addDetail(targetApp.IdentityKey(), NewSyncUserFailItem(errMsg))
+		s.result.addDetail(state.identity(), NewSyncUserFailItem(errMsg))
 		return errors.New(errMsg)
 	}
-	s.targetAppUsersMapping[targetApp.IdentityKey()] = users
-	log.Loger.WithField("targetapp_kind", targetApp.Kind()).
-		WithField("targetapp_name", targetApp.GetName()).
-		Infof("read targetapp user data success")
+	state.users = users
+	log.FromContext(ctx).V(1).Info("read targetapp user data success")
 	return nil
 }
 
-func (s *SyncLogicService) readTargetAppGroups(targetApp target.TargetApp) error {
-	defer util.PanicTrace()
-	groups, err := targetApp.GetGroups(s.ctx)
+func (s *SyncLogicService) readTargetAppGroups(ctx context.Context, state *targetAppState) (err error) {
+	defer s.recoverPanic(ctx, state, SyncGroupKind, &err)
+	if state.skipsRead(len(state.pendingGroups())) {
+		return nil
+	}
+	groups, err := state.targetApp.GetGroups(ctx)
 	if err != nil {
 		errMsg := fmt.Sprintf("read target groups fail, err:%v", err)
-		s.result.addDetail(targetApp.IdentityKey(), NewSyncGroupFailItem(errMsg))
+		s.result.addDetail(state.identity(), NewSyncGroupFailItem(errMsg))
 		return errors.New(errMsg)
 	}
-	s.targetAppGroupsMapping[targetApp.IdentityKey()] = groups
-	log.Loger.WithField("targetapp_kind", targetApp.Kind()).
-		WithField("targetapp_name", targetApp.GetName()).
-		Infof("read targetapp group data success")
+	state.groups = groups
+	log.FromContext(ctx).V(1).Info("read targetapp group data success")
 	return nil
 }
 
-func (s *SyncLogicService) readTargetAppProjects(targetApp target.TargetApp) error {
-	defer util.PanicTrace()
-	projects, err := targetApp.GetProjects(s.ctx)
+func (s *SyncLogicService) readTargetAppProjects(ctx context.Context, state *targetAppState) (err error) {
+	defer s.recoverPanic(ctx, state, SyncProjectKind, &err)
+	if state.skipsRead(len(state.pendingProjects())) {
+		return nil
+	}
+	projects, err := state.targetApp.GetProjects(ctx)
 	if err != nil {
 		errMsg := fmt.Sprintf("read target projects fail, err:%v", err)
-		s.result.addDetail(targetApp.IdentityKey(), NewSyncProjectFailItem(errMsg))
+		s.result.addDetail(state.identity(), NewSyncProjectFailItem(errMsg))
 		return errors.New(errMsg)
 	}
-	s.targetAppProjectsMapping[targetApp.IdentityKey()] = projects
-	log.Loger.WithField("targetapp_kind", targetApp.Kind()).
-		WithField("targetapp_name", targetApp.GetName()).
-		Infof("read targetapp projects data success")
+	state.projects = projects
+	log.FromContext(ctx).V(1).Info("read targetapp projects data success")
 	return nil
 }
 
-func (s *SyncLogicService) readTargetAppGroupMembers(targetApp target.TargetApp) error {
-	defer util.PanicTrace()
-	groupMembers, err := targetApp.GetGroupMembers(s.ctx)
+func (s *SyncLogicService) readTargetAppGroupMembers(ctx context.Context, state *targetAppState) (groupMembers []*schema.GroupMember, err error) {
+	defer s.recoverPanic(ctx, state, SyncGroupMemberKind, &err)
+	groupMembers, err = state.targetApp.GetGroupMembers(ctx)
 	if err != nil {
 		errMsg := fmt.Sprintf("read target group members fail, err:%v", err)
-		s.result.addDetail(targetApp.IdentityKey(), NewSyncGroupMemberFailItem(errMsg))
-		return errors.New(errMsg)
+		s.result.addDetail(state.identity(), NewSyncGroupMemberFailItem(errMsg))
+		return nil, errors.New(errMsg)
 	}
-	s.targetAppGroupMemberMapping[targetApp.IdentityKey()] = groupMembers
-	log.Loger.WithField("targetapp_kind", targetApp.Kind()).
-		WithField("targetapp_name", targetApp.GetName()).
-		Infof("read targetapp group members data success")
-	return nil
+	log.FromContext(ctx).V(1).Info("read targetapp group members data success")
+	return groupMembers, nil
 }
 
-func (s *SyncLogicService) userDataHandle() {
-	for targetIdentity, targetUsers := range s.targetAppUsersMapping {
-		createUsers, updateUsers := s.targetMapping[targetIdentity].CompareUsers(s.idpUsers, targetUsers)
-		s.createTargetAppUsersMapping[targetIdentity] = createUsers
-		s.updateTargetAppUsersMapping[targetIdentity] = updateUsers
-	}
-	return
+func (s *SyncLogicService) userDataHandle(ctx context.Context, state *targetAppState) {
+	state.createUsers, state.updateUsers = state.targetApp.CompareUsers(ctx, state.pendingUsers(), state.users)
 }
 
-func (s *SyncLogicService) groupDataHandle() {
-	for targetIdentity, targetGroups := range s.targetAppGroupsMapping {
-		createUsers, updateUsers := s.targetMapping[targetIdentity].CompareGroups(s.idpGroups, targetGroups)
-		s.createTargetAppGroupsMapping[targetIdentity] = createUsers
-		s.updateTargetAppGroupsMapping[targetIdentity] = updateUsers
-	}
-	return
+func (s *SyncLogicService) groupDataHandle(ctx context.Context, state *targetAppState) {
+	state.createGroups, state.updateGroups = state.targetApp.CompareGroups(ctx, state.pendingGroups(), state.groups)
+}
+
+func (s *SyncLogicService) projectDataHandle(ctx context.Context, state *targetAppState) {
+	state.createProjects, state.updateProjects = state.targetApp.CompareProjects(ctx, state.pendingProjects(), state.projects)
 }
 
-func (s *SyncLogicService) projectDataHandle() {
-	for targetIdentity, targetProjects := range s.targetAppProjectsMapping {
-		createProjects, updateProjects := s.targetMapping[targetIdentity].CompareProjects(s.idpProjects, targetProjects)
-		s.createTargetAppProjectsMapping[targetIdentity] = createProjects
-		s.updateTargetAppProjectsMapping[targetIdentity] = updateProjects
+func (s *SyncLogicService) syncGroupMember(ctx context.Context, state *targetAppState) (err error) {
+	targetApp := state.targetApp
+	targetGroupMembers, err := s.readTargetAppGroupMembers(ctx, state)
+	if err != nil {
+		return err
+	}
+	defer s.recoverPanic(ctx, state, SyncGroupMemberKind, &err)
+	idpGroupMembers := s.withForeignUsers(state, s.keepExcludedGroupMembers(targetApp, targetGroupMembers))
+	err = targetApp.SyncGroupMember(ctx, state.idpUsers, idpGroupMembers, targetGroupMembers)
+	if err != nil {
+		err = fmt.Errorf("sync group members fail, err:%w", err)
+		s.result.addDetail(targetApp.IdentityKey(), NewSyncGroupMemberFailItem(err.Error()))
+		return err
 	}
-	return
+	s.result.addDetail(targetApp.IdentityKey(), NewSyncGroupMemberSuccessItem())
+	return nil
 }
 
-func (s *SyncLogicService) syncGroupMember() error {
-	AggregateErr := (error)(nil)
-	for targetIdentity, targetGroupMembers := range s.targetAppGroupMemberMapping {
-		err := s.targetMapping[targetIdentity].SyncGroupMember(s.ctx, s.idpGroupMembers, targetGroupMembers)
-		AggregateErr = multierror.Append(AggregateErr, err)
+// keepExcludedGroupMembers adds the memberships of the excluded groups in the target app to the idp group members,
+// so that the target app keeps them as they are rather than removing them.
+func (s *SyncLogicService) keepExcludedGroupMembers(targetApp target.TargetApp, targetGroupMembers []*schema.GroupMember) []*schema.GroupMember {
+	if len(s.excludedGroupIds) == 0 {
+		return s.idpGroupMembers
 	}
-	return AggregateErr
+	excludedGroupIds := make(map[string]string, len(s.excludedGroupIds))
+	for groupId := range s.excludedGroupIds {
+		excludedGroupIds[targetApp.GenerateIdpGroupIdentity(schema.NamespaceGroup, groupId)] = groupId
+	}
+	userIds := make(map[string]string, len(s.idpUsers))
+	for _, user := range s.idpUsers {
+		userIds[targetApp.GenerateIdpUserIdentity(user.Identity)] = user.Identity
+	}
+	for userId, claim := range s.foreignUsers {
+		if identity := claim.targetIdentity(targetApp.IdentityKey()); len(identity) > 0 {
+			userIds[identity] = userId
+		}
+	}
+	groupMembers := append(make([]*schema.GroupMember, 0, len(s.idpGroupMembers)), s.idpGroupMembers...)
+	for _, member := range targetGroupMembers {
+		groupId, excluded := excludedGroupIds[member.GroupId]
+		userId, synchronized := userIds[member.UserId]
+		if excluded && synchronized {
+			groupMembers = append(groupMembers, &schema.GroupMember{GroupId: groupId, UserId: userId})
+		}
+	}
+	return groupMembers
 }
 
 func (s *SyncLogicService) writeTargetAppsData() error {
 	doErrChan := make(chan error)
 	wg := sync.WaitGroup{}
-	wg.Add(len(s.targetApps))
-	for _, targetApp := range s.targetApps {
-		go func(targetApp target.TargetApp) {
+	wg.Add(len(s.targetStates))
+	for _, state := range s.targetStates {
+		go func(state *targetAppState) {
 			defer wg.Done()
-			if err := s.writeTargetAppData(targetApp); err != nil {
+			ctx := s.targetContext(state.targetApp)
+			if err := s.writeTargetAppData(ctx, state); err != nil {
 				doErrChan <- err
-				log.Loger.WithFields(logrus.Fields{
-					"idp_kind":       s.idp.Kind(),
-					"idp_name":       s.idp.GetName(),
-					"targetapp_kind": targetApp.Kind(),
-					"targetapp_name": targetApp.GetName(),
-				}).Errorf("idp data to targetapp fail, err:%v", err)
+				log.FromContext(ctx).Error(err, "idp data to targetapp fail")
 				return
 			}
-			log.Loger.WithFields(logrus.Fields{
-				"idp_kind":       s.idp.Kind(),
-				"idp_name":       s.idp.GetName(),
-				"targetapp_kind": targetApp.Kind(),
-				"targetapp_name": targetApp.GetName(),
-			}).Info("idp data to targetapp success")
-		}(targetApp)
+			log.FromContext(ctx).V(1).Info("idp data to targetapp success")
+		}(state)
 	}
 	go func() {
 		defer close(doErrChan)
@@ -447,200 +599,138 @@ func (s *SyncLogicService) writeTargetAppsData() error {
 	return AggregateErr
 }
 
-func (s *SyncLogicService) writeTargetAppData(targetApp target.TargetApp) error {
-	defer util.PanicTrace()
-	err := (error)(nil)
-	err = s.syncUser(targetApp)
-	if err != nil {
-		return err
-	}
-	err = s.syncGroup(targetApp)
-	if err != nil {
-		return err
+// writeTargetAppData writes the changes to the target app, a failed step or entity does not stop the others.
+// The mappings are saved without the failed entities, they are not saved at all if a step of the target app fails,
+// e.g. the roles of groups are not nested, since the entities written before may be incomplete.
+func (s *SyncLogicService) writeTargetAppData(ctx context.Context, state *targetAppState) (err error) {
+	targetApp := state.targetApp
+	defer metrics.ObservePhase(phaseWrite, targetLabel(targetApp), time.Now())
+	ctx, span := startPhase(ctx, phaseWrite, targetLabel(targetApp))
+	defer func() { tracing.End(span, err) }()
+	defer s.recoverPanic(ctx, state, SyncRunKind, &err)
+	ctx, cancel := withPhaseTimeout(ctx, s.timeouts.Write)
+	defer cancel()
+	var entityErr, stepErr error
+	steps := []func(ctx context.Context, state *targetAppState) error{
+		s.syncCreateUser,
+		s.syncUpdateUser,
+		s.syncCreateGroup,
+		s.syncUpdateGroup,
+		s.wrappingUpAfterGroupSync,
+		s.syncCreateProject,
+		s.groupBindingProjects,
+		s.syncUpdateProject,
+		// Get the latest target application group members
+		s.syncGroupMember,
+	}
+	for _, step := range steps {
+		if ctx.Err() != nil {
+			// the rest steps are written in the next sync
+			break
+		}
+		err := step(ctx, state)
+		var failed *entitiesError
+		switch {
+		case err == nil:
+		case errors.As(err, &failed):
+			entityErr = multierror.Append(entityErr, err)
+		default:
+			stepErr = multierror.Append(stepErr, err)
+		}
 	}
-	err = s.syncProjects(targetApp)
-	if err != nil {
-		return err
+	if err := ctx.Err(); err != nil {
+		stepErr = multierror.Append(stepErr, phaseTimeoutError(ctx, phaseWrite, s.timeouts.Write, err))
 	}
-	// Get the latest target application group members
-	err = s.readTargetAppGroupMembers(targetApp)
-	if err != nil {
-		return err
+	if stepErr == nil {
+		s.saveMappings(ctx, state)
 	}
-	err = s.syncGroupMember()
-	if err != nil {
-		return err
+	if stepErr != nil || entityErr != nil {
+		return multierror.Append(stepErr, entityErr)
 	}
 	return nil
 }
 
-func (s *SyncLogicService) syncUser(targetApp target.TargetApp) error {
-	err := s.syncCreateUser(targetApp)
-	if err != nil {
-		return err
-	}
-	err = s.syncUpdateUser(targetApp)
-	if err != nil {
-		return err
-	}
-	return nil
+func (s *SyncLogicService) syncCreateUser(ctx context.Context, state *targetAppState) error {
+	createUsers := state.createUsers
+	return s.writeEntities(ctx, state, entityUser, metrics.OperationCreate, len(createUsers),
+		func(i int) string { return createUsers[i].Identity },
+		func(ctx context.Context, i int) error { return state.targetApp.CreateUser(ctx, createUsers[i]) })
 }
 
-func (s *SyncLogicService) syncCreateUser(targetApp target.TargetApp) error {
-	targetIdentity := targetApp.IdentityKey()
-	createUsers := s.createTargetAppUsersMapping[targetIdentity]
-	for _, createUser := range createUsers {
-		if devUser := os.Getenv("DEV_USERNAME_PREFIX"); len(devUser) > 0 && !strings.Contains(createUser.Name, devUser) {
-			log.Loger.Debugf("debug user_name :%v", devUser)
-			continue
-		}
-		err := targetApp.CreateUser(s.ctx, createUser)
-		if err != nil {
-			log.Loger.WithFields(logrus.Fields{
-				"targetapp_kind": targetApp.Kind(),
-				"targetapp_name": targetApp.GetName(),
-			}).Errorf("create user fail, err:%v", err)
-			s.result.addDetail(targetIdentity, NewSyncUserFailItem(err.Error()))
-			return err
-		}
-	}
-	return nil
+func (s *SyncLogicService) syncUpdateUser(ctx context.Context, state *targetAppState) error {
+	updateUsers := state.updateUsers
+	return s.writeEntities(ctx, state, entityUser, metrics.OperationUpdate, len(updateUsers),
+		func(i int) string { return updateUsers[i].Identity },
+		func(ctx context.Context, i int) error {
+			return state.targetApp.UpdateUser(ctx, updateUsers[i].Identity, updateUsers[i])
+		})
 }
 
-func (s *SyncLogicService) syncUpdateUser(targetApp target.TargetApp) error {
-	targetIdentity := targetApp.IdentityKey()
-	updateUsers := s.updateTargetAppUsersMapping[targetIdentity]
-	for _, updateUser := range updateUsers {
-		if devUser := os.Getenv("DEV_USERNAME_PREFIX"); len(devUser) > 0 && !strings.Contains(updateUser.Name, devUser) {
-			log.Loger.Debugf("debug user_name :%v", devUser)
-			continue
-		}
-		err := targetApp.UpdateUser(s.ctx, updateUser.Identity, updateUser)
-		if err != nil {
-			log.Loger.WithFields(logrus.Fields{
-				"targetapp_kind": targetApp.Kind(),
-				"targetapp_name": targetApp.GetName(),
-			}).Errorf("update user fail, err:%v", err)
-			s.result.addDetail(targetIdentity, NewSyncUserFailItem(err.Error()))
-			return err
-		}
-	}
-	return nil
+func (s *SyncLogicService) syncCreateGroup(ctx context.Context, state *targetAppState) error {
+	createGroups := state.createGroups
+	return s.writeEntities(ctx, state, entityGroup, metrics.OperationCreate, len(createGroups),
+		func(i int) string { return createGroups[i].Identity },
+		func(ctx context.Context, i int) error { return state.targetApp.CreateGroup(ctx, createGroups[i]) })
 }
 
-func (s *SyncLogicService) syncGroup(targetApp target.TargetApp) error {
-	err := s.syncCreateGroup(targetApp)
-	if err != nil {
-		return err
-	}
-	err = s.syncUpdateGroup(targetApp)
-	if err != nil {
-		return err
-	}
-	err = targetApp.WrappingUpAfterGroupSync(s.ctx)
-	if err != nil {
-		return err
-	}
-	return nil
+func (s *SyncLogicService) syncUpdateGroup(ctx context.Context, state *targetAppState) error {
+	updateGroups := state.updateGroups
+	return s.writeEntities(ctx, state, entityGroup, metrics.OperationUpdate, len(updateGroups),
+		func(i int) string { return updateGroups[i].Identity },
+		func(ctx context.Context, i int) error {
+			return state.targetApp.UpdateGroup(ctx, updateGroups[i].Identity, updateGroups[i])
+		})
 }
 
-func (s *SyncLogicService) syncCreateGroup(targetApp target.TargetApp) error {
-	targetIdentity := targetApp.IdentityKey()
-	createGroups := s.createTargetAppGroupsMapping[targetIdentity]
-	for _, createGroup := range createGroups {
-		if devUser := os.Getenv("DEV_GROUPNAME_PREFIX"); len(devUser) > 0 && !strings.Contains(createGroup.Name, devUser) {
-			log.Loger.Debugf("debug group_name :%v", devUser)
-			continue
-		}
-		err := targetApp.CreateGroup(s.ctx, createGroup)
-		if err != nil {
-			log.Loger.WithFields(logrus.Fields{
-				"targetapp_kind": targetApp.Kind(),
-				"targetapp_name": targetApp.GetName(),
-			}).Errorf("create group fail, err:%v", err)
-			s.result.addDetail(targetIdentity, NewSyncGroupFailItem(err.Error()))
-			return err
-		}
+// wrappingUpAfterGroupSync nests the roles of the groups after the groups are written.
+func (s *SyncLogicService) wrappingUpAfterGroupSync(ctx context.Context, state *targetAppState) (err error) {
+	defer s.recoverPanic(ctx, state, SyncGroupKind, &err)
+	if err := state.targetApp.WrappingUpAfterGroupSync(ctx, state.idpGroups); err != nil {
+		err = fmt.Errorf("wrapping up groups fail, err:%w", err)
+		s.result.addDetail(state.identity(), NewSyncGroupFailItem(err.Error()))
+		return err
 	}
 	return nil
 }
 
-func (s *SyncLogicService) syncUpdateGroup(targetApp target.TargetApp) error {
-	targetIdentity := targetApp.IdentityKey()
-	updateGroups := s.updateTargetAppGroupsMapping[targetIdentity]
-	for _, updateGroup := range updateGroups {
-		if devUser := os.Getenv("DEV_GROUPNAME_PREFIX"); len(devUser) > 0 && !strings.Contains(updateGroup.Name, devUser) {
-			log.Loger.Debugf("debug group_name :%v", devUser)
-			continue
-		}
-		err := targetApp.UpdateGroup(s.ctx, updateGroup.Identity, updateGroup)
-		if err != nil {
-			log.Loger.WithFields(logrus.Fields{
-				"targetapp_kind": targetApp.Kind(),
-				"targetapp_name": targetApp.GetName(),
-			}).Errorf("update group fail, err:%v", err)
-			s.result.addDetail(targetIdentity, NewSyncGroupFailItem(err.Error()))
-			return err
-		}
-	}
-	return nil
+func (s *SyncLogicService) syncCreateProject(ctx context.Context, state *targetAppState) error {
+	createProjects := state.createProjects
+	return s.writeEntities(ctx, state, entityProject, metrics.OperationCreate, len(createProjects),
+		func(i int) string { return createProjects[i].Identity },
+		func(ctx context.Context, i int) error { return state.targetApp.CreateProject(ctx, createProjects[i]) })
 }
 
-func (s *SyncLogicService) syncProjects(targetApp target.TargetApp) error {
-	err := (error)(nil)
-	err = s.syncCreateProject(targetApp)
-	if err != nil {
-		return err
-	}
-	err = targetApp.GroupBindingProjects(s.ctx, s.idpProjects)
-	if err != nil {
-		return err
-	}
-	err = s.syncUpdateProject(targetApp)
-	if err != nil {
+// groupBindingProjects binds the roles of the projects to the roles of their groups after the projects are created.
+func (s *SyncLogicService) groupBindingProjects(ctx context.Context, state *targetAppState) (err error) {
+	defer s.recoverPanic(ctx, state, SyncProjectKind, &err)
+	if err := state.targetApp.GroupBindingProjects(ctx, state.idpProjects); err != nil {
+		err = fmt.Errorf("bind projects to groups fail, err:%w", err)
+		s.result.addDetail(state.identity(), NewSyncProjectFailItem(err.Error()))
 		return err
 	}
 	return nil
 }
 
-func (s *SyncLogicService) syncCreateProject(targetApp target.TargetApp) error {
-	targetIdentity := targetApp.IdentityKey()
-	createProjects := s.createTargetAppProjectsMapping[targetIdentity]
-	for _, createProject := range createProjects {
-		if devUser := os.Getenv("DEV_PROJECTNAME_PREFIX"); len(devUser) > 0 && !strings.Contains(createProject.Name, devUser) {
-			log.Loger.Debugf("debug project_name :%v", devUser)
-			continue
-		}
-		err := targetApp.CreateProject(s.ctx, createProject)
-		if err != nil {
-			log.Loger.WithFields(logrus.Fields{
-				"targetapp_kind": targetApp.Kind(),
-				"targetapp_name": targetApp.GetName(),
-			}).Errorf("create project fail, err:%v", err)
-			s.result.addDetail(targetIdentity, NewSyncProjectFailItem(err.Error()))
-			return err
-		}
-	}
-	return nil
+func (s *SyncLogicService) syncUpdateProject(ctx context.Context, state *targetAppState) error {
+	updateProjects := state.updateProjects
+	return s.writeEntities(ctx, state, entityProject, metrics.OperationUpdate, len(updateProjects),
+		func(i int) string { return updateProjects[i].Identity },
+		func(ctx context.Context, i int) error {
+			return state.targetApp.UpdateProject(ctx, updateProjects[i].Identity, updateProjects[i])
+		})
 }
 
-func (s *SyncLogicService) syncUpdateProject(targetApp target.TargetApp) error {
-	targetIdentity := targetApp.IdentityKey()
-	updateProjects := s.updateTargetAppProjectsMapping[targetIdentity]
-	for _, updateProject := range updateProjects {
-		if devUser := os.Getenv("DEV_PROJECTNAME_PREFIX"); len(devUser) > 0 && !strings.Contains(updateProject.Name, devUser) {
-			log.Loger.Debugf("debug project_name :%v", devUser)
-			continue
-		}
-		err := targetApp.UpdateProject(s.ctx, updateProject.Identity, updateProject)
-		if err != nil {
-			log.Loger.WithFields(logrus.Fields{
-				"targetapp_kind": targetApp.Kind(),
-				"targetapp_name": targetApp.GetName(),
-			}).Errorf("update project fail, err:%v", err)
-			s.result.addDetail(targetIdentity, NewSyncProjectFailItem(err.Error()))
-			return err
-		}
-	}
-	return nil
+// targetContext returns the context whose logger has the keys of targetApp
+func (s *SyncLogicService) targetContext(targetApp target.TargetApp) context.Context {
+	return log.WithValues(s.ctx, log.KeyTargetKind, targetApp.Kind(), log.KeyTargetName, targetApp.GetName())
+}
+
+// startPhase starts the span of a sync phase, target is empty if the phase is not bound to a target app.
+func startPhase(ctx context.Context, phase, target string) (context.Context, trace.Span) {
+	return tracing.Start(ctx, "SyncLogicService."+phase, tracing.AttrSyncPhase.String(phase), tracing.AttrSyncTarget.String(target))
+}
+
+// targetLabel returns the target app name used in metrics
+func targetLabel(targetApp target.TargetApp) string {
+	return fmt.Sprintf("%s/%s", targetApp.Kind(), targetApp.GetName())
 }
@@ -15,12 +15,16 @@
 package services
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"time"
 
 	"github.com/golang/mock/gomock"
 	"github.com/nautes-labs/base-operator/pkg/idp"
 	"github.com/nautes-labs/base-operator/pkg/schema"
 	"github.com/nautes-labs/base-operator/pkg/secret_provider"
+	"github.com/nautes-labs/base-operator/pkg/store"
 	"github.com/nautes-labs/base-operator/pkg/target"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
@@ -37,6 +41,7 @@ var _ = Describe("Sync base data", func() {
 		targetAppname         string
 		targetAppApiServerUrl string
 		targetAppMock         *target.MockTargetApp
+		state                 *targetAppState
 		idpUsers              []*schema.User
 		idpGroups             []*schema.Group
 		idpProjects           []*schema.Project
@@ -75,6 +80,7 @@ var _ = Describe("Sync base data", func() {
 		targetAppMock.EXPECT().SetSecretProvider(secretProvider).AnyTimes()
 		targetAppMock.EXPECT().SetIdp(idpMock).AnyTimes()
 		svc.InjectTargetApps(targetAppMock)
+		state = newTargetAppState(targetAppMock, 0)
 		idpUsers = []*schema.User{
 			{BaseEntity: schema.BaseEntity{Identity: "100"}},
 		}
@@ -97,28 +103,28 @@ var _ = Describe("Sync base data", func() {
 	Context("Users", func() {
 		It("Failed to get targetapp user", func() {
 			targetAppMock.EXPECT().GetUsers(gomock.Any()).Return(nil, errors.New("timeout"))
-			err = svc.readTargetAppUsers(targetAppMock)
+			err = svc.readTargetAppUsers(ctx, state)
 			Expect(err).Should(HaveOccurred())
 		})
 		It("Failed to get idp user", func() {
 			idpMock.EXPECT().GetUsers(gomock.Any()).Return(nil, errors.New("timeout"))
-			err = svc.readIdpUsers()
+			err = svc.readIdpUsers(ctx)
 			Expect(err).Should(HaveOccurred())
 		})
 		It("Get idp user successfully, failed to get targetapp user", func() {
 			idpMock.EXPECT().GetUsers(gomock.Any()).Return(idpUsers, nil)
 			targetAppMock.EXPECT().GetUsers(gomock.Any()).Return(nil, errors.New("timeout"))
-			err = svc.readIdpUsers()
+			err = svc.readIdpUsers(ctx)
 			Expect(err).Should(BeNil())
-			err = svc.readTargetAppUsers(targetAppMock)
+			err = svc.readTargetAppUsers(ctx, state)
 			Expect(err).Should(HaveOccurred())
 		})
 		It("Failed to get idp user, get targetapp user successfully", func() {
 			idpMock.EXPECT().GetUsers(gomock.Any()).Return(nil, errors.New("timeout"))
 			targetAppMock.EXPECT().GetUsers(gomock.Any()).Return(targetUsers, nil)
-			err = svc.readIdpUsers()
+			err = svc.readIdpUsers(ctx)
 			Expect(err).Should(HaveOccurred())
-			err = svc.readTargetAppUsers(targetAppMock)
+			err = svc.readTargetAppUsers(ctx, state)
 			Expect(err).Should(BeNil())
 		})
 		It("Failed to creating new user", func() {
@@ -133,14 +139,14 @@ var _ = Describe("Sync base data", func() {
 			}
 			idpMock.EXPECT().GetUsers(gomock.Any()).Return(idpUsers, nil)
 			targetAppMock.EXPECT().GetUsers(gomock.Any()).Return(targetUsers, nil)
-			err = svc.readIdpUsers()
+			err = svc.readIdpUsers(ctx)
 			Expect(err).Should(BeNil())
-			err = svc.readTargetAppUsers(targetAppMock)
+			err = svc.readTargetAppUsers(ctx, state)
 			Expect(err).Should(BeNil())
-			targetAppMock.EXPECT().CompareUsers(gomock.Any(), gomock.Any()).Return(createUsers, nil)
-			svc.userDataHandle()
+			targetAppMock.EXPECT().CompareUsers(gomock.Any(), gomock.Any(), gomock.Any()).Return(createUsers, nil)
+			svc.userDataHandle(ctx, state)
 			targetAppMock.EXPECT().CreateUser(gomock.Any(), gomock.Any()).Return(errors.New("timeout"))
-			err = svc.syncCreateUser(targetAppMock)
+			err = svc.syncCreateUser(ctx, state)
 			Expect(err).Should(HaveOccurred())
 		})
 		It("Creating new user successfully", func() {
@@ -155,14 +161,14 @@ var _ = Describe("Sync base data", func() {
 			}
 			idpMock.EXPECT().GetUsers(gomock.Any()).Return(idpUsers, nil)
 			targetAppMock.EXPECT().GetUsers(gomock.Any()).Return(targetUsers, nil)
-			err = svc.readIdpUsers()
+			err = svc.readIdpUsers(ctx)
 			Expect(err).Should(BeNil())
-			err = svc.readTargetAppUsers(targetAppMock)
+			err = svc.readTargetAppUsers(ctx, state)
 			Expect(err).Should(BeNil())
-			targetAppMock.EXPECT().CompareUsers(gomock.Any(), gomock.Any()).Return(createUsers, nil)
-			svc.userDataHandle()
+			targetAppMock.EXPECT().CompareUsers(gomock.Any(), gomock.Any(), gomock.Any()).Return(createUsers, nil)
+			svc.userDataHandle(ctx, state)
 			targetAppMock.EXPECT().CreateUser(gomock.Any(), gomock.Any()).Return(nil)
-			err = svc.syncCreateUser(targetAppMock)
+			err = svc.syncCreateUser(ctx, state)
 			Expect(err).Should(BeNil())
 		})
 		It("Failed to update user", func() {
@@ -177,14 +183,14 @@ var _ = Describe("Sync base data", func() {
 			}
 			idpMock.EXPECT().GetUsers(gomock.Any()).Return(idpUsers, nil)
 			targetAppMock.EXPECT().GetUsers(gomock.Any()).Return(targetUsers, nil)
-			err = svc.readIdpUsers()
+			err = svc.readIdpUsers(ctx)
 			Expect(err).Should(BeNil())
-			err = svc.readTargetAppUsers(targetAppMock)
+			err = svc.readTargetAppUsers(ctx, state)
 			Expect(err).Should(BeNil())
-			targetAppMock.EXPECT().CompareUsers(gomock.Any(), gomock.Any()).Return(nil, updateUsers)
-			svc.userDataHandle()
+			targetAppMock.EXPECT().CompareUsers(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, updateUsers)
+			svc.userDataHandle(ctx, state)
 			targetAppMock.EXPECT().UpdateUser(gomock.Any(), gomock.Any(), gomock.Any()).Return(errors.New("timeout"))
-			err = svc.syncUpdateUser(targetAppMock)
+			err = svc.syncUpdateUser(ctx, state)
 			Expect(err).Should(HaveOccurred())
 		})
 		It("Update user successfully", func() {
@@ -199,42 +205,42 @@ var _ = Describe("Sync base data", func() {
 			}
 			idpMock.EXPECT().GetUsers(gomock.Any()).Return(idpUsers, nil)
 			targetAppMock.EXPECT().GetUsers(gomock.Any()).Return(targetUsers, nil)
-			err = svc.readIdpUsers()
+			err = svc.readIdpUsers(ctx)
 			Expect(err).Should(BeNil())
-			err = svc.readTargetAppUsers(targetAppMock)
+			err = svc.readTargetAppUsers(ctx, state)
 			Expect(err).Should(BeNil())
-			targetAppMock.EXPECT().CompareUsers(gomock.Any(), gomock.Any()).Return(nil, updateUsers)
-			svc.userDataHandle()
+			targetAppMock.EXPECT().CompareUsers(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, updateUsers)
+			svc.userDataHandle(ctx, state)
 			targetAppMock.EXPECT().UpdateUser(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil)
-			err = svc.syncUpdateUser(targetAppMock)
+			err = svc.syncUpdateUser(ctx, state)
 			Expect(err).Should(BeNil())
 		})
 	})
 	Context("Group", func() {
 		It("Failed to get targetapp group", func() {
 			targetAppMock.EXPECT().GetGroups(gomock.Any()).Return(nil, errors.New("timeout"))
-			err = svc.readTargetAppGroups(targetAppMock)
+			err = svc.readTargetAppGroups(ctx, state)
 			Expect(err).Should(HaveOccurred())
 		})
 		It("Failed to get idp group", func() {
 			idpMock.EXPECT().GetGroups(gomock.Any()).Return(nil, errors.New("timeout"))
-			err = svc.readIdpGroups()
+			err = svc.readIdpGroups(ctx)
 			Expect(err).Should(HaveOccurred())
 		})
 		It("Get idp group successfully, failed to get targetapp group", func() {
 			idpMock.EXPECT().GetGroups(gomock.Any()).Return(idpGroups, nil)
 			targetAppMock.EXPECT().GetGroups(gomock.Any()).Return(nil, errors.New("timeout"))
-			err = svc.readIdpGroups()
+			err = svc.readIdpGroups(ctx)
 			Expect(err).Should(BeNil())
-			err = svc.readTargetAppGroups(targetAppMock)
+			err = svc.readTargetAppGroups(ctx, state)
 			Expect(err).Should(HaveOccurred())
 		})
 		It("Failed to get idp group, get targetapp group successfully", func() {
 			idpMock.EXPECT().GetGroups(gomock.Any()).Return(nil, errors.New("timeout"))
 			targetAppMock.EXPECT().GetGroups(gomock.Any()).Return(targetGroups, nil)
-			err = svc.readIdpGroups()
+			err = svc.readIdpGroups(ctx)
 			Expect(err).Should(HaveOccurred())
-			err = svc.readTargetAppGroups(targetAppMock)
+			err = svc.readTargetAppGroups(ctx, state)
 			Expect(err).Should(BeNil())
 		})
 		It("Failed to creating new group", func() {
@@ -249,14 +255,14 @@ var _ = Describe("Sync base data", func() {
 			}
 			idpMock.EXPECT().GetGroups(gomock.Any()).Return(idpGroups, nil)
 			targetAppMock.EXPECT().GetGroups(gomock.Any()).Return(targetGroups, nil)
-			err = svc.readIdpGroups()
+			err = svc.readIdpGroups(ctx)
 			Expect(err).Should(BeNil())
-			err = svc.readTargetAppGroups(targetAppMock)
+			err = svc.readTargetAppGroups(ctx, state)
 			Expect(err).Should(BeNil())
-			targetAppMock.EXPECT().CompareGroups(gomock.Any(), gomock.Any()).Return(createGroups, nil)
-			svc.groupDataHandle()
+			targetAppMock.EXPECT().CompareGroups(gomock.Any(), gomock.Any(), gomock.Any()).Return(createGroups, nil)
+			svc.groupDataHandle(ctx, state)
 			targetAppMock.EXPECT().CreateGroup(gomock.Any(), gomock.Any()).Return(errors.New("timeout"))
-			err = svc.syncCreateGroup(targetAppMock)
+			err = svc.syncCreateGroup(ctx, state)
 			Expect(err).Should(HaveOccurred())
 		})
 		It("Creating new group successfully", func() {
@@ -271,14 +277,14 @@ var _ = Describe("Sync base data", func() {
 			}
 			idpMock.EXPECT().GetGroups(gomock.Any()).Return(idpGroups, nil)
 			targetAppMock.EXPECT().GetGroups(gomock.Any()).Return(targetGroups, nil)
-			err = svc.readIdpGroups()
+			err = svc.readIdpGroups(ctx)
 			Expect(err).Should(BeNil())
-			err = svc.readTargetAppGroups(targetAppMock)
+			err = svc.readTargetAppGroups(ctx, state)
 			Expect(err).Should(BeNil())
-			targetAppMock.EXPECT().CompareGroups(gomock.Any(), gomock.Any()).Return(createGroups, nil)
-			svc.groupDataHandle()
+			targetAppMock.EXPECT().CompareGroups(gomock.Any(), gomock.Any(), gomock.Any()).Return(createGroups, nil)
+			svc.groupDataHandle(ctx, state)
 			targetAppMock.EXPECT().CreateGroup(gomock.Any(), gomock.Any()).Return(nil)
-			err = svc.syncCreateGroup(targetAppMock)
+			err = svc.syncCreateGroup(ctx, state)
 			Expect(err).Should(BeNil())
 		})
 		It("Failed to update group", func() {
@@ -293,14 +299,14 @@ var _ = Describe("Sync base data", func() {
 			}
 			idpMock.EXPECT().GetGroups(gomock.Any()).Return(idpGroups, nil)
 			targetAppMock.EXPECT().GetGroups(gomock.Any()).Return(targetGroups, nil)
-			err = svc.readIdpGroups()
+			err = svc.readIdpGroups(ctx)
 			Expect(err).Should(BeNil())
-			err = svc.readTargetAppGroups(targetAppMock)
+			err = svc.readTargetAppGroups(ctx, state)
 			Expect(err).Should(BeNil())
-			targetAppMock.EXPECT().CompareGroups(gomock.Any(), gomock.Any()).Return(nil, updateGroups)
-			svc.groupDataHandle()
+			targetAppMock.EXPECT().CompareGroups(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, updateGroups)
+			svc.groupDataHandle(ctx, state)
 			targetAppMock.EXPECT().UpdateGroup(gomock.Any(), gomock.Any(), gomock.Any()).Return(errors.New("timeout"))
-			err = svc.syncUpdateGroup(targetAppMock)
+			err = svc.syncUpdateGroup(ctx, state)
 			Expect(err).Should(HaveOccurred())
 		})
 		It("Update group successfully", func() {
@@ -315,42 +321,42 @@ var _ = Describe("Sync base data", func() {
 			}
 			idpMock.EXPECT().GetGroups(gomock.Any()).Return(idpGroups, nil)
 			targetAppMock.EXPECT().GetGroups(gomock.Any()).Return(targetGroups, nil)
-			err = svc.readIdpGroups()
+			err = svc.readIdpGroups(ctx)
 			Expect(err).Should(BeNil())
-			err = svc.readTargetAppGroups(targetAppMock)
+			err = svc.readTargetAppGroups(ctx, state)
 			Expect(err).Should(BeNil())
-			targetAppMock.EXPECT().CompareGroups(gomock.Any(), gomock.Any()).Return(nil, updateGroups)
-			svc.groupDataHandle()
+			targetAppMock.EXPECT().CompareGroups(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, updateGroups)
+			svc.groupDataHandle(ctx, state)
 			targetAppMock.EXPECT().UpdateGroup(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil)
-			err = svc.syncUpdateGroup(targetAppMock)
+			err = svc.syncUpdateGroup(ctx, state)
 			Expect(err).Should(BeNil())
 		})
 	})
 	Context("Project", func() {
 		It("Failed to get targetapp project", func() {
 			targetAppMock.EXPECT().GetProjects(gomock.Any()).Return(nil, errors.New("timeout"))
-			err = svc.readTargetAppProjects(targetAppMock)
+			err = svc.readTargetAppProjects(ctx, state)
 			Expect(err).Should(HaveOccurred())
 		})
 		It("Failed to get idp project", func() {
 			idpMock.EXPECT().GetProjects(gomock.Any()).Return(nil, errors.New("timeout"))
-			err = svc.readIdpProjects()
+			err = svc.readIdpProjects(ctx)
 			Expect(err).Should(HaveOccurred())
 		})
 		It("Get idp project successfully, failed to get targetapp project", func() {
 			idpMock.EXPECT().GetProjects(gomock.Any()).Return(idpProjects, nil)
 			targetAppMock.EXPECT().GetProjects(gomock.Any()).Return(nil, errors.New("timeout"))
-			err = svc.readIdpProjects()
+			err = svc.readIdpProjects(ctx)
 			Expect(err).Should(BeNil())
-			err = svc.readTargetAppProjects(targetAppMock)
+			err = svc.readTargetAppProjects(ctx, state)
 			Expect(err).Should(HaveOccurred())
 		})
 		It("Failed to get idp project, get targetapp project successfully", func() {
 			idpMock.EXPECT().GetProjects(gomock.Any()).Return(nil, errors.New("timeout"))
 			targetAppMock.EXPECT().GetProjects(gomock.Any()).Return(targetProjects, nil)
-			err = svc.readIdpProjects()
+			err = svc.readIdpProjects(ctx)
 			Expect(err).Should(HaveOccurred())
-			err = svc.readTargetAppProjects(targetAppMock)
+			err = svc.readTargetAppProjects(ctx, state)
 			Expect(err).Should(BeNil())
 		})
 		It("Failed to creating new project", func() {
@@ -365,14 +371,14 @@ var _ = Describe("Sync base data", func() {
 			}
 			idpMock.EXPECT().GetProjects(gomock.Any()).Return(idpProjects, nil)
 			targetAppMock.EXPECT().GetProjects(gomock.Any()).Return(targetProjects, nil)
-			err = svc.readIdpProjects()
+			err = svc.readIdpProjects(ctx)
 			Expect(err).Should(BeNil())
-			err = svc.readTargetAppProjects(targetAppMock)
+			err = svc.readTargetAppProjects(ctx, state)
 			Expect(err).Should(BeNil())
-			targetAppMock.EXPECT().CompareProjects(gomock.Any(), gomock.Any()).Return(createProjects, nil)
-			svc.projectDataHandle()
+			targetAppMock.EXPECT().CompareProjects(gomock.Any(), gomock.Any(), gomock.Any()).Return(createProjects, nil)
+			svc.projectDataHandle(ctx, state)
 			targetAppMock.EXPECT().CreateProject(gomock.Any(), gomock.Any()).Return(errors.New("timeout"))
-			err = svc.syncCreateProject(targetAppMock)
+			err = svc.syncCreateProject(ctx, state)
 			Expect(err).Should(HaveOccurred())
 		})
 		It("Creating new project successfully", func() {
@@ -387,14 +393,14 @@ var _ = Describe("Sync base data", func() {
 			}
 			idpMock.EXPECT().GetProjects(gomock.Any()).Return(idpProjects, nil)
 			targetAppMock.EXPECT().GetProjects(gomock.Any()).Return(targetProjects, nil)
-			err = svc.readIdpProjects()
+			err = svc.readIdpProjects(ctx)
 			Expect(err).Should(BeNil())
-			err = svc.readTargetAppProjects(targetAppMock)
+			err = svc.readTargetAppProjects(ctx, state)
 			Expect(err).Should(BeNil())
-			targetAppMock.EXPECT().CompareProjects(gomock.Any(), gomock.Any()).Return(createProjects, nil)
-			svc.projectDataHandle()
+			targetAppMock.EXPECT().CompareProjects(gomock.Any(), gomock.Any(), gomock.Any()).Return(createProjects, nil)
+			svc.projectDataHandle(ctx, state)
 			targetAppMock.EXPECT().CreateProject(gomock.Any(), gomock.Any()).Return(nil)
-			err = svc.syncCreateProject(targetAppMock)
+			err = svc.syncCreateProject(ctx, state)
 			Expect(err).Should(BeNil())
 		})
 		It("Failed to update project", func() {
@@ -409,14 +415,14 @@ var _ = Describe("Sync base data", func() {
 			}
 			idpMock.EXPECT().GetProjects(gomock.Any()).Return(idpProjects, nil)
 			targetAppMock.EXPECT().GetProjects(gomock.Any()).Return(targetProjects, nil)
-			err = svc.readIdpProjects()
+			err = svc.readIdpProjects(ctx)
 			Expect(err).Should(BeNil())
-			err = svc.readTargetAppProjects(targetAppMock)
+			err = svc.readTargetAppProjects(ctx, state)
 			Expect(err).Should(BeNil())
-			targetAppMock.EXPECT().CompareProjects(gomock.Any(), gomock.Any()).Return(nil, updateProjects)
-			svc.projectDataHandle()
+			targetAppMock.EXPECT().CompareProjects(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, updateProjects)
+			svc.projectDataHandle(ctx, state)
 			targetAppMock.EXPECT().UpdateProject(gomock.Any(), gomock.Any(), gomock.Any()).Return(errors.New("timeout"))
-			err = svc.syncUpdateProject(targetAppMock)
+			err = svc.syncUpdateProject(ctx, state)
 			Expect(err).Should(HaveOccurred())
 		})
 		It("Update project successfully", func() {
@@ -431,17 +437,528 @@ var _ = Describe("Sync base data", func() {
 			}
 			idpMock.EXPECT().GetProjects(gomock.Any()).Return(idpProjects, nil)
 			targetAppMock.EXPECT().GetProjects(gomock.Any()).Return(targetProjects, nil)
-			err = svc.readIdpProjects()
+			err = svc.readIdpProjects(ctx)
 			Expect(err).Should(BeNil())
-			err = svc.readTargetAppProjects(targetAppMock)
+			err = svc.readTargetAppProjects(ctx, state)
 			Expect(err).Should(BeNil())
-			targetAppMock.EXPECT().CompareProjects(gomock.Any(), gomock.Any()).Return(nil, updateProjects)
-			svc.projectDataHandle()
+			targetAppMock.EXPECT().CompareProjects(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, updateProjects)
+			svc.projectDataHandle(ctx, state)
 			targetAppMock.EXPECT().UpdateProject(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil)
-			err = svc.syncUpdateProject(targetAppMock)
+			err = svc.syncUpdateProject(ctx, state)
 			Expect(err).Should(BeNil())
 		})
 	})
+	Context("User filter", func() {
+		It("Excludes the bots and external users with their memberships", func() {
+			idpUsers = []*schema.User{
+				{BaseEntity: schema.BaseEntity{Identity: "1"}, State: schema.UserStateActive},
+				{BaseEntity: schema.BaseEntity{Identity: "2"}, State: schema.UserStateBot},
+				{BaseEntity: schema.BaseEntity{Identity: "3"}, State: schema.UserStateBlocked, External: true},
+				{BaseEntity: schema.BaseEntity{Identity: "4"}, State: schema.UserStateDeactivated},
+			}
+			groupMembers := []*schema.GroupMember{
+				{Id: "1-10", GroupId: "10", UserId: "1"},
+				{Id: "2-10", GroupId: "10", UserId: "2"},
+				{Id: "3-10", GroupId: "10", UserId: "3"},
+			}
+			svc.SetUserFilter(UserFilter{ExcludeBots: true, ExcludeExternal: true})
+			idpMock.EXPECT().GetUsers(gomock.Any()).Return(idpUsers, nil)
+			idpMock.EXPECT().GetAllGroupMembers(gomock.Any(), gomock.Any(), gomock.Any()).Return(groupMembers, nil)
+			Expect(svc.readIdpUsers(ctx)).Should(Succeed())
+			Expect(svc.readIdpGroupMembers(ctx)).Should(Succeed())
+			Expect(svc.idpUsers).Should(Equal([]*schema.User{idpUsers[0], idpUsers[3]}))
+			Expect(svc.idpGroupMembers).Should(Equal(groupMembers[:1]))
+		})
+		It("Excludes the users who are not members of the groups", func() {
+			idpUsers = []*schema.User{
+				{BaseEntity: schema.BaseEntity{Identity: "1"}, State: schema.UserStateActive},
+				{BaseEntity: schema.BaseEntity{Identity: "2"}, State: schema.UserStateActive},
+			}
+			groupMembers := []*schema.GroupMember{
+				{Id: "2-10", GroupId: "10", UserId: "2"},
+			}
+			svc.SetUserFilter(UserFilter{MembersOnly: true})
+			idpMock.EXPECT().GetUsers(gomock.Any()).Return(idpUsers, nil)
+			idpMock.EXPECT().GetAllGroupMembers(gomock.Any(), gomock.Any(), gomock.Any()).Return(groupMembers, nil)
+			Expect(svc.readIdpUsers(ctx)).Should(Succeed())
+			Expect(svc.readIdpGroupMembers(ctx)).Should(Succeed())
+			Expect(svc.idpUsers).Should(Equal(idpUsers[1:]))
+			Expect(svc.idpGroupMembers).Should(Equal(groupMembers))
+		})
+		It("Expands the members of the parent groups if the membership is flattened", func() {
+			svc.idpGroups = []*schema.Group{
+				{BaseEntity: schema.BaseEntity{Identity: "10"}, ChildIds: []string{"11"}},
+				{BaseEntity: schema.BaseEntity{Identity: "11"}, ParentId: "10"},
+			}
+			groupMembers := []*schema.GroupMember{
+				{GroupId: "10", UserId: "1"},
+				{GroupId: "11", UserId: "2"},
+			}
+			svc.SetMembership(schema.MembershipFlattened)
+			idpMock.EXPECT().GetAllGroupMembers(gomock.Any(), gomock.Any(), gomock.Any()).Return(groupMembers, nil)
+			Expect(svc.readIdpGroupMembers(ctx)).Should(Succeed())
+			Expect(svc.idpGroupMembers).Should(Equal([]*schema.GroupMember{
+				{GroupId: "10", UserId: "1"},
+				{GroupId: "11", UserId: "1"},
+				{GroupId: "11", UserId: "2"},
+			}))
+		})
+	})
+	Context("Filters", func() {
+		It("Selects the entities by their names and ids", func() {
+			idpUsers = []*schema.User{
+				{BaseEntity: schema.BaseEntity{Identity: "1"}, Username: "dev-alice"},
+				{BaseEntity: schema.BaseEntity{Identity: "2"}, Username: "bob"},
+				{BaseEntity: schema.BaseEntity{Identity: "3"}, Username: "dev-carol"},
+			}
+			idpGroups = []*schema.Group{
+				{BaseEntity: schema.BaseEntity{Identity: "10", Name: "team-a"}},
+				{BaseEntity: schema.BaseEntity{Identity: "11", Name: "team-b"}},
+			}
+			idpProjects = []*schema.Project{
+				{BaseEntity: schema.BaseEntity{Identity: "20", Name: "api"}},
+				{BaseEntity: schema.BaseEntity{Identity: "21", Name: "web"}},
+			}
+			groupMembers := []*schema.GroupMember{
+				{GroupId: "10", UserId: "1"},
+				{GroupId: "11", UserId: "1"},
+				{GroupId: "10", UserId: "2"},
+			}
+			users, err := NewEntityFilter("^dev-", nil, []string{"3"})
+			Expect(err).ShouldNot(HaveOccurred())
+			svc.SetFilters(Filters{
+				Users:    users,
+				Groups:   EntityFilter{ExcludeIds: []string{"11"}},
+				Projects: EntityFilter{IncludeIds: []string{"21"}},
+			})
+			idpMock.EXPECT().GetUsers(gomock.Any()).Return(idpUsers, nil)
+			idpMock.EXPECT().GetGroups(gomock.Any()).Return(idpGroups, nil)
+			idpMock.EXPECT().GetProjects(gomock.Any()).Return(idpProjects, nil)
+			idpMock.EXPECT().GetAllGroupMembers(gomock.Any(), []*schema.Group{idpGroups[0]}, gomock.Any()).Return(groupMembers, nil)
+			Expect(svc.readIdpUsers(ctx)).Should(Succeed())
+			Expect(svc.readIdpGroups(ctx)).Should(Succeed())
+			Expect(svc.readIdpProjects(ctx)).Should(Succeed())
+			Expect(svc.readIdpGroupMembers(ctx)).Should(Succeed())
+			Expect(svc.idpUsers).Should(Equal(idpUsers[:1]))
+			Expect(svc.idpGroups).Should(Equal(idpGroups[:1]))
+			Expect(svc.idpProjects).Should(Equal(idpProjects[1:]))
+			Expect(svc.idpGroupMembers).Should(Equal(groupMembers[:1]))
+		})
+		It("Drops the excluded subgroups from the children of their parents", func() {
+			idpGroups = []*schema.Group{
+				{BaseEntity: schema.BaseEntity{Identity: "10", Name: "team"}, ChildIds: []string{"11", "12"}},
+				{BaseEntity: schema.BaseEntity{Identity: "11", Name: "team-a"}, ParentId: "10"},
+				{BaseEntity: schema.BaseEntity{Identity: "12", Name: "team-b"}, ParentId: "10"},
+			}
+			svc.SetFilters(Filters{Groups: EntityFilter{ExcludeIds: []string{"12"}}})
+			idpMock.EXPECT().GetGroups(gomock.Any()).Return(idpGroups, nil)
+			Expect(svc.readIdpGroups(ctx)).Should(Succeed())
+			Expect(svc.idpGroups).Should(HaveLen(2))
+			Expect(svc.idpGroups[0].ChildIds).Should(Equal([]string{"11"}))
+			Expect(idpGroups[0].ChildIds).Should(Equal([]string{"11", "12"}))
+		})
+		It("Keeps the memberships of the excluded groups in the target app", func() {
+			svc.idpUsers = []*schema.User{{BaseEntity: schema.BaseEntity{Identity: "1"}}}
+			svc.idpGroupMembers = []*schema.GroupMember{{GroupId: "10", UserId: "1"}}
+			svc.excludedGroupIds = map[string]bool{"11": true}
+			targetGroupMembers := []*schema.GroupMember{
+				{GroupId: "gitlab-gitlab1-group-11", UserId: "gitlab-gitlab1-user-1"},
+				{GroupId: "gitlab-gitlab1-group-11", UserId: "gitlab-gitlab1-user-2"},
+				{GroupId: "gitlab-gitlab1-group-12", UserId: "gitlab-gitlab1-user-1"},
+			}
+			targetAppMock.EXPECT().GenerateIdpGroupIdentity(schema.NamespaceGroup, gomock.Any()).DoAndReturn(func(kind, id string) string {
+				return "gitlab-gitlab1-group-" + id
+			}).AnyTimes()
+			targetAppMock.EXPECT().GenerateIdpUserIdentity(gomock.Any()).DoAndReturn(func(id string) string {
+				return "gitlab-gitlab1-user-" + id
+			}).AnyTimes()
+			targetAppMock.EXPECT().GetGroupMembers(gomock.Any()).Return(targetGroupMembers, nil)
+			targetAppMock.EXPECT().SyncGroupMember(gomock.Any(), gomock.Any(), []*schema.GroupMember{
+				{GroupId: "10", UserId: "1"},
+				{GroupId: "11", UserId: "1"},
+			}, targetGroupMembers).Return(nil)
+			Expect(svc.syncGroupMember(ctx, state)).Should(Succeed())
+			Expect(svc.idpGroupMembers).Should(HaveLen(1))
+		})
+		It("Rejects the invalid name regex", func() {
+			_, err := NewEntityFilter("team-(", nil, nil)
+			Expect(err).Should(HaveOccurred())
+		})
+	})
+	Context("Mapping rules", func() {
+		It("Transforms the idp entities for the target app without changing them", func() {
+			idpUsers = []*schema.User{
+				{BaseEntity: schema.BaseEntity{Identity: "1", Name: "Alice", Description: "dev"}, Username: "alice", Email: "alice@example.com"},
+			}
+			idpGroups = []*schema.Group{
+				{BaseEntity: schema.BaseEntity{Identity: "10", Name: "Team A", Description: "team"}, FullPath: "bu1/team-a"},
+			}
+			svc.idpUsers, svc.idpGroups, svc.idpProjects = idpUsers, idpGroups, idpProjects
+			users, err := NewMappingRule(map[string]string{"name": "username"}, nil, []string{"name", "username"}, nil)
+			Expect(err).ShouldNot(HaveOccurred())
+			groups, err := NewMappingRule(nil, map[string]string{"name": `{{ .FullPath | replace "/" "-" }}-developers`}, nil, []string{"description"})
+			Expect(err).ShouldNot(HaveOccurred())
+			rules := MappingRules{Users: users, Groups: groups}
+			Expect(rules.Validate()).Should(Succeed())
+			svc.SetMappingRules(targetAppMock.IdentityKey(), rules)
+
+			svc.applyMappingRules(ctx, state)
+			Expect(state.idpUsers).Should(Equal([]*schema.User{
+				{BaseEntity: schema.BaseEntity{Identity: "1", Name: "alice"}, Username: "alice"},
+			}))
+			Expect(state.idpGroups).Should(Equal([]*schema.Group{
+				{BaseEntity: schema.BaseEntity{Identity: "10", Name: "bu1-team-a-developers"}, FullPath: "bu1/team-a"},
+			}))
+			Expect(state.idpProjects).Should(Equal(idpProjects))
+			Expect(svc.idpUsers[0].Name).Should(Equal("Alice"))
+			Expect(svc.idpGroups[0].Name).Should(Equal("Team A"))
+		})
+		It("Rejects the unknown fields", func() {
+			rule, err := NewMappingRule(map[string]string{"name": "nickname"}, nil, nil, nil)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(MappingRules{Users: rule}.Validate()).Should(MatchError(ContainSubstring(`unknown field "nickname"`)))
+			rule, err = NewMappingRule(nil, map[string]string{"title": "{{ .Name }}"}, nil, nil)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(MappingRules{Groups: rule}.Validate()).Should(HaveOccurred())
+			_, err = NewMappingRule(nil, map[string]string{"name": "{{ .Name "}, nil, nil)
+			Expect(err).Should(HaveOccurred())
+		})
+		It("Reports the entities failed to transform and does not synchronize them", func() {
+			svc.idpProjects = []*schema.Project{
+				{BaseEntity: schema.BaseEntity{Identity: "20", Name: "ab"}},
+				{BaseEntity: schema.BaseEntity{Identity: "21", Name: "abcd"}},
+			}
+			projects, err := NewMappingRule(nil, map[string]string{"name": "{{ slice .Name 3 }}"}, nil, nil)
+			Expect(err).ShouldNot(HaveOccurred())
+			svc.SetMappingRules(targetAppMock.IdentityKey(), MappingRules{Projects: projects})
+
+			svc.applyMappingRules(ctx, state)
+			Expect(state.idpProjects).Should(Equal([]*schema.Project{
+				{BaseEntity: schema.BaseEntity{Identity: "21", Name: "d"}},
+			}))
+			items := svc.GetResult().Detail[targetAppMock.IdentityKey()]
+			Expect(items).Should(HaveLen(1))
+			Expect(items[0].Type).Should(Equal(SyncProjectKind))
+			Expect(items[0].Identity).Should(Equal("20"))
+		})
+	})
+	Context("Merged sources", func() {
+		var (
+			ldapMock   *idp.MockIdp
+			ldapTarget *target.MockTargetApp
+			ldapSvc    *SyncLogicService
+			ldapState  *targetAppState
+		)
+		BeforeEach(func() {
+			ldapMock = idp.NewMockIdp(ctl)
+			ldapMock.EXPECT().Kind().Return(idp.IdpKind("ldap")).AnyTimes()
+			ldapMock.EXPECT().GetName().Return("ldap1").AnyTimes()
+			ldapTarget = target.NewMockTargetApp(ctl)
+			ldapTarget.EXPECT().Kind().Return(target.NexusAppKind).AnyTimes()
+			ldapTarget.EXPECT().GetName().Return(targetAppname).AnyTimes()
+			ldapTarget.EXPECT().IdentityKey().Return(targetAppMock.IdentityKey()).AnyTimes()
+			ldapSvc = NewSyncLogicService(ctx).InjectIdp(ldapMock).InjectTargetApps(ldapTarget)
+			ldapState = newTargetAppState(ldapTarget, 0)
+			targetAppMock.EXPECT().GenerateIdpUserIdentity(gomock.Any()).DoAndReturn(func(id string) string { return "gitlab.gitlab1." + id }).AnyTimes()
+		})
+		It("Synchronizes the same person from the source of the highest priority and keeps the memberships of the others", func() {
+			NewMergedSyncService(MergePolicy{MatchBy: []string{MatchByEmail, MatchByUsername}}, svc, ldapSvc)
+			svc.idpUsers = []*schema.User{
+				{BaseEntity: schema.BaseEntity{Identity: "1"}, Username: "alice", Email: "alice@example.com"},
+				{BaseEntity: schema.BaseEntity{Identity: "2"}, Username: "bob"},
+			}
+			ldapSvc.idpUsers = []*schema.User{
+				{BaseEntity: schema.BaseEntity{Identity: "uid=a"}, Username: "a", Email: "Alice@example.com"},
+				{BaseEntity: schema.BaseEntity{Identity: "uid=bob"}, Username: "bob"},
+				{BaseEntity: schema.BaseEntity{Identity: "uid=carol"}, Username: "carol", Email: "carol@example.com"},
+			}
+			ldapSvc.idpGroupMembers = []*schema.GroupMember{
+				{GroupId: "cn=dev", UserId: "uid=a"},
+				{GroupId: "cn=dev", UserId: "uid=carol"},
+			}
+			svc.resolveUserCollisions(ctx)
+			ldapSvc.resolveUserCollisions(ctx)
+			Expect(svc.idpUsers).Should(HaveLen(2))
+			Expect(svc.usersClaimed).Should(BeTrue())
+			Expect(ldapSvc.idpUsers).Should(Equal([]*schema.User{
+				{BaseEntity: schema.BaseEntity{Identity: "uid=carol"}, Username: "carol", Email: "carol@example.com"},
+			}))
+			Expect(ldapSvc.withForeignUsers(ldapState, ldapSvc.idpGroupMembers)).Should(Equal([]*schema.GroupMember{
+				{GroupId: "cn=dev", UserId: "uid=a", TargetUserId: "gitlab.gitlab1.1"},
+				{GroupId: "cn=dev", UserId: "uid=carol"},
+			}))
+			Expect(ldapSvc.idpGroupMembers[0].TargetUserId).Should(BeEmpty())
+		})
+		It("Does not match the users without a merge policy", func() {
+			NewMergedSyncService(MergePolicy{}, svc, ldapSvc)
+			svc.idpUsers = []*schema.User{{BaseEntity: schema.BaseEntity{Identity: "1"}, Email: "alice@example.com"}}
+			ldapSvc.idpUsers = []*schema.User{{BaseEntity: schema.BaseEntity{Identity: "uid=a"}, Email: "alice@example.com"}}
+			svc.resolveUserCollisions(ctx)
+			ldapSvc.resolveUserCollisions(ctx)
+			Expect(ldapSvc.idpUsers).Should(HaveLen(1))
+		})
+		It("Stops at the source whose users are not read", func() {
+			idpMock.EXPECT().GetUsers(gomock.Any()).Return(nil, errors.New("unauthorized"))
+			idpMock.EXPECT().GetGroups(gomock.Any()).Return(idpGroups, nil).AnyTimes()
+			idpMock.EXPECT().GetProjects(gomock.Any()).Return(idpProjects, nil).AnyTimes()
+			targetAppMock.EXPECT().GetUsers(gomock.Any()).Return(targetUsers, nil).AnyTimes()
+			targetAppMock.EXPECT().GetGroups(gomock.Any()).Return(targetGroups, nil).AnyTimes()
+			targetAppMock.EXPECT().GetProjects(gomock.Any()).Return(targetProjects, nil).AnyTimes()
+
+			merged := NewMergedSyncService(MergePolicy{MatchBy: []string{MatchByEmail}}, svc, ldapSvc)
+			err := merged.Run()
+			Expect(err).Should(MatchError(ContainSubstring("unauthorized")))
+			Expect(err.Error()).Should(ContainSubstring("sync idp gitlab/"))
+			Expect(merged.GetResults()).Should(HaveLen(2))
+		})
+		It("Rejects the unknown match fields", func() {
+			Expect(MergePolicy{MatchBy: []string{MatchByEmail, MatchByUsername}}.Validate()).Should(Succeed())
+			Expect(MergePolicy{MatchBy: []string{"phone"}}.Validate()).Should(HaveOccurred())
+		})
+	})
+	Context("Mapping store", func() {
+		var (
+			st            store.Store
+			updateUsers   []*schema.User
+			comparedUsers []*schema.User
+		)
+		BeforeEach(func() {
+			st = store.NewMemoryStore()
+			svc.InjectStore(st)
+			targetAppMock.EXPECT().GenerateIdpUserIdentity(gomock.Any()).DoAndReturn(func(id string) string { return "gitlab.gitlab1." + id }).AnyTimes()
+			targetAppMock.EXPECT().GenerateIdpGroupIdentity(gomock.Any(), gomock.Any()).DoAndReturn(func(kind, id string) string { return "gitlab.gitlab1." + kind + "." + id }).AnyTimes()
+			targetAppMock.EXPECT().GenerateIdpProjectIdentity(gomock.Any()).DoAndReturn(func(id string) string { return "gitlab.gitlab1.project." + id }).AnyTimes()
+			idpUsers = []*schema.User{
+				{BaseEntity: schema.BaseEntity{Identity: "100", Name: "zhangxh", Description: "x6666"}},
+			}
+			updateUsers = []*schema.User{
+				{BaseEntity: schema.BaseEntity{Identity: "100", Name: "zhangxh", Description: "x6666"}},
+			}
+			svc.idpUsers = idpUsers
+			state.users = targetUsers
+			comparedUsers = nil
+			targetAppMock.EXPECT().CompareUsers(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(
+				func(_ context.Context, idpUsers, _ []*schema.User) ([]*schema.User, []*schema.User) {
+					comparedUsers = idpUsers
+					return nil, updateUsers
+				})
+		})
+		seed := func(user *schema.User, syncedAt time.Time) {
+			hash, err := store.Hash(user)
+			Expect(err).Should(BeNil())
+			err = st.Save(ctx, svc.mappingScope(targetAppMock), store.NewMappings(&store.Mapping{
+				Kind:           entityUser,
+				IdpIdentity:    user.Identity,
+				TargetIdentity: "gitlab.gitlab1." + user.Identity,
+				Name:           user.Name,
+				Hash:           hash,
+				SyncedAt:       syncedAt,
+			}))
+			Expect(err).Should(BeNil())
+		}
+		compare := func() {
+			svc.applyMappingRules(ctx, state)
+			svc.loadMappings(ctx, state)
+			svc.planSync(ctx, state)
+			svc.userDataHandle(ctx, state)
+		}
+
+		It("Records the synchronized entities", func() {
+			compare()
+			Expect(state.updateUsers).Should(Equal(updateUsers))
+			svc.saveMappings(ctx, state)
+			mappings, err := st.Load(ctx, svc.mappingScope(targetAppMock))
+			Expect(err).Should(BeNil())
+			mapping, ok := mappings.Get(entityUser, "100")
+			Expect(ok).Should(BeTrue())
+			Expect(mapping.TargetIdentity).Should(Equal("gitlab.gitlab1.100"))
+			Expect(mapping.Name).Should(Equal("zhangxh"))
+		})
+		It("Neither reads nor compares the entity unchanged since the last sync", func() {
+			syncedAt := time.Now().Add(-time.Hour).Truncate(time.Second)
+			seed(idpUsers[0], syncedAt)
+			compare()
+			Expect(comparedUsers).Should(BeEmpty())
+			Expect(svc.readTargetAppUsers(ctx, state)).Should(Succeed())
+			svc.saveMappings(ctx, state)
+			mappings, err := st.Load(ctx, svc.mappingScope(targetAppMock))
+			Expect(err).Should(BeNil())
+			mapping, _ := mappings.Get(entityUser, "100")
+			Expect(mapping.SyncedAt.Equal(syncedAt)).Should(BeTrue())
+		})
+		It("Compares the entity changed or renamed in idp and keeps the differences found", func() {
+			seed(&schema.User{BaseEntity: schema.BaseEntity{Identity: "100", Name: "lisi", Description: "x6666"}}, time.Now())
+			compare()
+			Expect(comparedUsers).Should(Equal(idpUsers))
+			Expect(state.updateUsers).Should(Equal(updateUsers))
+		})
+		It("Compares all entities after the resync period", func() {
+			seed(idpUsers[0], time.Now().Add(-mappingResyncPeriod))
+			compare()
+			Expect(state.incremental).Should(BeFalse())
+			Expect(comparedUsers).Should(Equal(idpUsers))
+			Expect(state.updateUsers).Should(Equal(updateUsers))
+		})
+		It("Compares all entities in every sync if the full sync period is zero", func() {
+			svc.SetFullSyncPeriod(0)
+			seed(idpUsers[0], time.Now())
+			compare()
+			Expect(state.incremental).Should(BeFalse())
+			Expect(comparedUsers).Should(Equal(idpUsers))
+		})
+		It("Keeps the previous mapping of the entity failed to write", func() {
+			syncedAt := time.Now().Add(-mappingResyncPeriod).Truncate(time.Second)
+			seed(&schema.User{BaseEntity: schema.BaseEntity{Identity: "100", Name: "lisi"}}, syncedAt)
+			compare()
+			state.markFailed(entityUser, "100")
+			svc.saveMappings(ctx, state)
+			mappings, err := st.Load(ctx, svc.mappingScope(targetAppMock))
+			Expect(err).Should(BeNil())
+			mapping, ok := mappings.Get(entityUser, "100")
+			Expect(ok).Should(BeTrue())
+			Expect(mapping.Name).Should(Equal("lisi"))
+			Expect(mapping.SyncedAt.Equal(syncedAt)).Should(BeTrue())
+		})
+	})
+	Context("Write", func() {
+		expectSteps := func(targetApp *target.MockTargetApp, wrappingUpErr error) {
+			targetApp.EXPECT().WrappingUpAfterGroupSync(gomock.Any(), gomock.Any()).Return(wrappingUpErr)
+			targetApp.EXPECT().GroupBindingProjects(gomock.Any(), gomock.Any()).Return(nil)
+			targetApp.EXPECT().GetGroupMembers(gomock.Any()).Return(nil, nil)
+			targetApp.EXPECT().SyncGroupMember(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(nil)
+		}
+		It("Keeps writing the other entities after one fails", func() {
+			createUsers := []*schema.User{
+				{BaseEntity: schema.BaseEntity{Identity: "100"}},
+				{BaseEntity: schema.BaseEntity{Identity: "101"}},
+				{BaseEntity: schema.BaseEntity{Identity: "102"}},
+			}
+			state.createUsers = createUsers
+			state.concurrency = 2
+			targetAppMock.EXPECT().CreateUser(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, user *schema.User) error {
+				if user.Identity == "101" {
+					return errors.New("timeout")
+				}
+				return nil
+			}).Times(3)
+			expectSteps(targetAppMock, nil)
+
+			err := svc.writeTargetAppData(ctx, state)
+			Expect(err).Should(HaveOccurred())
+			Expect(err.Error()).Should(ContainSubstring("user 101: timeout"))
+			Expect(svc.GetResult().Detail[targetAppMock.IdentityKey()]).Should(Equal([]*SyncLogicResultItem{
+				NewSyncEntityFailItem(SyncUserKind, "101", "timeout"),
+				NewSyncProgressItem(SyncUserKind, "create users", 2, 3),
+				NewSyncGroupMemberSuccessItem(),
+			}))
+			Expect(svc.GetResult().Detail[targetAppMock.IdentityKey()][1].Message).Should(Equal("create users: 2/3 succeeded"))
+		})
+		It("Syncs the group members of each target app only once and isolates the failures of target apps", func() {
+			otherTargetApp := target.NewMockTargetApp(ctl)
+			otherTargetApp.EXPECT().Kind().Return(target.NexusAppKind).AnyTimes()
+			otherTargetApp.EXPECT().GetName().Return("nexus2").AnyTimes()
+			otherTargetApp.EXPECT().IdentityKey().Return(target.TargetAppKindName{
+				Kind: string(target.NexusAppKind),
+				Name: "nexus2",
+			}).AnyTimes()
+			svc.InjectTargetApps(otherTargetApp)
+			svc.targetStates = []*targetAppState{state, newTargetAppState(otherTargetApp, 0)}
+			expectSteps(targetAppMock, errors.New("timeout"))
+			expectSteps(otherTargetApp, nil)
+
+			err := svc.writeTargetAppsData()
+			Expect(err).Should(HaveOccurred())
+			Expect(err.Error()).Should(ContainSubstring("wrapping up groups fail"))
+			Expect(svc.GetResult().Detail[targetAppMock.IdentityKey()]).Should(HaveLen(2))
+			Expect(svc.GetResult().Detail[otherTargetApp.IdentityKey()]).Should(Equal([]*SyncLogicResultItem{NewSyncGroupMemberSuccessItem()}))
+		})
+	})
+	Context("Panics and deadlines", func() {
+		It("Converts the panics of readers into errors", func() {
+			idpMock.EXPECT().GetUsers(gomock.Any()).DoAndReturn(func(context.Context) ([]*schema.User, error) { panic("nil map") })
+			err := svc.readIdpUsers(ctx)
+			Expect(err).Should(MatchError("panic: nil map"))
+
+			targetAppMock.EXPECT().GetUsers(gomock.Any()).DoAndReturn(func(context.Context) ([]*schema.User, error) { panic("nil map") })
+			err = svc.readTargetAppUsers(ctx, state)
+			Expect(err).Should(MatchError("panic: nil map"))
+			Expect(svc.GetResult().Detail[state.identity()]).Should(Equal([]*SyncLogicResultItem{NewPanicItem(SyncUserKind, "panic: nil map")}))
+		})
+		It("Reports the entity whose write panics as failed", func() {
+			state.createUsers = []*schema.User{{BaseEntity: schema.BaseEntity{Identity: "100"}}}
+			targetAppMock.EXPECT().CreateUser(gomock.Any(), gomock.Any()).DoAndReturn(func(context.Context, *schema.User) error { panic("nil map") })
+			err := svc.syncCreateUser(ctx, state)
+			Expect(err).Should(HaveOccurred())
+			Expect(svc.GetResult().Detail[state.identity()]).Should(ContainElement(NewSyncEntityFailItem(SyncUserKind, "100", "panic: nil map")))
+		})
+		It("Stops reading a target app after the deadline", func() {
+			svc.SetPhaseTimeouts(PhaseTimeouts{ReadTarget: 50 * time.Millisecond})
+			targetAppMock.EXPECT().GetUsers(gomock.Any()).DoAndReturn(func(ctx context.Context) ([]*schema.User, error) {
+				<-ctx.Done()
+				return nil, ctx.Err()
+			})
+			targetAppMock.EXPECT().GetGroups(gomock.Any()).Return(targetGroups, nil)
+			targetAppMock.EXPECT().GetProjects(gomock.Any()).Return(targetProjects, nil)
+			err := svc.readTargetAppData(ctx, state)
+			Expect(err).Should(HaveOccurred())
+			Expect(err.Error()).Should(ContainSubstring("phase read_target timed out after 50ms"))
+		})
+		It("Stops writing a target app after the deadline", func() {
+			svc.SetPhaseTimeouts(PhaseTimeouts{Write: 50 * time.Millisecond})
+			state.createUsers = []*schema.User{{BaseEntity: schema.BaseEntity{Identity: "100"}}}
+			targetAppMock.EXPECT().CreateUser(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, _ *schema.User) error {
+				<-ctx.Done()
+				return ctx.Err()
+			})
+			// the other steps are not run
+			err := svc.writeTargetAppData(ctx, state)
+			Expect(err).Should(HaveOccurred())
+			Expect(err.Error()).Should(ContainSubstring("phase write timed out after 50ms"))
+			Expect(svc.GetResult().Detail[state.identity()]).Should(ContainElement(NewSyncEntityFailItem(SyncUserKind, "100", context.DeadlineExceeded.Error())))
+		})
+	})
+	Context("Run", func() {
+		It("Synchronizes many target apps concurrently", func() {
+			idpMock.EXPECT().GetUsers(gomock.Any()).Return(idpUsers, nil)
+			idpMock.EXPECT().GetGroups(gomock.Any()).Return(idpGroups, nil)
+			idpMock.EXPECT().GetProjects(gomock.Any()).Return(idpProjects, nil)
+			idpMock.EXPECT().GetAllGroupMembers(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, nil)
+			svc = NewSyncLogicService(ctx).InjectIdp(idpMock).InjectStore(store.NewMemoryStore())
+			for i := 0; i < 16; i++ {
+				name := fmt.Sprintf("nexus%d", i)
+				targetApp := target.NewMockTargetApp(ctl)
+				targetApp.EXPECT().Kind().Return(target.NexusAppKind).AnyTimes()
+				targetApp.EXPECT().GetName().Return(name).AnyTimes()
+				targetApp.EXPECT().IdentityKey().Return(target.TargetAppKindName{Kind: string(target.NexusAppKind), Name: name}).AnyTimes()
+				targetApp.EXPECT().GenerateIdpUserIdentity(gomock.Any()).DoAndReturn(func(id string) string { return "gitlab.gitlab1.user." + id }).AnyTimes()
+				targetApp.EXPECT().GenerateIdpGroupIdentity(gomock.Any(), gomock.Any()).DoAndReturn(func(kind, id string) string { return "gitlab.gitlab1." + kind + "." + id }).AnyTimes()
+				targetApp.EXPECT().GenerateIdpProjectIdentity(gomock.Any()).DoAndReturn(func(id string) string { return "gitlab.gitlab1.project." + id }).AnyTimes()
+				targetApp.EXPECT().GetUsers(gomock.Any()).Return(targetUsers, nil)
+				targetApp.EXPECT().GetGroups(gomock.Any()).Return(targetGroups, nil)
+				targetApp.EXPECT().GetProjects(gomock.Any()).Return(targetProjects, nil)
+				targetApp.EXPECT().CompareUsers(gomock.Any(), gomock.Any(), gomock.Any()).Return(idpUsers, nil)
+				targetApp.EXPECT().CompareGroups(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, idpGroups)
+				targetApp.EXPECT().CompareProjects(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, idpProjects)
+				targetApp.EXPECT().CreateUser(gomock.Any(), gomock.Any()).Return(nil)
+				targetApp.EXPECT().UpdateGroup(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil)
+				targetApp.EXPECT().UpdateProject(gomock.Any(), gomock.Any(), gomock.Any()).Return(errors.New("timeout"))
+				targetApp.EXPECT().WrappingUpAfterGroupSync(gomock.Any(), gomock.Any()).Return(nil)
+				targetApp.EXPECT().GroupBindingProjects(gomock.Any(), gomock.Any()).Return(nil)
+				targetApp.EXPECT().GetGroupMembers(gomock.Any()).Return(nil, nil)
+				targetApp.EXPECT().SyncGroupMember(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(nil)
+				svc.InjectTargetApps(targetApp)
+			}
+
+			err := svc.Run()
+			Expect(err).Should(HaveOccurred())
+			Expect(svc.GetResult().Brief).Should(Equal([]*SyncLogicResultItem{NewReadResourceSuccessItem()}))
+			Expect(svc.GetResult().Detail).Should(HaveLen(16))
+			for _, items := range svc.GetResult().Detail {
+				Expect(items).Should(ContainElement(NewSyncEntityFailItem(SyncProjectKind, "200", "timeout")))
+				Expect(items).Should(ContainElement(NewSyncGroupMemberSuccessItem()))
+			}
+		})
+	})
 	// Context("wrapping Up After Project", func() {
 	// 	It("Failed", func() {
 	// 		targetAppMock.EXPECT().GroupBindingProjects(gomock.Any(), gomock.Any()).Return(errors.New("timeout"))
@@ -0,0 +1,102 @@
+// Copyright 2023 Nautes Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package services
+
+import (
+	"github.com/nautes-labs/base-operator/pkg/schema"
+	"github.com/nautes-labs/base-operator/pkg/store"
+	"github.com/nautes-labs/base-operator/pkg/target"
+)
+
+// targetAppState is the snapshot of a target app in a sync and the changes to write to it.
+// Each target app has its own state which is only accessed by the goroutines of the target app,
+// the readers of the target app running at the same time write different fields.
+type targetAppState struct {
+	targetApp   target.TargetApp
+	concurrency int
+	// the idp data transformed by the mapping rules of the target app
+	idpUsers    []*schema.User
+	idpGroups   []*schema.Group
+	idpProjects []*schema.Project
+	// the data read from the target app
+	users    []*schema.User
+	groups   []*schema.Group
+	projects []*schema.Project
+	// the changes to write
+	createUsers    []*schema.User
+	updateUsers    []*schema.User
+	createGroups   []*schema.Group
+	updateGroups   []*schema.Group
+	createProjects []*schema.Project
+	updateProjects []*schema.Project
+	// the mappings recorded by the previous sync, nil if there is no store or they fail to load
+	mappings *store.Mappings
+	// whether the entities unchanged in the idp are skipped, see planSync
+	incremental bool
+	// the entities neither read nor compared in an incremental sync
+	skipped map[store.Key]bool
+	// the entities failed to write, the identity of a key is the idp identity or the target identity of the entity
+	failed map[store.Key]bool
+}
+
+// newTargetAppState returns the empty state of the target app, concurrency is the max number of entities written at the same time.
+func newTargetAppState(targetApp target.TargetApp, concurrency int) *targetAppState {
+	if concurrency <= 0 {
+		concurrency = defaultWriteConcurrency
+	}
+	return &targetAppState{
+		targetApp:   targetApp,
+		concurrency: concurrency,
+		skipped:     make(map[store.Key]bool),
+		failed:      make(map[store.Key]bool),
+	}
+}
+
+func (t *targetAppState) identity() target.TargetAppKindName {
+	return t.targetApp.IdentityKey()
+}
+
+// pendingUsers returns the idp users compared with the target app.
+func (t *targetAppState) pendingUsers() []*schema.User {
+	return pending(t, entityUser, t.idpUsers, func(user *schema.User) string { return user.Identity })
+}
+
+// pendingGroups returns the idp groups compared with the target app.
+func (t *targetAppState) pendingGroups() []*schema.Group {
+	return pending(t, entityGroup, t.idpGroups, func(group *schema.Group) string { return group.Identity })
+}
+
+// pendingProjects returns the idp projects compared with the target app.
+func (t *targetAppState) pendingProjects() []*schema.Project {
+	return pending(t, entityProject, t.idpProjects, func(project *schema.Project) string { return project.Identity })
+}
+
+// skipsRead checks whether the entities of the kind are not read from the target app, since none of them is compared.
+func (t *targetAppState) skipsRead(count int) bool {
+	return t.incremental && count == 0
+}
+
+func pending[T any](t *targetAppState, kind string, entities []T, identity func(T) string) []T {
+	if len(t.skipped) == 0 {
+		return entities
+	}
+	result := make([]T, 0, len(entities))
+	for _, entity := range entities {
+		if !t.skipped[store.Key{Kind: kind, IdpIdentity: identity(entity)}] {
+			result = append(result, entity)
+		}
+	}
+	return result
+}
@@ -0,0 +1,110 @@
+// Copyright 2023 Nautes Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package services
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hashicorp/go-multierror"
+	"github.com/nautes-labs/base-operator/pkg/log"
+	"github.com/nautes-labs/base-operator/pkg/metrics"
+	"github.com/nautes-labs/base-operator/pkg/store"
+	"github.com/nautes-labs/base-operator/pkg/util"
+	"golang.org/x/sync/errgroup"
+)
+
+// defaultWriteConcurrency is the max number of entities written to a target app at the same time.
+const defaultWriteConcurrency = 4
+
+// syncKinds maps the entities to the kinds of the result items.
+var syncKinds = map[string]string{
+	entityUser:    SyncUserKind,
+	entityGroup:   SyncGroupKind,
+	entityProject: SyncProjectKind,
+}
+
+// entitiesError is returned if some entities fail to write, the other entities and steps are not affected.
+type entitiesError struct {
+	entity    string
+	operation string
+	err       error
+}
+
+func (e *entitiesError) Error() string {
+	return fmt.Sprintf("%s %ss fail, err:%v", e.operation, e.entity, e.err)
+}
+
+func (e *entitiesError) Unwrap() error {
+	return e.err
+}
+
+// writeEntities writes n entities to the target app concurrently, a failed entity does not stop the others.
+// The failed entities are reported one by one in the detail of result, followed by the progress of the operation.
+func (s *SyncLogicService) writeEntities(ctx context.Context, state *targetAppState, entity, operation string, n int,
+	identity func(i int) string, write func(ctx context.Context, i int) error) error {
+	if n == 0 {
+		return nil
+	}
+	errs := make([]error, n)
+	errGroup := errgroup.Group{}
+	errGroup.SetLimit(state.concurrency)
+	for i := 0; i < n; i++ {
+		i := i
+		errGroup.Go(func() error {
+			defer func() {
+				if recovered := recover(); recovered != nil {
+					errs[i] = util.PanicError(ctx, recovered)
+				}
+			}()
+			if errs[i] = ctx.Err(); errs[i] != nil {
+				return nil
+			}
+			errs[i] = write(ctx, i)
+			return nil
+		})
+	}
+	_ = errGroup.Wait()
+
+	targetApp := state.targetApp
+	aggregateErr := (error)(nil)
+	failed := 0
+	for i, err := range errs {
+		metrics.SyncItems.WithLabelValues(targetLabel(targetApp), entity, operation, metrics.Result(err)).Inc()
+		if err == nil {
+			continue
+		}
+		log.FromContext(ctx).Error(err, fmt.Sprintf("%s %s fail", operation, entity), log.KeyEntity, entity, log.KeyIdentity, identity(i))
+		s.result.addDetail(state.identity(), NewSyncEntityFailItem(syncKinds[entity], identity(i), err.Error()))
+		state.markFailed(entity, identity(i))
+		aggregateErr = multierror.Append(aggregateErr, fmt.Errorf("%s %s: %w", entity, identity(i), err))
+		failed++
+	}
+	s.result.addDetail(state.identity(), NewSyncProgressItem(syncKinds[entity], fmt.Sprintf("%s %ss", operation, entity), n-failed, n))
+	if aggregateErr != nil {
+		return &entitiesError{entity: entity, operation: operation, err: aggregateErr}
+	}
+	return nil
+}
+
+// markFailed records the entity failed to write, the identity is the idp identity or the target identity of the entity.
+func (t *targetAppState) markFailed(entity, identity string) {
+	t.failed[store.Key{Kind: entity, IdpIdentity: identity}] = true
+}
+
+// isFailed checks whether the entity failed to write to the target app.
+func (t *targetAppState) isFailed(state entityState) bool {
+	return t.failed[store.Key{Kind: state.kind, IdpIdentity: state.idpIdentity}] || t.failed[store.Key{Kind: state.kind, IdpIdentity: state.targetIdentity}]
+}
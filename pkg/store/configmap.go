@@ -0,0 +1,166 @@
+// Copyright 2023 Nautes Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package store
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	configMapNamePrefix = "base-operator-mappings-"
+	// ConfigMapDataKey is the key of the gzipped json of mappings in the binary data of the config map.
+	ConfigMapDataKey = "mappings.json.gz"
+	// LabelManagedBy is the label of the config maps created by the store.
+	LabelManagedBy = "app.kubernetes.io/managed-by"
+	// AnnotationScope records the scope of the mappings in the config map.
+	AnnotationScope = "nautes.io/mapping-scope"
+	managedBy       = "base-operator"
+)
+
+type configMapStore struct {
+	client    client.Client
+	namespace string
+}
+
+// NewConfigMapStore returns a store keeping the mappings of each scope in a config map of the namespace,
+// the mappings are gzipped to stay far below the size limit of config maps.
+// The client should read from the api server directly, the cached client may return stale config maps.
+func NewConfigMapStore(c client.Client, namespace string) Store {
+	return &configMapStore{
+		client:    c,
+		namespace: namespace,
+	}
+}
+
+// configMapName returns the name of the config map of the scope, the scope is hashed as it may contain any characters.
+func configMapName(scope Scope) string {
+	sum := sha256.Sum256([]byte(scope.String()))
+	return configMapNamePrefix + hex.EncodeToString(sum[:])[:16]
+}
+
+func (c *configMapStore) Load(ctx context.Context, scope Scope) (*Mappings, error) {
+	cm := &corev1.ConfigMap{}
+	err := c.client.Get(ctx, client.ObjectKey{Namespace: c.namespace, Name: configMapName(scope)}, cm)
+	if apierrors.IsNotFound(err) {
+		return NewMappings(), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get mappings of %s failed: %w", scope, err)
+	}
+	if recorded := cm.Annotations[AnnotationScope]; recorded != scope.String() {
+		return nil, fmt.Errorf("config map %s/%s records the mappings of %s, not %s", cm.Namespace, cm.Name, recorded, scope)
+	}
+	data, ok := cm.BinaryData[ConfigMapDataKey]
+	if !ok {
+		return NewMappings(), nil
+	}
+	list, err := decodeMappings(data)
+	if err != nil {
+		return nil, fmt.Errorf("decode mappings of %s failed: %w", scope, err)
+	}
+	return NewMappings(list...), nil
+}
+
+func (c *configMapStore) Save(ctx context.Context, scope Scope, mappings *Mappings) error {
+	data, err := encodeMappings(mappings.List())
+	if err != nil {
+		return fmt.Errorf("encode mappings of %s failed: %w", scope, err)
+	}
+	key := client.ObjectKey{Namespace: c.namespace, Name: configMapName(scope)}
+	err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		cm := &corev1.ConfigMap{}
+		err := c.client.Get(ctx, key, cm)
+		if apierrors.IsNotFound(err) {
+			cm = &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        key.Name,
+					Namespace:   key.Namespace,
+					Labels:      map[string]string{LabelManagedBy: managedBy},
+					Annotations: map[string]string{AnnotationScope: scope.String()},
+				},
+				BinaryData: map[string][]byte{ConfigMapDataKey: data},
+			}
+			return c.client.Create(ctx, cm)
+		}
+		if err != nil {
+			return err
+		}
+		if recorded := cm.Annotations[AnnotationScope]; recorded != scope.String() {
+			return fmt.Errorf("config map %s/%s records the mappings of %s, not %s", cm.Namespace, cm.Name, recorded, scope)
+		}
+		cm.BinaryData = map[string][]byte{ConfigMapDataKey: data}
+		return c.client.Update(ctx, cm)
+	})
+	if err != nil {
+		return fmt.Errorf("save mappings of %s failed: %w", scope, err)
+	}
+	return nil
+}
+
+func (c *configMapStore) Delete(ctx context.Context, scope Scope) error {
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      configMapName(scope),
+			Namespace: c.namespace,
+		},
+	}
+	err := c.client.Delete(ctx, cm)
+	if err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("delete mappings of %s failed: %w", scope, err)
+	}
+	return nil
+}
+
+func encodeMappings(mappings []*Mapping) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	w := gzip.NewWriter(buf)
+	if err := json.NewEncoder(w).Encode(mappings); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func decodeMappings(data []byte) ([]*Mapping, error) {
+	r, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	raw, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	var mappings []*Mapping
+	if err := json.Unmarshal(raw, &mappings); err != nil {
+		return nil, err
+	}
+	return mappings, nil
+}
@@ -0,0 +1,62 @@
+// Copyright 2023 Nautes Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package store
+
+import (
+	"context"
+	"sync"
+)
+
+type memoryStore struct {
+	lock sync.RWMutex
+	data map[Scope][]Mapping
+}
+
+// NewMemoryStore returns a store keeping the mappings in memory, they are lost when the operator is restarted.
+func NewMemoryStore() Store {
+	return &memoryStore{
+		data: make(map[Scope][]Mapping),
+	}
+}
+
+func (m *memoryStore) Load(ctx context.Context, scope Scope) (*Mappings, error) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	result := NewMappings()
+	for _, mapping := range m.data[scope] {
+		mapping := mapping
+		result.Set(&mapping)
+	}
+	return result, nil
+}
+
+func (m *memoryStore) Save(ctx context.Context, scope Scope, mappings *Mappings) error {
+	list := mappings.List()
+	data := make([]Mapping, 0, len(list))
+	for _, mapping := range list {
+		data = append(data, *mapping)
+	}
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.data[scope] = data
+	return nil
+}
+
+func (m *memoryStore) Delete(ctx context.Context, scope Scope) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	delete(m.data, scope)
+	return nil
+}
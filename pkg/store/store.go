@@ -0,0 +1,123 @@
+// Copyright 2023 Nautes Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package store
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Mapping records an idp entity and the target entity it is synchronized to.
+type Mapping struct {
+	// Kind is the kind of the entity, one of user, group and project.
+	Kind           string `json:"kind"`
+	IdpIdentity    string `json:"idpIdentity"`
+	TargetIdentity string `json:"targetIdentity"`
+	// Name is the name of the idp entity when it was synchronized, it is used to detect renames.
+	Name string `json:"name"`
+	// Hash is the hash of the idp entity when it was synchronized, see Hash.
+	Hash     string    `json:"hash"`
+	SyncedAt time.Time `json:"syncedAt"`
+}
+
+// Key identifies a mapping in a scope.
+type Key struct {
+	Kind        string
+	IdpIdentity string
+}
+
+func (m *Mapping) Key() Key {
+	return Key{Kind: m.Kind, IdpIdentity: m.IdpIdentity}
+}
+
+// Mappings is the set of mappings between an idp and a target app.
+type Mappings struct {
+	items map[Key]*Mapping
+}
+
+func NewMappings(mappings ...*Mapping) *Mappings {
+	m := &Mappings{items: make(map[Key]*Mapping, len(mappings))}
+	for _, mapping := range mappings {
+		m.Set(mapping)
+	}
+	return m
+}
+
+func (m *Mappings) Get(kind, idpIdentity string) (*Mapping, bool) {
+	mapping, ok := m.items[Key{Kind: kind, IdpIdentity: idpIdentity}]
+	return mapping, ok
+}
+
+// Set adds the mapping, the existing mapping of the same idp entity is replaced.
+func (m *Mappings) Set(mapping *Mapping) {
+	m.items[mapping.Key()] = mapping
+}
+
+func (m *Mappings) Len() int {
+	return len(m.items)
+}
+
+// List returns the mappings sorted by kind and idp identity.
+func (m *Mappings) List() []*Mapping {
+	result := make([]*Mapping, 0, len(m.items))
+	for _, mapping := range m.items {
+		result = append(result, mapping)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Kind != result[j].Kind {
+			return result[i].Kind < result[j].Kind
+		}
+		return result[i].IdpIdentity < result[j].IdpIdentity
+	})
+	return result
+}
+
+// Scope is the pair of idp and target app whose mappings are stored together.
+type Scope struct {
+	IdpKind    string
+	IdpName    string
+	TargetKind string
+	TargetName string
+}
+
+func (s Scope) String() string {
+	return fmt.Sprintf("%s/%s->%s/%s", s.IdpKind, s.IdpName, s.TargetKind, s.TargetName)
+}
+
+// Store persists the mappings, so that a sync is able to skip the unchanged entities and detect renames
+// with the state recorded by the previous syncs, even if the operator is restarted.
+type Store interface {
+	// Load returns the mappings of the scope, the mappings are empty if nothing is stored.
+	Load(ctx context.Context, scope Scope) (*Mappings, error)
+	// Save replaces the mappings of the scope.
+	Save(ctx context.Context, scope Scope, mappings *Mappings) error
+	// Delete removes the mappings of the scope.
+	Delete(ctx context.Context, scope Scope) error
+}
+
+// Hash returns the hash of the json encoding of the entity.
+func Hash(entity interface{}) (string, error) {
+	data, err := json.Marshal(entity)
+	if err != nil {
+		return "", fmt.Errorf("encode entity failed: %w", err)
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
@@ -0,0 +1,154 @@
+// Copyright 2023 Nautes Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package store
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+const namespace = "nautes"
+
+var _ = Describe("Mapping store", func() {
+	var (
+		ctx       context.Context
+		scope     Scope
+		syncedAt  time.Time
+		userHash  string
+		groupHash string
+	)
+
+	BeforeEach(func() {
+		var err error
+		ctx = context.Background()
+		scope = Scope{IdpKind: "gitlab", IdpName: "gitlab1", TargetKind: "nexus", TargetName: "nexus1"}
+		syncedAt = time.Date(2023, 5, 1, 8, 0, 0, 0, time.UTC)
+		userHash, err = Hash(map[string]string{"name": "zhangsan"})
+		Expect(err).Should(BeNil())
+		groupHash, err = Hash(map[string]string{"name": "devops"})
+		Expect(err).Should(BeNil())
+	})
+
+	mappings := func() *Mappings {
+		return NewMappings(
+			&Mapping{Kind: "user", IdpIdentity: "1", TargetIdentity: "gitlab.gitlab1.1", Name: "zhangsan", Hash: userHash, SyncedAt: syncedAt},
+			&Mapping{Kind: "group", IdpIdentity: "1", TargetIdentity: "gitlab.gitlab1.group.1", Name: "devops", Hash: groupHash, SyncedAt: syncedAt},
+		)
+	}
+
+	behavesLikeStore := func(newStore func() Store) {
+		It("returns empty mappings if nothing is stored", func() {
+			loaded, err := newStore().Load(ctx, scope)
+			Expect(err).Should(BeNil())
+			Expect(loaded.Len()).Should(Equal(0))
+		})
+
+		It("saves, loads and deletes the mappings of a scope", func() {
+			st := newStore()
+			Expect(st.Save(ctx, scope, mappings())).Should(Succeed())
+
+			loaded, err := st.Load(ctx, scope)
+			Expect(err).Should(BeNil())
+			Expect(loaded.List()).Should(Equal(mappings().List()))
+			user, ok := loaded.Get("user", "1")
+			Expect(ok).Should(BeTrue())
+			Expect(user.SyncedAt.Equal(syncedAt)).Should(BeTrue())
+
+			other := scope
+			other.TargetName = "nexus2"
+			loaded, err = st.Load(ctx, other)
+			Expect(err).Should(BeNil())
+			Expect(loaded.Len()).Should(Equal(0))
+
+			updated := mappings()
+			updated.Set(&Mapping{Kind: "user", IdpIdentity: "1", TargetIdentity: "gitlab.gitlab1.1", Name: "lisi", Hash: groupHash, SyncedAt: syncedAt})
+			Expect(st.Save(ctx, scope, updated)).Should(Succeed())
+			loaded, err = st.Load(ctx, scope)
+			Expect(err).Should(BeNil())
+			user, _ = loaded.Get("user", "1")
+			Expect(user.Name).Should(Equal("lisi"))
+
+			Expect(st.Delete(ctx, scope)).Should(Succeed())
+			Expect(st.Delete(ctx, scope)).Should(Succeed())
+			loaded, err = st.Load(ctx, scope)
+			Expect(err).Should(BeNil())
+			Expect(loaded.Len()).Should(Equal(0))
+		})
+
+		It("does not share the stored mappings with the caller", func() {
+			st := newStore()
+			saved := mappings()
+			Expect(st.Save(ctx, scope, saved)).Should(Succeed())
+			user, _ := saved.Get("user", "1")
+			user.Name = "lisi"
+
+			loaded, err := st.Load(ctx, scope)
+			Expect(err).Should(BeNil())
+			user, _ = loaded.Get("user", "1")
+			Expect(user.Name).Should(Equal("zhangsan"))
+		})
+	}
+
+	Context("Memory", func() {
+		behavesLikeStore(NewMemoryStore)
+	})
+
+	Context("ConfigMap", func() {
+		var k8sClient client.Client
+
+		BeforeEach(func() {
+			k8sClient = fake.NewClientBuilder().WithScheme(scheme.Scheme).Build()
+		})
+
+		behavesLikeStore(func() Store { return NewConfigMapStore(k8sClient, namespace) })
+
+		It("keeps the gzipped mappings in a config map labeled as managed", func() {
+			Expect(NewConfigMapStore(k8sClient, namespace).Save(ctx, scope, mappings())).Should(Succeed())
+
+			list := &corev1.ConfigMapList{}
+			Expect(k8sClient.List(ctx, list, client.InNamespace(namespace), client.MatchingLabels{LabelManagedBy: "base-operator"})).Should(Succeed())
+			Expect(list.Items).Should(HaveLen(1))
+			cm := list.Items[0]
+			Expect(cm.Name).Should(HavePrefix("base-operator-mappings-"))
+			Expect(cm.Annotations[AnnotationScope]).Should(Equal("gitlab/gitlab1->nexus/nexus1"))
+			decoded, err := decodeMappings(cm.BinaryData[ConfigMapDataKey])
+			Expect(err).Should(BeNil())
+			Expect(decoded).Should(Equal(mappings().List()))
+		})
+
+		It("refuses a config map recording another scope", func() {
+			Expect(k8sClient.Create(ctx, &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        configMapName(scope),
+					Namespace:   namespace,
+					Annotations: map[string]string{AnnotationScope: "gitlab/gitlab2->nexus/nexus1"},
+				},
+			})).Should(Succeed())
+
+			st := NewConfigMapStore(k8sClient, namespace)
+			_, err := st.Load(ctx, scope)
+			Expect(err).Should(HaveOccurred())
+			Expect(st.Save(ctx, scope, mappings())).ShouldNot(Succeed())
+		})
+	})
+})
@@ -17,9 +17,8 @@ package target
 import (
 	"context"
 	"fmt"
-	"os"
 	"sort"
-	"strings"
+	"sync"
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-cmp/cmp/cmpopts"
@@ -33,7 +32,6 @@ import (
 	"github.com/nautes-labs/base-operator/pkg/schema"
 	"github.com/nautes-labs/base-operator/pkg/secret_provider"
 	"github.com/nautes-labs/base-operator/pkg/util"
-	"github.com/sirupsen/logrus"
 )
 
 var _ TargetApp = (*nexusApp)(nil)
@@ -42,12 +40,16 @@ type nexusApp struct {
 	idp                idp.Idp
 	name               string
 	apiServerUrl       string
+	options            ClientOptions
+	membership         schema.MembershipMode
 	client             *nexus.NexusClient
 	secretProvider     *secret_provider.SecretProvider
 	nexus2IdpConverter *convert2idp.Nexus2IdpConverter
 	idp2NexusConverter *convert2target.Idp2NexusConverter
-	roles              []*security.Role
-	groups             []*schema.Group
+	prepareLock        sync.Mutex
+	migrated           bool
+	ownershipLock      sync.RWMutex
+	roleOwnerships     map[string]schema.Ownership
 }
 
 func (n *nexusApp) newClient() error {
@@ -56,14 +58,34 @@ func (n *nexusApp) newClient() error {
 		if err != nil {
 			return fmt.Errorf("get basic auth info fail, err:%w", err)
 		}
-		//
-		n.client = nexus.NewClient(client.Config{
+		n.client, err = nexus.NewClient(client.Config{
 			URL:      n.apiServerUrl,
 			Username: username,
 			Password: passwd,
-			Insecure: true,
+			Insecure: n.options.SkipVerify(),
+			CABundle: n.options.CABundle,
 		})
+		if err != nil {
+			return fmt.Errorf("create nexus client fail, err:%w", err)
+		}
+	}
+	return nil
+}
+
+// prepare creates the client and migrates the legacy identities before the first request.
+func (n *nexusApp) prepare(ctx context.Context) error {
+	n.prepareLock.Lock()
+	defer n.prepareLock.Unlock()
+	if err := n.newClient(); err != nil {
+		return err
+	}
+	if n.migrated {
+		return nil
 	}
+	if err := n.migrateLegacyIdentities(ctx); err != nil {
+		return fmt.Errorf("migrate legacy identities fail, err:%w", err)
+	}
+	n.migrated = true
 	return nil
 }
 
@@ -96,17 +118,28 @@ func (n *nexusApp) SetApiServerUrl(url string) {
 	return
 }
 
+func (n *nexusApp) SetClientOptions(opts ClientOptions) {
+	n.options = opts
+	n.client = nil
+}
+
+// SetMembership sets how the membership of the subgroups is represented, the roles of the subgroups are nested
+// in the roles of their parents unless the members are flattened.
+func (n *nexusApp) SetMembership(mode schema.MembershipMode) {
+	n.membership = mode
+}
+
 func (n *nexusApp) SetSecretProvider(provider *secret_provider.SecretProvider) {
 	n.secretProvider = provider
 	return
 }
 
 func (n *nexusApp) GetUsers(ctx context.Context) ([]*schema.User, error) {
-	err := n.newClient()
+	err := n.prepare(ctx)
 	if err != nil {
 		return nil, err
 	}
-	list, err := n.client.Security.User.List()
+	list, err := n.client.Security.User.List(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -117,72 +150,61 @@ func (n *nexusApp) GetUsers(ctx context.Context) ([]*schema.User, error) {
 	return result, nil
 }
 
-func (n *nexusApp) getAllRoles() error {
-	if len(n.roles) > 0 {
-		return nil
-	}
-	err := n.newClient()
-	if err != nil {
-		return err
-	}
-	list, err := n.client.Security.Role.List()
-	if err != nil {
-		return err
-	}
-	n.roles = list
-	return nil
-}
-
 func (n *nexusApp) GetGroups(ctx context.Context) ([]*schema.Group, error) {
-	if len(n.groups) > 0 {
-		return n.groups, nil
+	err := n.prepare(ctx)
+	if err != nil {
+		return nil, err
 	}
-	// invoke nexus client query all roles
-	err := n.getAllRoles()
+	list, err := n.listRoles(ctx)
 	if err != nil {
 		return nil, err
 	}
-	result := n.nexus2IdpConverter.RolesToGroups(n.idp.Kind().Tostring(), n.idp.GetName(), n.roles)
-	n.groups = result
+	result := n.nexus2IdpConverter.RolesToGroups(n.idp.Kind().Tostring(), n.idp.GetName(), list)
 	return result, nil
 }
 
-func (n *nexusApp) getLatestGroups() ([]*schema.Group, error) {
-	err := n.newClient()
+// listRoles lists the roles and records their ownerships, which tell the kinds of the child roles.
+func (n *nexusApp) listRoles(ctx context.Context) ([]*security.Role, error) {
+	list, err := n.client.Security.Role.List(ctx)
 	if err != nil {
 		return nil, err
 	}
-	list, err := n.client.Security.Role.List()
-	if err != nil {
-		return nil, err
+	ownerships := make(map[string]schema.Ownership, len(list))
+	for _, role := range list {
+		_, ownerships[role.ID] = n.nexus2IdpConverter.RoleOwnership(role)
 	}
-	result := n.nexus2IdpConverter.RolesToGroups(n.idp.Kind().Tostring(), n.idp.GetName(), list)
-	return result, nil
+	n.ownershipLock.Lock()
+	n.roleOwnerships = ownerships
+	n.ownershipLock.Unlock()
+	return list, nil
 }
 
-func (n *nexusApp) addGroup(group *schema.Group) {
-	n.groups = append(n.groups, group)
+// roleOwnership returns the ownership of the role, it is inferred from the id if the role is created after the roles are listed.
+func (n *nexusApp) roleOwnership(id string) schema.Ownership {
+	n.ownershipLock.RLock()
+	ownership, ok := n.roleOwnerships[id]
+	n.ownershipLock.RUnlock()
+	if ok {
+		return ownership
+	}
+	return schema.OwnershipOfIdentity(id)
 }
 
-func (n *nexusApp) updateGroupInMemery(newGroup *schema.Group) {
-	for _, g := range n.groups {
-		if g.Identity == n.GenerateIdpGroupIdentity(newGroup.Kind, newGroup.Identity) {
-			g.Name = newGroup.Name
-			g.Description = newGroup.Description
-			g.Kind = newGroup.Kind
-			g.ChildIds = newGroup.ChildIds
-			break
-		}
-	}
-	return
+func (n *nexusApp) isProjectRole(id string) bool {
+	return n.roleOwnership(id).Kind == schema.NamespaceProject
+}
+
+// ownership returns the ownership recorded on the roles of the entity kind.
+func (n *nexusApp) ownership(kind string) schema.Ownership {
+	return schema.Ownership{IdpKind: n.idp.Kind().Tostring(), IdpName: n.idp.GetName(), Kind: kind}
 }
 
 func (n *nexusApp) GetProjects(ctx context.Context) ([]*schema.Project, error) {
-	err := n.newClient()
+	err := n.prepare(ctx)
 	if err != nil {
 		return nil, err
 	}
-	list, err := n.client.Security.Role.List()
+	list, err := n.listRoles(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -198,17 +220,11 @@ func (n *nexusApp) GetGroupMembers(ctx context.Context) ([]*schema.GroupMember,
 	result := make([]*schema.GroupMember, 0)
 	for _, user := range users {
 		for _, roleId := range user.RoleIds {
-			knri := schema.StringToKNRI(roleId)
-			if knri.IsEmpty() {
-				continue
-			}
-			if knri.Kind != string(n.idp.Kind()) {
-				continue
-			}
-			if knri.Name != n.idp.GetName() {
+			ownership := n.roleOwnership(roleId)
+			if !ownership.IsOwnedBy(n.idp.Kind().Tostring(), n.idp.GetName()) {
 				continue
 			}
-			if !util.InArray(knri.RoleKind, []string{schema.NamespaceGroup, schema.NamespaceUser}) {
+			if !util.InArray(ownership.Kind, []string{schema.NamespaceGroup, schema.NamespaceUser}) {
 				continue
 			}
 			groupMember := &schema.GroupMember{
@@ -226,7 +242,7 @@ func (n *nexusApp) GetProjectMembers(ctx context.Context, project *schema.Projec
 }
 
 func (n *nexusApp) CreateUser(ctx context.Context, user *schema.User) error {
-	err := n.newClient()
+	err := n.prepare(ctx)
 	if err != nil {
 		return err
 	}
@@ -247,21 +263,31 @@ func (n *nexusApp) CreateUser(ctx context.Context, user *schema.User) error {
 	groupIdentity := n.GenerateIdpGroupIdentity(schema.NamespaceUser, user.NamespaceId)
 	u := n.idp2NexusConverter.IdpUser2NexusUser(identity, user, []string{groupIdentity})
 	//2. create user
-	err = n.client.Security.User.Create(*u)
+	err = n.createUser(ctx, u)
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
+// createUser treats an existing user as created, the request may be retried after nexus has already created it.
+func (n *nexusApp) createUser(ctx context.Context, user *security.User) error {
+	err := n.client.Security.User.Create(ctx, *user)
+	if client.IsConflict(err) {
+		log.FromContext(ctx).V(1).Info("user already exists", log.KeyIdentity, user.UserID)
+		return nil
+	}
+	return err
+}
+
 func (n *nexusApp) UpdateUser(ctx context.Context, id string, user *schema.User) error {
-	err := n.newClient()
+	err := n.prepare(ctx)
 	if err != nil {
 		return err
 	}
 	id = n.GenerateIdpUserIdentity(id)
 	u := n.idp2NexusConverter.IdpUser2NexusUser(id, user, user.RoleIds)
-	err = n.client.Security.User.Update(id, *u)
+	err = n.client.Security.User.Update(ctx, id, *u)
 	if err != nil {
 		return err
 	}
@@ -269,7 +295,7 @@ func (n *nexusApp) UpdateUser(ctx context.Context, id string, user *schema.User)
 }
 
 func (n *nexusApp) CreateGroup(ctx context.Context, group *schema.Group) error {
-	err := n.newClient()
+	err := n.prepare(ctx)
 	if err != nil {
 		return err
 	}
@@ -279,58 +305,49 @@ func (n *nexusApp) CreateGroup(ctx context.Context, group *schema.Group) error {
 	} else {
 		groupIdentity = n.GenerateIdpGroupIdentity(schema.NamespaceUser, group.Identity)
 	}
-	role := n.idp2NexusConverter.IdpGroup2NexusRole(groupIdentity, group)
+	role := n.idp2NexusConverter.IdpGroup2NexusRole(n.ownership(group.Kind), groupIdentity, group)
 	// create nexus role
-	err = n.client.Security.Role.Create(*role)
+	err = n.createRole(ctx, role)
 	if err != nil {
 		return err
 	}
-	// if group.Kind == schema.NamespaceGroup {
-	// 	// add group in memery
-	// 	memGroup := &schema.Group{
-	// 		BaseEntity: schema.BaseEntity{
-	// 			Identity:    groupIdentity,
-	// 			Name:        group.Name,
-	// 			Description: group.Description,
-	// 		},
-	// 		Kind:     group.Kind,
-	// 		ParentId: group.ParentId,
-	// 	}
-	// 	if len(group.ParentId) > 0 {
-	// 		memGroup.ParentId = n.GenerateIdpGroupIdentity(schema.NamespaceGroup, group.ParentId)
-	// 	}
-	// 	for _, childId := range group.ChildIds {
-	// 		memGroup.ChildIds = append(memGroup.ChildIds, n.GenerateIdpGroupIdentity(schema.NamespaceGroup, childId))
-	// 	}
-	// 	n.addGroup(memGroup)
-	// }
 	return nil
 }
 
+// createRole creates the role, an existing role with the same id is treated as created,
+// e.g. it was created by a previous sync which failed afterwards.
+func (n *nexusApp) createRole(ctx context.Context, role *security.Role) error {
+	err := n.client.Security.Role.Create(ctx, *role)
+	if client.IsConflict(err) {
+		log.FromContext(ctx).V(1).Info("role already exists", log.KeyIdentity, role.ID)
+		return nil
+	}
+	return err
+}
+
 func (n *nexusApp) UpdateGroup(ctx context.Context, id string, group *schema.Group) error {
-	err := n.newClient()
+	err := n.prepare(ctx)
 	if err != nil {
 		return err
 	}
 	//id = n.GenerateIdpGroupIdentity(schema.NamespaceGroup, id)
-	role := n.idp2NexusConverter.IdpGroup2NexusRole(id, group)
+	role := n.idp2NexusConverter.IdpGroup2NexusRole(n.ownership(group.Kind), id, group)
 	role.Roles = group.ChildIds
-	err = n.client.Security.Role.Update(id, *role)
+	err = n.client.Security.Role.Update(ctx, id, *role)
 	if err != nil {
 		return err
 	}
-	//n.updateGroupInMemery(group)
 	return nil
 }
 
 func (n *nexusApp) CreateProject(ctx context.Context, project *schema.Project) error {
-	err := n.newClient()
+	err := n.prepare(ctx)
 	if err != nil {
 		return err
 	}
 	projectIdentity := n.GenerateIdpProjectIdentity(project.Identity)
-	role := n.idp2NexusConverter.IdpProject2NexusRole(projectIdentity, project)
-	err = n.client.Security.Role.Create(*role)
+	role := n.idp2NexusConverter.IdpProject2NexusRole(n.ownership(schema.NamespaceProject), projectIdentity, project)
+	err = n.createRole(ctx, role)
 	if err != nil {
 		return err
 	}
@@ -338,14 +355,14 @@ func (n *nexusApp) CreateProject(ctx context.Context, project *schema.Project) e
 }
 
 func (n *nexusApp) UpdateProject(ctx context.Context, id string, project *schema.Project) error {
-	err := n.newClient()
+	err := n.prepare(ctx)
 	if err != nil {
 		return err
 	}
 	id = n.GenerateIdpProjectIdentity(id)
-	role := n.idp2NexusConverter.IdpProject2NexusRole(id, project)
+	role := n.idp2NexusConverter.IdpProject2NexusRole(n.ownership(schema.NamespaceProject), id, project)
 	// update role
-	err = n.client.Security.Role.Update(id, *role)
+	err = n.client.Security.Role.Update(ctx, id, *role)
 	if err != nil {
 		return err
 	}
@@ -369,24 +386,24 @@ func (n *nexusApp) UpdateProjectMember(ctx context.Context, id string, projectMe
 }
 
 func (n *nexusApp) DeleteUserById(ctx context.Context, id string) error {
-	err := n.newClient()
+	err := n.prepare(ctx)
 	if err != nil {
 		return err
 	}
-	err = n.client.Security.User.Delete(id)
-	if err != nil {
+	err = n.client.Security.User.Delete(ctx, id)
+	if err != nil && !client.IsNotFound(err) {
 		return err
 	}
 	return nil
 }
 
 func (n *nexusApp) DeleteGroupById(ctx context.Context, id string) error {
-	err := n.newClient()
+	err := n.prepare(ctx)
 	if err != nil {
 		return err
 	}
-	err = n.client.Security.Role.Delete(id)
-	if err != nil {
+	err = n.client.Security.Role.Delete(ctx, id)
+	if err != nil && !client.IsNotFound(err) {
 		return err
 	}
 	return nil
@@ -403,18 +420,18 @@ func (n *nexusApp) DeleteProjectMemberById(ctx context.Context, id string) error
 }
 
 func (n *nexusApp) GenerateIdpUserIdentity(Identity string) (idpUserIdentity string) {
-	return fmt.Sprintf("%s-%s-%s", n.idp.Kind(), n.idp.GetName(), Identity)
+	return schema.TargetKNI{Kind: n.idp.Kind().Tostring(), Name: n.idp.GetName(), Identity: Identity}.String()
 }
 
 func (n *nexusApp) GenerateIdpGroupIdentity(groupKind string, Identity string) (idpGroupIdentity string) {
-	return fmt.Sprintf("%s-%s-%s-%s", n.idp.Kind(), n.idp.GetName(), groupKind, Identity)
+	return schema.TargetKNRI{Kind: n.idp.Kind().Tostring(), Name: n.idp.GetName(), RoleKind: groupKind, Identity: Identity}.String()
 }
 
 func (n *nexusApp) GenerateIdpProjectIdentity(Identity string) (idpProjectIdentity string) {
-	return fmt.Sprintf("%s-%s-%s-%s", n.idp.Kind(), n.idp.GetName(), schema.NamespaceProject, Identity)
+	return schema.TargetKNRI{Kind: n.idp.Kind().Tostring(), Name: n.idp.GetName(), RoleKind: schema.NamespaceProject, Identity: Identity}.String()
 }
 
-func (n *nexusApp) CompareUsers(idpUsers []*schema.User, targetAppUsers []*schema.User) (createUsers []*schema.User, updateUsers []*schema.User) {
+func (n *nexusApp) CompareUsers(ctx context.Context, idpUsers []*schema.User, targetAppUsers []*schema.User) (createUsers []*schema.User, updateUsers []*schema.User) {
 	targetAppUserIds := make([]string, 0, len(targetAppUsers))
 	targetUserIdMapping := make(map[string]*schema.User, 0)
 	for _, targetAppUser := range targetAppUsers {
@@ -424,30 +441,21 @@ func (n *nexusApp) CompareUsers(idpUsers []*schema.User, targetAppUsers []*schem
 	for _, idpUser := range idpUsers {
 		idpUserIdentity := n.GenerateIdpUserIdentity(idpUser.Identity)
 		if !util.InArray(idpUserIdentity, targetAppUserIds) {
-			log.Loger.WithFields(logrus.Fields{
-				"targetapp_kind": n.Kind(),
-				"targetapp_name": n.GetName(),
-				"new_user":       idpUser,
-			}).Debugf("Existence of new users")
+			log.FromContext(ctx).V(1).Info("existence of new users", log.KeyEntity, schema.NamespaceUser, log.KeyIdentity, idpUser.Identity)
 			createUsers = append(createUsers, idpUser)
 			continue
 		}
 		targetAppUser := targetUserIdMapping[idpUserIdentity]
 		copyIdpUser := n.copyUser(idpUser, targetAppUser.RoleIds)
-		if schema.UserIsChanged(targetAppUser, copyIdpUser) {
-			log.Loger.WithFields(logrus.Fields{
-				"targetapp_kind": n.Kind(),
-				"targetapp_name": n.GetName(),
-				"old_user":       targetAppUser,
-				"new_user":       idpUser,
-			}).Debugf("Existence of updated users")
+		if schema.UserIsChanged(targetAppUser, n.normalizeUser(idpUserIdentity, copyIdpUser)) {
+			log.FromContext(ctx).V(1).Info("existence of updated users", log.KeyEntity, schema.NamespaceUser, log.KeyIdentity, idpUser.Identity)
 			updateUsers = append(updateUsers, copyIdpUser)
 		}
 	}
 	return
 }
 
-func (n *nexusApp) CompareGroups(idpGroups []*schema.Group, targetAppGroups []*schema.Group) (createGroups []*schema.Group, updateGroups []*schema.Group) {
+func (n *nexusApp) CompareGroups(ctx context.Context, idpGroups []*schema.Group, targetAppGroups []*schema.Group) (createGroups []*schema.Group, updateGroups []*schema.Group) {
 	targetAppGroupIds := make([]string, 0, len(targetAppGroups))
 	targetGroupIdMapping := make(map[string]*schema.Group, 0)
 	for _, targetAppGroup := range targetAppGroups {
@@ -457,30 +465,21 @@ func (n *nexusApp) CompareGroups(idpGroups []*schema.Group, targetAppGroups []*s
 	for _, idpGroup := range idpGroups {
 		idpGroupIdentity := n.GenerateIdpGroupIdentity(schema.NamespaceGroup, idpGroup.Identity)
 		if !util.InArray(idpGroupIdentity, targetAppGroupIds) {
-			log.Loger.WithFields(logrus.Fields{
-				"targetapp_kind": n.Kind(),
-				"targetapp_name": n.GetName(),
-				"new_group":      idpGroup,
-			}).Debugf("Existence of new group")
+			log.FromContext(ctx).V(1).Info("existence of new group", log.KeyEntity, schema.NamespaceGroup, log.KeyIdentity, idpGroup.Identity)
 			createGroups = append(createGroups, idpGroup)
 			continue
 		}
 		targetAppGroup := targetGroupIdMapping[idpGroupIdentity]
 		newGroup := n.copyGroup(*idpGroup, *targetAppGroup)
-		if schema.GroupIsChanged(targetAppGroup, newGroup) {
-			log.Loger.WithFields(logrus.Fields{
-				"targetapp_kind": n.Kind(),
-				"targetapp_name": n.GetName(),
-				"old_group":      targetAppGroup,
-				"new_group":      idpGroup,
-			}).Debugf("Existence of updated group")
+		if schema.GroupIsChanged(targetAppGroup, newGroup, n.isProjectRole) {
+			log.FromContext(ctx).V(1).Info("existence of updated group", log.KeyEntity, schema.NamespaceGroup, log.KeyIdentity, idpGroup.Identity)
 			updateGroups = append(updateGroups, newGroup)
 		}
 	}
 	return
 }
 
-func (n *nexusApp) CompareProjects(idpProjects []*schema.Project, targetAppProjects []*schema.Project) (createProjects []*schema.Project, updateProjects []*schema.Project) {
+func (n *nexusApp) CompareProjects(ctx context.Context, idpProjects []*schema.Project, targetAppProjects []*schema.Project) (createProjects []*schema.Project, updateProjects []*schema.Project) {
 	targetAppProjectIds := make([]string, 0, len(targetAppProjects))
 	targetProjectIdMapping := make(map[string]*schema.Project, 0)
 	for _, targetAppProject := range targetAppProjects {
@@ -490,41 +489,52 @@ func (n *nexusApp) CompareProjects(idpProjects []*schema.Project, targetAppProje
 	for _, idpProject := range idpProjects {
 		idpProjectIdentity := n.GenerateIdpProjectIdentity(idpProject.Identity)
 		if !util.InArray(idpProjectIdentity, targetAppProjectIds) {
-			log.Loger.WithFields(logrus.Fields{
-				"targetapp_kind": n.Kind(),
-				"targetapp_name": n.GetName(),
-				"new_project":    idpProject,
-			}).Debugf("Existence of new project")
+			log.FromContext(ctx).V(1).Info("existence of new project", log.KeyEntity, schema.NamespaceProject, log.KeyIdentity, idpProject.Identity)
 			createProjects = append(createProjects, idpProject)
 			continue
 		}
 		targetAppProject := targetProjectIdMapping[idpProjectIdentity]
 		if schema.ProjectIsChanged(targetAppProject, idpProject) {
-			log.Loger.WithFields(logrus.Fields{
-				"targetapp_kind": n.Kind(),
-				"targetapp_name": n.GetName(),
-				"old_project":    targetAppProject,
-				"new_project":    idpProject,
-			}).Debugf("Existence of updated project")
+			log.FromContext(ctx).V(1).Info("existence of updated project", log.KeyEntity, schema.NamespaceProject, log.KeyIdentity, idpProject.Identity)
 			updateProjects = append(updateProjects, idpProject)
 		}
 	}
 	return
 }
 
-func (n *nexusApp) SyncGroupMember(ctx context.Context, idpGroupMembers []*schema.GroupMember, targetAppGroupMembers []*schema.GroupMember) error {
-	idpUsers := schema.GroupMembersToUsers(idpGroupMembers)
+// SyncGroupMember writes the roles of the groups the users are members of, the roles not owned by the idp are kept,
+// such as the roles synchronized from other idps and the roles granted in nexus.
+// A member whose TargetUserId is set is a user synchronized from another idp, only its roles are written.
+func (n *nexusApp) SyncGroupMember(ctx context.Context, idpUsers []*schema.User, idpGroupMembers []*schema.GroupMember, targetAppGroupMembers []*schema.GroupMember) error {
+	writtenUsers := make(map[string]*schema.User, len(idpUsers))
+	for _, user := range idpUsers {
+		writtenUsers[user.Identity] = user
+	}
+	foreignUserIds := make(map[string]string)
+	for _, member := range idpGroupMembers {
+		if len(member.TargetUserId) > 0 {
+			foreignUserIds[member.UserId] = member.TargetUserId
+		}
+	}
+	currentUsers, err := n.currentUsers(ctx)
+	if err != nil {
+		return err
+	}
+	idpMemberUsers := schema.GroupMembersToUsers(idpGroupMembers)
 	targetAppUsers := schema.GroupMembersToUsers(targetAppGroupMembers)
 	idpUserIds := make(map[string][]string, 0)
 	idpUserIdToOrgIdMapping := make(map[string]string)
 	targetAppUserIds := make(map[string][]string, 0)
 	targetAppOnlyGIds := make(map[string][]string, 0)
-	for _, idpUser := range idpUsers {
+	for _, idpUser := range idpMemberUsers {
 		idpUserRoleIds := make([]string, 0)
 		for _, roleId := range idpUser.RoleIds {
 			idpUserRoleIds = append(idpUserRoleIds, n.GenerateIdpGroupIdentity(schema.NamespaceGroup, roleId))
 		}
-		identity := n.GenerateIdpUserIdentity(idpUser.Identity)
+		identity, foreign := foreignUserIds[idpUser.Identity]
+		if !foreign {
+			identity = n.GenerateIdpUserIdentity(idpUser.Identity)
+		}
 		idpUserIdToOrgIdMapping[identity] = idpUser.Identity
 		idpUserIds[identity] = idpUserRoleIds
 	}
@@ -532,8 +542,8 @@ func (n *nexusApp) SyncGroupMember(ctx context.Context, idpGroupMembers []*schem
 		targetAppUserRoleIds := make([]string, 0)
 		onlyGIds := make([]string, 0)
 		for _, roleId := range targetAppUser.RoleIds {
-			knri := schema.StringToKNRI(roleId)
-			if !knri.IsEmpty() && knri.Kind == n.idp.Kind().Tostring() && knri.Name == n.idp.GetName() && knri.RoleKind == schema.NamespaceGroup {
+			ownership := n.roleOwnership(roleId)
+			if ownership.IsOwnedBy(n.idp.Kind().Tostring(), n.idp.GetName()) && ownership.Kind == schema.NamespaceGroup {
 				onlyGIds = append(onlyGIds, roleId)
 			}
 			targetAppUserRoleIds = append(targetAppUserRoleIds, roleId)
@@ -542,15 +552,17 @@ func (n *nexusApp) SyncGroupMember(ctx context.Context, idpGroupMembers []*schem
 		targetAppOnlyGIds[targetAppUser.Identity] = onlyGIds
 	}
 	updateUsers := make([]*schema.User, 0)
+	updateForeignUsers := make([]*security.User, 0)
 	for identity, idpUserRoleIds := range idpUserIds {
+		_, foreign := foreignUserIds[idpUserIdToOrgIdMapping[identity]]
 		nexusRoleIds, ok := targetAppOnlyGIds[identity]
-		if !ok {
+		if !ok && !foreign {
 			continue
 		}
 		sort.Strings(idpUserRoleIds)
 		sort.Strings(nexusRoleIds)
 		isUpdate := false
-		if !cmp.Equal(idpUserRoleIds, nexusRoleIds) {
+		if !cmp.Equal(idpUserRoleIds, nexusRoleIds, cmpopts.EquateEmpty()) {
 			isUpdate = true
 		}
 		if isUpdate {
@@ -561,22 +573,32 @@ func (n *nexusApp) SyncGroupMember(ctx context.Context, idpGroupMembers []*schem
 			for _, roleId := range delRoleIds {
 				delRoleIdMap[roleId] = struct{}{}
 			}
-			for _, roleId := range targetAppUserIds[identity] {
+			roleIds := targetAppUserIds[identity]
+			currentUser, exist := currentUsers[identity]
+			if exist {
+				roleIds = currentUser.Roles
+			}
+			for _, roleId := range roleIds {
 				if _, ok := delRoleIdMap[roleId]; !ok {
 					newRoleIds = append(newRoleIds, roleId)
 				}
 			}
 			newRoleIds = append(newRoleIds, addRoleIds...)
-			// query new lastest user data
-			user, err := n.idp.GetStaticUserById(idpUserIdToOrgIdMapping[identity])
+			if foreign {
+				if !exist {
+					log.FromContext(ctx).V(1).Info("skip the user of another idp not found", log.KeyIdentity, identity)
+					continue
+				}
+				u := *currentUser
+				u.Roles = newRoleIds
+				updateForeignUsers = append(updateForeignUsers, &u)
+				continue
+			}
+			// the user is written as it is written by the user sync, the latest user data is queried if it is not synchronized
+			user, err := n.writtenUser(writtenUsers, idpUserIdToOrgIdMapping[identity])
 			if err != nil {
 				return err
 			}
-			// local dev, except `gitlab-gitlab1-group-48``
-			if v := os.Getenv("EXCEPT_GROUP_MEMBER_ID"); len(v) > 0 {
-				util.DeleteArrayItem(v, newRoleIds)
-			}
-
 			user.RoleIds = newRoleIds
 			updateUsers = append(updateUsers, user)
 		}
@@ -587,18 +609,49 @@ func (n *nexusApp) SyncGroupMember(ctx context.Context, idpGroupMembers []*schem
 			return err
 		}
 	}
+	for _, updateUser := range updateForeignUsers {
+		err := n.client.Security.User.Update(ctx, updateUser.UserID, *updateUser)
+		if err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
+// currentUsers returns the nexus users by their ids, the roles of the users are kept when their memberships are written.
+func (n *nexusApp) currentUsers(ctx context.Context) (map[string]*security.User, error) {
+	if err := n.prepare(ctx); err != nil {
+		return nil, err
+	}
+	list, err := n.client.Security.User.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	users := make(map[string]*security.User, len(list))
+	for _, user := range list {
+		users[user.UserID] = user
+	}
+	return users, nil
+}
+
+// writtenUser returns a copy of the user written by the user sync, or the user read from idp if it is not synchronized.
+func (n *nexusApp) writtenUser(writtenUsers map[string]*schema.User, id string) (*schema.User, error) {
+	if user, ok := writtenUsers[id]; ok {
+		u := *user
+		return &u, nil
+	}
+	return n.idp.GetStaticUserById(id)
+}
+
 func (n *nexusApp) GroupBindingProjects(ctx context.Context, idpProjects []*schema.Project) error {
 	groups, err := n.groupBindingProjectsHandle(ctx, idpProjects)
 	if err != nil {
 		return err
 	}
 	for _, group := range groups {
-		role := n.idp2NexusConverter.IdpGroup2NexusRole(group.Identity, group)
+		role := n.idp2NexusConverter.IdpGroup2NexusRole(n.ownership(group.Kind), group.Identity, group)
 		role.Roles = group.ChildIds
-		err := n.client.Security.Role.Update(group.Identity, *role)
+		err := n.client.Security.Role.Update(ctx, group.Identity, *role)
 		if err != nil {
 			return err
 		}
@@ -606,13 +659,18 @@ func (n *nexusApp) GroupBindingProjects(ctx context.Context, idpProjects []*sche
 	return nil
 }
 
-func (n *nexusApp) WrappingUpAfterGroupSync(ctx context.Context) error {
-	// Update father-son relationship
-	idpGroups, err := n.idp.GetGroups(ctx)
-	if err != nil {
-		return err
+// nestedChildIds returns the subgroups whose roles are nested in the role of the group,
+// the roles of the groups are not nested if the members are flattened, the inherited members are granted the roles directly.
+func (n *nexusApp) nestedChildIds(group *schema.Group) []string {
+	if !n.membership.NestsGroups() {
+		return nil
 	}
-	nexusGroups, err := n.getLatestGroups()
+	return group.ChildIds
+}
+
+func (n *nexusApp) WrappingUpAfterGroupSync(ctx context.Context, idpGroups []*schema.Group) error {
+	// Update father-son relationship
+	nexusGroups, err := n.GetGroups(ctx)
 	if err != nil {
 		return err
 	}
@@ -633,7 +691,7 @@ func (n *nexusApp) WrappingUpAfterGroupSync(ctx context.Context) error {
 	for _, idpGroup := range idpGroups {
 		idpGroupIdentity := n.GenerateIdpGroupIdentity(idpGroup.Kind, idpGroup.Identity)
 		childIds := make([]string, 0)
-		for _, childId := range idpGroup.ChildIds {
+		for _, childId := range n.nestedChildIds(idpGroup) {
 			childIds = append(childIds, n.GenerateIdpGroupIdentity(idpGroup.Kind, childId))
 		}
 		idpGroupIdChildIds[idpGroupIdentity] = append(idpGroupIdChildIds[idpGroupIdentity], childIds...)
@@ -652,12 +710,7 @@ func (n *nexusApp) WrappingUpAfterGroupSync(ctx context.Context) error {
 		sort.Strings(nexusGroupMapping[identity].ChildIds)
 		isChanged := false
 		// ignore project role
-		if !cmp.Equal(idpChildIds, nexusGroupMapping[identity].ChildIds, cmpopts.IgnoreSliceElements(func(item string) bool {
-			if strings.Contains(item, "project") {
-				return true
-			}
-			return false
-		})) {
+		if !cmp.Equal(idpChildIds, nexusGroupMapping[identity].ChildIds, cmpopts.IgnoreSliceElements(n.isProjectRole)) {
 			isChanged = true
 		}
 		for _, childId := range idpChildIds {
@@ -683,11 +736,11 @@ func (n *nexusApp) WrappingUpAfterGroupSync(ctx context.Context) error {
 }
 
 func (n *nexusApp) getGroupIdProjectIdsRelationMapping(ctx context.Context) (map[string][]string, error) {
-	err := n.newClient()
+	err := n.prepare(ctx)
 	if err != nil {
 		return nil, err
 	}
-	list, err := n.client.Security.Role.List()
+	list, err := n.listRoles(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -695,7 +748,7 @@ func (n *nexusApp) getGroupIdProjectIdsRelationMapping(ctx context.Context) (map
 	result := make(map[string][]string)
 	for _, group := range groups {
 		for _, child := range group.ChildIds {
-			if strings.Contains(child, schema.NamespaceProject) {
+			if n.isProjectRole(child) {
 				result[group.Identity] = append(result[group.Identity], child)
 			}
 		}
@@ -704,17 +757,13 @@ func (n *nexusApp) getGroupIdProjectIdsRelationMapping(ctx context.Context) (map
 }
 
 func (n *nexusApp) groupBindingProjectsHandle(ctx context.Context, idpProjects []*schema.Project) ([]*schema.Group, error) {
-	nexusGroups, err := n.getLatestGroups()
+	nexusGroups, err := n.GetGroups(ctx)
 	if err != nil {
 		return nil, err
 	}
 	result := make([]*schema.Group, 0)
 	groupIdProjectIdMap := make(map[string][]string)
 	for _, idpProject := range idpProjects {
-		if devProject := os.Getenv("DEV_PROJECTNAME_PREFIX"); len(devProject) > 0 && !strings.Contains(idpProject.Name, devProject) {
-			log.Loger.Debugf("debug project_name :%v", devProject)
-			continue
-		}
 		groupId := n.GenerateIdpGroupIdentity(idpProject.Namespace.Kind, idpProject.Namespace.Identity)
 		projectId := n.GenerateIdpProjectIdentity(idpProject.Identity)
 		groupIdProjectIdMap[groupId] = append(groupIdProjectIdMap[groupId], projectId)
@@ -760,6 +809,12 @@ func (n *nexusApp) groupBindingProjectsHandle(ctx context.Context, idpProjects [
 	return result, nil
 }
 
+// normalizeUser returns the user as it is read from nexus after it is written,
+// so that the fields nexus does not keep are not compared and the updates converge.
+func (n *nexusApp) normalizeUser(identity string, user *schema.User) *schema.User {
+	return n.nexus2IdpConverter.ToIdpUser(n.idp2NexusConverter.IdpUser2NexusUser(identity, user, user.RoleIds))
+}
+
 func (n *nexusApp) copyUser(idpUser *schema.User, roleIds []string) *schema.User {
 	u := &schema.User{
 		BaseEntity: schema.BaseEntity{
@@ -773,6 +828,8 @@ func (n *nexusApp) copyUser(idpUser *schema.User, roleIds []string) *schema.User
 		Mobile:      idpUser.Mobile,
 		NamespaceId: idpUser.NamespaceId,
 		RoleIds:     roleIds,
+		State:       idpUser.State,
+		External:    idpUser.External,
 	}
 	return u
 }
@@ -788,23 +845,18 @@ func (n *nexusApp) copyGroup(idpGroup schema.Group, targetAppGroup schema.Group)
 		ChildIds: make([]string, 0),
 	}
 	idpGroupChildIds := make([]string, 0)
-	for _, childId := range idpGroup.ChildIds {
+	for _, childId := range n.nestedChildIds(&idpGroup) {
 		idpGroupChildIds = append(idpGroupChildIds, n.GenerateIdpGroupIdentity(schema.NamespaceGroup, childId))
 	}
 	projectRoleIds := make([]string, 0)
 	for _, childId := range targetAppGroup.ChildIds {
-		if strings.Contains(childId, schema.NamespaceProject) {
+		if n.isProjectRole(childId) {
 			projectRoleIds = append(projectRoleIds, childId)
 		}
 	}
 	isChanged := false
 	// ignore project role
-	if !cmp.Equal(idpGroupChildIds, targetAppGroup.ChildIds, cmpopts.IgnoreSliceElements(func(item string) bool {
-		if strings.Contains(item, "project") {
-			return true
-		}
-		return false
-	})) {
+	if !cmp.Equal(idpGroupChildIds, targetAppGroup.ChildIds, cmpopts.IgnoreSliceElements(n.isProjectRole)) {
 		isChanged = true
 	}
 	for _, childId := range idpGroupChildIds {
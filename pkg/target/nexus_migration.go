@@ -0,0 +1,175 @@
+// Copyright 2023 Nautes Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package target
+
+import (
+	"context"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/nautes-labs/base-operator/pkg/log"
+	"github.com/nautes-labs/base-operator/pkg/nexus/pkg/client"
+	"github.com/nautes-labs/base-operator/pkg/nexus/schema/security"
+	"github.com/nautes-labs/base-operator/pkg/schema"
+)
+
+// migrateLegacyIdentities renames the roles and users created with the dash separated identities to the encoded identities.
+// Nexus can't change the id of a role or user, so they are recreated with the new ids,
+// the references to them are updated, and then the legacy ones are deleted.
+// Every step can be repeated, so a migration interrupted by an error is finished by the next sync.
+func (n *nexusApp) migrateLegacyIdentities(ctx context.Context) error {
+	kind, name := n.idp.Kind().Tostring(), n.idp.GetName()
+	roles, err := n.client.Security.Role.List(ctx)
+	if err != nil {
+		return err
+	}
+	users, err := n.client.Security.User.List(ctx)
+	if err != nil {
+		return err
+	}
+
+	renamedRoles := make(map[string]string)
+	for _, role := range roles {
+		if knri := schema.LegacyStringToKNRI(kind, name, role.ID); !knri.IsEmpty() {
+			renamedRoles[role.ID] = knri.String()
+		}
+	}
+	renamedUsers := make(map[string]string)
+	for _, user := range users {
+		if kni := schema.LegacyStringToKNI(kind, name, user.UserID); !kni.IsEmpty() {
+			renamedUsers[user.UserID] = kni.String()
+		}
+	}
+	if len(renamedRoles) == 0 && len(renamedUsers) == 0 {
+		return nil
+	}
+	logger := log.FromContext(ctx)
+	logger.Info("migrate legacy identities", "roles", len(renamedRoles), "users", len(renamedUsers))
+
+	// 1. create the roles with new ids, their child roles are set in the next step as they may not exist yet
+	for _, role := range roles {
+		newId, ok := renamedRoles[role.ID]
+		if !ok {
+			continue
+		}
+		newRole := *role
+		newRole.ID = newId
+		newRole.Description = schema.OwnershipOfIdentity(newId).Mark(role.Description)
+		newRole.Roles = []string{}
+		if err := n.createRole(ctx, &newRole); err != nil {
+			return err
+		}
+	}
+
+	// 2. point the child roles of all roles to the new ids
+	for _, role := range roles {
+		newRole := *role
+		newRole.Roles = renameIdentities(role.Roles, renamedRoles)
+		newId, renamed := renamedRoles[role.ID]
+		if renamed {
+			newRole.ID = newId
+			newRole.Description = schema.OwnershipOfIdentity(newId).Mark(role.Description)
+		} else if cmp.Equal(newRole.Roles, role.Roles) {
+			continue
+		}
+		if err := n.client.Security.Role.Update(ctx, newRole.ID, newRole); err != nil {
+			return err
+		}
+	}
+
+	// 3. recreate the users with new ids and point the roles of all users to the new ids
+	for _, user := range users {
+		newUser := *user
+		newUser.Roles = renameIdentities(user.Roles, renamedRoles)
+		newId, renamed := renamedUsers[user.UserID]
+		if !renamed {
+			if cmp.Equal(newUser.Roles, user.Roles) {
+				continue
+			}
+			if err := n.client.Security.User.Update(ctx, user.UserID, newUser); err != nil {
+				return err
+			}
+			continue
+		}
+		newUser.UserID = newId
+		err := n.client.Security.User.Create(ctx, newUser)
+		if client.IsConflict(err) {
+			err = n.client.Security.User.Update(ctx, newId, newUser)
+		}
+		if err != nil {
+			return err
+		}
+		if err := n.client.Security.User.Delete(ctx, user.UserID); err != nil && !client.IsNotFound(err) {
+			return err
+		}
+		logger.V(1).Info("user renamed", log.KeyIdentity, user.UserID, "newIdentity", newId)
+	}
+
+	// 4. delete the legacy roles, the parents are deleted before their children as the legacy roles still reference each other
+	for _, role := range legacyRolesInDeletionOrder(roles, renamedRoles) {
+		if err := n.client.Security.Role.Delete(ctx, role.ID); err != nil && !client.IsNotFound(err) {
+			return err
+		}
+		logger.V(1).Info("role renamed", log.KeyIdentity, role.ID, "newIdentity", renamedRoles[role.ID])
+	}
+	return nil
+}
+
+// legacyRolesInDeletionOrder sorts the legacy roles so that a role is after the legacy roles referencing it.
+func legacyRolesInDeletionOrder(roles []*security.Role, renamedRoles map[string]string) []*security.Role {
+	remaining := make([]*security.Role, 0, len(renamedRoles))
+	for _, role := range roles {
+		if _, ok := renamedRoles[role.ID]; ok {
+			remaining = append(remaining, role)
+		}
+	}
+	result := make([]*security.Role, 0, len(remaining))
+	for len(remaining) > 0 {
+		referenced := make(map[string]struct{})
+		for _, role := range remaining {
+			for _, child := range role.Roles {
+				referenced[child] = struct{}{}
+			}
+		}
+		next := make([]*security.Role, 0, len(remaining))
+		for _, role := range remaining {
+			if _, ok := referenced[role.ID]; ok {
+				next = append(next, role)
+				continue
+			}
+			result = append(result, role)
+		}
+		if len(next) == len(remaining) {
+			// the roles reference each other in a cycle, nexus decides whether they can be deleted
+			return append(result, next...)
+		}
+		remaining = next
+	}
+	return result
+}
+
+// renameIdentities replaces the identities in ids by the renamed ones.
+func renameIdentities(ids []string, renamed map[string]string) []string {
+	if ids == nil {
+		return nil
+	}
+	result := make([]string, 0, len(ids))
+	for _, id := range ids {
+		if newId, ok := renamed[id]; ok {
+			id = newId
+		}
+		result = append(result, id)
+	}
+	return result
+}
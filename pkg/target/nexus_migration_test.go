@@ -0,0 +1,224 @@
+// Copyright 2023 Nautes Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package target
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/golang/mock/gomock"
+	"github.com/nautes-labs/base-operator/pkg/idp"
+	"github.com/nautes-labs/base-operator/pkg/nexus/schema/security"
+	"github.com/nautes-labs/base-operator/pkg/secret_provider"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+const testSecret = `[{"identity":{"type":"nexus","name":"nexus1"},"authentication_type":"basic-auth","authentication_data":{"username":"admin","passwd":"admin123"}}]`
+
+// fakeNexusSecurity is an in-memory stand-in of the nexus users and roles api,
+// like nexus it rejects roles whose child roles do not exist.
+type fakeNexusSecurity struct {
+	lock  sync.Mutex
+	roles map[string]security.Role
+	users map[string]security.User
+}
+
+func (f *fakeNexusSecurity) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	path := strings.TrimPrefix(r.URL.Path, "/service/rest/v1/security/")
+	resource, id, _ := strings.Cut(path, "/")
+	switch {
+	case r.Method == http.MethodGet && resource == "roles":
+		roles := make([]security.Role, 0, len(f.roles))
+		for _, role := range f.roles {
+			roles = append(roles, role)
+		}
+		_ = json.NewEncoder(w).Encode(roles)
+	case r.Method == http.MethodGet && resource == "users":
+		users := make([]security.User, 0, len(f.users))
+		for _, user := range f.users {
+			users = append(users, user)
+		}
+		_ = json.NewEncoder(w).Encode(users)
+	case (r.Method == http.MethodPost || r.Method == http.MethodPut) && resource == "roles":
+		role := security.Role{}
+		_ = json.NewDecoder(r.Body).Decode(&role)
+		_, exist := f.roles[role.ID]
+		if r.Method == http.MethodPost && exist {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = fmt.Fprintf(w, `[{"id":"*","message":"Role '%s' already exists"}]`, role.ID)
+			return
+		}
+		if r.Method == http.MethodPut && (!exist || id != role.ID) {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		for _, child := range role.Roles {
+			if _, ok := f.roles[child]; !ok {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+		}
+		f.roles[role.ID] = role
+		w.WriteHeader(http.StatusOK)
+	case (r.Method == http.MethodPost || r.Method == http.MethodPut) && resource == "users":
+		user := security.User{}
+		_ = json.NewDecoder(r.Body).Decode(&user)
+		_, exist := f.users[user.UserID]
+		if r.Method == http.MethodPost && exist {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = fmt.Fprintf(w, `[{"id":"*","message":"User '%s' already exists"}]`, user.UserID)
+			return
+		}
+		if r.Method == http.MethodPut && (!exist || id != user.UserID) {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		f.users[user.UserID] = user
+		w.WriteHeader(http.StatusOK)
+	case r.Method == http.MethodDelete && resource == "roles":
+		for _, role := range f.roles {
+			for _, child := range role.Roles {
+				if child == id {
+					w.WriteHeader(http.StatusBadRequest)
+					return
+				}
+			}
+		}
+		delete(f.roles, id)
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == http.MethodDelete && resource == "users":
+		delete(f.users, id)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+var _ = Describe("Nexus identity migration", func() {
+	var (
+		fake      *fakeNexusSecurity
+		nexus     TargetApp
+		mockCtl   *gomock.Controller
+		idpEntity *idp.MockIdp
+	)
+
+	BeforeEach(func() {
+		fake = &fakeNexusSecurity{
+			roles: map[string]security.Role{
+				"nx-admin":                       {ID: "nx-admin", Name: "nx-admin"},
+				"gitlab-gitlab-prod-group-1":     {ID: "gitlab-gitlab-prod-group-1", Name: "g1", Privileges: []string{"p1"}, Roles: []string{"gitlab-gitlab-prod-group-2", "gitlab-gitlab-prod-project-3"}},
+				"gitlab-gitlab-prod-group-2":     {ID: "gitlab-gitlab-prod-group-2", Name: "g2"},
+				"gitlab-gitlab-prod-project-3":   {ID: "gitlab-gitlab-prod-project-3", Name: "p3"},
+				"gitlab-gitlab-prod-user-4":      {ID: "gitlab-gitlab-prod-user-4", Name: "u4"},
+				"gitlab-gitlab-other-group-1":    {ID: "gitlab-gitlab-other-group-1", Name: "other"},
+				"custom":                         {ID: "custom", Name: "custom", Roles: []string{"gitlab-gitlab-prod-group-2"}},
+				"gitlab.gitlab-prod.group.5":     {ID: "gitlab.gitlab-prod.group.5", Name: "g5"},
+				"gitlab.gitlab-prod.project.100": {ID: "gitlab.gitlab-prod.project.100", Name: "p100"},
+			},
+			users: map[string]security.User{
+				"admin":                   {UserID: "admin", Roles: []string{"nx-admin", "gitlab-gitlab-prod-group-1"}},
+				"gitlab-gitlab-prod-42":   {UserID: "gitlab-gitlab-prod-42", FirstName: "john", Roles: []string{"gitlab-gitlab-prod-user-4", "gitlab-gitlab-prod-group-2"}},
+				"gitlab.gitlab-prod.43":   {UserID: "gitlab.gitlab-prod.43", Roles: []string{"gitlab.gitlab-prod.group.5"}},
+				"gitlab-gitlab-other-100": {UserID: "gitlab-gitlab-other-100"},
+			},
+		}
+		server := httptest.NewServer(fake)
+		DeferCleanup(server.Close)
+
+		secretPath := filepath.Join(GinkgoT().TempDir(), "secret.json")
+		Expect(os.WriteFile(secretPath, []byte(testSecret), 0600)).Should(Succeed())
+		provider, err := secret_provider.NewSecretProvider(secretPath)
+		Expect(err).Should(BeNil())
+
+		mockCtl = gomock.NewController(GinkgoT())
+		idpEntity = idp.NewMockIdp(mockCtl)
+		idpEntity.EXPECT().Kind().Return(idp.GitlabIdpKind).AnyTimes()
+		idpEntity.EXPECT().GetName().Return("gitlab-prod").AnyTimes()
+
+		nexus, err = NewTargetApplication(string(NexusAppKind))
+		Expect(err).Should(BeNil())
+		nexus.SetIdp(idpEntity)
+		nexus.SetName("nexus1")
+		nexus.SetApiServerUrl(server.URL)
+		nexus.SetSecretProvider(provider)
+	})
+
+	AfterEach(func() {
+		mockCtl.Finish()
+	})
+
+	It("renames the legacy roles and users of the idp", func() {
+		users, err := nexus.GetUsers(context.Background())
+		Expect(err).Should(BeNil())
+		Expect(users).Should(HaveLen(4))
+
+		roleIds := make([]string, 0)
+		for id := range fake.roles {
+			roleIds = append(roleIds, id)
+		}
+		Expect(roleIds).Should(ConsistOf(
+			"nx-admin",
+			"custom",
+			"gitlab-gitlab-other-group-1",
+			"gitlab.gitlab-prod.group.1",
+			"gitlab.gitlab-prod.group.2",
+			"gitlab.gitlab-prod.project.3",
+			"gitlab.gitlab-prod.user.4",
+			"gitlab.gitlab-prod.group.5",
+			"gitlab.gitlab-prod.project.100",
+		))
+		Expect(fake.roles["gitlab.gitlab-prod.group.1"]).Should(Equal(security.Role{
+			ID:          "gitlab.gitlab-prod.group.1",
+			Name:        "g1",
+			Description: "[managed-by=base-operator idp=gitlab/gitlab-prod kind=group]",
+			Privileges:  []string{"p1"},
+			Roles:       []string{"gitlab.gitlab-prod.group.2", "gitlab.gitlab-prod.project.3"},
+		}))
+		Expect(fake.roles["custom"].Roles).Should(Equal([]string{"gitlab.gitlab-prod.group.2"}))
+
+		Expect(fake.users).Should(HaveLen(4))
+		Expect(fake.users["admin"].Roles).Should(Equal([]string{"nx-admin", "gitlab.gitlab-prod.group.1"}))
+		Expect(fake.users["gitlab.gitlab-prod.42"].FirstName).Should(Equal("john"))
+		Expect(fake.users["gitlab.gitlab-prod.42"].Roles).Should(Equal([]string{"gitlab.gitlab-prod.user.4", "gitlab.gitlab-prod.group.2"}))
+		Expect(fake.users).Should(HaveKey("gitlab-gitlab-other-100"))
+
+		groups, err := nexus.GetGroups(context.Background())
+		Expect(err).Should(BeNil())
+		Expect(groups).Should(HaveLen(4))
+	})
+
+	It("migrates only once", func() {
+		_, err := nexus.GetUsers(context.Background())
+		Expect(err).Should(BeNil())
+
+		fake.lock.Lock()
+		fake.roles["gitlab-gitlab-prod-group-9"] = security.Role{ID: "gitlab-gitlab-prod-group-9"}
+		fake.lock.Unlock()
+		_, err = nexus.GetProjects(context.Background())
+		Expect(err).Should(BeNil())
+		Expect(fake.roles).Should(HaveKey("gitlab-gitlab-prod-group-9"))
+	})
+})
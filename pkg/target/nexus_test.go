@@ -0,0 +1,229 @@
+// Copyright 2023 Nautes Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package target
+
+import (
+	"context"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+
+	"github.com/golang/mock/gomock"
+	"github.com/nautes-labs/base-operator/pkg/convert/convert2idp"
+	"github.com/nautes-labs/base-operator/pkg/convert/convert2target"
+	"github.com/nautes-labs/base-operator/pkg/idp"
+	"github.com/nautes-labs/base-operator/pkg/nexus/schema/security"
+	"github.com/nautes-labs/base-operator/pkg/schema"
+	"github.com/nautes-labs/base-operator/pkg/secret_provider"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Nexus target app", func() {
+	var (
+		nexus   TargetApp
+		mockCtl *gomock.Controller
+	)
+
+	BeforeEach(func() {
+		mockCtl = gomock.NewController(GinkgoT())
+		idpEntity := idp.NewMockIdp(mockCtl)
+		idpEntity.EXPECT().Kind().Return(idp.GitlabIdpKind).AnyTimes()
+		idpEntity.EXPECT().GetName().Return("project-gitlab").AnyTimes()
+
+		var err error
+		nexus, err = NewTargetApplication(string(NexusAppKind))
+		Expect(err).Should(BeNil())
+		nexus.SetIdp(idpEntity)
+	})
+
+	AfterEach(func() {
+		mockCtl.Finish()
+	})
+
+	It("verifies the server certificate unless insecure is set", func() {
+		insecure, secure := true, false
+		Expect(ClientOptions{}.SkipVerify()).Should(BeFalse())
+		Expect(ClientOptions{CABundle: []byte("ca")}.SkipVerify()).Should(BeFalse())
+		Expect(ClientOptions{Insecure: &secure}.SkipVerify()).Should(BeFalse())
+		Expect(ClientOptions{Insecure: &insecure}.SkipVerify()).Should(BeTrue())
+	})
+
+	It("tells project roles by ownership rather than by the names", func() {
+		targetGroups := []*schema.Group{
+			{
+				BaseEntity: schema.BaseEntity{Identity: "gitlab.project-gitlab.group.1", Name: "project-x"},
+				Kind:       schema.NamespaceGroup,
+				ChildIds:   []string{"gitlab.project-gitlab.group.2", "gitlab.project-gitlab.project.3"},
+			},
+		}
+		idpGroup := &schema.Group{
+			BaseEntity: schema.BaseEntity{Identity: "1", Name: "project-x"},
+			Kind:       schema.NamespaceGroup,
+			ChildIds:   []string{"2"},
+		}
+
+		createGroups, updateGroups := nexus.CompareGroups(context.Background(), []*schema.Group{idpGroup}, targetGroups)
+		Expect(createGroups).Should(BeEmpty())
+		Expect(updateGroups).Should(BeEmpty())
+
+		idpGroup.ChildIds = nil
+		createGroups, updateGroups = nexus.CompareGroups(context.Background(), []*schema.Group{idpGroup}, targetGroups)
+		Expect(createGroups).Should(BeEmpty())
+		Expect(updateGroups).Should(HaveLen(1))
+		Expect(updateGroups[0].ChildIds).Should(Equal([]string{"gitlab.project-gitlab.project.3"}))
+	})
+
+	It("does not nest the roles of subgroups if the members are flattened", func() {
+		targetGroups := []*schema.Group{
+			{
+				BaseEntity: schema.BaseEntity{Identity: "gitlab.project-gitlab.group.1", Name: "project-x"},
+				Kind:       schema.NamespaceGroup,
+				ChildIds:   []string{"gitlab.project-gitlab.group.2", "gitlab.project-gitlab.project.3"},
+			},
+		}
+		idpGroup := &schema.Group{
+			BaseEntity: schema.BaseEntity{Identity: "1", Name: "project-x"},
+			Kind:       schema.NamespaceGroup,
+			ChildIds:   []string{"2"},
+		}
+		nexus.SetMembership(schema.MembershipFlattened)
+
+		createGroups, updateGroups := nexus.CompareGroups(context.Background(), []*schema.Group{idpGroup}, targetGroups)
+		Expect(createGroups).Should(BeEmpty())
+		Expect(updateGroups).Should(HaveLen(1))
+		Expect(updateGroups[0].ChildIds).Should(Equal([]string{"gitlab.project-gitlab.project.3"}))
+
+		createGroups, updateGroups = nexus.CompareGroups(context.Background(), []*schema.Group{idpGroup}, updateGroups)
+		Expect(createGroups).Should(BeEmpty())
+		Expect(updateGroups).Should(BeEmpty())
+	})
+
+	It("converges the users after one update", func() {
+		idpUser := &schema.User{
+			BaseEntity:  schema.BaseEntity{Identity: "1", Name: "Zhang San"},
+			Username:    "zhangsan",
+			Email:       "zhangsan@example.com",
+			AvatarURL:   "https://gitlab.example.com/avatar.png",
+			NamespaceId: "10",
+			State:       schema.UserStateActive,
+		}
+		identity := nexus.GenerateIdpUserIdentity("1")
+		read := func(user *schema.User) []*schema.User {
+			nexusUser := convert2target.NewIdp2NexusConverter().IdpUser2NexusUser(identity, user, []string{"gitlab.project-gitlab.user.10"})
+			Expect(nexusUser.FirstName).Should(Equal("Zhang"))
+			Expect(nexusUser.LastName).Should(Equal("San"))
+			return []*schema.User{convert2idp.NewNexus2IdpConverter().ToIdpUser(nexusUser)}
+		}
+		targetUsers := read(idpUser)
+		createUsers, updateUsers := nexus.CompareUsers(context.Background(), []*schema.User{idpUser}, targetUsers)
+		Expect(createUsers).Should(BeEmpty())
+		Expect(updateUsers).Should(BeEmpty())
+
+		renamed := *idpUser
+		renamed.Username = "zhangsan2"
+		createUsers, updateUsers = nexus.CompareUsers(context.Background(), []*schema.User{&renamed}, targetUsers)
+		Expect(createUsers).Should(BeEmpty())
+		Expect(updateUsers).Should(BeEmpty())
+
+		renamed.Email = "zhangsan2@example.com"
+		renamed.State = schema.UserStateBlocked
+		createUsers, updateUsers = nexus.CompareUsers(context.Background(), []*schema.User{&renamed}, targetUsers)
+		Expect(createUsers).Should(BeEmpty())
+		Expect(updateUsers).Should(HaveLen(1))
+		Expect(updateUsers[0].RoleIds).Should(Equal([]string{"gitlab.project-gitlab.user.10"}))
+
+		Expect(convert2target.NewIdp2NexusConverter().IdpUser2NexusUser(identity, updateUsers[0], nil).Status).Should(Equal("disabled"))
+		targetUsers = read(updateUsers[0])
+		Expect(targetUsers[0].IsDisabled()).Should(BeTrue())
+		_, updateUsers = nexus.CompareUsers(context.Background(), []*schema.User{&renamed}, targetUsers)
+		Expect(updateUsers).Should(BeEmpty())
+	})
+})
+
+var _ = Describe("Nexus group members", func() {
+	var (
+		fake    *fakeNexusSecurity
+		nexus   TargetApp
+		mockCtl *gomock.Controller
+	)
+
+	BeforeEach(func() {
+		fake = &fakeNexusSecurity{
+			roles: map[string]security.Role{
+				"nx-admin":                   {ID: "nx-admin"},
+				"gitlab.gitlab-prod.group.1": {ID: "gitlab.gitlab-prod.group.1"},
+				"gitlab.gitlab-prod.group.2": {ID: "gitlab.gitlab-prod.group.2"},
+				"gitlab.gitlab-prod.user.4":  {ID: "gitlab.gitlab-prod.user.4"},
+				"ldap.ldap1.group.7":         {ID: "ldap.ldap1.group.7"},
+				"ldap.ldap1.user.9":          {ID: "ldap.ldap1.user.9"},
+			},
+			users: map[string]security.User{
+				"gitlab.gitlab-prod.42": {UserID: "gitlab.gitlab-prod.42", FirstName: "john",
+					Roles: []string{"nx-admin", "ldap.ldap1.group.7", "gitlab.gitlab-prod.user.4", "gitlab.gitlab-prod.group.1"}},
+				"ldap.ldap1.9": {UserID: "ldap.ldap1.9", FirstName: "alice", Roles: []string{"ldap.ldap1.user.9"}},
+			},
+		}
+		server := httptest.NewServer(fake)
+		DeferCleanup(server.Close)
+
+		secretPath := filepath.Join(GinkgoT().TempDir(), "secret.json")
+		Expect(os.WriteFile(secretPath, []byte(testSecret), 0600)).Should(Succeed())
+		provider, err := secret_provider.NewSecretProvider(secretPath)
+		Expect(err).Should(BeNil())
+
+		mockCtl = gomock.NewController(GinkgoT())
+		idpEntity := idp.NewMockIdp(mockCtl)
+		idpEntity.EXPECT().Kind().Return(idp.GitlabIdpKind).AnyTimes()
+		idpEntity.EXPECT().GetName().Return("gitlab-prod").AnyTimes()
+
+		nexus, err = NewTargetApplication(string(NexusAppKind))
+		Expect(err).Should(BeNil())
+		nexus.SetIdp(idpEntity)
+		nexus.SetName("nexus1")
+		nexus.SetApiServerUrl(server.URL)
+		nexus.SetSecretProvider(provider)
+	})
+
+	AfterEach(func() {
+		mockCtl.Finish()
+	})
+
+	It("keeps the roles not owned by the idp and writes the roles of the users of other idps", func() {
+		targetGroupMembers, err := nexus.GetGroupMembers(context.Background())
+		Expect(err).Should(BeNil())
+		idpUsers := []*schema.User{
+			{BaseEntity: schema.BaseEntity{Identity: "42", Name: "John Doe"}, Email: "john@example.com"},
+		}
+		idpGroupMembers := []*schema.GroupMember{
+			{GroupId: "2", UserId: "42"},
+			{GroupId: "1", UserId: "77", TargetUserId: "ldap.ldap1.9"},
+		}
+		Expect(nexus.SyncGroupMember(context.Background(), idpUsers, idpGroupMembers, targetGroupMembers)).Should(Succeed())
+
+		Expect(fake.users["gitlab.gitlab-prod.42"].FirstName).Should(Equal("John"))
+		Expect(fake.users["gitlab.gitlab-prod.42"].Roles).Should(Equal([]string{
+			"nx-admin", "ldap.ldap1.group.7", "gitlab.gitlab-prod.user.4", "gitlab.gitlab-prod.group.2",
+		}))
+		Expect(fake.users["ldap.ldap1.9"].FirstName).Should(Equal("alice"))
+		Expect(fake.users["ldap.ldap1.9"].Roles).Should(Equal([]string{"ldap.ldap1.user.9", "gitlab.gitlab-prod.group.1"}))
+	})
+
+	It("treats a user already created as created", func() {
+		user := &schema.User{BaseEntity: schema.BaseEntity{Identity: "42", Name: "John Doe"}, Username: "john", NamespaceId: "4"}
+		Expect(nexus.CreateUser(context.Background(), user)).Should(Succeed())
+		Expect(fake.users["gitlab.gitlab-prod.42"].FirstName).Should(Equal("john"))
+	})
+})
@@ -31,6 +31,8 @@ type TargetApp interface {
 	SetName(name string)
 	GetName() string
 	SetApiServerUrl(url string)
+	SetClientOptions(opts ClientOptions)
+	SetMembership(mode schema.MembershipMode)
 	SetSecretProvider(provider *secret_provider.SecretProvider)
 	GetUsers(ctx context.Context) ([]*schema.User, error)
 	GetGroups(ctx context.Context) ([]*schema.Group, error)
@@ -40,7 +42,8 @@ type TargetApp interface {
 	CreateUser(ctx context.Context, user *schema.User) error
 	UpdateUser(ctx context.Context, id string, user *schema.User) error
 	CreateGroup(ctx context.Context, group *schema.Group) error
-	WrappingUpAfterGroupSync(ctx context.Context) error
+	// WrappingUpAfterGroupSync nests the roles of the subgroups of idpGroups, the groups synchronized to the target app.
+	WrappingUpAfterGroupSync(ctx context.Context, idpGroups []*schema.Group) error
 	UpdateGroup(ctx context.Context, id string, group *schema.Group) error
 	CreateProject(ctx context.Context, project *schema.Project) error
 	UpdateProject(ctx context.Context, id string, project *schema.Project) error
@@ -56,13 +59,27 @@ type TargetApp interface {
 	GenerateIdpUserIdentity(Identity string) (idpUserIdentity string)
 	GenerateIdpGroupIdentity(groupKind string, Identity string) (idpGroupIdentity string)
 	GenerateIdpProjectIdentity(Identity string) (idpProjectIdentity string)
-	CompareUsers(idpUsers []*schema.User, targetAppUsers []*schema.User) (createUsers []*schema.User, updateUsers []*schema.User)
-	CompareGroups(idpGroups []*schema.Group, targetAppGroups []*schema.Group) (createGroups []*schema.Group, updateGroups []*schema.Group)
-	CompareProjects(idpProjects []*schema.Project, targetAppProjects []*schema.Project) (createProjects []*schema.Project, updateProjects []*schema.Project)
-	SyncGroupMember(ctx context.Context, idpGroupMembers []*schema.GroupMember, targetAppGroupMembers []*schema.GroupMember) error
+	CompareUsers(ctx context.Context, idpUsers []*schema.User, targetAppUsers []*schema.User) (createUsers []*schema.User, updateUsers []*schema.User)
+	CompareGroups(ctx context.Context, idpGroups []*schema.Group, targetAppGroups []*schema.Group) (createGroups []*schema.Group, updateGroups []*schema.Group)
+	CompareProjects(ctx context.Context, idpProjects []*schema.Project, targetAppProjects []*schema.Project) (createProjects []*schema.Project, updateProjects []*schema.Project)
+	// SyncGroupMember writes the roles of the users as the group members of idp, idpUsers are the users written to the target app.
+	SyncGroupMember(ctx context.Context, idpUsers []*schema.User, idpGroupMembers []*schema.GroupMember, targetAppGroupMembers []*schema.GroupMember) error
 	GroupBindingProjects(ctx context.Context, projects []*schema.Project) error
 }
 
+// ClientOptions configures the client connecting to the target application.
+type ClientOptions struct {
+	// Insecure skips the verification of the server certificate, the certificate is verified if it is nil.
+	Insecure *bool
+	// CABundle is the PEM encoded CA certificates of the server, the system roots are used if it is empty.
+	CABundle []byte
+}
+
+// SkipVerify reports whether the server certificate is not verified.
+func (o ClientOptions) SkipVerify() bool {
+	return o.Insecure != nil && *o.Insecure
+}
+
 type TargetAppKindName struct {
 	Kind string
 	Name string
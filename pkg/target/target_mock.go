@@ -38,48 +38,48 @@ func (m *MockTargetApp) EXPECT() *MockTargetAppMockRecorder {
 }
 
 // CompareGroups mocks base method.
-func (m *MockTargetApp) CompareGroups(idpGroups, targetAppGroups []*schema.Group) ([]*schema.Group, []*schema.Group) {
+func (m *MockTargetApp) CompareGroups(ctx context.Context, idpGroups, targetAppGroups []*schema.Group) ([]*schema.Group, []*schema.Group) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "CompareGroups", idpGroups, targetAppGroups)
+	ret := m.ctrl.Call(m, "CompareGroups", ctx, idpGroups, targetAppGroups)
 	ret0, _ := ret[0].([]*schema.Group)
 	ret1, _ := ret[1].([]*schema.Group)
 	return ret0, ret1
 }
 
 // CompareGroups indicates an expected call of CompareGroups.
-func (mr *MockTargetAppMockRecorder) CompareGroups(idpGroups, targetAppGroups interface{}) *gomock.Call {
+func (mr *MockTargetAppMockRecorder) CompareGroups(ctx, idpGroups, targetAppGroups interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CompareGroups", reflect.TypeOf((*MockTargetApp)(nil).CompareGroups), idpGroups, targetAppGroups)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CompareGroups", reflect.TypeOf((*MockTargetApp)(nil).CompareGroups), ctx, idpGroups, targetAppGroups)
 }
 
 // CompareProjects mocks base method.
-func (m *MockTargetApp) CompareProjects(idpProjects, targetAppProjects []*schema.Project) ([]*schema.Project, []*schema.Project) {
+func (m *MockTargetApp) CompareProjects(ctx context.Context, idpProjects, targetAppProjects []*schema.Project) ([]*schema.Project, []*schema.Project) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "CompareProjects", idpProjects, targetAppProjects)
+	ret := m.ctrl.Call(m, "CompareProjects", ctx, idpProjects, targetAppProjects)
 	ret0, _ := ret[0].([]*schema.Project)
 	ret1, _ := ret[1].([]*schema.Project)
 	return ret0, ret1
 }
 
 // CompareProjects indicates an expected call of CompareProjects.
-func (mr *MockTargetAppMockRecorder) CompareProjects(idpProjects, targetAppProjects interface{}) *gomock.Call {
+func (mr *MockTargetAppMockRecorder) CompareProjects(ctx, idpProjects, targetAppProjects interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CompareProjects", reflect.TypeOf((*MockTargetApp)(nil).CompareProjects), idpProjects, targetAppProjects)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CompareProjects", reflect.TypeOf((*MockTargetApp)(nil).CompareProjects), ctx, idpProjects, targetAppProjects)
 }
 
 // CompareUsers mocks base method.
-func (m *MockTargetApp) CompareUsers(idpUsers, targetAppUsers []*schema.User) ([]*schema.User, []*schema.User) {
+func (m *MockTargetApp) CompareUsers(ctx context.Context, idpUsers, targetAppUsers []*schema.User) ([]*schema.User, []*schema.User) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "CompareUsers", idpUsers, targetAppUsers)
+	ret := m.ctrl.Call(m, "CompareUsers", ctx, idpUsers, targetAppUsers)
 	ret0, _ := ret[0].([]*schema.User)
 	ret1, _ := ret[1].([]*schema.User)
 	return ret0, ret1
 }
 
 // CompareUsers indicates an expected call of CompareUsers.
-func (mr *MockTargetAppMockRecorder) CompareUsers(idpUsers, targetAppUsers interface{}) *gomock.Call {
+func (mr *MockTargetAppMockRecorder) CompareUsers(ctx, idpUsers, targetAppUsers interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CompareUsers", reflect.TypeOf((*MockTargetApp)(nil).CompareUsers), idpUsers, targetAppUsers)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CompareUsers", reflect.TypeOf((*MockTargetApp)(nil).CompareUsers), ctx, idpUsers, targetAppUsers)
 }
 
 // CreateGroup mocks base method.
@@ -407,6 +407,18 @@ func (mr *MockTargetAppMockRecorder) SetApiServerUrl(url interface{}) *gomock.Ca
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetApiServerUrl", reflect.TypeOf((*MockTargetApp)(nil).SetApiServerUrl), url)
 }
 
+// SetClientOptions mocks base method.
+func (m *MockTargetApp) SetClientOptions(opts ClientOptions) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "SetClientOptions", opts)
+}
+
+// SetClientOptions indicates an expected call of SetClientOptions.
+func (mr *MockTargetAppMockRecorder) SetClientOptions(opts interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetClientOptions", reflect.TypeOf((*MockTargetApp)(nil).SetClientOptions), opts)
+}
+
 // SetIdp mocks base method.
 func (m *MockTargetApp) SetIdp(arg0 idp.Idp) {
 	m.ctrl.T.Helper()
@@ -419,6 +431,18 @@ func (mr *MockTargetAppMockRecorder) SetIdp(arg0 interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetIdp", reflect.TypeOf((*MockTargetApp)(nil).SetIdp), arg0)
 }
 
+// SetMembership mocks base method.
+func (m *MockTargetApp) SetMembership(mode schema.MembershipMode) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "SetMembership", mode)
+}
+
+// SetMembership indicates an expected call of SetMembership.
+func (mr *MockTargetAppMockRecorder) SetMembership(mode interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetMembership", reflect.TypeOf((*MockTargetApp)(nil).SetMembership), mode)
+}
+
 // SetName mocks base method.
 func (m *MockTargetApp) SetName(name string) {
 	m.ctrl.T.Helper()
@@ -444,17 +468,17 @@ func (mr *MockTargetAppMockRecorder) SetSecretProvider(provider interface{}) *go
 }
 
 // SyncGroupMember mocks base method.
-func (m *MockTargetApp) SyncGroupMember(ctx context.Context, idpGroupMembers, targetAppGroupMembers []*schema.GroupMember) error {
+func (m *MockTargetApp) SyncGroupMember(ctx context.Context, idpUsers []*schema.User, idpGroupMembers, targetAppGroupMembers []*schema.GroupMember) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "SyncGroupMember", ctx, idpGroupMembers, targetAppGroupMembers)
+	ret := m.ctrl.Call(m, "SyncGroupMember", ctx, idpUsers, idpGroupMembers, targetAppGroupMembers)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // SyncGroupMember indicates an expected call of SyncGroupMember.
-func (mr *MockTargetAppMockRecorder) SyncGroupMember(ctx, idpGroupMembers, targetAppGroupMembers interface{}) *gomock.Call {
+func (mr *MockTargetAppMockRecorder) SyncGroupMember(ctx, idpUsers, idpGroupMembers, targetAppGroupMembers interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SyncGroupMember", reflect.TypeOf((*MockTargetApp)(nil).SyncGroupMember), ctx, idpGroupMembers, targetAppGroupMembers)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SyncGroupMember", reflect.TypeOf((*MockTargetApp)(nil).SyncGroupMember), ctx, idpUsers, idpGroupMembers, targetAppGroupMembers)
 }
 
 // UpdateGroup mocks base method.
@@ -528,15 +552,15 @@ func (mr *MockTargetAppMockRecorder) UpdateUser(ctx, id, user interface{}) *gomo
 }
 
 // WrappingUpAfterGroupSync mocks base method.
-func (m *MockTargetApp) WrappingUpAfterGroupSync(ctx context.Context) error {
+func (m *MockTargetApp) WrappingUpAfterGroupSync(ctx context.Context, idpGroups []*schema.Group) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "WrappingUpAfterGroupSync", ctx)
+	ret := m.ctrl.Call(m, "WrappingUpAfterGroupSync", ctx, idpGroups)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // WrappingUpAfterGroupSync indicates an expected call of WrappingUpAfterGroupSync.
-func (mr *MockTargetAppMockRecorder) WrappingUpAfterGroupSync(ctx interface{}) *gomock.Call {
+func (mr *MockTargetAppMockRecorder) WrappingUpAfterGroupSync(ctx, idpGroups interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "WrappingUpAfterGroupSync", reflect.TypeOf((*MockTargetApp)(nil).WrappingUpAfterGroupSync), ctx)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "WrappingUpAfterGroupSync", reflect.TypeOf((*MockTargetApp)(nil).WrappingUpAfterGroupSync), ctx, idpGroups)
 }
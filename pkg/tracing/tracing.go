@@ -0,0 +1,144 @@
+// Copyright 2023 Nautes Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package tracing creates the spans of base operator and exports them by OTLP.
+// Spans are dropped by the default no-op tracer provider until Setup is called with an endpoint.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	sdkresource "go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.10.0"
+	"go.opentelemetry.io/otel/trace"
+	crlog "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+const (
+	instrumentationName  = "github.com/nautes-labs/base-operator"
+	serviceName          = "base-operator"
+	tracesPath           = "/v1/traces"
+	defaultExportTimeout = 10 * time.Second
+)
+
+// Attributes of the base operator spans.
+const (
+	AttrProduct         = attribute.Key("nautes.product")
+	AttrProductProvider = attribute.Key("nautes.product_provider")
+	AttrDeletionPhase   = attribute.Key("nautes.deletion_phase")
+	AttrIdp             = attribute.Key("nautes.idp")
+	AttrSyncPhase       = attribute.Key("nautes.sync.phase")
+	AttrSyncTarget      = attribute.Key("nautes.sync.target")
+)
+
+// Options is the tracing config of base operator.
+type Options struct {
+	// The OTLP/HTTP endpoint of collector, such as "http://otel-collector:4318".
+	// Tracing is disabled if it is empty.
+	Endpoint string
+	// The ratio of traces to be sampled, from 0 to 1.
+	SampleRatio float64
+	// The max time to wait for an export request.
+	Timeout time.Duration
+}
+
+// ShutdownFunc flushes the remaining spans and stops the exporter.
+type ShutdownFunc func(ctx context.Context) error
+
+// Setup installs the global tracer provider exporting spans to opts.Endpoint.
+// It does nothing if the endpoint is empty, so that the spans are not recorded.
+func Setup(opts Options) (ShutdownFunc, error) {
+	if opts.Endpoint == "" {
+		return func(context.Context) error { return nil }, nil
+	}
+	if opts.SampleRatio < 0 || opts.SampleRatio > 1 {
+		return nil, fmt.Errorf("sample ratio %v is out of range [0, 1]", opts.SampleRatio)
+	}
+
+	exporter, err := newExporter(opts.Endpoint, opts.Timeout)
+	if err != nil {
+		return nil, err
+	}
+
+	resource, err := sdkresource.Merge(sdkresource.Default(), sdkresource.NewSchemaless(semconv.ServiceNameKey.String(serviceName)))
+	if err != nil {
+		return nil, fmt.Errorf("create tracing resource failed: %w", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(opts.SampleRatio))),
+	)
+	otel.SetLogger(crlog.Log.WithName("tracing"))
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	return provider.Shutdown, nil
+}
+
+// newExporter creates an OTLP/HTTP exporter sending spans to the endpoint,
+// the path "/v1/traces" is appended to the path of the endpoint.
+func newExporter(endpoint string, timeout time.Duration) (*otlptrace.Exporter, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("parse tracing endpoint failed: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("tracing endpoint %s must be a http or https url", endpoint)
+	}
+	if timeout <= 0 {
+		timeout = defaultExportTimeout
+	}
+
+	opts := []otlptracehttp.Option{
+		otlptracehttp.WithEndpoint(u.Host),
+		otlptracehttp.WithURLPath(strings.TrimSuffix(u.Path, "/") + tracesPath),
+		otlptracehttp.WithTimeout(timeout),
+	}
+	if u.Scheme == "http" {
+		opts = append(opts, otlptracehttp.WithInsecure())
+	}
+
+	exporter, err := otlptracehttp.New(context.Background(), opts...)
+	if err != nil {
+		return nil, fmt.Errorf("create tracing exporter failed: %w", err)
+	}
+	return exporter, nil
+}
+
+// Start creates a span as the child of the span in ctx.
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(instrumentationName).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// End records err in span if it is not nil, then ends the span.
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -0,0 +1,156 @@
+// Copyright 2023 Nautes Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tracing
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.10.0"
+	collectortrace "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	tracev1 "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+var _ = Describe("Tracing", func() {
+	var recorder *tracetest.SpanRecorder
+
+	BeforeEach(func() {
+		recorder = tracetest.NewSpanRecorder()
+		provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+		otel.SetTracerProvider(provider)
+		otel.SetTextMapPropagator(propagation.TraceContext{})
+		DeferCleanup(func() {
+			_ = provider.Shutdown(context.Background())
+		})
+	})
+
+	It("is disabled without endpoint", func() {
+		shutdown, err := Setup(Options{})
+		Expect(err).Should(BeNil())
+		Expect(shutdown(context.Background())).Should(Succeed())
+	})
+
+	It("rejects invalid options", func() {
+		_, err := Setup(Options{Endpoint: "http://localhost:4318", SampleRatio: 2})
+		Expect(err).ShouldNot(BeNil())
+		_, err = Setup(Options{Endpoint: "localhost:4318", SampleRatio: 1})
+		Expect(err).ShouldNot(BeNil())
+	})
+
+	It("records error in span", func() {
+		_, span := Start(context.Background(), "test")
+		End(span, errors.New("boom"))
+
+		spans := recorder.Ended()
+		Expect(spans).Should(HaveLen(1))
+		Expect(spans[0].Status().Code).Should(Equal(codes.Error))
+		Expect(spans[0].Status().Description).Should(Equal("boom"))
+	})
+
+	It("creates client span and propagates trace context", func() {
+		var traceparent string
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			traceparent = r.Header.Get("traceparent")
+			w.WriteHeader(http.StatusBadGateway)
+		}))
+		defer server.Close()
+
+		ctx, parent := Start(context.Background(), "parent")
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+"/api/v4/groups/42", nil)
+		Expect(err).Should(BeNil())
+		client := &http.Client{Transport: NewTransport("gitlab", 5, nil)}
+		resp, err := client.Do(req)
+		Expect(err).Should(BeNil())
+		resp.Body.Close()
+		parent.End()
+
+		spans := recorder.Ended()
+		Expect(spans).Should(HaveLen(2))
+		span := spans[0]
+		Expect(span.Name()).Should(Equal("gitlab GET /api/v4/groups/:id"))
+		Expect(span.Parent().SpanID()).Should(Equal(parent.SpanContext().SpanID()))
+		Expect(span.Attributes()).Should(ContainElement(semconv.HTTPStatusCodeKey.Int(http.StatusBadGateway)))
+		Expect(span.Status().Code).Should(Equal(codes.Error))
+		Expect(traceparent).Should(ContainSubstring(span.SpanContext().TraceID().String()))
+		Expect(traceparent).Should(ContainSubstring(span.SpanContext().SpanID().String()))
+	})
+
+	It("exports spans by otlp", func() {
+		request := &collectortrace.ExportTraceServiceRequest{}
+		var path, contentType string
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			path = r.URL.Path
+			contentType = r.Header.Get("Content-Type")
+			data, _ := io.ReadAll(r.Body)
+			_ = proto.Unmarshal(data, request)
+		}))
+		defer server.Close()
+
+		ctx, parent := Start(context.Background(), "parent")
+		_, child := Start(ctx, "child", AttrProduct.String("product-1"))
+		End(child, errors.New("boom"))
+		parent.End()
+
+		exporter, err := newExporter(server.URL+"/", 0)
+		Expect(err).Should(BeNil())
+		defer exporter.Shutdown(context.Background())
+		Expect(exporter.ExportSpans(context.Background(), recorder.Ended())).Should(Succeed())
+		Expect(path).Should(Equal("/v1/traces"))
+		Expect(contentType).Should(Equal("application/x-protobuf"))
+
+		Expect(request.ResourceSpans).Should(HaveLen(1))
+		scopeSpans := request.ResourceSpans[0].ScopeSpans
+		Expect(scopeSpans).Should(HaveLen(1))
+		Expect(scopeSpans[0].Scope.Name).Should(Equal(instrumentationName))
+		spans := scopeSpans[0].Spans
+		Expect(spans).Should(HaveLen(2))
+
+		childTraceID, childParentID := parent.SpanContext().TraceID(), parent.SpanContext().SpanID()
+		Expect(spans[0].Name).Should(Equal("child"))
+		Expect(spans[0].TraceId).Should(Equal(childTraceID[:]))
+		Expect(spans[0].ParentSpanId).Should(Equal(childParentID[:]))
+		Expect(spans[0].Status.Code).Should(Equal(tracev1.Status_STATUS_CODE_ERROR))
+		Expect(spans[0].Attributes).Should(HaveLen(1))
+		Expect(spans[0].Attributes[0].Key).Should(Equal(string(AttrProduct)))
+		Expect(spans[0].Attributes[0].Value.GetStringValue()).Should(Equal("product-1"))
+		Expect(spans[1].ParentSpanId).Should(BeEmpty())
+	})
+
+	It("returns error if collector refuses the spans", func() {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusBadRequest)
+		}))
+		defer server.Close()
+
+		_, span := Start(context.Background(), "test")
+		span.End()
+
+		exporter, err := newExporter(server.URL, 0)
+		Expect(err).Should(BeNil())
+		defer exporter.Shutdown(context.Background())
+		Expect(exporter.ExportSpans(context.Background(), recorder.Ended())).ShouldNot(Succeed())
+	})
+})
@@ -0,0 +1,78 @@
+// Copyright 2023 Nautes Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tracing
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/nautes-labs/base-operator/pkg/metrics"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.10.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// transport creates a client span for every request sent by the wrapped round tripper.
+type transport struct {
+	client       string
+	maxSegments  int
+	roundTripper http.RoundTripper
+}
+
+// NewTransport wraps rt with a client span per request, rt is http.DefaultTransport if it is nil.
+// The span is named by the client, the method and the endpoint, see metrics.Endpoint for the endpoint.
+// The trace context is propagated to the server in the request headers.
+func NewTransport(client string, maxSegments int, rt http.RoundTripper) http.RoundTripper {
+	if rt == nil {
+		rt = http.DefaultTransport
+	}
+	return &transport{
+		client:       client,
+		maxSegments:  maxSegments,
+		roundTripper: rt,
+	}
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	endpoint := metrics.Endpoint(req.URL.EscapedPath(), t.maxSegments)
+	ctx, span := otel.Tracer(instrumentationName).Start(req.Context(),
+		fmt.Sprintf("%s %s %s", t.client, req.Method, endpoint),
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.HTTPMethodKey.String(req.Method),
+			semconv.HTTPTargetKey.String(endpoint),
+			semconv.NetPeerNameKey.String(req.URL.Hostname()),
+		),
+	)
+	defer span.End()
+
+	req = req.Clone(ctx)
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+
+	resp, err := t.roundTripper.RoundTrip(req)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return resp, err
+	}
+
+	span.SetAttributes(semconv.HTTPStatusCodeKey.Int(resp.StatusCode))
+	if resp.StatusCode >= http.StatusInternalServerError {
+		span.SetStatus(codes.Error, resp.Status)
+	}
+	return resp, nil
+}
@@ -16,6 +16,7 @@ package util
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -80,10 +81,18 @@ func GetObjectGvr(restMapper meta.RESTMapper, obj interface{}) schema.GroupVersi
 	}
 }
 
-func PanicTrace() {
-	if err := recover(); err != nil {
-		log.Loger.WithField("err_msg", err).WithField("trace_stack", string(debug.Stack())).Error("application occurrence of panic")
-	}
+// PanicError converts the value recovered from a panic into an error, the stack of the panic is logged.
+// It has to be called in the deferred function which recovers, e.g.
+//
+//	defer func() {
+//		if r := recover(); r != nil {
+//			err = util.PanicError(ctx, r)
+//		}
+//	}()
+func PanicError(ctx context.Context, recovered interface{}) error {
+	err := fmt.Errorf("panic: %v", recovered)
+	log.FromContext(ctx).Error(err, "application occurrence of panic", "traceStack", string(debug.Stack()))
+	return err
 }
 
 func Intersect(a []string, b []string) []string {
@@ -121,13 +130,3 @@ func DiffArray(a []string, b []string) []string {
 
 	return diffArray
 }
-
-func DeleteArrayItem(item string, list []string) {
-	index := 0
-	for i, v := range list {
-		if v == item {
-			index = i
-		}
-	}
-	list = append(list[:index], list[index+1:]...)
-}